@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"threshAI/internal/core/providers"
+	"threshAI/pkg/cache"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cacheCmd.AddCommand(cacheStatsCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+	cacheCmd.AddCommand(cachePruneCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the on-disk response cache",
+	Long: `Manage the response cache kept on disk by the CLI, in ~/.thresh/cache/responses
+unless the cache dir is set in the config file.`,
+	GroupID: "system",
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show cache size and hit rate",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fc, err := openFileCache()
+		if err != nil {
+			return err
+		}
+		stats, err := fc.Stats(context.Background())
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Response Cache:")
+		fmt.Printf("Directory:   %s\n", fc.Dir())
+		fmt.Printf("Entries:     %d\n", stats.Entries)
+		fmt.Printf("Size:        %s\n", formatBytes(stats.Bytes))
+		fmt.Printf("Hits:        %d\n", stats.Hits)
+		fmt.Printf("Misses:      %d\n", stats.Misses)
+		fmt.Printf("Hit rate:    %.1f%%\n", stats.HitRate()*100)
+		fmt.Printf("Evictions:   %d\n", stats.Evictions)
+		fmt.Printf("Expirations: %d\n", stats.Expirations)
+		return nil
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove every cached response",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fc, err := openFileCache()
+		if err != nil {
+			return err
+		}
+		if err := fc.Clear(context.Background()); err != nil {
+			return err
+		}
+		fmt.Println("✓ Cache cleared")
+		return nil
+	},
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove expired responses and enforce the size limit",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fc, err := openFileCache()
+		if err != nil {
+			return err
+		}
+		removed, err := fc.Prune(context.Background())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✓ Removed %d cached responses\n", removed)
+		return nil
+	},
+}
+
+func openFileCache() (*cache.FileCache, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return providers.NewFileCache(cfg)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
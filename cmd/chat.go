@@ -2,44 +2,119 @@ package cmd
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"threshAI/internal/core/memory"
+	"threshAI/internal/core/task"
+	"threshAI/internal/core/usage"
+	"threshAI/internal/prompt"
+	"threshAI/pkg/core/generation"
+	"threshAI/pkg/llm"
+	"threshAI/pkg/llm/budget"
+	"threshAI/pkg/llm/schema"
 
 	"github.com/spf13/cobra"
 )
 
+// chatHistoryTurns is the number of recent exchanges replayed to the model
+const chatHistoryTurns = 10
+
+const defaultSystemPrompt = "You are Eidos, the ThreshAI assistant. Answer clearly and concisely."
+
+// toolTaskTimeout bounds each command a model runs with the run_task tool
+const toolTaskTimeout = 2 * time.Minute
+
 var (
-	model       string
-	interactive bool
+	model        string
+	interactive  bool
+	chatProvider string
+	systemPrompt string
+	schemaFile   string
+
+	temperature float64
+	maxTokens   int
+	stopWords   []string
+	seed        int
+	topP        float64
+	topK        int
+
+	noCache      bool
+	refreshCache bool
+
+	noTools       bool
+	allowCommands []string
 )
 
+var registerToolsOnce sync.Once
+
 var chatCmd = &cobra.Command{
 	Use:   "chat",
 	Short: "Start an interactive chat session",
 	Long: `Start an interactive chat session with the AI.
 Supports conversation history and context management.`,
 	GroupID: "core",
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !interactive && len(args) == 0 {
+			return fmt.Errorf("please provide a message or use --interactive for chat mode")
+		}
+		if interactive && schemaFile != "" {
+			return fmt.Errorf("--json-schema requires a single message")
+		}
+		if noCache && refreshCache {
+			return fmt.Errorf("--no-cache and --refresh-cache can't be combined")
+		}
+
+		gen, tracker, err := newGenerator(chatProvider)
+		if err != nil {
+			return err
+		}
+		embedder, err := newEmbedder()
+		if err != nil {
+			return err
+		}
+		if !noTools {
+			registerChatTools()
+		}
+		opts := chatOptions(cmd)
+		b, err := newBudget(chatProvider, opts.Model, opts.MaxTokens)
+		if err != nil {
+			return err
+		}
+
+		mem := memory.LoadMemory()
+		mem.SetEmbedder(embedder)
+		defer mem.Save()
+
 		if interactive {
-			startInteractiveChat()
-		} else if len(args) > 0 {
-			handleSingleMessage(strings.Join(args, " "))
+			err = startInteractiveChat(gen, opts, b, mem)
+			printSessionUsage(tracker)
+			return err
+		}
+		if schemaFile != "" {
+			err = handleStructuredMessage(gen, opts, b, strings.Join(args, " "), mem)
 		} else {
-			fmt.Println("Error: Please provide a message or use --interactive for chat mode")
+			err = handleMessage(gen, opts, b, strings.Join(args, " "), mem)
 		}
+		if verbose {
+			printSessionUsage(tracker)
+		}
+		return err
 	},
 }
 
-func startInteractiveChat() {
+func startInteractiveChat(gen generation.Generator, opts llm.GenerateOptions, b budget.Budget, mem *memory.Memory) error {
 	fmt.Println("Starting interactive chat session (type 'exit' to quit)")
 	fmt.Println("----------------------------------------------------")
 
 	scanner := bufio.NewScanner(os.Stdin)
-	mem := memory.LoadMemory()
-	defer mem.Save()
 
 	for {
 		fmt.Print("\nUser > ")
@@ -57,54 +132,212 @@ func startInteractiveChat() {
 			continue
 		}
 
-		// Handle the message
-		handleMessage(input, mem)
+		// A failed turn shouldn't end the session
+		if err := handleMessage(gen, opts, b, input, mem); err != nil {
+			fmt.Printf("\nError: %v\n", err)
+		}
 	}
+	return nil
 }
 
-func handleSingleMessage(message string) {
-	mem := memory.LoadMemory()
-	defer mem.Save()
-	handleMessage(message, mem)
-}
-
-func handleMessage(input string, mem *memory.Memory) {
-	// Get relevant context from memory
-	context := mem.RetrieveRelevantContext(input)
+func handleMessage(gen generation.Generator, opts llm.GenerateOptions, b budget.Budget, input string, mem *memory.Memory) error {
+	messages, err := buildChatMessages(input, mem, b)
+	if err != nil {
+		return err
+	}
+	req := llm.ChatRequest{
+		Messages: messages,
+		Options:  opts,
+		Tools:    chatTools(),
+	}
 
-	// Generate response based on input and context
-	response := generateResponse(input, context)
+	// Tool calls are run between model turns, only the replies are shown
+	ctx, route := generation.WithRoute(chatContext())
+	stream, err := generation.StreamTools(ctx, gen, req, 0)
+	if err != nil {
+		return fmt.Errorf("generation failed: %w", err)
+	}
 
-	// Display the response
-	fmt.Printf("\nAI > %s\n", response)
+	// Display the response as it arrives
+	fmt.Print("\nAI > ")
+	response, err := generation.Collect(stream, func(token string) {
+		fmt.Print(token)
+	})
+	fmt.Println()
+	if err != nil {
+		return fmt.Errorf("generation failed: %w", err)
+	}
+	if verbose && route.Backend != "" {
+		fmt.Printf("(answered by %s)\n", route.Backend)
+	}
 
 	// Store the interaction
 	mem.AddInteraction(input, response)
+	return nil
 }
 
-func generateResponse(input string, context []memory.Interaction) string {
-	// Simple response generation - this can be enhanced with actual LLM integration
-	if len(context) > 0 {
-		return fmt.Sprintf("I remember our previous conversation about %s. Regarding your current question: %s",
-			context[0].UserInput, simpleResponse(input))
+// handleStructuredMessage answers a message with JSON matching the schema in
+// schemaFile, re-prompting the model until its reply validates
+func handleStructuredMessage(gen generation.Generator, opts llm.GenerateOptions, b budget.Budget, input string, mem *memory.Memory) error {
+	raw, err := os.ReadFile(schemaFile)
+	if err != nil {
+		return fmt.Errorf("failed to read schema: %w", err)
+	}
+	s, err := schema.Parse(raw)
+	if err != nil {
+		return err
+	}
+
+	messages, err := buildChatMessages(input, mem, b)
+	if err != nil {
+		return err
+	}
+	req := llm.ChatRequest{
+		Messages: messages,
+		Options:  opts,
+	}
+	name := strings.TrimSuffix(filepath.Base(schemaFile), filepath.Ext(schemaFile))
+	document, err := generation.GenerateJSON(chatContext(), gen, req, s, name, 0)
+	if err != nil {
+		return fmt.Errorf("generation failed: %w", err)
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, document, "", "  "); err != nil {
+		return err
+	}
+	fmt.Println(out.String())
+
+	mem.AddInteraction(input, string(document))
+	return nil
+}
+
+// printSessionUsage summarises the tokens and cost of the chat session
+func printSessionUsage(tracker *usage.Tracker) {
+	for _, t := range tracker.Totals() {
+		fmt.Printf("Session usage (%s/%s): %d requests, %d prompt + %d completion tokens, $%.4f\n",
+			t.Provider, t.Model, t.Requests, t.PromptTokens, t.CompletionTokens, t.Cost)
 	}
-	return simpleResponse(input)
 }
 
-func simpleResponse(input string) string {
-	// Basic response templates - can be extended or replaced with actual LLM
-	if strings.Contains(strings.ToLower(input), "hello") {
-		return "Hello! How can I assist you today?"
-	} else if strings.Contains(strings.ToLower(input), "help") {
-		return "I can help you with various tasks. What would you like to know?"
-	} else {
-		return "I understand you're asking about " + input + ". Could you elaborate?"
+// buildChatMessages assembles the conversation sent to the model: the system
+// prompt, relevant older exchanges, the recent history and the new input.
+// Recalled exchanges are dropped before recent ones, oldest first, to fit b.
+func buildChatMessages(input string, mem *memory.Memory, b budget.Budget) ([]llm.Message, error) {
+	var recalled, history []llm.Message
+
+	recent := mem.RetrieveRecent(chatHistoryTurns)
+	inRecent := make(map[memory.Interaction]bool, len(recent))
+	for _, interaction := range recent {
+		inRecent[interaction] = true
+	}
+
+	// Relevant context is returned newest first
+	relevant := mem.RetrieveRelevantContext(input)
+	for i := len(relevant) - 1; i >= 0; i-- {
+		if !inRecent[relevant[i]] {
+			recalled = appendInteraction(recalled, relevant[i])
+		}
+	}
+	for _, interaction := range recent {
+		history = appendInteraction(history, interaction)
+	}
+
+	messages, fit, err := b.FitChat(budget.Conversation{
+		System:  systemPrompt,
+		Context: recalled,
+		History: history,
+		Input:   input,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if verbose && fit.Trimmed() {
+		fmt.Printf("(trimmed the conversation to the %d-token window: %s)\n", b.Window, fit)
 	}
+	return messages, nil
+}
+
+func appendInteraction(messages []llm.Message, interaction memory.Interaction) []llm.Message {
+	return append(messages,
+		llm.Message{Role: llm.RoleUser, Content: interaction.UserInput},
+		llm.Message{Role: llm.RoleAssistant, Content: interaction.EidosResp},
+	)
+}
+
+// chatOptions collects the generation options set on the command line. Flags
+// that weren't given leave the configured provider defaults in place.
+func chatOptions(cmd *cobra.Command) llm.GenerateOptions {
+	opts := llm.GenerateOptions{
+		Model:     model,
+		MaxTokens: maxTokens,
+		Stop:      stopWords,
+		TopP:      topP,
+		TopK:      topK,
+	}
+	if cmd.Flags().Changed("temperature") {
+		opts.Temperature = llm.Float64(temperature)
+	}
+	if cmd.Flags().Changed("seed") {
+		opts.Seed = llm.Int(seed)
+	}
+	return opts
+}
+
+// registerChatTools adds the prompt-chain tools and, when commands are
+// allowed, the task tools to the tools offered to the model. Tools that fail
+// to register are left out with a warning.
+func registerChatTools() {
+	registerToolsOnce.Do(func() {
+		tools := prompt.Tools(promptsDir)
+		tools = append(tools, task.Tools(task.NewManager(toolTaskTimeout), allowCommands)...)
+		for _, tool := range tools {
+			if err := generation.RegisterTool(tool); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: tool not offered: %v\n", err)
+			}
+		}
+	})
+}
+
+// chatTools returns the tools offered to the model, including those of
+// loaded plugins, or none with --no-tools
+func chatTools() []llm.Tool {
+	if noTools {
+		return nil
+	}
+	return generation.Tools()
+}
+
+// chatContext returns the context for a chat request, carrying the cache
+// mode chosen on the command line
+func chatContext() context.Context {
+	ctx := context.Background()
+	switch {
+	case noCache:
+		ctx = llm.WithCacheMode(ctx, llm.CacheBypass)
+	case refreshCache:
+		ctx = llm.WithCacheMode(ctx, llm.CacheRefresh)
+	}
+	return ctx
 }
 
 func init() {
-	chatCmd.Flags().StringVarP(&model, "model", "m", "default", "Model to use for chat")
+	chatCmd.Flags().StringVarP(&model, "model", "m", "", "Model to use for chat (defaults to the provider's configured model)")
 	chatCmd.Flags().BoolVarP(&interactive, "interactive", "i", false, "Start interactive chat session")
+	chatCmd.Flags().StringVarP(&systemPrompt, "system", "s", defaultSystemPrompt, "System prompt sent at the start of the conversation")
+	chatCmd.Flags().StringVar(&schemaFile, "json-schema", "", "Reply with JSON validated against the JSON Schema in this file")
+	chatCmd.Flags().StringVarP(&chatProvider, "provider", "p", "ollama", "LLM provider to chat with (ollama, deepseek, openai, transformer, failover)")
+
+	chatCmd.Flags().Float64VarP(&temperature, "temperature", "t", 0, "Sampling temperature (0 for greedy decoding)")
+	chatCmd.Flags().IntVar(&maxTokens, "max-tokens", 0, "Maximum number of tokens to generate")
+	chatCmd.Flags().StringSliceVar(&stopWords, "stop", nil, "Stop sequences that end generation")
+	chatCmd.Flags().IntVar(&seed, "seed", 0, "Seed for reproducible sampling")
+	chatCmd.Flags().Float64Var(&topP, "top-p", 0, "Nucleus sampling probability mass")
+	chatCmd.Flags().IntVar(&topK, "top-k", 0, "Top-k sampling cutoff")
+	chatCmd.Flags().BoolVar(&noCache, "no-cache", false, "Neither read nor write the response cache")
+	chatCmd.Flags().BoolVar(&refreshCache, "refresh-cache", false, "Ignore cached replies but cache the new ones")
+	chatCmd.Flags().BoolVar(&noTools, "no-tools", false, "Don't offer tools to the model")
+	chatCmd.Flags().StringSliceVar(&allowCommands, "allow-command", nil, "Commands the model may run with the run_task tool")
 
 	chatCmd.GroupID = "core"
 	rootCmd.AddCommand(chatCmd)
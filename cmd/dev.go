@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"threshAI/pkg/llm/fakellm"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	fakeAddr   string
+	fakeScript string
+	fakeConfig fakellm.Config
+	fakeModels []string
+)
+
+func init() {
+	devFakeLLMCmd.Flags().StringVar(&fakeAddr, "addr", "127.0.0.1:11434", "Address to listen on")
+	devFakeLLMCmd.Flags().StringVar(&fakeScript, "script", "", "YAML file scripting the server; flags override it")
+	devFakeLLMCmd.Flags().StringVar(&fakeConfig.Mode, "mode", fakellm.ModeCanned, "Reply mode: canned, echo or template")
+	devFakeLLMCmd.Flags().StringVar(&fakeConfig.Response, "response", "", "Canned reply, or the template in template mode")
+	devFakeLLMCmd.Flags().StringSliceVar(&fakeModels, "models", nil, "Models to list (default llama2,nomic-embed-text)")
+	devFakeLLMCmd.Flags().BoolVar(&fakeConfig.StrictModels, "strict-models", false, "Reject requests for unlisted models with a 404")
+	devFakeLLMCmd.Flags().DurationVar(&fakeConfig.Latency, "latency", 0, "Delay before every response, e.g. 200ms")
+	devFakeLLMCmd.Flags().DurationVar(&fakeConfig.ChunkDelay, "chunk-delay", 0, "Delay between streamed chunks")
+	devFakeLLMCmd.Flags().IntVar(&fakeConfig.FailFirst, "fail-first", 0, "Fail the first N requests")
+	devFakeLLMCmd.Flags().Float64Var(&fakeConfig.ErrorRate, "error-rate", 0, "Fraction of requests to fail, between 0 and 1")
+	devFakeLLMCmd.Flags().IntVar(&fakeConfig.ErrorStatus, "error-status", 500, "HTTP status of injected failures")
+	devFakeLLMCmd.Flags().Int64Var(&fakeConfig.Seed, "seed", 0, "Seed for injected failures")
+	devCmd.AddCommand(devFakeLLMCmd)
+	rootCmd.AddCommand(devCmd)
+}
+
+var devCmd = &cobra.Command{
+	Use:     "dev",
+	Short:   "Development tools",
+	GroupID: "system",
+}
+
+var devFakeLLMCmd = &cobra.Command{
+	Use:   "fake-llm",
+	Short: "Serve a fake Ollama and OpenAI-compatible API",
+	Long: `Serve a fake LLM speaking the Ollama (/api/tags, /api/generate, /api/chat,
+/api/embeddings) and OpenAI (/v1/chat/completions, /v1/embeddings, /v1/models)
+protocols, for developing and testing without a real model.
+
+Replies are canned, echo the prompt, or are rendered from a Go template with
+.Prompt, .Model, .Messages and .N (the request number). A script file can add
+rules replying differently, or failing, for prompts containing some text:
+
+  mode: echo
+  latency: 100ms
+  rules:
+    - match: weather
+      response: Sunny all week
+    - match: boom
+      status: 503
+
+Point the CLI at it with OLLAMA_API_URL=http://127.0.0.1:11434, or set
+openai.base_url to http://127.0.0.1:11434/v1.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config := fakellm.Config{}
+		if fakeScript != "" {
+			data, err := os.ReadFile(fakeScript)
+			if err != nil {
+				return fmt.Errorf("failed to read script: %v", err)
+			}
+			if err := yaml.Unmarshal(data, &config); err != nil {
+				return fmt.Errorf("invalid script %s: %v", fakeScript, err)
+			}
+		}
+
+		// Flags given on the command line take precedence over the script
+		flags := cmd.Flags()
+		override := map[string]func(){
+			"mode":          func() { config.Mode = fakeConfig.Mode },
+			"response":      func() { config.Response = fakeConfig.Response },
+			"models":        func() { config.Models = fakeModels },
+			"strict-models": func() { config.StrictModels = fakeConfig.StrictModels },
+			"latency":       func() { config.Latency = fakeConfig.Latency },
+			"chunk-delay":   func() { config.ChunkDelay = fakeConfig.ChunkDelay },
+			"fail-first":    func() { config.FailFirst = fakeConfig.FailFirst },
+			"error-rate":    func() { config.ErrorRate = fakeConfig.ErrorRate },
+			"error-status":  func() { config.ErrorStatus = fakeConfig.ErrorStatus },
+			"seed":          func() { config.Seed = fakeConfig.Seed },
+		}
+		for name, apply := range override {
+			if flags.Changed(name) {
+				apply()
+			}
+		}
+
+		srv, err := fakellm.New(config)
+		if err != nil {
+			return err
+		}
+
+		mode := config.Mode
+		if mode == "" {
+			mode = fakellm.ModeCanned
+		}
+		fmt.Printf("Fake LLM (%s mode) listening on http://%s\n", mode, fakeAddr)
+		if len(config.Models) > 0 {
+			fmt.Printf("Models: %s\n", strings.Join(config.Models, ", "))
+		}
+		return http.ListenAndServe(fakeAddr, srv)
+	},
+}
@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"threshAI/internal/core"
+	"threshAI/internal/core/config"
+	"threshAI/internal/core/generator"
+	"threshAI/internal/render"
+	"threshAI/pkg/analytics"
+	"threshAI/pkg/core/utils"
+	"threshAI/pkg/flags"
+	"threshAI/pkg/monitor"
+	"threshAI/pkg/quantum"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/sha3"
+)
+
+// quantumShards is the number of generations sampled in quantum mode
+const quantumShards = 3
+
+var (
+	brutalMode  int
+	quantumMode bool
+	metrics     bool
+
+	memeInput      string
+	crisisSeverity int
+)
+
+var generateCmd = &cobra.Command{
+	Use:   "generate [command]",
+	Short: "Generate content using AI",
+	Long: `Generate content through the Thresh AI generation pipeline.
+Output is rendered according to the selected mode:
+- default: raw generator output
+- --brutal 1-3: markdown stripped and wrapped by the brutalizer
+- --quantum: multiple shards sampled, alternates rendered in superposition`,
+	Example: `thresh generate meme --input joke.txt
+thresh generate vector --quantum
+thresh generate crisis --severity 5 --brutal 2`,
+	GroupID: "core",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return errors.New("a generation type is required (meme, vector, crisis)")
+	},
+}
+
+var generateMemeCmd = &cobra.Command{
+	Use:   "meme",
+	Short: "Generate a meme from an input file or text",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		content, err := readMemeInput(memeInput)
+		if err != nil {
+			return err
+		}
+
+		// The digest ties the generated meme back to its exact source
+		digest := sha3.Sum256([]byte(content))
+		prompt := fmt.Sprintf("meme %s SHA3-256:%x", strings.Join(strings.Fields(content), " "), digest)
+		return runGeneration(cmd, prompt)
+	},
+}
+
+var generateVectorCmd = &cobra.Command{
+	Use:   "vector",
+	Short: "Generate an 11D embedding vector",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runGeneration(cmd, fmt.Sprintf("vector dimensions=11 smearing=%t", flags.Quantum()))
+	},
+}
+
+var generateCrisisCmd = &cobra.Command{
+	Use:   "crisis",
+	Short: "Generate a crisis protocol at the given severity",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if crisisSeverity < 1 || crisisSeverity > 5 {
+			return fmt.Errorf("severity must be between 1 and 5, got %d", crisisSeverity)
+		}
+		return runGeneration(cmd, "crisis"+strconv.Itoa(crisisSeverity))
+	},
+}
+
+// readMemeInput loads the meme source from a file, falling back to the
+// literal value when no such file exists
+func readMemeInput(input string) (string, error) {
+	if strings.TrimSpace(input) == "" {
+		return "", errors.New("--input is required")
+	}
+
+	data, err := os.ReadFile(input)
+	if err == nil {
+		return string(data), nil
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return input, nil
+	}
+	return "", fmt.Errorf("failed to read input: %w", err)
+}
+
+// resolveGenerationMode maps the --brutal/--quantum flags onto a generation mode
+func resolveGenerationMode() (config.GenerationMode, error) {
+	brutalMode = flags.Brutal()
+	quantumMode = flags.Quantum()
+
+	if brutalMode != 0 {
+		if !core.ValidateBrutalMode(brutalMode) {
+			return config.ModeDefault, fmt.Errorf("invalid brutal tier %d (valid tiers: 1-3)", brutalMode)
+		}
+		return config.ModeBrutal, nil
+	}
+	if quantumMode {
+		return config.ModeQuantum, nil
+	}
+	return config.ModeDefault, nil
+}
+
+func runGeneration(cmd *cobra.Command, prompt string) error {
+	mode, err := resolveGenerationMode()
+	if err != nil {
+		return err
+	}
+	config.CurrentGenerationMode = mode
+	config.BrutalLevel = brutalMode
+
+	out := cmd.OutOrStdout()
+	if mode == config.ModeBrutal {
+		preset, _ := core.GetBrutalPreset(brutalMode)
+		fmt.Fprintf(out, "⚠️  Brutal tier %d engaged – %s (%s, VRAM %s)\n",
+			brutalMode, preset.Description, preset.Quantization, preset.VRAMLimit)
+	}
+
+	shardCount := 1
+	if mode == config.ModeQuantum {
+		shardCount = quantumShards
+	}
+
+	shards := make([]string, shardCount)
+	for i := range shards {
+		shards[i], err = generator.Generate(prompt)
+		if err != nil {
+			return fmt.Errorf("generation failed: %w", err)
+		}
+	}
+
+	fmt.Fprint(out, renderOutput(mode, shards))
+
+	if metrics {
+		logGenerationMetrics(prompt, shards)
+	}
+	return nil
+}
+
+// renderOutput formats generator output for the given mode. In quantum mode
+// the first shard is the observed result and the rest are shown superposed.
+func renderOutput(mode config.GenerationMode, shards []string) string {
+	switch mode {
+	case config.ModeBrutal:
+		return render.Brutalize(shards[0])
+	case config.ModeQuantum:
+		var builder strings.Builder
+		builder.WriteString(shards[0] + "\n")
+		for i, shard := range shards[1:] {
+			builder.WriteString(fmt.Sprintf("--- superposed state %d ---\n", i+1))
+			builder.WriteString(render.Quantumize(shard))
+		}
+		return builder.String()
+	default:
+		return shards[0] + "\n"
+	}
+}
+
+func logGenerationMetrics(prompt string, shards []string) {
+	payload := analytics.MetricPayload{
+		Coherence:   analytics.CalculateCoherence(shards[0]),
+		Slang:       analytics.CalculateSlangRatio(shards[0]),
+		BrutalLevel: brutalMode,
+		PromptHash:  utils.HashPrompt(prompt),
+	}
+
+	// Entanglement only makes sense across multiple shards
+	if len(shards) > 1 {
+		if embedder, err := newEmbedder(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: using offline embeddings: %v\n", err)
+		} else {
+			quantum.SetEmbedder(embedder)
+		}
+		payload.Entanglement = quantum.CalculateEntanglement(shards)
+	}
+
+	monitor.LogMetrics(map[string]interface{}{
+		"timestamp":           time.Now().UTC().Format(time.RFC3339),
+		"coherence_score":     payload.Coherence,
+		"entanglement_factor": payload.Entanglement,
+		"slang_ratio":         payload.Slang,
+		"prompt_sha256":       payload.PromptHash,
+		"brutal_level":        payload.BrutalLevel,
+	})
+}
+
+func init() {
+	flags.Init(generateCmd.PersistentFlags())
+	generateCmd.PersistentFlags().BoolVar(&metrics, "metrics", false, "Enable metrics collection and logging")
+
+	generateMemeCmd.Flags().StringVarP(&memeInput, "input", "i", "", "Input file (or literal text) to build the meme from")
+	generateCrisisCmd.Flags().IntVarP(&crisisSeverity, "severity", "s", 1, "Crisis severity level (1-5)")
+
+	generateCmd.AddCommand(generateMemeCmd)
+	generateCmd.AddCommand(generateVectorCmd)
+	generateCmd.AddCommand(generateCrisisCmd)
+}
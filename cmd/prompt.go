@@ -10,9 +10,15 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// promptsDir holds the prompt templates listed by prompt list and offered to
+// models by the prompt-chain tools
+const promptsDir = "internal/core/memory/sys_prompts"
+
 var (
-	outputFormat string
-	promptFile   string
+	outputFormat     string
+	promptFile       string
+	promptModel      string
+	promptCompletion int
 )
 
 var promptCmd = &cobra.Command{
@@ -27,9 +33,12 @@ var promptRunCmd = &cobra.Command{
 	Use:   "run [file]",
 	Short: "Execute a prompt chain",
 	Long: `Execute a prompt chain from an XML template file.
-The template defines the system context, inputs, and expected outputs.`,
+The template defines the system context, inputs, and expected outputs.
+With --model, the repository content and then the focus areas are trimmed
+until the prompt fits the model's context window.`,
 	Example: `thresh prompt run codecraft.xml
-thresh prompt run oracle.xml --format json`,
+thresh prompt run oracle.xml --format json
+thresh prompt run codecraft.xml --model llama3 --completion-tokens 2048`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) < 1 && promptFile == "" {
 			return fmt.Errorf("prompt file is required")
@@ -72,6 +81,18 @@ func executePrompt(filename string) error {
 	fmt.Printf("✅ Loaded %s\n", filepath.Base(filename))
 	fmt.Printf("System Context: %s\n", p.System)
 
+	if promptModel != "" {
+		b, err := newBudget("", promptModel, promptCompletion)
+		if err != nil {
+			return err
+		}
+		tokens, err := prompt.FitToBudget(p, b)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Prompt: %d of %d tokens (%d reserved for the reply)\n", tokens, b.Window, b.Completion)
+	}
+
 	output := prompt.ExecuteChain(p)
 	if outputFormat == "json" {
 		// TODO: Implement JSON output formatting
@@ -83,7 +104,6 @@ func executePrompt(filename string) error {
 }
 
 func listPrompts() {
-	promptsDir := "internal/core/memory/sys_prompts"
 	files, err := os.ReadDir(promptsDir)
 	if err != nil {
 		fmt.Printf("Error reading prompts directory: %v\n", err)
@@ -109,6 +129,8 @@ func validatePrompt(filename string) error {
 func init() {
 	promptRunCmd.Flags().StringVarP(&outputFormat, "format", "f", "text", "Output format (text/json)")
 	promptRunCmd.Flags().StringVarP(&promptFile, "file", "i", "", "Input prompt file")
+	promptRunCmd.Flags().StringVarP(&promptModel, "model", "m", "", "Trim the template inputs to fit this model's context window")
+	promptRunCmd.Flags().IntVar(&promptCompletion, "completion-tokens", 0, "Tokens of the window to reserve for the reply (default 1024)")
 
 	promptCmd.AddCommand(promptRunCmd)
 	promptCmd.AddCommand(promptListCmd)
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"threshAI/internal/core/config"
+	"threshAI/internal/core/providers"
+	"threshAI/internal/core/usage"
+	"threshAI/pkg/core/generation"
+	"threshAI/pkg/llm/budget"
+)
+
+// loadConfig loads the CLI config. Each invocation is a new process, so
+// responses are cached on disk unless another cache backend is configured.
+func loadConfig() (*config.Config, error) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Cache.Backend == "" {
+		cfg.Cache.Backend = "file"
+	}
+	return cfg, nil
+}
+
+// newGenerator builds the generator for the named provider from the CLI config,
+// along with the tracker accounting its usage for this session
+func newGenerator(provider string) (generation.Generator, *usage.Tracker, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	tracker := providers.NewTracker(cfg)
+	gen, err := providers.New(cfg, provider, tracker)
+	if err != nil {
+		return nil, nil, err
+	}
+	return gen, tracker, nil
+}
+
+// newEmbedder builds the embedder configured in the CLI config
+func newEmbedder() (generation.Embedder, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	return providers.NewEmbedder(cfg)
+}
+
+// newBudget returns the context budget of requests to the named provider from
+// the CLI config. An empty model and a completion of zero use the provider's
+// configured model and max_tokens.
+func newBudget(provider, model string, completion int) (budget.Budget, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return budget.Budget{}, err
+	}
+	return providers.Budget(cfg, provider, model, completion)
+}
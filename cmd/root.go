@@ -49,6 +49,7 @@ func init() {
 
 	// Add commands to appropriate groups
 	rootCmd.AddCommand(promptCmd)
+	rootCmd.AddCommand(generateCmd)
 	rootCmd.AddCommand(chatCmd)
 	rootCmd.AddCommand(configCmd)
 	rootCmd.AddCommand(systemCmd)
@@ -1,12 +1,19 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
+	"text/tabwriter"
+	"time"
 
+	"threshAI/internal/core/config"
 	"threshAI/internal/core/plugin"
 	"threshAI/internal/core/plugin/examples"
+	"threshAI/internal/core/providers"
+	"threshAI/internal/core/usage"
+	"threshAI/pkg/core/generation"
 
 	"github.com/spf13/cobra"
 )
@@ -19,6 +26,9 @@ func init() {
 	systemCmd.AddCommand(systemStatusCmd)
 	systemCmd.AddCommand(systemDiagCmd)
 	systemCmd.AddCommand(systemMetricsCmd)
+	systemMetricsCmd.Flags().DurationVar(&metricsSince, "since", 0, "Only include requests made within this duration, e.g. 24h")
+	systemMetricsCmd.Flags().StringVar(&metricsSession, "session", "", "Only include requests from this session")
+	systemCmd.AddCommand(systemProvidersCmd)
 	rootCmd.AddCommand(systemCmd)
 }
 
@@ -56,17 +66,101 @@ var systemDiagCmd = &cobra.Command{
 	},
 }
 
+var (
+	metricsSince   time.Duration
+	metricsSession string
+)
+
 var systemMetricsCmd = &cobra.Command{
 	Use:   "metrics",
 	Short: "Show system metrics",
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `Show LLM token usage and cost per provider and model, aggregated from
+the usage ledger written by the CLI and web server.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		records, err := usage.ReadLedger(usage.DefaultLedgerPath())
+		if err != nil {
+			return err
+		}
+
+		filtered := records[:0]
+		for _, r := range records {
+			if metricsSince > 0 && time.Since(r.Time) > metricsSince {
+				continue
+			}
+			if metricsSession != "" && r.Session != metricsSession {
+				continue
+			}
+			filtered = append(filtered, r)
+		}
+
 		fmt.Println("System Metrics:")
-		// TODO: Implement metrics collection and display
+		if len(filtered) == 0 {
+			fmt.Println("No LLM usage recorded.")
+			return nil
+		}
+
+		var all usage.Totals
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PROVIDER\tMODEL\tREQUESTS\tPROMPT\tCOMPLETION\tCOST (USD)")
+		for _, t := range usage.Summarize(filtered) {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%.4f\n",
+				t.Provider, t.Model, t.Requests, t.PromptTokens, t.CompletionTokens, t.Cost)
+			all.Requests += t.Requests
+			all.Estimated += t.Estimated
+			all.PromptTokens += t.PromptTokens
+			all.CompletionTokens += t.CompletionTokens
+			all.Cost += t.Cost
+		}
+		fmt.Fprintf(w, "TOTAL\t\t%d\t%d\t%d\t%.4f\n",
+			all.Requests, all.PromptTokens, all.CompletionTokens, all.Cost)
+		w.Flush()
+
+		if all.Estimated > 0 {
+			fmt.Printf("\n%d of %d requests used estimated token counts.\n", all.Estimated, all.Requests)
+		}
+		return nil
 	},
 }
 
+var systemProvidersCmd = &cobra.Command{
+	Use:   "providers",
+	Short: "List registered LLM providers",
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("Registered Providers:")
+		for _, p := range generation.Providers() {
+			fmt.Printf("- %s (capabilities: %s)\n", p.Name, p.Capabilities)
+		}
+	},
+}
+
+// checkRedisConnection pings the Redis server of the configured cache, if
+// there is one
 func checkRedisConnection() {
-	fmt.Println("✓ Redis Connection: OK")
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fmt.Printf("✗ Redis Connection: %v\n", err)
+		return
+	}
+	if cfg.Cache.Backend != "redis" && cfg.Cache.Redis.Addr == "" {
+		fmt.Println("- Redis Connection: not configured")
+		return
+	}
+
+	rc, err := providers.NewRedisCache(cfg)
+	if err != nil {
+		fmt.Printf("✗ Redis Connection: %v\n", err)
+		return
+	}
+	defer rc.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := rc.Ping(ctx); err != nil {
+		fmt.Printf("✗ Redis Connection: %v\n", err)
+		return
+	}
+	fmt.Printf("✓ Redis Connection: OK (%s)\n", time.Since(start).Round(time.Microsecond))
 }
 
 func checkModelAvailability() {
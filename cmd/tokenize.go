@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"threshAI/pkg/llm/budget"
+	"threshAI/pkg/llm/tokenizer"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	tokenizeCount    bool
+	tokenizeProvider string
+	tokenizeModel    string
+	tokenizeVocab    string
+	tokenizeMerges   string
+	tokenizeConfig   string
+)
+
+func init() {
+	tokenizeCmd.Flags().BoolVar(&tokenizeCount, "count", false, "Print the number of tokens instead of the token IDs")
+	tokenizeCmd.Flags().StringVarP(&tokenizeProvider, "provider", "p", "", "Count tokens for this provider's model when no vocabulary is given")
+	tokenizeCmd.Flags().StringVarP(&tokenizeModel, "model", "m", "", "Count tokens for this model when no vocabulary is given")
+	tokenizeCmd.Flags().StringVar(&tokenizeVocab, "vocab", "", "GPT-2 vocab.json to tokenize with")
+	tokenizeCmd.Flags().StringVar(&tokenizeMerges, "merges", "", "GPT-2 merges.txt to tokenize with")
+	tokenizeCmd.Flags().StringVar(&tokenizeConfig, "tokenizer-config", "", "tokenizer_config.json naming the special tokens")
+	rootCmd.AddCommand(tokenizeCmd)
+}
+
+var tokenizeCmd = &cobra.Command{
+	Use:   "tokenize [text]",
+	Short: "Tokenize text or count its tokens",
+	Long: `Tokenize text given as arguments or on stdin and print the token IDs, one per
+line. With --count, print the number of tokens instead.
+
+Tokens are counted exactly with a BPE vocabulary given by --vocab and --merges,
+such as one written by "thresh tokenizer train". Without one, --count counts
+them the way chat budgets its prompts to the --model or --provider: with the
+tokenizer configured for the model under tokenizers or the transformer's own,
+else estimated for the vocabulary of the model family.`,
+	Example: `thresh tokenize --count --model llama3 < prompt.txt
+thresh tokenize --count --provider transformer "Hello world"
+thresh tokenize --vocab models/tiny/vocab.json --merges models/tiny/merges.txt "Hello world"`,
+	GroupID: "system",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if (tokenizeVocab == "") != (tokenizeMerges == "") {
+			return fmt.Errorf("--vocab and --merges must be given together")
+		}
+		if tokenizeVocab == "" && !tokenizeCount {
+			return fmt.Errorf("printing token IDs requires --vocab and --merges; use --count for an estimate")
+		}
+
+		text := strings.Join(args, " ")
+		if len(args) == 0 {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return fmt.Errorf("failed to read stdin: %v", err)
+			}
+			text = string(data)
+		}
+
+		if tokenizeVocab == "" {
+			b, err := newBudget(tokenizeProvider, tokenizeModel, 0)
+			if err != nil {
+				return err
+			}
+			fmt.Println(b.Count(text))
+			return nil
+		}
+
+		tok, err := tokenizer.LoadGPT2Tokenizer(tokenizeVocab, tokenizeMerges)
+		if err != nil {
+			return err
+		}
+		if tokenizeConfig != "" {
+			config, err := tokenizer.LoadConfig(tokenizeConfig)
+			if err != nil {
+				return err
+			}
+			specials := config.SpecialTokens()
+			if specials.EOS == "" {
+				specials.EOS = tok.SpecialTokens().EOS
+			}
+			if added := tok.SetSpecialTokens(specials); len(added) > 0 {
+				fmt.Fprintf(os.Stderr, "Warning: special tokens %s are missing from the vocabulary and were added, making %d tokens\n",
+					strings.Join(added, ", "), tok.VocabSize())
+			}
+		}
+
+		if tokenizeCount {
+			fmt.Println(budget.TokenizerCounter{Tokenizer: tok}.CountTokens(text))
+			return nil
+		}
+		for _, id := range tok.Tokenize(text) {
+			fmt.Println(id)
+		}
+		return nil
+	},
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"threshAI/pkg/llm/tokenizer"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	trainOutput       string
+	trainConfig       tokenizer.TrainConfig
+	trainChatTemplate string
+)
+
+func init() {
+	tokenizerTrainCmd.Flags().StringVarP(&trainOutput, "output", "o", ".", "Directory to write vocab.json and merges.txt to")
+	tokenizerTrainCmd.Flags().IntVar(&trainConfig.VocabSize, "vocab-size", tokenizer.DefaultTrainVocabSize, "Number of tokens to learn, counting special and byte tokens")
+	tokenizerTrainCmd.Flags().IntVar(&trainConfig.MinFrequency, "min-frequency", tokenizer.DefaultMinFrequency, "Occurrences a pair needs to be merged")
+	tokenizerTrainCmd.Flags().StringSliceVar(&trainConfig.SpecialTokens, "special-tokens", []string{"<|endoftext|>"}, "Special tokens, given the first IDs")
+	tokenizerTrainCmd.Flags().StringVar(&trainChatTemplate, "chat-template", "", "Chat template to record in the config: plain, chatml, llama or mistral")
+	tokenizerTrainCmd.Flags().IntVar(&trainConfig.Workers, "workers", 0, "Goroutines reading files and counting pairs (default one per CPU)")
+	tokenizerCmd.AddCommand(tokenizerTrainCmd)
+	rootCmd.AddCommand(tokenizerCmd)
+}
+
+var tokenizerCmd = &cobra.Command{
+	Use:     "tokenizer",
+	Short:   "Build tokenizers for local transformer models",
+	GroupID: "system",
+}
+
+var tokenizerTrainCmd = &cobra.Command{
+	Use:   "train <corpus-dir>",
+	Short: "Train a byte-level BPE tokenizer on a directory of text files",
+	Long: `Train a byte-level BPE tokenizer on every text file under a directory, skipping
+hidden files and files that aren't UTF-8. Merges are learned until the
+vocabulary reaches --vocab-size or no pair occurs --min-frequency times.
+
+The tokenizer is written as vocab.json and merges.txt in the GPT-2 format, for
+the vocab_path and merge_path of a transformer model using tokenizer_type bpe,
+and tokenizer_config.json, naming the special tokens and chat template, for its
+tokenizer_config. Set the model's vocab_size to the size reported:
+
+  thresh tokenizer train ./corpus --vocab-size 16000 -o ./models/tiny \
+    --special-tokens "<|endoftext|>,<|im_start|>,<|im_end|>" --chat-template chatml`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var chatTemplate tokenizer.ChatTemplate
+		if trainChatTemplate != "" {
+			var err error
+			if chatTemplate, err = tokenizer.ParseChatTemplate(trainChatTemplate); err != nil {
+				return err
+			}
+		}
+
+		start := time.Now()
+		trainer := tokenizer.NewTrainer(trainConfig)
+		files, err := trainer.FeedDir(args[0])
+		if err != nil {
+			return err
+		}
+		if files == 0 {
+			return fmt.Errorf("no text files found in %s", args[0])
+		}
+
+		tok, err := trainer.Train()
+		if err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(trainOutput, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %v", err)
+		}
+		vocabPath := filepath.Join(trainOutput, "vocab.json")
+		mergesPath := filepath.Join(trainOutput, "merges.txt")
+		configPath := filepath.Join(trainOutput, "tokenizer_config.json")
+		if err := tok.Save(vocabPath, mergesPath); err != nil {
+			return err
+		}
+		if err := tok.SaveConfig(configPath, chatTemplate); err != nil {
+			return err
+		}
+
+		fmt.Printf("✓ Trained on %d files in %s\n", files, time.Since(start).Round(time.Millisecond))
+		fmt.Printf("Vocabulary: %d tokens\n", tok.VocabSize())
+		fmt.Printf("Wrote %s, %s and %s\n", vocabPath, mergesPath, configPath)
+		return nil
+	},
+}
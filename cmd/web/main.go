@@ -1,59 +1,177 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
 	"net/http"
-	"os"
-	"threshAI/pkg/cache"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"threshAI/internal/core/config"
+	"threshAI/internal/core/providers"
+	"threshAI/internal/core/task"
+	"threshAI/internal/prompt"
 	"threshAI/pkg/core/generation"
-	"threshAI/pkg/llm/deepseek"
-	"threshAI/pkg/llm/ollama"
+	"threshAI/pkg/llm"
+	"threshAI/pkg/llm/admission"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// taskTimeout bounds each command a model runs with the run_task tool
+const taskTimeout = 2 * time.Minute
+
 func main() {
-	cache := cache.NewInMemoryCache()
+	configPath := flag.String("config", "", "Path to config file")
+	promptsDir := flag.String("prompts", "internal/core/memory/sys_prompts", "Directory of the prompt templates offered to models")
+	allowCommands := flag.String("allow-commands", "", "Comma-separated commands models may run with the run_task tool")
+	flag.Parse()
 
-	ollamaClient, err := generation.NewGenerator(
-		generation.ProviderOllama,
-		ollama.Config{BaseURL: "http://localhost:11434"},
-		cache,
-	)
+	cfg, err := config.Load(*configPath)
 	if err != nil {
 		panic(err)
 	}
 
-	deepseekAPIKey := os.Getenv("DEEPSEEK_API_KEY")
-	if deepseekAPIKey == "" {
-		panic("DEEPSEEK_API_KEY environment variable is required")
+	tracker := providers.NewTracker(cfg)
+
+	var commands []string
+	if *allowCommands != "" {
+		commands = strings.Split(*allowCommands, ",")
+	}
+	for _, tool := range append(prompt.Tools(*promptsDir), task.Tools(task.NewManager(taskTimeout), commands)...) {
+		if err := generation.RegisterTool(tool); err != nil {
+			panic(err)
+		}
+	}
+
+	ollamaClient, err := providers.New(cfg, string(generation.ProviderOllama), tracker)
+	if err != nil {
+		panic(err)
 	}
 
-	deepseekClient, err := generation.NewGenerator(
-		generation.ProviderDeepSeek,
-		deepseek.Config{BaseURL: "https://api.deepseek.com", APIKey: deepseekAPIKey},
-		cache,
-	)
+	deepseekClient, err := providers.New(cfg, string(generation.ProviderDeepSeek), tracker)
 	if err != nil {
 		panic(err)
 	}
 
-	http.HandleFunc("/generate/ollama", func(w http.ResponseWriter, r *http.Request) {
+	http.HandleFunc("/generate/ollama", streamHandler(ollamaClient))
+	http.HandleFunc("/generate/deepseek", streamHandler(deepseekClient))
+
+	if len(cfg.Failover) > 0 {
+		failover, err := providers.NewFailover(cfg, tracker)
+		if err != nil {
+			panic(err)
+		}
+		http.HandleFunc("/generate/failover", streamHandler(failover))
+	}
+
+	// Token and cost counters among others
+	http.Handle("/metrics", promhttp.Handler())
+
+	http.ListenAndServe(":8080", nil)
+}
+
+// streamHandler writes generated tokens to the response as they arrive,
+// flushing after each chunk so clients can render output incrementally. The
+// prompt is sent as a chat message with the registered tools, whose calls
+// are run between model turns.
+func streamHandler(generator generation.Generator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		prompt := r.URL.Query().Get("prompt")
-		output, err := generation.Generate(r.Context(), ollamaClient, prompt)
+		opts, err := parseOptions(r.URL.Query())
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mode, err := llm.ParseCacheMode(r.URL.Query().Get("cache"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		w.Write([]byte(output))
-	})
 
-	http.HandleFunc("/generate/deepseek", func(w http.ResponseWriter, r *http.Request) {
-		prompt := r.URL.Query().Get("prompt")
-		output, err := generation.Generate(r.Context(), deepseekClient, prompt)
+		// Web clients wait for their answer, so they are admitted ahead of
+		// batch chains when a provider's concurrency limit is reached
+		ctx := admission.WithPriority(r.Context(), admission.Interactive)
+		ctx = llm.WithCacheMode(ctx, mode)
+		ctx, route := generation.WithRoute(ctx)
+		stream, err := generation.StreamTools(ctx, generator, llm.ChatRequest{
+			Messages: []llm.Message{{Role: llm.RoleUser, Content: prompt}},
+			Options:  opts,
+			Tools:    generation.Tools(),
+		}, 0)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			status := http.StatusInternalServerError
+			if errors.Is(err, admission.ErrQueueFull) || errors.Is(err, admission.ErrQueueTimeout) {
+				status = http.StatusServiceUnavailable
+			}
+			http.Error(w, err.Error(), status)
 			return
 		}
-		w.Write([]byte(output))
-	})
+		if route.Backend != "" {
+			// Failover streams settle on a backend before returning
+			w.Header().Set("X-Thresh-Backend", route.Backend)
+		}
 
-	http.ListenAndServe(":8080", nil)
+		flusher, _ := w.(http.Flusher)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+
+		_, err = generation.Collect(stream, func(token string) {
+			w.Write([]byte(token))
+			if flusher != nil {
+				flusher.Flush()
+			}
+		})
+		if err != nil {
+			// Headers are already sent, so the error can only be logged
+			log.Printf("stream for %s failed: %v", r.URL.Path, err)
+		}
+	}
+}
+
+// parseOptions reads generation options from the query string, e.g.
+// ?model=llama3&temperature=0.2&max_tokens=256&stop=END&seed=7
+func parseOptions(query url.Values) (llm.GenerateOptions, error) {
+	opts := llm.GenerateOptions{
+		Model: query.Get("model"),
+		Stop:  query["stop"],
+	}
+
+	if v := query.Get("temperature"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return opts, fmt.Errorf("invalid temperature %q", v)
+		}
+		opts.Temperature = &t
+	}
+	if v := query.Get("seed"); v != "" {
+		seed, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid seed %q", v)
+		}
+		opts.Seed = &seed
+	}
+	if v := query.Get("top_p"); v != "" {
+		p, err := strconv.ParseFloat(v, 64)
+		if err != nil || p < 0 || p > 1 {
+			return opts, fmt.Errorf("invalid top_p %q", v)
+		}
+		opts.TopP = p
+	}
+
+	for name, dst := range map[string]*int{"max_tokens": &opts.MaxTokens, "top_k": &opts.TopK} {
+		if v := query.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return opts, fmt.Errorf("invalid %s %q", name, v)
+			}
+			*dst = n
+		}
+	}
+
+	return opts, nil
 }
@@ -8,10 +8,10 @@ import (
 
 	"threshAI/pkg/analytics"
 	"threshAI/pkg/quantum"
-	"threshAI/pkg/security"
 )
 
-var securityModel = security.SecurityConfig{}.ApprovedModels[0]
+// securityModel is the first approved model from config/security.yaml.
+var securityModel = MODEL_MINISTRAL_8B
 
 func GetCoherenceScore() float64 {
 	return float64(analytics.CalculateCoherence(""))
@@ -74,6 +74,12 @@ func ValidateBrutalMode(brutalMode int) bool {
 	return exists
 }
 
+// GetBrutalPreset returns the preset for the given brutal tier
+func GetBrutalPreset(brutalMode int) (BrutalConfig, bool) {
+	preset, exists := brutalPresets[brutalMode]
+	return preset, exists
+}
+
 func GetChaosMetrics() map[string]float64 {
 	return map[string]float64{
 		"output_coherence_score":      GetCoherenceScore(),
@@ -9,18 +9,219 @@ import (
 
 type Config struct {
 	Ollama struct {
-		URL string `yaml:"url"`
+		URL            string   `yaml:"url"`
+		Model          string   `yaml:"model"`
+		MaxTokens      int      `yaml:"max_tokens"`
+		Temperature    *float64 `yaml:"temperature"`
+		RequestTimeout string   `yaml:"request_timeout"`
+		MaxRetries     int      `yaml:"max_retries"`
+		EmbeddingModel string   `yaml:"embedding_model"`
 	} `yaml:"ollama"`
 
 	DeepSeek struct {
-		APIKey         string  `yaml:"-"` // From environment variable
-		BaseURL        string  `yaml:"base_url"`
-		MaxTokens      int     `yaml:"max_tokens"`
-		CacheTTL       string  `yaml:"cache_ttl"`
-		RequestTimeout string  `yaml:"request_timeout"`
-		MaxRetries     int     `yaml:"max_retries"`
-		Temperature    float64 `yaml:"temperature"`
+		APIKey         string   `yaml:"-"` // From environment variable
+		BaseURL        string   `yaml:"base_url"`
+		Model          string   `yaml:"model"`
+		MaxTokens      int      `yaml:"max_tokens"`
+		CacheTTL       string   `yaml:"cache_ttl"`
+		RequestTimeout string   `yaml:"request_timeout"`
+		MaxRetries     int      `yaml:"max_retries"`
+		Temperature    *float64 `yaml:"temperature"` // Unset leaves the provider default
 	} `yaml:"deepseek"`
+
+	// OpenAI configures any server speaking the OpenAI chat completions
+	// protocol (llama.cpp server, vLLM, LM Studio, ...)
+	OpenAI struct {
+		APIKey         string            `yaml:"-"` // From environment variable
+		BaseURL        string            `yaml:"base_url"`
+		Model          string            `yaml:"model"`
+		Headers        map[string]string `yaml:"headers"`
+		MaxTokens      int               `yaml:"max_tokens"`
+		Temperature    *float64          `yaml:"temperature"`
+		RequestTimeout string            `yaml:"request_timeout"`
+		MaxRetries     int               `yaml:"max_retries"`
+		EmbeddingModel string            `yaml:"embedding_model"`
+	} `yaml:"openai"`
+
+	// Embeddings selects the provider used for embeddings. An empty provider
+	// uses the offline hashing embedder.
+	Embeddings struct {
+		Provider string `yaml:"provider"`
+	} `yaml:"embeddings"`
+
+	// Failover is the ordered chain of provider/model pairs tried by the
+	// failover provider until one of them answers
+	Failover []FailoverBackend `yaml:"failover"`
+
+	// Pricing is the cost per million tokens keyed by model name, or by
+	// "provider/model" where a model is served by several providers
+	Pricing map[string]ModelPrice `yaml:"pricing"`
+
+	// ContextWindows overrides the context window in tokens of models, keyed
+	// by model name, for models the built-in table doesn't know or serves
+	// with a different window
+	ContextWindows map[string]int `yaml:"context_windows"`
+
+	// Tokenizers names the BPE tokenizer files of models, keyed by model
+	// name, so that their prompts are counted exactly instead of estimated
+	Tokenizers map[string]TokenizerFiles `yaml:"tokenizers"`
+
+	// Cassette records provider traffic to a file or replays it from one.
+	// THRESH_CASSETTE and THRESH_CASSETTE_MODE override it.
+	Cassette Cassette `yaml:"cassette"`
+
+	// Cache selects and bounds the response cache of providers that cache
+	// replies
+	Cache CacheConfig `yaml:"cache"`
+
+	// SemanticCache answers prompts from the replies to earlier prompts that
+	// mean the same
+	SemanticCache SemanticCache `yaml:"semantic_cache"`
+
+	// Concurrency limits the requests in flight to each provider, keyed by
+	// provider name. Providers without an entry are not limited.
+	Concurrency map[string]ConcurrencyLimit `yaml:"concurrency"`
+
+	// Providers holds raw configs for additional registered providers, such
+	// as those contributed by plugins, keyed by provider name
+	Providers map[string]interface{} `yaml:"providers"`
+}
+
+// FailoverBackend is one entry of the failover chain. An empty model uses the
+// provider's configured model.
+type FailoverBackend struct {
+	Provider string `yaml:"provider"`
+	Model    string `yaml:"model"`
+}
+
+// TokenizerFiles locates a GPT-2 style BPE tokenizer: its vocab.json,
+// merges.txt and, optionally, tokenizer_config.json naming special tokens
+type TokenizerFiles struct {
+	Vocab  string `yaml:"vocab"`
+	Merges string `yaml:"merges"`
+	Config string `yaml:"config"`
+}
+
+// CacheConfig selects a response cache. Backend is "memory", "file" or
+// "redis"; the CLI defaults to file and the web server to memory. In memory
+// and on disk, entries beyond MaxEntries or MaxBytes evict the least recently
+// used ones; zero values keep the defaults. Dir locates the file cache.
+type CacheConfig struct {
+	Backend    string `yaml:"backend"`
+	MaxEntries int    `yaml:"max_entries"`
+	MaxBytes   int64  `yaml:"max_bytes"`
+	Dir        string `yaml:"dir"`
+	Redis      Redis  `yaml:"redis"`
+}
+
+// Redis holds the connection settings of a Redis server. Timeouts are
+// durations such as "2s". REDIS_ADDR and REDIS_PASSWORD override the file.
+type Redis struct {
+	Addr         string `yaml:"addr"`
+	Username     string `yaml:"username"`
+	Password     string `yaml:"-"` // From environment variable
+	DB           int    `yaml:"db"`
+	Namespace    string `yaml:"namespace"`
+	Compress     bool   `yaml:"compress"`
+	DialTimeout  string `yaml:"dial_timeout"`
+	ReadTimeout  string `yaml:"read_timeout"`
+	WriteTimeout string `yaml:"write_timeout"`
+}
+
+// SemanticCache enables the semantic cache. Threshold is the cosine
+// similarity from which prompts count as the same and TTL a duration such as
+// "1h"; zero values keep the defaults.
+type SemanticCache struct {
+	Enabled    bool    `yaml:"enabled"`
+	Threshold  float64 `yaml:"threshold"`
+	TTL        string  `yaml:"ttl"`
+	MaxEntries int     `yaml:"max_entries"`
+}
+
+// ConcurrencyLimit bounds the requests sent to a provider at once. Further
+// requests wait in a queue of up to MaxQueue requests for at most
+// QueueTimeout, e.g. "30s".
+type ConcurrencyLimit struct {
+	MaxConcurrent int    `yaml:"max_concurrent"`
+	MaxQueue      int    `yaml:"max_queue"`
+	QueueTimeout  string `yaml:"queue_timeout"`
+}
+
+// Cassette selects a cassette file. Mode is "record" or "replay"; a path
+// without a mode is replayed.
+type Cassette struct {
+	Path string `yaml:"path"`
+	Mode string `yaml:"mode"`
+}
+
+// ModelPrice is the cost of a model in US dollars per million tokens
+type ModelPrice struct {
+	Prompt     float64 `yaml:"prompt"`
+	Completion float64 `yaml:"completion"`
+}
+
+const (
+	defaultOllamaURL       = "http://localhost:11434"
+	defaultDeepSeekBaseURL = "https://api.deepseek.com"
+)
+
+// Load reads the provider configuration from a YAML file. An empty path
+// yields the defaults. Endpoints and keys from the environment take
+// precedence over the file.
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file %s: %v", path, err)
+		}
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal config file %s: %v", path, err)
+		}
+	}
+
+	if url := os.Getenv("OLLAMA_API_URL"); url != "" {
+		cfg.Ollama.URL = url
+	}
+	if cfg.Ollama.URL == "" {
+		cfg.Ollama.URL = defaultOllamaURL
+	}
+
+	cfg.DeepSeek.APIKey = os.Getenv("DEEPSEEK_API_KEY")
+	cfg.OpenAI.APIKey = os.Getenv("OPENAI_API_KEY")
+	if cfg.DeepSeek.BaseURL == "" {
+		cfg.DeepSeek.BaseURL = defaultDeepSeekBaseURL
+	}
+
+	if addr := os.Getenv("REDIS_ADDR"); addr != "" {
+		cfg.Cache.Redis.Addr = addr
+	}
+	cfg.Cache.Redis.Password = os.Getenv("REDIS_PASSWORD")
+	switch cfg.Cache.Backend {
+	case "", "memory", "file", "redis":
+	default:
+		return nil, fmt.Errorf("invalid cache backend %q: must be memory, file or redis", cfg.Cache.Backend)
+	}
+
+	if path := os.Getenv("THRESH_CASSETTE"); path != "" {
+		cfg.Cassette.Path = path
+	}
+	if mode := os.Getenv("THRESH_CASSETTE_MODE"); mode != "" {
+		cfg.Cassette.Mode = mode
+	}
+	if cfg.Cassette.Path != "" && cfg.Cassette.Mode == "" {
+		cfg.Cassette.Mode = "replay"
+	}
+	switch cfg.Cassette.Mode {
+	case "", "record", "replay":
+	default:
+		return nil, fmt.Errorf("invalid cassette mode %q: must be record or replay", cfg.Cassette.Mode)
+	}
+	if cfg.Cassette.Mode != "" && cfg.Cassette.Path == "" {
+		return nil, fmt.Errorf("cassette mode %s requires a cassette path", cfg.Cassette.Mode)
+	}
+
+	return cfg, nil
 }
 
 type SecurityConfig struct {
@@ -1,16 +1,43 @@
 package memory
 
 import (
+	"context"
 	"encoding/json"
 	"os"
 	"path/filepath"
-	"strings"
+	"sort"
+
+	"threshAI/pkg/core/generation"
+	"threshAI/pkg/logging"
+	"threshAI/pkg/nlpvalidator"
+	"threshAI/pkg/quantum"
+)
+
+const (
+	// maxRelevant limits the number of relevant interactions retrieved
+	maxRelevant = 3
+	// minRelevance is the similarity an interaction needs to count as relevant
+	minRelevance = 0.2
 )
 
 // Memory represents the chat memory system
 type Memory struct {
 	Interactions []Interaction
 	filepath     string
+
+	// embedder scores relevance; embeddings caches vectors by text since
+	// history is re-scored on every turn
+	embedder   generation.Embedder
+	embeddings map[string][]float32
+	// fitted counts the interactions the embedder has learned term weights
+	// from, for embedders that learn them
+	fitted int
+}
+
+// fitter is an embedder weighting terms by how rare they are in a corpus,
+// such as the offline hashing embedder
+type fitter interface {
+	Fit(documents []string)
 }
 
 // Interaction represents a single chat interaction
@@ -56,26 +83,129 @@ func (m *Memory) AddInteraction(input, response string) {
 	})
 }
 
-// RetrieveRelevantContext finds relevant past interactions
+// SetEmbedder selects the embedder used to find relevant interactions. nil
+// selects the offline hashing embedder.
+func (m *Memory) SetEmbedder(e generation.Embedder) {
+	m.embedder = e
+	m.embeddings = nil
+	m.fitted = 0
+}
+
+// RetrieveRelevantContext finds the past interactions most similar to input,
+// newest first
 func (m *Memory) RetrieveRelevantContext(input string) []Interaction {
-	var relevant []Interaction
-	input = strings.ToLower(input)
-
-	// Simple relevance matching - can be enhanced with more sophisticated matching
-	for i := len(m.Interactions) - 1; i >= 0; i-- {
-		interaction := m.Interactions[i]
-		if strings.Contains(strings.ToLower(interaction.UserInput), input) ||
-			strings.Contains(input, strings.ToLower(interaction.UserInput)) {
-			relevant = append(relevant, interaction)
-			if len(relevant) >= 3 { // Limit context to last 3 relevant interactions
-				break
-			}
+	if len(m.Interactions) == 0 {
+		return nil
+	}
+
+	texts := make([]string, 0, len(m.Interactions)+1)
+	texts = append(texts, input)
+	for _, interaction := range m.Interactions {
+		texts = append(texts, interaction.UserInput)
+	}
+	vectors := m.embed(texts)
+
+	type scored struct {
+		index int
+		score float32
+	}
+	var candidates []scored
+	for i := range m.Interactions {
+		if score := quantum.CosineSimilarity(vectors[0], vectors[i+1]); score >= minRelevance {
+			candidates = append(candidates, scored{i, score})
 		}
 	}
 
+	// Keep the best matches, preferring newer interactions on ties
+	sort.Slice(candidates, func(a, b int) bool {
+		if candidates[a].score != candidates[b].score {
+			return candidates[a].score > candidates[b].score
+		}
+		return candidates[a].index > candidates[b].index
+	})
+	if len(candidates) > maxRelevant {
+		candidates = candidates[:maxRelevant]
+	}
+	sort.Slice(candidates, func(a, b int) bool {
+		return candidates[a].index > candidates[b].index
+	})
+
+	relevant := make([]Interaction, len(candidates))
+	for i, c := range candidates {
+		relevant[i] = m.Interactions[c.index]
+	}
 	return relevant
 }
 
+// embed returns the embeddings of texts, embedding only those not seen
+// before. If the embedder fails, all texts are embedded offline instead.
+func (m *Memory) embed(texts []string) [][]float32 {
+	if m.embedder == nil {
+		m.embedder = nlpvalidator.NewHashingEmbedder(nlpvalidator.DefaultEmbeddingDim)
+	}
+	if f, ok := m.embedder.(fitter); ok && m.fitted < len(m.Interactions) {
+		f.Fit(userInputs(m.Interactions[m.fitted:]))
+		m.fitted = len(m.Interactions)
+		// The term weights changed, so earlier vectors are stale
+		m.embeddings = nil
+	}
+	if m.embeddings == nil {
+		m.embeddings = make(map[string][]float32)
+	}
+
+	var missing []string
+	for _, text := range texts {
+		if _, ok := m.embeddings[text]; !ok {
+			missing = append(missing, text)
+			m.embeddings[text] = nil
+		}
+	}
+
+	if len(missing) > 0 {
+		vectors, err := m.embedder.Embed(context.Background(), missing)
+		if err != nil || len(vectors) != len(missing) {
+			for _, text := range missing {
+				delete(m.embeddings, text)
+			}
+			logging.Logger.Printf("Warning: embedding failed, using offline embeddings: %v", err)
+			offline := nlpvalidator.NewHashingEmbedder(nlpvalidator.DefaultEmbeddingDim)
+			offline.Fit(userInputs(m.Interactions))
+			vectors, _ = offline.Embed(context.Background(), texts)
+			return vectors
+		}
+		for i, text := range missing {
+			m.embeddings[text] = vectors[i]
+		}
+	}
+
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		out[i] = m.embeddings[text]
+	}
+	return out
+}
+
+// userInputs returns the user inputs of interactions, the corpus term
+// weights are learned from
+func userInputs(interactions []Interaction) []string {
+	inputs := make([]string, len(interactions))
+	for i, interaction := range interactions {
+		inputs[i] = interaction.UserInput
+	}
+	return inputs
+}
+
+// RetrieveRecent returns up to n of the most recent interactions, oldest first
+func (m *Memory) RetrieveRecent(n int) []Interaction {
+	if n <= 0 || len(m.Interactions) == 0 {
+		return nil
+	}
+	if n > len(m.Interactions) {
+		n = len(m.Interactions)
+	}
+	return m.Interactions[len(m.Interactions)-n:]
+}
+
 // RetrieveLastInteraction gets the most recent interaction
 func (m *Memory) RetrieveLastInteraction() (*Interaction, error) {
 	if len(m.Interactions) == 0 {
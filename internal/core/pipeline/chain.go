@@ -2,9 +2,14 @@ package pipeline
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"sync"
 	"text/template"
+
+	"threshAI/pkg/core/generation"
+	"threshAI/pkg/llm"
+	"threshAI/pkg/llm/admission"
 )
 
 // Chain represents a prompt execution pipeline
@@ -28,6 +33,8 @@ type StepHandler interface {
 
 // Context holds the execution context for a chain
 type Context struct {
+	// Ctx carries the cancellation and request priority of the chain run
+	Ctx       context.Context
 	Variables map[string]interface{}
 	Input     interface{}
 	Output    interface{}
@@ -67,7 +74,14 @@ func (c *Chain) GetVariable(key string) (interface{}, bool) {
 
 // Execute runs the chain with the given input
 func (c *Chain) Execute(input interface{}) (interface{}, error) {
+	return c.ExecuteContext(context.Background(), input)
+}
+
+// ExecuteContext runs the chain with the given input, passing ctx on to the
+// steps
+func (c *Chain) ExecuteContext(runCtx context.Context, input interface{}) (interface{}, error) {
 	ctx := &Context{
+		Ctx:       runCtx,
 		Variables: c.variables,
 		Input:     input,
 	}
@@ -135,6 +149,15 @@ func (b *BatchExecutor) AddChain(chain *Chain) {
 
 // ExecuteAll runs all chains in parallel with rate limiting
 func (b *BatchExecutor) ExecuteAll(input interface{}) []error {
+	return b.ExecuteAllContext(context.Background(), input)
+}
+
+// ExecuteAllContext runs all chains in parallel with rate limiting. Provider
+// requests made by the chains queue behind interactive requests for the
+// provider's admission slots.
+func (b *BatchExecutor) ExecuteAllContext(ctx context.Context, input interface{}) []error {
+	ctx = admission.WithPriority(ctx, admission.Batch)
+
 	var wg sync.WaitGroup
 	sem := make(chan struct{}, b.maxConcurrent)
 	errors := make([]error, len(b.chains))
@@ -146,7 +169,7 @@ func (b *BatchExecutor) ExecuteAll(input interface{}) []error {
 			sem <- struct{}{}        // Acquire semaphore
 			defer func() { <-sem }() // Release semaphore
 
-			_, err := c.Execute(input)
+			_, err := c.ExecuteContext(ctx, input)
 			if err != nil {
 				errors[idx] = fmt.Errorf("chain %d error: %w", idx, err)
 			}
@@ -193,3 +216,22 @@ func (h *TransformHandler) Execute(ctx *Context) error {
 	ctx.Output = output
 	return nil
 }
+
+// GenerateHandler sends the step input as a prompt to a generator
+type GenerateHandler struct {
+	Generator generation.Generator
+	Options   llm.GenerateOptions
+}
+
+func (h *GenerateHandler) Execute(ctx *Context) error {
+	runCtx := ctx.Ctx
+	if runCtx == nil {
+		runCtx = context.Background()
+	}
+	output, err := generation.Generate(runCtx, h.Generator, fmt.Sprint(ctx.Input), h.Options)
+	if err != nil {
+		return err
+	}
+	ctx.Output = output
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"sync"
 	"text/template"
 	"threshAI/internal/core/plugin"
+	"threshAI/pkg/llm"
 	"time"
 )
 
@@ -119,6 +120,33 @@ func (p *TemplatePlugin) Health() *plugin.HealthStatus {
 	}
 }
 
+// Tools implements ToolPlugin interface, letting models render the template
+func (p *TemplatePlugin) Tools() []llm.Tool {
+	return []llm.Tool{{
+		Name:        "process_template",
+		Description: "Render the " + p.id + " template with the given variables",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"variables": {
+					"type": "object",
+					"description": "Template variables, overriding the configured ones",
+					"additionalProperties": {"type": "string"}
+				}
+			}
+		}`),
+		Handler: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+			var args struct {
+				Variables map[string]interface{} `json:"variables"`
+			}
+			if err := json.Unmarshal(arguments, &args); err != nil {
+				return "", fmt.Errorf("invalid arguments: %v", err)
+			}
+			return p.ProcessTemplate(args.Variables)
+		},
+	}}
+}
+
 // ProcessTemplate applies the template with given variables
 func (p *TemplatePlugin) ProcessTemplate(vars map[string]interface{}) (string, error) {
 	p.mutex.RLock()
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"sync"
 	"threshAI/internal/telemetry"
+	"threshAI/pkg/core/generation"
+	"threshAI/pkg/llm"
 )
 
 // NewPluginManager creates a new plugin manager
@@ -31,9 +33,61 @@ func (pm *PluginManager) LoadPlugin(pluginID string, plugin Plugin, config json.
 		return fmt.Errorf("failed to register plugin %s: %v", pluginID, err)
 	}
 
+	if pp, ok := plugin.(ProviderPlugin); ok {
+		if err := registerProviders(pp.Providers()); err != nil {
+			pm.registry.Unregister(plugin.ID())
+			return fmt.Errorf("failed to register providers for plugin %s: %v", pluginID, err)
+		}
+	}
+
+	if tp, ok := plugin.(ToolPlugin); ok {
+		if err := registerTools(tp.Tools()); err != nil {
+			unregisterProviders(plugin)
+			pm.registry.Unregister(plugin.ID())
+			return fmt.Errorf("failed to register tools for plugin %s: %v", pluginID, err)
+		}
+	}
+
+	return nil
+}
+
+// registerProviders adds all providers to the generation registry, rolling
+// back the ones already added if any of them fails
+func registerProviders(providers []generation.Provider) error {
+	for i, p := range providers {
+		if err := generation.Register(p); err != nil {
+			for _, added := range providers[:i] {
+				generation.Unregister(added.Name)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// registerTools adds all tools to the generation tool registry, rolling back
+// the ones already added if any of them fails
+func registerTools(tools []llm.Tool) error {
+	for i, tool := range tools {
+		if err := generation.RegisterTool(tool); err != nil {
+			for _, added := range tools[:i] {
+				generation.UnregisterTool(added.Name)
+			}
+			return err
+		}
+	}
 	return nil
 }
 
+// unregisterProviders removes the providers contributed by a plugin
+func unregisterProviders(plugin Plugin) {
+	if pp, ok := plugin.(ProviderPlugin); ok {
+		for _, p := range pp.Providers() {
+			generation.Unregister(p.Name)
+		}
+	}
+}
+
 // StartPlugin starts a specific plugin
 func (pm *PluginManager) StartPlugin(pluginID string) error {
 	plugin, err := pm.registry.Get(pluginID)
@@ -56,12 +110,27 @@ func (pm *PluginManager) StopPlugin(pluginID string) error {
 	return plugin.Stop(ctx)
 }
 
-// UnloadPlugin stops and unregisters a plugin
+// UnloadPlugin stops and unregisters a plugin along with any providers and
+// tools it contributed
 func (pm *PluginManager) UnloadPlugin(pluginID string) error {
+	plugin, err := pm.registry.Get(pluginID)
+	if err != nil {
+		return err
+	}
 	if err := pm.StopPlugin(pluginID); err != nil {
 		return err
 	}
-	return pm.registry.Unregister(pluginID)
+	if err := pm.registry.Unregister(pluginID); err != nil {
+		return err
+	}
+
+	unregisterProviders(plugin)
+	if tp, ok := plugin.(ToolPlugin); ok {
+		for _, tool := range tp.Tools() {
+			generation.UnregisterTool(tool.Name)
+		}
+	}
+	return nil
 }
 
 // GetPluginStatus returns the current health status of a plugin
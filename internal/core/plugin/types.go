@@ -3,6 +3,9 @@ package plugin
 import (
 	"context"
 	"encoding/json"
+
+	"threshAI/pkg/core/generation"
+	"threshAI/pkg/llm"
 )
 
 // Plugin represents a loadable extension that can modify or enhance system behavior
@@ -23,6 +26,25 @@ type Plugin interface {
 	Health() *HealthStatus
 }
 
+// ProviderPlugin is implemented by plugins that contribute LLM providers.
+// The providers are registered when the plugin loads and removed when it is
+// unloaded.
+type ProviderPlugin interface {
+	Plugin
+
+	// Providers returns the generation providers supplied by the plugin
+	Providers() []generation.Provider
+}
+
+// ToolPlugin is implemented by plugins that expose functions to models. The
+// tools are registered when the plugin loads and removed when it is unloaded.
+type ToolPlugin interface {
+	Plugin
+
+	// Tools returns the tools supplied by the plugin; each needs a handler
+	Tools() []llm.Tool
+}
+
 // HealthStatus represents the health of a plugin
 type HealthStatus struct {
 	Healthy bool              `json:"healthy"`
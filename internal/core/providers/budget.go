@@ -0,0 +1,95 @@
+package providers
+
+import (
+	"fmt"
+
+	"threshAI/internal/core/config"
+	"threshAI/pkg/core/generation"
+	"threshAI/pkg/llm/budget"
+	"threshAI/pkg/llm/tokenizer"
+	"threshAI/pkg/llm/transformer"
+)
+
+// Budget returns the context budget of requests to the named provider. An
+// empty model uses the provider's configured model and a completion of zero
+// reserves the provider's configured max_tokens. Tokens are counted with the
+// model's tokenizer when it is known, and estimated otherwise. The failover
+// provider gets the budget of the backend with the smallest window, since any
+// of them may answer.
+func Budget(cfg *config.Config, provider, model string, completion int) (budget.Budget, error) {
+	if provider == Failover && len(cfg.Failover) > 0 {
+		var tightest budget.Budget
+		for i, entry := range cfg.Failover {
+			b, err := backendBudget(cfg, entry.Provider, entry.Model, completion)
+			if err != nil {
+				return budget.Budget{}, err
+			}
+			if i == 0 || b.Prompt() < tightest.Prompt() {
+				tightest = b
+			}
+		}
+		return tightest, nil
+	}
+	return backendBudget(cfg, provider, model, completion)
+}
+
+func backendBudget(cfg *config.Config, provider, model string, completion int) (budget.Budget, error) {
+	if model == "" {
+		model = defaultModel(cfg, provider)
+	}
+	if completion <= 0 {
+		completion = configuredMaxTokens(cfg, provider)
+	}
+
+	window := cfg.ContextWindows[model]
+	if window <= 0 && generation.ProviderType(provider) == generation.ProviderTransformer {
+		window = transformer.DefaultConfig().MaxContext
+	}
+	b := budget.New(model, window, completion)
+
+	tok, err := modelTokenizer(cfg, provider, model)
+	if err != nil {
+		return budget.Budget{}, err
+	}
+	if tok != nil {
+		b.Counter = budget.TokenizerCounter{Tokenizer: tok}
+	}
+	return b, nil
+}
+
+// modelTokenizer loads the tokenizer configured for model under tokenizers,
+// or the transformer provider's own. It returns nil when the model's
+// tokenizer isn't known.
+func modelTokenizer(cfg *config.Config, provider, model string) (*tokenizer.Tokenizer, error) {
+	if files, ok := cfg.Tokenizers[model]; ok {
+		tok, err := transformer.LoadTokenizer(transformer.Config{
+			TokenizerType:   "bpe",
+			VocabPath:       files.Vocab,
+			MergePath:       files.Merges,
+			TokenizerConfig: files.Config,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("tokenizer of %s: %w", model, err)
+		}
+		return tok, nil
+	}
+	if generation.ProviderType(provider) == generation.ProviderTransformer {
+		return transformer.LoadTokenizer(transformer.DefaultConfig())
+	}
+	return nil, nil
+}
+
+// configuredMaxTokens returns the max_tokens configured for a provider, or
+// zero when unset
+func configuredMaxTokens(cfg *config.Config, provider string) int {
+	switch generation.ProviderType(provider) {
+	case generation.ProviderOllama:
+		return cfg.Ollama.MaxTokens
+	case generation.ProviderDeepSeek:
+		return cfg.DeepSeek.MaxTokens
+	case generation.ProviderOpenAI:
+		return cfg.OpenAI.MaxTokens
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,43 @@
+package providers
+
+import (
+	"testing"
+
+	"threshAI/internal/core/config"
+	"threshAI/pkg/llm/budget"
+)
+
+func TestBudgetUsesConfiguredTokenizer(t *testing.T) {
+	cfg := &config.Config{
+		Tokenizers: map[string]config.TokenizerFiles{
+			"gpt2": {
+				Vocab:  "../../../pkg/llm/tokenizer/testdata/gpt2/vocab.json",
+				Merges: "../../../pkg/llm/tokenizer/testdata/gpt2/merges.txt",
+			},
+		},
+	}
+	b, err := Budget(cfg, "openai", "gpt2", 0)
+	if err != nil {
+		t.Fatalf("Budget() error = %v", err)
+	}
+	if _, ok := b.Counter.(budget.TokenizerCounter); !ok {
+		t.Fatalf("Budget() counter = %T, want TokenizerCounter", b.Counter)
+	}
+	if got := b.Count("it, of the!"); got != 5 {
+		t.Errorf("Count() = %d, want 5", got)
+	}
+
+	// Models without a tokenizer fall back to the estimate
+	b, err = Budget(cfg, "openai", "gpt-4o", 0)
+	if err != nil {
+		t.Fatalf("Budget() error = %v", err)
+	}
+	if _, ok := b.Counter.(budget.HeuristicCounter); !ok {
+		t.Errorf("Budget() counter = %T, want HeuristicCounter", b.Counter)
+	}
+
+	cfg.Tokenizers["gpt2"] = config.TokenizerFiles{Vocab: "missing.json", Merges: "missing.txt"}
+	if _, err := Budget(cfg, "openai", "gpt2", 0); err == nil {
+		t.Error("Budget() with missing tokenizer files succeeded")
+	}
+}
@@ -0,0 +1,66 @@
+package providers
+
+import (
+	"fmt"
+	"time"
+
+	"threshAI/internal/core/config"
+	"threshAI/pkg/cache"
+)
+
+// NewCache builds the response cache configured in cfg
+func NewCache(cfg *config.Config) (cache.Cache, error) {
+	switch cfg.Cache.Backend {
+	case "redis":
+		return NewRedisCache(cfg)
+	case "file":
+		return NewFileCache(cfg)
+	}
+	return cache.NewInMemoryCacheWithConfig(cache.InMemoryConfig{
+		MaxEntries: cfg.Cache.MaxEntries,
+		MaxBytes:   cfg.Cache.MaxBytes,
+	}), nil
+}
+
+// NewFileCache builds the on-disk cache configured in cfg, whether or not it
+// is the selected backend
+func NewFileCache(cfg *config.Config) (*cache.FileCache, error) {
+	return cache.NewFileCache(cache.FileConfig{
+		Dir:        cfg.Cache.Dir,
+		MaxEntries: cfg.Cache.MaxEntries,
+		MaxBytes:   cfg.Cache.MaxBytes,
+	})
+}
+
+// NewRedisCache builds a cache on the Redis server configured in cfg,
+// whether or not it is the selected backend
+func NewRedisCache(cfg *config.Config) (*cache.RedisCache, error) {
+	r := cfg.Cache.Redis
+	rc := cache.RedisConfig{
+		Addr:      r.Addr,
+		Username:  r.Username,
+		Password:  r.Password,
+		DB:        r.DB,
+		Namespace: r.Namespace,
+		Compress:  r.Compress,
+	}
+	for _, timeout := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"dial_timeout", r.DialTimeout, &rc.DialTimeout},
+		{"read_timeout", r.ReadTimeout, &rc.ReadTimeout},
+		{"write_timeout", r.WriteTimeout, &rc.WriteTimeout},
+	} {
+		if timeout.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(timeout.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis config: invalid %s %q: %v", timeout.name, timeout.value, err)
+		}
+		*timeout.dst = d
+	}
+	return cache.NewRedisCache(rc), nil
+}
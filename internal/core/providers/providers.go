@@ -0,0 +1,327 @@
+// Package providers builds generators for the configured LLM providers
+package providers
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"threshAI/internal/core/config"
+	"threshAI/internal/core/usage"
+	"threshAI/internal/telemetry"
+	"threshAI/pkg/core/generation"
+	"threshAI/pkg/llm"
+	"threshAI/pkg/llm/admission"
+	"threshAI/pkg/llm/cassette"
+	"threshAI/pkg/llm/deepseek"
+	"threshAI/pkg/llm/ollama"
+	"threshAI/pkg/llm/openai"
+	"threshAI/pkg/llm/transformer"
+	"threshAI/pkg/llm/transport"
+	"threshAI/pkg/nlpvalidator"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Failover is the name of the provider that tries the configured failover
+// chain in order
+const Failover = "failover"
+
+// New builds the generator for the named provider from cfg. Requests are
+// accounted to tracker unless it is nil. With a cassette configured, requests
+// are recorded to it or answered from it without contacting the provider.
+func New(cfg *config.Config, provider string, tracker *usage.Tracker) (generation.Generator, error) {
+	var gen generation.Generator
+	switch {
+	case cfg.Cassette.Mode == cassette.ModeReplay:
+		c, err := openCassette(cfg.Cassette.Path)
+		if err != nil {
+			return nil, err
+		}
+		gen = cassette.NewReplayer(c, provider)
+	case provider == Failover:
+		// Each backend of the chain records its own requests
+		return NewFailover(cfg, tracker)
+	default:
+		var err error
+		if gen, err = newGenerator(cfg, provider); err != nil {
+			return nil, err
+		}
+		if cfg.Cassette.Mode == cassette.ModeRecord {
+			c, err := openCassette(cfg.Cassette.Path)
+			if err != nil {
+				return nil, err
+			}
+			gen = cassette.NewRecorder(gen, c, provider)
+		}
+		if limit, ok := cfg.Concurrency[provider]; ok && limit.MaxConcurrent > 0 {
+			controller, err := admissionController(provider, limit)
+			if err != nil {
+				return nil, err
+			}
+			gen = generation.NewLimiter(gen, controller)
+		}
+	}
+
+	// Identical concurrent requests share one upstream call, which is
+	// accounted once and takes a single admission slot
+	model := defaultModel(cfg, provider)
+	if tracker != nil {
+		gen = tracker.Wrap(gen, provider, model)
+	}
+	if cfg.SemanticCache.Enabled {
+		// Answers served from the semantic cache cost no tokens
+		var err error
+		if gen, err = newSemanticCache(cfg, gen, provider, model); err != nil {
+			return nil, err
+		}
+	}
+	return generation.NewDeduplicator(gen, provider, model, telemetry.GetMetrics().RecordDeduplicated), nil
+}
+
+var (
+	cassettesMu sync.Mutex
+	cassettes   = make(map[string]*cassette.Cassette)
+)
+
+// openCassette loads the cassette at path once per process, so that every
+// generator built from the same config shares its recordings
+func openCassette(path string) (*cassette.Cassette, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cassette path %s: %v", path, err)
+	}
+
+	cassettesMu.Lock()
+	defer cassettesMu.Unlock()
+
+	if c, ok := cassettes[abs]; ok {
+		return c, nil
+	}
+	c, err := cassette.Load(abs)
+	if err != nil {
+		return nil, err
+	}
+	cassettes[abs] = c
+	return c, nil
+}
+
+// NewTracker creates a usage tracker for a new session, priced from cfg and
+// writing to the default ledger
+func NewTracker(cfg *config.Config) *usage.Tracker {
+	pricing := make(map[string]usage.Price, len(cfg.Pricing))
+	for model, price := range cfg.Pricing {
+		pricing[model] = usage.Price{Prompt: price.Prompt, Completion: price.Completion}
+	}
+	return usage.NewTracker(pricing, usage.DefaultLedgerPath())
+}
+
+func newGenerator(cfg *config.Config, provider string) (generation.Generator, error) {
+	switch generation.ProviderType(provider) {
+	case generation.ProviderOllama:
+		tc, err := transportConfig(cfg.Ollama.MaxRetries, cfg.Ollama.RequestTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ollama config: %v", err)
+		}
+		return generation.NewGenerator(generation.ProviderOllama, ollama.Config{
+			BaseURL:        cfg.Ollama.URL,
+			EmbeddingModel: cfg.Ollama.EmbeddingModel,
+			Transport:      tc,
+			Options: llm.GenerateOptions{
+				Model:       cfg.Ollama.Model,
+				MaxTokens:   cfg.Ollama.MaxTokens,
+				Temperature: cfg.Ollama.Temperature,
+			},
+		}, nil)
+	case generation.ProviderDeepSeek:
+		if cfg.DeepSeek.APIKey == "" {
+			return nil, fmt.Errorf("DEEPSEEK_API_KEY environment variable is required for the deepseek provider")
+		}
+		tc, err := transportConfig(cfg.DeepSeek.MaxRetries, cfg.DeepSeek.RequestTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid deepseek config: %v", err)
+		}
+		var cacheTTL time.Duration
+		if cfg.DeepSeek.CacheTTL != "" {
+			if cacheTTL, err = time.ParseDuration(cfg.DeepSeek.CacheTTL); err != nil {
+				return nil, fmt.Errorf("invalid deepseek cache_ttl %q: %v", cfg.DeepSeek.CacheTTL, err)
+			}
+		}
+		responseCache, err := NewCache(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return generation.NewGenerator(generation.ProviderDeepSeek, deepseek.Config{
+			BaseURL:   cfg.DeepSeek.BaseURL,
+			APIKey:    cfg.DeepSeek.APIKey,
+			Transport: tc,
+			CacheTTL:  cacheTTL,
+			Options: llm.GenerateOptions{
+				Model:       cfg.DeepSeek.Model,
+				MaxTokens:   cfg.DeepSeek.MaxTokens,
+				Temperature: cfg.DeepSeek.Temperature,
+			},
+		}, responseCache)
+	case generation.ProviderOpenAI:
+		tc, err := transportConfig(cfg.OpenAI.MaxRetries, cfg.OpenAI.RequestTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid openai config: %v", err)
+		}
+		return generation.NewGenerator(generation.ProviderOpenAI, openai.Config{
+			BaseURL:        cfg.OpenAI.BaseURL,
+			APIKey:         cfg.OpenAI.APIKey,
+			Headers:        cfg.OpenAI.Headers,
+			EmbeddingModel: cfg.OpenAI.EmbeddingModel,
+			Transport:      tc,
+			Options: llm.GenerateOptions{
+				Model:       cfg.OpenAI.Model,
+				MaxTokens:   cfg.OpenAI.MaxTokens,
+				Temperature: cfg.OpenAI.Temperature,
+			},
+		}, nil)
+	case generation.ProviderTransformer:
+		return generation.NewGenerator(generation.ProviderTransformer, transformer.DefaultConfig(), nil)
+	default:
+		// Providers registered at runtime are configured from their raw section
+		raw, err := yaml.Marshal(cfg.Providers[provider])
+		if err != nil {
+			return nil, fmt.Errorf("invalid config for provider %s: %v", provider, err)
+		}
+		return generation.NewGeneratorFromConfig(generation.ProviderType(provider), raw, nil)
+	}
+}
+
+// Hashing names the offline hashing embedder
+const Hashing = "hashing"
+
+// NewEmbedder builds the embedder configured in cfg
+func NewEmbedder(cfg *config.Config) (generation.Embedder, error) {
+	provider := cfg.Embeddings.Provider
+	if provider == "" || provider == Hashing {
+		return nlpvalidator.NewHashingEmbedder(nlpvalidator.DefaultEmbeddingDim), nil
+	}
+
+	if cfg.Cassette.Mode == cassette.ModeReplay {
+		c, err := openCassette(cfg.Cassette.Path)
+		if err != nil {
+			return nil, err
+		}
+		return cassette.NewReplayer(c, provider), nil
+	}
+
+	gen, err := newGenerator(cfg, provider)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := gen.(generation.Embedder); !ok {
+		return nil, fmt.Errorf("provider %s does not support embeddings", provider)
+	}
+	if cfg.Cassette.Mode == cassette.ModeRecord {
+		c, err := openCassette(cfg.Cassette.Path)
+		if err != nil {
+			return nil, err
+		}
+		return cassette.NewRecorder(gen, c, provider), nil
+	}
+	return gen.(generation.Embedder), nil
+}
+
+// defaultModel returns the model a provider uses for requests that don't
+// name one
+func defaultModel(cfg *config.Config, provider string) string {
+	switch generation.ProviderType(provider) {
+	case generation.ProviderOllama:
+		if cfg.Ollama.Model != "" {
+			return cfg.Ollama.Model
+		}
+		return ollama.DefaultModel
+	case generation.ProviderDeepSeek:
+		if cfg.DeepSeek.Model != "" {
+			return cfg.DeepSeek.Model
+		}
+		return deepseek.DefaultModel
+	case generation.ProviderOpenAI:
+		return cfg.OpenAI.Model
+	case generation.ProviderTransformer:
+		return "local"
+	default:
+		return ""
+	}
+}
+
+// transportConfig maps the max_retries and request_timeout keys of a provider
+// section onto the shared transport settings
+func transportConfig(maxRetries int, requestTimeout string) (transport.Config, error) {
+	tc := transport.Config{MaxRetries: maxRetries}
+	if requestTimeout != "" {
+		timeout, err := time.ParseDuration(requestTimeout)
+		if err != nil {
+			return tc, fmt.Errorf("invalid request_timeout %q: %v", requestTimeout, err)
+		}
+		tc.Timeout = timeout
+	}
+	return tc, nil
+}
+
+// newSemanticCache wraps gen in a semantic cache embedding prompts with the
+// configured embedder
+func newSemanticCache(cfg *config.Config, gen generation.Generator, provider, model string) (generation.Generator, error) {
+	sc := generation.SemanticConfig{
+		Threshold:  cfg.SemanticCache.Threshold,
+		MaxEntries: cfg.SemanticCache.MaxEntries,
+	}
+	if cfg.SemanticCache.TTL != "" {
+		ttl, err := time.ParseDuration(cfg.SemanticCache.TTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid semantic_cache config: invalid ttl %q: %v", cfg.SemanticCache.TTL, err)
+		}
+		sc.TTL = ttl
+	}
+	embedder, err := NewEmbedder(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("semantic cache: %w", err)
+	}
+	return generation.NewSemanticCache(gen, embedder, provider, model, sc, telemetry.GetMetrics().ObserveSemanticLookup), nil
+}
+
+// admissionController returns the controller shared by every generator of a
+// provider, so that the limit holds across the web handlers and chains of
+// one process
+func admissionController(provider string, limit config.ConcurrencyLimit) (*admission.Controller, error) {
+	ac := admission.Config{MaxConcurrent: limit.MaxConcurrent, MaxQueue: limit.MaxQueue}
+	if limit.QueueTimeout != "" {
+		timeout, err := time.ParseDuration(limit.QueueTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid concurrency config for %s: invalid queue_timeout %q: %v", provider, limit.QueueTimeout, err)
+		}
+		ac.QueueTimeout = timeout
+	}
+	return admission.For(provider, ac, telemetry.GetMetrics()), nil
+}
+
+// NewFailover builds a failover generator over the chain configured in cfg.
+// Each backend accounts its own requests to tracker unless it is nil.
+func NewFailover(cfg *config.Config, tracker *usage.Tracker) (*generation.FailoverGenerator, error) {
+	if len(cfg.Failover) == 0 {
+		return nil, fmt.Errorf("no failover chain configured")
+	}
+
+	backends := make([]generation.Backend, 0, len(cfg.Failover))
+	for _, entry := range cfg.Failover {
+		if entry.Provider == Failover {
+			return nil, fmt.Errorf("failover chain cannot contain the failover provider")
+		}
+		gen, err := New(cfg, entry.Provider, tracker)
+		if err != nil {
+			return nil, fmt.Errorf("failover backend %s: %w", entry.Provider, err)
+		}
+
+		name := entry.Provider
+		if entry.Model != "" {
+			name += "/" + entry.Model
+		}
+		backends = append(backends, generation.Backend{Name: name, Generator: gen, Model: entry.Model})
+	}
+	return generation.NewFailover(backends...)
+}
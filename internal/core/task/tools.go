@@ -0,0 +1,116 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"threshAI/pkg/llm"
+
+	"github.com/go-cmd/cmd"
+)
+
+// maxOutputLines bounds the lines of stdout and stderr reported to a model
+const maxOutputLines = 50
+
+// Tools returns tools letting a model run tasks on tm and read back their
+// status. Only the given commands may be run; without any, no tools are
+// returned.
+func Tools(tm *TaskManager, commands []string) []llm.Tool {
+	if len(commands) == 0 {
+		return nil
+	}
+	allowed := make(map[string]bool, len(commands))
+	for _, command := range commands {
+		allowed[command] = true
+	}
+	var next atomic.Int64
+
+	return []llm.Tool{
+		{
+			Name:        "run_task",
+			Description: "Run a command and return its exit code and output. Allowed commands: " + strings.Join(commands, ", "),
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"command": {"type": "string", "description": "The command to run"},
+					"args": {"type": "array", "items": {"type": "string"}, "description": "Command arguments"}
+				},
+				"required": ["command"]
+			}`),
+			Handler: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+				var args struct {
+					Command string   `json:"command"`
+					Args    []string `json:"args"`
+				}
+				if err := json.Unmarshal(arguments, &args); err != nil {
+					return "", fmt.Errorf("invalid arguments: %v", err)
+				}
+				if !allowed[args.Command] {
+					return "", fmt.Errorf("command %q is not allowed", args.Command)
+				}
+
+				taskID := fmt.Sprintf("task-%d", next.Add(1))
+				if err := tm.Execute(taskID, args.Command, args.Args...); err != nil {
+					return "", fmt.Errorf("%s failed: %v", taskID, err)
+				}
+				status, _ := tm.Status(taskID)
+				return formatStatus(taskID, status), nil
+			},
+		},
+		{
+			Name:        "task_status",
+			Description: "Return the exit code and output of a task started with run_task",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"task_id": {"type": "string", "description": "The task ID reported by run_task"}
+				},
+				"required": ["task_id"]
+			}`),
+			Handler: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+				var args struct {
+					TaskID string `json:"task_id"`
+				}
+				if err := json.Unmarshal(arguments, &args); err != nil {
+					return "", fmt.Errorf("invalid arguments: %v", err)
+				}
+				status, ok := tm.Status(args.TaskID)
+				if !ok {
+					return "", fmt.Errorf("unknown task %q", args.TaskID)
+				}
+				return formatStatus(args.TaskID, status), nil
+			},
+		},
+	}
+}
+
+// formatStatus reports a task's exit code and the tail of its output
+func formatStatus(taskID string, status cmd.Status) string {
+	var b strings.Builder
+	state := "running"
+	if status.StopTs > 0 {
+		state = fmt.Sprintf("exited with code %d", status.Exit)
+	}
+	fmt.Fprintf(&b, "%s: %s %s\n", taskID, status.Cmd, state)
+	for _, stream := range []struct {
+		name  string
+		lines []string
+	}{{"stdout", status.Stdout}, {"stderr", status.Stderr}} {
+		if len(stream.lines) == 0 {
+			continue
+		}
+		lines := stream.lines
+		if len(lines) > maxOutputLines {
+			fmt.Fprintf(&b, "%s (last %d of %d lines):\n", stream.name, maxOutputLines, len(lines))
+			lines = lines[len(lines)-maxOutputLines:]
+		} else {
+			fmt.Fprintf(&b, "%s:\n", stream.name)
+		}
+		b.WriteString(strings.Join(lines, "\n"))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
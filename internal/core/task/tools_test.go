@@ -0,0 +1,40 @@
+package task
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTools(t *testing.T) {
+	if tools := Tools(NewManager(time.Second), nil); len(tools) != 0 {
+		t.Errorf("Tools() = %v without allowed commands, want none", tools)
+	}
+
+	tools := Tools(NewManager(10*time.Second), []string{"echo"})
+	if len(tools) != 2 || tools[0].Name != "run_task" || tools[1].Name != "task_status" {
+		t.Fatalf("Tools() = %v, want run_task and task_status", tools)
+	}
+	run, status := tools[0].Handler, tools[1].Handler
+	ctx := context.Background()
+
+	out, err := run(ctx, json.RawMessage(`{"command": "echo", "args": ["hello"]}`))
+	if err != nil {
+		t.Fatalf("run_task error = %v", err)
+	}
+	if !strings.HasPrefix(out, "task-1: echo exited with code 0") || !strings.Contains(out, "stdout:\nhello\n") {
+		t.Errorf("run_task = %q, want the exit code and output of task-1", out)
+	}
+	if again, err := status(ctx, json.RawMessage(`{"task_id": "task-1"}`)); err != nil || again != out {
+		t.Errorf("task_status = %q, %v, want %q", again, err, out)
+	}
+
+	if _, err := run(ctx, json.RawMessage(`{"command": "rm", "args": ["-rf", "/"]}`)); err == nil {
+		t.Error("run_task ran a command that isn't allowed")
+	}
+	if _, err := status(ctx, json.RawMessage(`{"task_id": "task-9"}`)); err == nil {
+		t.Error("task_status succeeded for an unknown task")
+	}
+}
@@ -0,0 +1,119 @@
+package usage
+
+import (
+	"context"
+	"strings"
+
+	"threshAI/pkg/core/generation"
+	"threshAI/pkg/llm"
+	"threshAI/pkg/llm/budget"
+	"threshAI/pkg/llm/tokenizer"
+)
+
+// meter records the usage of every request made through a generator
+type meter struct {
+	generator generation.Generator
+	tracker   *Tracker
+	provider  string
+	model     string
+}
+
+// Wrap returns a generator that accounts every request to the tracker.
+// model is recorded for requests that don't name one. Usage the backend
+// doesn't report is estimated from the prompt and the generated text.
+func (t *Tracker) Wrap(generator generation.Generator, provider, model string) generation.Generator {
+	return &meter{generator: generator, tracker: t, provider: provider, model: model}
+}
+
+// Generate collects the stream when the backend can stream, since only the
+// final chunk of a stream carries the reported usage
+func (m *meter) Generate(ctx context.Context, prompt string, opts llm.GenerateOptions) (string, error) {
+	if _, ok := m.generator.(generation.StreamGenerator); ok {
+		stream, err := m.GenerateStream(ctx, prompt, opts)
+		if err != nil {
+			return "", err
+		}
+		return generation.Collect(stream, nil)
+	}
+
+	out, err := m.generator.Generate(ctx, prompt, opts)
+	if err != nil {
+		return "", err
+	}
+	m.record(opts, nil, tokenizer.EstimateTokens(prompt), out)
+	return out, nil
+}
+
+func (m *meter) GenerateStream(ctx context.Context, prompt string, opts llm.GenerateOptions) (<-chan llm.Chunk, error) {
+	stream, err := generation.Stream(ctx, m.generator, prompt, opts)
+	if err != nil {
+		return nil, err
+	}
+	return m.observe(ctx, stream, opts, tokenizer.EstimateTokens(prompt)), nil
+}
+
+func (m *meter) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	resp, err := generation.Chat(ctx, m.generator, req)
+	if err != nil {
+		return nil, err
+	}
+	m.record(req.Options, resp.Usage, estimateMessages(req.Messages), resp.Message.Content)
+	return resp, nil
+}
+
+func (m *meter) ChatStream(ctx context.Context, req llm.ChatRequest) (<-chan llm.Chunk, error) {
+	stream, err := generation.ChatStream(ctx, m.generator, req)
+	if err != nil {
+		return nil, err
+	}
+	return m.observe(ctx, stream, req.Options, estimateMessages(req.Messages)), nil
+}
+
+// observe forwards a stream, recording its usage once it completes or fails
+func (m *meter) observe(ctx context.Context, stream <-chan llm.Chunk, opts llm.GenerateOptions, promptTokens int) <-chan llm.Chunk {
+	ch := make(chan llm.Chunk)
+	go func() {
+		defer close(ch)
+
+		var completion strings.Builder
+		for chunk := range stream {
+			completion.WriteString(chunk.Content)
+			switch {
+			case chunk.Done:
+				m.record(opts, chunk.Usage, promptTokens, completion.String())
+			case chunk.Err != nil:
+				// Tokens generated before the failure are still billed
+				m.record(opts, nil, promptTokens, completion.String())
+			}
+
+			select {
+			case ch <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+func (m *meter) record(opts llm.GenerateOptions, reported *llm.Usage, promptTokens int, completion string) {
+	model := opts.Model
+	if model == "" {
+		model = m.model
+	}
+
+	if reported != nil {
+		m.tracker.Record(m.provider, model, *reported, false)
+		return
+	}
+	completionTokens := tokenizer.EstimateTokens(completion)
+	m.tracker.Record(m.provider, model, llm.Usage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+	}, true)
+}
+
+func estimateMessages(messages []llm.Message) int {
+	return budget.CountMessages(budget.Estimate, messages)
+}
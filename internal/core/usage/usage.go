@@ -0,0 +1,238 @@
+// Package usage accounts for the tokens and cost of LLM requests. A Tracker
+// keeps per-session totals, feeds the telemetry counters and appends every
+// request to a ledger that outlives the process.
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"threshAI/internal/telemetry"
+	"threshAI/pkg/llm"
+	"threshAI/pkg/logging"
+)
+
+// Price is the cost of a model in US dollars per million tokens
+type Price struct {
+	Prompt     float64
+	Completion float64
+}
+
+// Cost returns the cost of the given usage
+func (p Price) Cost(u llm.Usage) float64 {
+	return (float64(u.PromptTokens)*p.Prompt + float64(u.CompletionTokens)*p.Completion) / 1e6
+}
+
+// Record is a single accounted request as stored in the ledger
+type Record struct {
+	Time             time.Time `json:"time"`
+	Session          string    `json:"session"`
+	Provider         string    `json:"provider"`
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	Estimated        bool      `json:"estimated,omitempty"`
+	Cost             float64   `json:"cost_usd"`
+}
+
+// Totals aggregates the requests made to one provider and model
+type Totals struct {
+	Provider         string
+	Model            string
+	Requests         int
+	Estimated        int // Requests whose token counts were estimated
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64
+}
+
+func (t *Totals) add(r Record) {
+	t.Requests++
+	if r.Estimated {
+		t.Estimated++
+	}
+	t.PromptTokens += r.PromptTokens
+	t.CompletionTokens += r.CompletionTokens
+	t.Cost += r.Cost
+}
+
+// Tracker accounts for the requests of one session
+type Tracker struct {
+	pricing map[string]Price
+	ledger  string
+	session string
+	metrics *telemetry.PipelineMetrics
+
+	mu     sync.Mutex
+	totals map[string]*Totals
+}
+
+// NewTracker creates a tracker for a new session. Prices are looked up by
+// "provider/model" and then by model name; unpriced models cost nothing.
+// An empty ledger path keeps records in memory only.
+func NewTracker(pricing map[string]Price, ledgerPath string) *Tracker {
+	return &Tracker{
+		pricing: pricing,
+		ledger:  ledgerPath,
+		session: fmt.Sprintf("%s-%d", time.Now().Format("20060102T150405"), os.Getpid()),
+		metrics: telemetry.GetMetrics(),
+		totals:  make(map[string]*Totals),
+	}
+}
+
+// DefaultLedgerPath returns the ledger shared by CLI and web server
+func DefaultLedgerPath() string {
+	return filepath.Join(os.Getenv("HOME"), ".thresh", "usage.jsonl")
+}
+
+// Session returns the session identifier recorded with every request
+func (t *Tracker) Session() string {
+	return t.session
+}
+
+// Record accounts for a request. Estimated marks token counts that were
+// not reported by the backend.
+func (t *Tracker) Record(provider, model string, u llm.Usage, estimated bool) Record {
+	r := Record{
+		Time:             time.Now().UTC(),
+		Session:          t.session,
+		Provider:         provider,
+		Model:            model,
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		Estimated:        estimated,
+		Cost:             t.price(provider, model).Cost(u),
+	}
+
+	source := "reported"
+	if estimated {
+		source = "estimated"
+	}
+	t.metrics.IncrementLLMRequests(provider, model, source)
+	t.metrics.RecordTokens(provider, model, "prompt", r.PromptTokens)
+	t.metrics.RecordTokens(provider, model, "completion", r.CompletionTokens)
+	t.metrics.RecordCost(provider, model, r.Cost)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := provider + "/" + model
+	totals, ok := t.totals[key]
+	if !ok {
+		totals = &Totals{Provider: provider, Model: model}
+		t.totals[key] = totals
+	}
+	totals.add(r)
+
+	if t.ledger != "" {
+		if err := appendRecord(t.ledger, r); err != nil {
+			logging.Logger.Printf("Warning: failed to write usage ledger: %v", err)
+		}
+	}
+	return r
+}
+
+// Totals returns the session totals per provider and model
+func (t *Tracker) Totals() []Totals {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := make([]Totals, 0, len(t.totals))
+	for _, totals := range t.totals {
+		out = append(out, *totals)
+	}
+	sortTotals(out)
+	return out
+}
+
+func (t *Tracker) price(provider, model string) Price {
+	if p, ok := t.pricing[provider+"/"+model]; ok {
+		return p
+	}
+	return t.pricing[model]
+}
+
+// ReadLedger loads all records from a ledger file. A missing ledger holds no
+// records.
+func ReadLedger(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage ledger: %v", err)
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("invalid usage record on line %d: %v", line, err)
+		}
+		records = append(records, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read usage ledger: %v", err)
+	}
+	return records, nil
+}
+
+// Summarize aggregates records per provider and model
+func Summarize(records []Record) []Totals {
+	byKey := make(map[string]*Totals)
+	for _, r := range records {
+		key := r.Provider + "/" + r.Model
+		totals, ok := byKey[key]
+		if !ok {
+			totals = &Totals{Provider: r.Provider, Model: r.Model}
+			byKey[key] = totals
+		}
+		totals.add(r)
+	}
+
+	out := make([]Totals, 0, len(byKey))
+	for _, totals := range byKey {
+		out = append(out, *totals)
+	}
+	sortTotals(out)
+	return out
+}
+
+func sortTotals(totals []Totals) {
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Provider != totals[j].Provider {
+			return totals[i].Provider < totals[j].Provider
+		}
+		return totals[i].Model < totals[j].Model
+	})
+}
+
+func appendRecord(path string, r Record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,110 @@
+package usage
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"threshAI/pkg/core/generation"
+	"threshAI/pkg/llm"
+)
+
+// stubChat answers every chat request with a fixed reply and usage
+type stubChat struct {
+	usage *llm.Usage
+}
+
+func (s *stubChat) Generate(ctx context.Context, prompt string, opts llm.GenerateOptions) (string, error) {
+	return "four token reply here", nil
+}
+
+func (s *stubChat) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	return &llm.ChatResponse{
+		Message: llm.Message{Role: llm.RoleAssistant, Content: "four token reply here"},
+		Usage:   s.usage,
+	}, nil
+}
+
+func (s *stubChat) ChatStream(ctx context.Context, req llm.ChatRequest) (<-chan llm.Chunk, error) {
+	ch := make(chan llm.Chunk, 3)
+	ch <- llm.Chunk{Content: "four token "}
+	ch <- llm.Chunk{Content: "reply here"}
+	ch <- llm.Chunk{Done: true, Usage: s.usage}
+	close(ch)
+	return ch, nil
+}
+
+func TestTrackerPricingAndLedger(t *testing.T) {
+	ledger := filepath.Join(t.TempDir(), "usage.jsonl")
+	tracker := NewTracker(map[string]Price{
+		"deepseek-chat":      {Prompt: 0.27, Completion: 1.10},
+		"openai/gpt-4o-mini": {Prompt: 0.15, Completion: 0.60},
+	}, ledger)
+
+	gen := tracker.Wrap(&stubChat{usage: &llm.Usage{PromptTokens: 1000, CompletionTokens: 500, TotalTokens: 1500}}, "deepseek", "deepseek-chat")
+	req := llm.ChatRequest{Messages: []llm.Message{{Role: llm.RoleUser, Content: "Hi"}}}
+	if _, err := generation.Chat(context.Background(), gen, req); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	stream, err := generation.ChatStream(context.Background(), gen, req)
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+	if _, err := generation.Collect(stream, nil); err != nil {
+		t.Fatalf("stream error = %v", err)
+	}
+
+	totals := tracker.Totals()
+	if len(totals) != 1 {
+		t.Fatalf("Totals() = %+v, want one provider/model", totals)
+	}
+	got := totals[0]
+	if got.Requests != 2 || got.PromptTokens != 2000 || got.CompletionTokens != 1000 || got.Estimated != 0 {
+		t.Errorf("Totals() = %+v, want 2 reported requests with 2000/1000 tokens", got)
+	}
+	// 2 * (1000 * 0.27 + 500 * 1.10) / 1e6
+	if want := 0.00164; math.Abs(got.Cost-want) > 1e-9 {
+		t.Errorf("Cost = %v, want %v", got.Cost, want)
+	}
+
+	records, err := ReadLedger(ledger)
+	if err != nil {
+		t.Fatalf("ReadLedger() error = %v", err)
+	}
+	if len(records) != 2 || records[0].Session != tracker.Session() {
+		t.Fatalf("ledger = %+v, want 2 records of this session", records)
+	}
+	if summary := Summarize(records); len(summary) != 1 || summary[0] != got {
+		t.Errorf("Summarize() = %+v, want %+v", summary, got)
+	}
+}
+
+func TestMeterEstimatesMissingUsage(t *testing.T) {
+	tracker := NewTracker(nil, "")
+	gen := tracker.Wrap(&stubChat{}, "ollama", "llama2")
+
+	_, err := generation.Chat(context.Background(), gen, llm.ChatRequest{
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "How are you today?"}},
+		Options:  llm.GenerateOptions{Model: "llama3"},
+	})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	totals := tracker.Totals()
+	if len(totals) != 1 {
+		t.Fatalf("Totals() = %+v, want one provider/model", totals)
+	}
+	got := totals[0]
+	if got.Model != "llama3" {
+		t.Errorf("Model = %q, want the requested model", got.Model)
+	}
+	if got.Estimated != 1 || got.PromptTokens == 0 || got.CompletionTokens == 0 {
+		t.Errorf("Totals() = %+v, want one estimated request with non-zero tokens", got)
+	}
+	if got.Cost != 0 {
+		t.Errorf("Cost = %v, want 0 for an unpriced model", got.Cost)
+	}
+}
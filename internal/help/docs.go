@@ -36,6 +36,34 @@ var commandHelp = map[string]CommandHelp{
 		},
 		Category: "core",
 	},
+	"generate": {
+		Command:     "generate",
+		Usage:       "thresh generate [command] [flags]",
+		Description: "Generate memes, vectors and crisis protocols",
+		Examples: []string{
+			"thresh generate meme --input joke.txt",
+			"thresh generate vector --quantum",
+			"thresh generate crisis --severity 5 --brutal 2",
+		},
+		SubCommands: []CommandHelp{
+			{
+				Command:     "meme",
+				Usage:       "thresh generate meme --input [file|text]",
+				Description: "Generate a meme from an input file or text",
+			},
+			{
+				Command:     "vector",
+				Usage:       "thresh generate vector [--quantum]",
+				Description: "Generate an 11D embedding vector",
+			},
+			{
+				Command:     "crisis",
+				Usage:       "thresh generate crisis --severity [1-5]",
+				Description: "Generate a crisis protocol at the given severity",
+			},
+		},
+		Category: "core",
+	},
 	"config": {
 		Command:     "config",
 		Usage:       "thresh config [command]",
@@ -80,8 +108,13 @@ var commandHelp = map[string]CommandHelp{
 			},
 			{
 				Command:     "metrics",
-				Usage:       "thresh system metrics",
-				Description: "Display system metrics",
+				Usage:       "thresh system metrics [--since 24h] [--session ID]",
+				Description: "Display LLM token usage and cost per provider and model",
+			},
+			{
+				Command:     "providers",
+				Usage:       "thresh system providers",
+				Description: "List registered LLM providers and their capabilities",
 			},
 		},
 		Category: "system",
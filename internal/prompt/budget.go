@@ -0,0 +1,28 @@
+package prompt
+
+import (
+	"fmt"
+
+	"threshAI/pkg/llm/budget"
+)
+
+// FitToBudget trims the inputs of p until the rendered prompt fits the
+// prompt budget of b, cutting the repository content first and then the
+// focus areas. The system context, process flow and user request are kept
+// whole. It returns the tokens the rendered prompt takes.
+func FitToBudget(p *TaskPrompt, b budget.Budget) (int, error) {
+	tokens := b.Count(ExecuteChain(p))
+	for _, input := range []*string{&p.Inputs.RepoContent, &p.Inputs.FocusAreas} {
+		// Token counts of parts don't add up exactly to the count of the
+		// whole, so cut until the whole fits
+		for tokens > b.Prompt() && *input != "" {
+			over := tokens - b.Prompt()
+			*input = b.Truncate(*input, b.Count(*input)-over)
+			tokens = b.Count(ExecuteChain(p))
+		}
+	}
+	if tokens > b.Prompt() {
+		return tokens, fmt.Errorf("%w: %d tokens with the inputs trimmed, %d available", budget.ErrOverBudget, tokens, b.Prompt())
+	}
+	return tokens, nil
+}
@@ -0,0 +1,84 @@
+package prompt
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"threshAI/pkg/llm/budget"
+)
+
+// wordCounter counts one token per word, so test budgets are easy to follow
+type wordCounter struct{}
+
+func (wordCounter) CountTokens(text string) int {
+	return len(strings.Fields(text))
+}
+
+func words(word string, n int) string {
+	return strings.TrimSpace(strings.Repeat(word+" ", n))
+}
+
+func testPrompt() *TaskPrompt {
+	p := &TaskPrompt{System: "You review code", ProcessFlow: "Read then report"}
+	p.Inputs.UserRequest = "find the bugs"
+	p.Inputs.RepoContent = words("repo", 100)
+	p.Inputs.FocusAreas = words("focus", 20)
+	return p
+}
+
+// fixedTokens counts the tokens of p without its trimmable inputs
+func fixedTokens(p TaskPrompt) int {
+	p.Inputs.RepoContent, p.Inputs.FocusAreas = "", ""
+	return wordCounter{}.CountTokens(ExecuteChain(&p))
+}
+
+func TestFitToBudgetTrimsRepoContentFirst(t *testing.T) {
+	p := testPrompt()
+	b := budget.Budget{Counter: wordCounter{}, Window: fixedTokens(*p) + 20 + 30}
+
+	tokens, err := FitToBudget(p, b)
+	if err != nil {
+		t.Fatalf("FitToBudget() error = %v", err)
+	}
+	if tokens > b.Prompt() {
+		t.Errorf("FitToBudget() = %d tokens, over the %d budget", tokens, b.Prompt())
+	}
+	if got := (wordCounter{}).CountTokens(p.Inputs.RepoContent); got != 30 {
+		t.Errorf("repository content kept %d words, want 30", got)
+	}
+	if p.Inputs.FocusAreas != words("focus", 20) {
+		t.Errorf("focus areas were trimmed while repository content was left")
+	}
+	if p.Inputs.UserRequest != "find the bugs" {
+		t.Errorf("user request was trimmed: %q", p.Inputs.UserRequest)
+	}
+}
+
+func TestFitToBudgetTrimsFocusAreasLast(t *testing.T) {
+	p := testPrompt()
+	b := budget.Budget{Counter: wordCounter{}, Window: fixedTokens(*p) + 5}
+
+	if _, err := FitToBudget(p, b); err != nil {
+		t.Fatalf("FitToBudget() error = %v", err)
+	}
+	if p.Inputs.RepoContent != "" {
+		t.Errorf("repository content kept %q, want it dropped", p.Inputs.RepoContent)
+	}
+	if got := (wordCounter{}).CountTokens(p.Inputs.FocusAreas); got != 5 {
+		t.Errorf("focus areas kept %d words, want 5", got)
+	}
+}
+
+func TestFitToBudgetOverBudget(t *testing.T) {
+	p := testPrompt()
+	b := budget.Budget{Counter: wordCounter{}, Window: fixedTokens(*p) - 1}
+
+	tokens, err := FitToBudget(p, b)
+	if !errors.Is(err, budget.ErrOverBudget) {
+		t.Fatalf("FitToBudget() error = %v, want ErrOverBudget", err)
+	}
+	if tokens != fixedTokens(*p) || p.Inputs.RepoContent != "" || p.Inputs.FocusAreas != "" {
+		t.Errorf("FitToBudget() = %d tokens, want every trimmable input dropped", tokens)
+	}
+}
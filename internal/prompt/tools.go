@@ -0,0 +1,74 @@
+package prompt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"threshAI/pkg/llm"
+)
+
+// Tools returns tools letting a model list the prompt templates in dir and
+// run chains of them. Templates are named by file name and can't be read
+// from outside dir.
+func Tools(dir string) []llm.Tool {
+	return []llm.Tool{
+		{
+			Name:        "list_prompts",
+			Description: "List the prompt templates that can be run as a chain",
+			Parameters:  json.RawMessage(`{"type": "object", "properties": {}}`),
+			Handler: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+				files, err := ListPromptFiles(dir)
+				if err != nil {
+					return "", err
+				}
+				if len(files) == 0 {
+					return "no prompt templates available", nil
+				}
+				return strings.Join(files, "\n"), nil
+			},
+		},
+		{
+			Name:        "run_prompt_chain",
+			Description: "Run a chain of prompt templates in order and return the rendered chain",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"prompts": {
+						"type": "array",
+						"description": "Template file names as returned by list_prompts",
+						"items": {"type": "string"},
+						"minItems": 1
+					}
+				},
+				"required": ["prompts"]
+			}`),
+			Handler: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+				var args struct {
+					Prompts []string `json:"prompts"`
+				}
+				if err := json.Unmarshal(arguments, &args); err != nil {
+					return "", fmt.Errorf("invalid arguments: %v", err)
+				}
+				if len(args.Prompts) == 0 {
+					return "", fmt.Errorf("at least one prompt is required")
+				}
+
+				chain := make([]*TaskPrompt, len(args.Prompts))
+				for i, name := range args.Prompts {
+					if name != filepath.Base(name) || filepath.Ext(name) != ".xml" {
+						return "", fmt.Errorf("unknown prompt %q", name)
+					}
+					p, err := LoadPrompt(filepath.Join(dir, name))
+					if err != nil {
+						return "", fmt.Errorf("prompt %s: %w", name, err)
+					}
+					chain[i] = p
+				}
+				return ExecuteChain(chain...), nil
+			},
+		},
+	}
+}
@@ -0,0 +1,58 @@
+package prompt
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"threshAI/pkg/llm"
+)
+
+func findTool(t *testing.T, tools []llm.Tool, name string) llm.Tool {
+	t.Helper()
+	for _, tool := range tools {
+		if tool.Name == name {
+			return tool
+		}
+	}
+	t.Fatalf("no %s tool", name)
+	return llm.Tool{}
+}
+
+func TestTools(t *testing.T) {
+	dir := t.TempDir()
+	for name, system := range map[string]string{"review.xml": "You review code", "plan.xml": "You plan work"} {
+		xml := "<TaskPrompt><System>" + system + "</System></TaskPrompt>"
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(xml), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(filepath.Join(filepath.Dir(dir), "secret.xml"), []byte("<TaskPrompt/>"), 0644)
+	tools := Tools(dir)
+	ctx := context.Background()
+
+	list, err := findTool(t, tools, "list_prompts").Handler(ctx, json.RawMessage(`{}`))
+	if err != nil || list != "plan.xml\nreview.xml" {
+		t.Errorf("list_prompts = %q, %v, want both templates", list, err)
+	}
+
+	run := findTool(t, tools, "run_prompt_chain").Handler
+	out, err := run(ctx, json.RawMessage(`{"prompts": ["review.xml", "plan.xml"]}`))
+	if err != nil {
+		t.Fatalf("run_prompt_chain error = %v", err)
+	}
+	first, second := strings.Index(out, "You review code"), strings.Index(out, "You plan work")
+	if first < 0 || second < first {
+		t.Errorf("run_prompt_chain = %q, want the templates in order", out)
+	}
+
+	// Templates outside the directory can't be named
+	for _, args := range []string{`{"prompts": ["../secret.xml"]}`, `{"prompts": ["missing.xml"]}`, `{"prompts": []}`} {
+		if _, err := run(ctx, json.RawMessage(args)); err == nil {
+			t.Errorf("run_prompt_chain(%s) succeeded, want an error", args)
+		}
+	}
+}
@@ -32,6 +32,21 @@ type PipelineMetrics struct {
 	pluginStatusGauge   *prometheus.GaugeVec
 	pluginErrorsCounter *prometheus.CounterVec
 	pluginMetricsGauge  *prometheus.GaugeVec
+
+	// LLM usage metrics
+	llmRequestsCounter *prometheus.CounterVec
+	llmTokensCounter   *prometheus.CounterVec
+	llmCostCounter     *prometheus.CounterVec
+	llmDedupCounter    *prometheus.CounterVec
+
+	// Admission metrics
+	admissionQueueGauge      *prometheus.GaugeVec
+	admissionInFlightGauge   *prometheus.GaugeVec
+	admissionRejectedCounter *prometheus.CounterVec
+
+	// Semantic cache metrics
+	semanticSimilarityHistogram *prometheus.HistogramVec
+	semanticLookupsCounter      *prometheus.CounterVec
 }
 
 func GetMetrics() *PipelineMetrics {
@@ -116,6 +131,79 @@ func GetMetrics() *PipelineMetrics {
 				},
 				[]string{"plugin_id", "metric_name"},
 			),
+
+			llmRequestsCounter: promauto.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: "llm_requests_total",
+					Help: "Total number of LLM requests by usage source (reported/estimated)",
+				},
+				[]string{"provider", "model", "source"},
+			),
+
+			llmTokensCounter: promauto.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: "llm_tokens_total",
+					Help: "Total number of LLM tokens by type (prompt/completion)",
+				},
+				[]string{"provider", "model", "type"},
+			),
+
+			llmCostCounter: promauto.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: "llm_cost_usd_total",
+					Help: "Total cost of LLM requests in US dollars",
+				},
+				[]string{"provider", "model"},
+			),
+
+			llmDedupCounter: promauto.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: "llm_deduplicated_requests_total",
+					Help: "Total number of LLM requests served by an identical request already in flight",
+				},
+				[]string{"provider", "model"},
+			),
+
+			admissionQueueGauge: promauto.NewGaugeVec(
+				prometheus.GaugeOpts{
+					Name: "llm_admission_queue_depth",
+					Help: "Number of LLM requests waiting for a free slot by priority",
+				},
+				[]string{"provider", "priority"},
+			),
+
+			admissionInFlightGauge: promauto.NewGaugeVec(
+				prometheus.GaugeOpts{
+					Name: "llm_admission_in_flight",
+					Help: "Number of LLM requests admitted to a provider",
+				},
+				[]string{"provider"},
+			),
+
+			admissionRejectedCounter: promauto.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: "llm_admission_rejected_total",
+					Help: "Total number of LLM requests rejected by admission control (queue_full/timeout)",
+				},
+				[]string{"provider", "reason"},
+			),
+
+			semanticSimilarityHistogram: promauto.NewHistogramVec(
+				prometheus.HistogramOpts{
+					Name:    "llm_semantic_cache_similarity",
+					Help:    "Cosine similarity of the closest cached prompt for each semantic cache lookup",
+					Buckets: prometheus.LinearBuckets(0.5, 0.05, 11),
+				},
+				[]string{"provider", "model"},
+			),
+
+			semanticLookupsCounter: promauto.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: "llm_semantic_cache_lookups_total",
+					Help: "Total number of semantic cache lookups by result (hit/miss)",
+				},
+				[]string{"provider", "model", "result"},
+			),
 		}
 	})
 	return metrics
@@ -189,3 +277,54 @@ func (m *PipelineMetrics) SetPluginMetric(pluginID string, metricName string, va
 
 	m.pluginMetricsGauge.WithLabelValues(pluginID, metricName).Set(floatValue)
 }
+
+// LLM usage metric methods
+
+// IncrementLLMRequests increments the LLM request counter
+func (m *PipelineMetrics) IncrementLLMRequests(provider string, model string, source string) {
+	m.llmRequestsCounter.WithLabelValues(provider, model, source).Inc()
+}
+
+// RecordTokens adds to the LLM token counter
+func (m *PipelineMetrics) RecordTokens(provider string, model string, tokenType string, count int) {
+	m.llmTokensCounter.WithLabelValues(provider, model, tokenType).Add(float64(count))
+}
+
+// RecordCost adds to the LLM cost counter
+func (m *PipelineMetrics) RecordCost(provider string, model string, usd float64) {
+	m.llmCostCounter.WithLabelValues(provider, model).Add(usd)
+}
+
+// RecordDeduplicated increments the counter of deduplicated LLM requests
+func (m *PipelineMetrics) RecordDeduplicated(provider string, model string) {
+	m.llmDedupCounter.WithLabelValues(provider, model).Inc()
+}
+
+// Admission metric methods
+
+// SetQueueDepth updates the number of requests waiting for a provider slot
+func (m *PipelineMetrics) SetQueueDepth(provider string, priority string, depth int) {
+	m.admissionQueueGauge.WithLabelValues(provider, priority).Set(float64(depth))
+}
+
+// SetInFlight updates the number of requests admitted to a provider
+func (m *PipelineMetrics) SetInFlight(provider string, count int) {
+	m.admissionInFlightGauge.WithLabelValues(provider).Set(float64(count))
+}
+
+// RecordAdmissionRejected increments the counter of requests rejected by
+// admission control
+func (m *PipelineMetrics) RecordAdmissionRejected(provider string, reason string) {
+	m.admissionRejectedCounter.WithLabelValues(provider, reason).Inc()
+}
+
+// ObserveSemanticLookup records the similarity of the closest cached prompt
+// and whether it was served
+func (m *PipelineMetrics) ObserveSemanticLookup(provider string, model string, similarity float64, hit bool) {
+	m.semanticSimilarityHistogram.WithLabelValues(provider, model).Observe(similarity)
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.semanticLookupsCounter.WithLabelValues(provider, model, result).Inc()
+}
@@ -2,41 +2,39 @@ package cache
 
 import (
 	"context"
-	"fmt"
-	"sync"
+	"errors"
 	"time"
 )
 
+// ErrNotFound is returned by Get for keys that are missing or expired
+var ErrNotFound = errors.New("key not found")
+
+// Cache stores string values by key. A ttl of zero or less keeps an entry
+// until it is deleted or evicted.
 type Cache interface {
 	Get(ctx context.Context, key string) (string, error)
 	Set(ctx context.Context, key string, value string, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+	Stats(ctx context.Context) (Stats, error)
 }
 
-type InMemoryCache struct {
-	data map[string]string
-	mu   sync.RWMutex
-}
+// Stats describes the contents and effectiveness of a cache. Counters cover
+// the lifetime of the cache instance.
+type Stats struct {
+	Entries int   `json:"entries"`
+	Bytes   int64 `json:"bytes"`
 
-func NewInMemoryCache() *InMemoryCache {
-	return &InMemoryCache{
-		data: make(map[string]string),
-	}
+	Hits        uint64 `json:"hits"`
+	Misses      uint64 `json:"misses"`
+	Evictions   uint64 `json:"evictions"`
+	Expirations uint64 `json:"expirations"`
 }
 
-func (c *InMemoryCache) Get(ctx context.Context, key string) (string, error) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	if value, ok := c.data[key]; ok {
-		return value, nil
+// HitRate returns the fraction of lookups that found a value
+func (s Stats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
 	}
-	return "", fmt.Errorf("key not found")
-}
-
-func (c *InMemoryCache) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	c.data[key] = value
-	return nil
+	return float64(s.Hits) / float64(total)
 }
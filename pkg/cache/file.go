@@ -0,0 +1,408 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const DefaultFileMaxBytes = 256 << 20
+
+const (
+	entryExt      = ".json"
+	lockFileName  = ".lock"
+	statsFileName = ".stats"
+)
+
+// DefaultFileCacheDir is where the CLI keeps cached responses
+func DefaultFileCacheDir() string {
+	return filepath.Join(os.Getenv("HOME"), ".thresh", "cache", "responses")
+}
+
+// FileConfig locates and bounds a file cache. Zero values of Dir, MaxEntries
+// and MaxBytes select the defaults.
+type FileConfig struct {
+	Dir        string
+	MaxEntries int
+	MaxBytes   int64
+}
+
+// FileCache keeps every entry in a file of its own so that cached responses
+// outlive the process. Entries are written atomically and the directory is
+// locked for every operation, so several processes can share a cache. Once
+// it grows beyond its bounds the least recently used entries are removed.
+// Counters are kept with the entries and cover every process using them,
+// along with a running count and size of the entries, so that the directory
+// is only scanned when the cache may be over its bounds.
+type FileCache struct {
+	config FileConfig
+}
+
+// fileEntry is the content of an entry file
+type fileEntry struct {
+	Key       string    `json:"key"`
+	Value     string    `json:"value"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+func (e *fileEntry) expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && !now.Before(e.ExpiresAt)
+}
+
+// fileCounters are persisted next to the entries. Entries and Bytes track the
+// entry files as they are written and removed; they are only trusted once
+// Counted is set by a scan of the directory, which also corrects any drift
+// from files removed by hand.
+type fileCounters struct {
+	Hits        uint64 `json:"hits"`
+	Misses      uint64 `json:"misses"`
+	Evictions   uint64 `json:"evictions"`
+	Expirations uint64 `json:"expirations"`
+
+	Entries int   `json:"entries"`
+	Bytes   int64 `json:"bytes"`
+	Counted bool  `json:"counted"`
+}
+
+// NewFileCache creates a cache in config.Dir, creating the directory if
+// needed
+func NewFileCache(config FileConfig) (*FileCache, error) {
+	if config.Dir == "" {
+		config.Dir = DefaultFileCacheDir()
+	}
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = DefaultMaxEntries
+	}
+	if config.MaxBytes <= 0 {
+		config.MaxBytes = DefaultFileMaxBytes
+	}
+	if err := os.MkdirAll(config.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating cache directory: %w", err)
+	}
+	return &FileCache{config: config}, nil
+}
+
+// Dir returns the directory holding the entries
+func (c *FileCache) Dir() string {
+	return c.config.Dir
+}
+
+func (c *FileCache) Get(ctx context.Context, key string) (string, error) {
+	var value string
+	err := c.locked(func(counters *fileCounters) error {
+		path := c.path(key)
+		entry, err := readEntry(path)
+		if errors.Is(err, fs.ErrNotExist) || (err == nil && entry.Key != key) {
+			counters.Misses++
+			return ErrNotFound
+		}
+		if err != nil {
+			// A corrupt entry is dropped rather than failing every lookup
+			removeEntry(path, counters)
+			counters.Misses++
+			return ErrNotFound
+		}
+
+		now := time.Now()
+		if entry.expired(now) {
+			removeEntry(path, counters)
+			counters.Expirations++
+			counters.Misses++
+			return ErrNotFound
+		}
+
+		// The modification time records the last use for eviction
+		os.Chtimes(path, now, now)
+		counters.Hits++
+		value = entry.Value
+		return nil
+	})
+	return value, err
+}
+
+func (c *FileCache) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	now := time.Now()
+	entry := fileEntry{Key: key, Value: value, CreatedAt: now}
+	if ttl > 0 {
+		entry.ExpiresAt = now.Add(ttl)
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encoding cache entry: %w", err)
+	}
+
+	return c.locked(func(counters *fileCounters) error {
+		path := c.path(key)
+		if info, err := os.Stat(path); err == nil {
+			counters.Entries--
+			counters.Bytes -= info.Size()
+		}
+		if err := writeFileAtomic(path, data, true); err != nil {
+			return err
+		}
+		counters.Entries++
+		counters.Bytes += int64(len(data))
+
+		if counters.Counted && counters.Entries <= c.config.MaxEntries && counters.Bytes <= c.config.MaxBytes {
+			return nil
+		}
+		_, err := c.evict(counters)
+		return err
+	})
+}
+
+func (c *FileCache) Delete(ctx context.Context, key string) error {
+	return c.locked(func(counters *fileCounters) error {
+		return removeEntry(c.path(key), counters)
+	})
+}
+
+func (c *FileCache) Stats(ctx context.Context) (Stats, error) {
+	var stats Stats
+	err := c.locked(func(counters *fileCounters) error {
+		files, err := c.recount(counters)
+		if err != nil {
+			return err
+		}
+		stats = Stats{
+			Entries:     len(files),
+			Hits:        counters.Hits,
+			Misses:      counters.Misses,
+			Evictions:   counters.Evictions,
+			Expirations: counters.Expirations,
+		}
+		for _, f := range files {
+			stats.Bytes += f.size
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// Prune removes expired entries, then the least recently used ones beyond
+// the bounds of the cache, and returns the number of entries removed
+func (c *FileCache) Prune(ctx context.Context) (int, error) {
+	var removed int
+	err := c.locked(func(counters *fileCounters) error {
+		files, err := c.entryFiles()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, f := range files {
+			entry, err := readEntry(f.path)
+			if err != nil || entry.expired(now) {
+				removeEntry(f.path, counters)
+				counters.Expirations++
+				removed++
+			}
+		}
+
+		evicted, err := c.evict(counters)
+		removed += evicted
+		return err
+	})
+	return removed, err
+}
+
+// Clear removes every entry and resets the counters
+func (c *FileCache) Clear(ctx context.Context) error {
+	return c.locked(func(counters *fileCounters) error {
+		files, err := c.entryFiles()
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			if err := os.Remove(f.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("deleting cache entry: %w", err)
+			}
+		}
+		*counters = fileCounters{Counted: true}
+		return nil
+	})
+}
+
+// locked runs fn holding the directory lock, with the persisted counters,
+// which are saved again once fn returns
+func (c *FileCache) locked(fn func(counters *fileCounters) error) error {
+	unlock, err := lockDir(filepath.Join(c.config.Dir, lockFileName))
+	if err != nil {
+		return fmt.Errorf("locking cache directory: %w", err)
+	}
+	defer unlock()
+
+	statsPath := filepath.Join(c.config.Dir, statsFileName)
+	var counters fileCounters
+	if data, err := os.ReadFile(statsPath); err == nil {
+		// Unreadable counters start over
+		json.Unmarshal(data, &counters)
+	}
+	before := counters
+
+	fnErr := fn(&counters)
+	if counters != before {
+		data, err := json.Marshal(counters)
+		if err == nil {
+			// Counters are rewritten on every operation, so they aren't
+			// synced; a crash at worst loses the last few
+			err = writeFileAtomic(statsPath, data, false)
+		}
+		if err != nil && fnErr == nil {
+			return fmt.Errorf("saving cache stats: %w", err)
+		}
+	}
+	return fnErr
+}
+
+// evict removes the least recently used entries until the cache is within
+// its bounds. The directory lock must be held.
+func (c *FileCache) evict(counters *fileCounters) (int, error) {
+	files, err := c.recount(counters)
+	if err != nil {
+		return 0, err
+	}
+
+	total := counters.Bytes
+	if len(files) <= c.config.MaxEntries && total <= c.config.MaxBytes {
+		return 0, nil
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].used.Before(files[j].used)
+	})
+	evicted := 0
+	for _, f := range files {
+		if len(files)-evicted <= c.config.MaxEntries && total <= c.config.MaxBytes {
+			break
+		}
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return evicted, fmt.Errorf("evicting cache entry: %w", err)
+		}
+		total -= f.size
+		evicted++
+		counters.Evictions++
+		counters.Entries--
+		counters.Bytes -= f.size
+	}
+	return evicted, nil
+}
+
+// recount scans the entry files and resets the running count and size of the
+// entries from them. The directory lock must be held.
+func (c *FileCache) recount(counters *fileCounters) ([]entryFile, error) {
+	files, err := c.entryFiles()
+	if err != nil {
+		return nil, err
+	}
+	counters.Entries, counters.Bytes, counters.Counted = len(files), 0, true
+	for _, f := range files {
+		counters.Bytes += f.size
+	}
+	return files, nil
+}
+
+// removeEntry removes an entry file, keeping the running count and size of
+// the entries. The directory lock must be held.
+func removeEntry(path string, counters *fileCounters) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("deleting cache entry: %w", err)
+	}
+	if info != nil {
+		counters.Entries--
+		counters.Bytes -= info.Size()
+	}
+	return nil
+}
+
+type entryFile struct {
+	path string
+	size int64
+	used time.Time
+}
+
+func (c *FileCache) entryFiles() ([]entryFile, error) {
+	dirEntries, err := os.ReadDir(c.config.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading cache directory: %w", err)
+	}
+
+	files := make([]entryFile, 0, len(dirEntries))
+	for _, de := range dirEntries {
+		name := de.Name()
+		if de.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != entryExt {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			// Removed since the directory was read
+			continue
+		}
+		files = append(files, entryFile{
+			path: filepath.Join(c.config.Dir, name),
+			size: info.Size(),
+			used: info.ModTime(),
+		})
+	}
+	return files, nil
+}
+
+// path names the entry file of a key, which may contain any characters
+func (c *FileCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.config.Dir, hex.EncodeToString(sum[:])+entryExt)
+}
+
+func readEntry(path string) (*fileEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entry fileEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("decoding cache entry %s: %w", path, err)
+	}
+	return &entry, nil
+}
+
+// writeFileAtomic writes data to a temporary file renamed over path, so that
+// readers never see a partial file. With sync set the data is flushed to disk
+// before the rename, so that a crash can't leave an empty file behind.
+func writeFileAtomic(path string, data []byte, sync bool) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("writing cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing cache file: %w", err)
+	}
+	if sync {
+		if err := tmp.Sync(); err != nil {
+			tmp.Close()
+			return fmt.Errorf("writing cache file: %w", err)
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing cache file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("writing cache file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileCachePersists(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	first, err := NewFileCache(FileConfig{Dir: dir})
+	if err != nil {
+		t.Fatalf("NewFileCache() error = %v", err)
+	}
+	if err := first.Set(ctx, "what is / threshAI?", "an answer", time.Hour); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	// A later process sees the entry and the counters of earlier ones
+	second, _ := NewFileCache(FileConfig{Dir: dir})
+	if got, err := second.Get(ctx, "what is / threshAI?"); err != nil || got != "an answer" {
+		t.Fatalf("Get() = %q, %v, want %q", got, err, "an answer")
+	}
+	if _, err := first.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(missing) error = %v, want ErrNotFound", err)
+	}
+
+	stats, err := second.Stats(ctx)
+	if err != nil {
+		t.Fatalf("Stats() error = %v", err)
+	}
+	if stats.Entries != 1 || stats.Bytes == 0 || stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Stats() = %+v, want 1 entry, 1 hit and 1 miss", stats)
+	}
+
+	// No temporary files are left behind
+	leftovers, _ := filepath.Glob(filepath.Join(dir, ".tmp-*"))
+	if len(leftovers) != 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+}
+
+func TestFileCacheExpiryAndPrune(t *testing.T) {
+	ctx := context.Background()
+	c, _ := NewFileCache(FileConfig{Dir: t.TempDir()})
+
+	c.Set(ctx, "short", "a", 10*time.Millisecond)
+	c.Set(ctx, "stale", "b", 10*time.Millisecond)
+	c.Set(ctx, "kept", "c", 0)
+	time.Sleep(20 * time.Millisecond)
+
+	if _, err := c.Get(ctx, "short"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(short) error = %v after expiry, want ErrNotFound", err)
+	}
+	removed, err := c.Prune(ctx)
+	if err != nil || removed != 1 {
+		t.Errorf("Prune() = %d, %v, want the stale entry removed", removed, err)
+	}
+	if stats, _ := c.Stats(ctx); stats.Entries != 1 || stats.Expirations != 2 {
+		t.Errorf("Stats() = %+v, want 1 entry and 2 expirations", stats)
+	}
+
+	if err := c.Clear(ctx); err != nil {
+		t.Fatalf("Clear() error = %v", err)
+	}
+	if stats, _ := c.Stats(ctx); stats != (Stats{}) {
+		t.Errorf("Stats() = %+v after Clear, want zero", stats)
+	}
+}
+
+func TestFileCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c, _ := NewFileCache(FileConfig{Dir: t.TempDir(), MaxEntries: 2})
+
+	c.Set(ctx, "a", "1", 0)
+	c.Set(ctx, "b", "2", 0)
+	// Make b the least recently used regardless of timestamp resolution
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(c.path("b"), old, old)
+	c.Get(ctx, "a")
+	c.Set(ctx, "c", "3", 0)
+
+	if _, err := c.Get(ctx, "b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(b) error = %v, want ErrNotFound after eviction", err)
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, err := c.Get(ctx, key); err != nil {
+			t.Errorf("Get(%s) error = %v, want kept", key, err)
+		}
+	}
+	if stats, _ := c.Stats(ctx); stats.Evictions != 1 {
+		t.Errorf("Evictions = %d, want 1", stats.Evictions)
+	}
+}
+
+func TestFileCacheMaxBytes(t *testing.T) {
+	ctx := context.Background()
+	c, _ := NewFileCache(FileConfig{Dir: t.TempDir(), MaxBytes: 300})
+
+	for i := 0; i < 10; i++ {
+		c.Set(ctx, fmt.Sprintf("key%d", i), "a value of some length", 0)
+	}
+	stats, _ := c.Stats(ctx)
+	if stats.Bytes > 300 || stats.Entries == 0 {
+		t.Errorf("Stats() = %+v, want entries within 300 bytes", stats)
+	}
+}
+
+func TestFileCacheTracksSize(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	c, _ := NewFileCache(FileConfig{Dir: dir})
+
+	counters := func() fileCounters {
+		t.Helper()
+		var counters fileCounters
+		data, err := os.ReadFile(filepath.Join(dir, statsFileName))
+		if err != nil {
+			t.Fatalf("reading stats: %v", err)
+		}
+		json.Unmarshal(data, &counters)
+		return counters
+	}
+
+	c.Set(ctx, "a", "1", 0)
+	c.Set(ctx, "b", "2", 0)
+	c.Set(ctx, "a", "a longer value", 0)
+	c.Delete(ctx, "b")
+	c.Delete(ctx, "missing")
+
+	stats, _ := c.Stats(ctx)
+	got := counters()
+	if !got.Counted || got.Entries != stats.Entries || got.Bytes != stats.Bytes || got.Entries != 1 {
+		t.Errorf("tracked %d entries of %d bytes, want %d of %d", got.Entries, got.Bytes, stats.Entries, stats.Bytes)
+	}
+
+	// Set doesn't scan the directory while the cache is within its bounds,
+	// so an entry removed by hand is only noticed by the next scan
+	os.Remove(c.path("a"))
+	c.Set(ctx, "c", "3", 0)
+	if got := counters(); got.Entries != 2 {
+		t.Errorf("tracked %d entries, want 2 until a scan", got.Entries)
+	}
+	c.Prune(ctx)
+	if got := counters(); got.Entries != 1 {
+		t.Errorf("tracked %d entries after Prune, want 1", got.Entries)
+	}
+}
+
+func TestFileCacheConcurrentInstances(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// Every instance locks the directory on its own, like separate processes
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			c, err := NewFileCache(FileConfig{Dir: dir})
+			if err != nil {
+				t.Errorf("NewFileCache() error = %v", err)
+				return
+			}
+			for i := 0; i < 20; i++ {
+				key := fmt.Sprintf("key%d", i%5)
+				if err := c.Set(ctx, key, fmt.Sprintf("writer %d", w), 0); err != nil {
+					t.Errorf("Set() error = %v", err)
+				}
+				if _, err := c.Get(ctx, key); err != nil {
+					t.Errorf("Get() error = %v", err)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	c, _ := NewFileCache(FileConfig{Dir: dir})
+	stats, _ := c.Stats(ctx)
+	if stats.Entries != 5 || stats.Hits != 80 {
+		t.Errorf("Stats() = %+v, want 5 entries and 80 hits", stats)
+	}
+}
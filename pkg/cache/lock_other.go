@@ -0,0 +1,14 @@
+//go:build !unix
+
+package cache
+
+import "sync"
+
+// lockDirMu serialises access within the process. Without flock, processes
+// sharing a directory aren't coordinated beyond the atomic writes.
+var lockDirMu sync.Mutex
+
+func lockDir(path string) (unlock func(), err error) {
+	lockDirMu.Lock()
+	return lockDirMu.Unlock, nil
+}
@@ -0,0 +1,196 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMaxEntries      = 10000
+	DefaultJanitorInterval = time.Minute
+)
+
+// InMemoryConfig bounds an in-memory cache. Zero values of MaxEntries and
+// JanitorInterval select the defaults; a zero MaxBytes doesn't limit the
+// size of the cache.
+type InMemoryConfig struct {
+	// MaxEntries is the number of entries kept before the least recently
+	// used ones are evicted
+	MaxEntries int
+	// MaxBytes bounds the total size of keys and values
+	MaxBytes int64
+	// JanitorInterval is how often expired entries are removed in the
+	// background. Expired entries are never returned, whether or not the
+	// janitor has removed them yet.
+	JanitorInterval time.Duration
+}
+
+// InMemoryCache is a process-local cache with per-entry expiry and least
+// recently used eviction
+type InMemoryCache struct {
+	config InMemoryConfig
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// lru holds the entries, most recently used first
+	lru   *list.List
+	bytes int64
+	stats Stats
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+type memoryEntry struct {
+	key       string
+	value     string
+	expiresAt time.Time // zero for entries that don't expire
+}
+
+func (e *memoryEntry) size() int64 {
+	return int64(len(e.key) + len(e.value))
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// NewInMemoryCache creates a cache with the default bounds
+func NewInMemoryCache() *InMemoryCache {
+	return NewInMemoryCacheWithConfig(InMemoryConfig{})
+}
+
+// NewInMemoryCacheWithConfig creates a cache bounded by config. Its janitor
+// runs until Close is called.
+func NewInMemoryCacheWithConfig(config InMemoryConfig) *InMemoryCache {
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = DefaultMaxEntries
+	}
+	if config.JanitorInterval <= 0 {
+		config.JanitorInterval = DefaultJanitorInterval
+	}
+	c := &InMemoryCache{
+		config:  config,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		stop:    make(chan struct{}),
+	}
+	go c.janitor()
+	return c
+}
+
+func (c *InMemoryCache) Get(ctx context.Context, key string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return "", ErrNotFound
+	}
+	entry := elem.Value.(*memoryEntry)
+	if entry.expired(time.Now()) {
+		c.removeLocked(elem)
+		c.stats.Expirations++
+		c.stats.Misses++
+		return "", ErrNotFound
+	}
+
+	c.lru.MoveToFront(elem)
+	c.stats.Hits++
+	return entry.value, nil
+}
+
+func (c *InMemoryCache) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	entry := &memoryEntry{key: key, value: value}
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.removeLocked(elem)
+	}
+	if c.config.MaxBytes > 0 && entry.size() > c.config.MaxBytes {
+		// The entry could never fit, so it isn't stored at all
+		c.stats.Evictions++
+		return nil
+	}
+
+	c.entries[key] = c.lru.PushFront(entry)
+	c.bytes += entry.size()
+
+	for len(c.entries) > c.config.MaxEntries || (c.config.MaxBytes > 0 && c.bytes > c.config.MaxBytes) {
+		c.removeLocked(c.lru.Back())
+		c.stats.Evictions++
+	}
+	return nil
+}
+
+func (c *InMemoryCache) Delete(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.removeLocked(elem)
+	}
+	return nil
+}
+
+func (c *InMemoryCache) Stats(ctx context.Context) (Stats, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = len(c.entries)
+	stats.Bytes = c.bytes
+	return stats, nil
+}
+
+// Close stops the janitor. The cache remains usable, but expired entries are
+// then only removed when they are looked up or evicted.
+func (c *InMemoryCache) Close() error {
+	c.stopOnce.Do(func() { close(c.stop) })
+	return nil
+}
+
+// DeleteExpired removes every expired entry
+func (c *InMemoryCache) DeleteExpired() {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for elem := c.lru.Back(); elem != nil; {
+		prev := elem.Prev()
+		if elem.Value.(*memoryEntry).expired(now) {
+			c.removeLocked(elem)
+			c.stats.Expirations++
+		}
+		elem = prev
+	}
+}
+
+func (c *InMemoryCache) janitor() {
+	ticker := time.NewTicker(c.config.JanitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.DeleteExpired()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+func (c *InMemoryCache) removeLocked(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*memoryEntry)
+	delete(c.entries, entry.key)
+	c.bytes -= entry.size()
+}
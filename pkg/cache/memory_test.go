@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestInMemoryCacheExpiry(t *testing.T) {
+	ctx := context.Background()
+	c := NewInMemoryCache()
+	defer c.Close()
+
+	c.Set(ctx, "short", "a", 10*time.Millisecond)
+	c.Set(ctx, "forever", "b", 0)
+
+	if got, err := c.Get(ctx, "short"); err != nil || got != "a" {
+		t.Fatalf("Get(short) = %q, %v before expiry, want %q", got, err, "a")
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	if _, err := c.Get(ctx, "short"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(short) error = %v after expiry, want ErrNotFound", err)
+	}
+	if got, err := c.Get(ctx, "forever"); err != nil || got != "b" {
+		t.Errorf("Get(forever) = %q, %v, want %q", got, err, "b")
+	}
+
+	stats, _ := c.Stats(ctx)
+	if stats.Entries != 1 || stats.Expirations != 1 || stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("Stats() = %+v, want 1 entry, 1 expiration, 2 hits and 1 miss", stats)
+	}
+}
+
+func TestInMemoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c := NewInMemoryCacheWithConfig(InMemoryConfig{MaxEntries: 2})
+	defer c.Close()
+
+	c.Set(ctx, "a", "1", 0)
+	c.Set(ctx, "b", "2", 0)
+	c.Get(ctx, "a") // b is now the least recently used
+	c.Set(ctx, "c", "3", 0)
+
+	if _, err := c.Get(ctx, "b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(b) error = %v, want ErrNotFound after eviction", err)
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, err := c.Get(ctx, key); err != nil {
+			t.Errorf("Get(%s) error = %v, want kept", key, err)
+		}
+	}
+	if stats, _ := c.Stats(ctx); stats.Evictions != 1 || stats.Entries != 2 {
+		t.Errorf("Stats() = %+v, want 1 eviction and 2 entries", stats)
+	}
+}
+
+func TestInMemoryCacheMaxBytes(t *testing.T) {
+	ctx := context.Background()
+	c := NewInMemoryCacheWithConfig(InMemoryConfig{MaxBytes: 10})
+	defer c.Close()
+
+	c.Set(ctx, "a", "1234", 0) // 5 bytes
+	c.Set(ctx, "b", "1234", 0) // 10 bytes
+	c.Set(ctx, "c", "12", 0)   // evicts a
+
+	stats, _ := c.Stats(ctx)
+	if stats.Bytes > 10 || stats.Entries != 2 {
+		t.Errorf("Stats() = %+v, want 2 entries within 10 bytes", stats)
+	}
+	if _, err := c.Get(ctx, "a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(a) error = %v, want ErrNotFound after eviction", err)
+	}
+
+	// Replacing a value accounts for the size of the new one only
+	c.Set(ctx, "c", "1", 0)
+	if stats, _ := c.Stats(ctx); stats.Bytes != 7 {
+		t.Errorf("Bytes = %d after replacing c, want 7", stats.Bytes)
+	}
+
+	// Values larger than the cache aren't stored
+	c.Set(ctx, "huge", "0123456789", 0)
+	if _, err := c.Get(ctx, "huge"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(huge) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestInMemoryCacheJanitorAndDelete(t *testing.T) {
+	ctx := context.Background()
+	c := NewInMemoryCacheWithConfig(InMemoryConfig{JanitorInterval: 5 * time.Millisecond})
+	defer c.Close()
+
+	c.Set(ctx, "old", "x", time.Millisecond)
+	c.Set(ctx, "kept", "y", 0)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		stats, _ := c.Stats(ctx)
+		if stats.Entries == 1 && stats.Expirations == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("janitor didn't remove the expired entry: %+v", stats)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	c.Delete(ctx, "kept")
+	if stats, _ := c.Stats(ctx); stats.Entries != 0 || stats.Bytes != 0 {
+		t.Errorf("Stats() = %+v after Delete, want empty", stats)
+	}
+}
@@ -0,0 +1,193 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	DefaultRedisAddr        = "localhost:6379"
+	DefaultRedisNamespace   = "thresh:cache"
+	DefaultCompressMinBytes = 1024
+)
+
+// Stored values start with a byte telling how they are encoded
+const (
+	encodingRaw  = 'r'
+	encodingGzip = 'z'
+)
+
+// RedisConfig selects a Redis server and tunes how values are stored.
+// Zero values select the defaults; zero timeouts keep the go-redis defaults.
+type RedisConfig struct {
+	Addr     string
+	Username string
+	Password string
+	DB       int
+
+	// Namespace prefixes every key so that several applications can share
+	// a database
+	Namespace string
+
+	// Compress gzips values of at least CompressMinBytes bytes
+	Compress         bool
+	CompressMinBytes int
+
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+}
+
+// RedisCache stores entries in Redis, where they expire by their TTL and
+// are shared by every process using the same server and namespace. Hits and
+// misses are counted per instance; evictions are left to the server's
+// maxmemory policy and aren't reported.
+type RedisCache struct {
+	client *redis.Client
+	config RedisConfig
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// NewRedisCache creates a cache on the server in config. Connections are
+// made on first use; call Ping to check that the server is reachable.
+func NewRedisCache(config RedisConfig) *RedisCache {
+	if config.Addr == "" {
+		config.Addr = DefaultRedisAddr
+	}
+	if config.Namespace == "" {
+		config.Namespace = DefaultRedisNamespace
+	}
+	if config.CompressMinBytes <= 0 {
+		config.CompressMinBytes = DefaultCompressMinBytes
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:         config.Addr,
+		Username:     config.Username,
+		Password:     config.Password,
+		DB:           config.DB,
+		DialTimeout:  config.DialTimeout,
+		ReadTimeout:  config.ReadTimeout,
+		WriteTimeout: config.WriteTimeout,
+	})
+	return &RedisCache{client: client, config: config}
+}
+
+func (c *RedisCache) Get(ctx context.Context, key string) (string, error) {
+	data, err := c.client.Get(ctx, c.key(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		c.misses.Add(1)
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("redis get: %w", err)
+	}
+
+	value, err := decodeValue(data)
+	if err != nil {
+		c.misses.Add(1)
+		return "", fmt.Errorf("redis get %s: %w", key, err)
+	}
+	c.hits.Add(1)
+	return value, nil
+}
+
+func (c *RedisCache) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	data, err := c.encodeValue(value)
+	if err != nil {
+		return err
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+	if err := c.client.Set(ctx, c.key(key), data, ttl).Err(); err != nil {
+		return fmt.Errorf("redis set: %w", err)
+	}
+	return nil
+}
+
+func (c *RedisCache) Delete(ctx context.Context, key string) error {
+	if err := c.client.Del(ctx, c.key(key)).Err(); err != nil {
+		return fmt.Errorf("redis del: %w", err)
+	}
+	return nil
+}
+
+// Stats counts the entries of the namespace, scanning its keys
+func (c *RedisCache) Stats(ctx context.Context) (Stats, error) {
+	stats := Stats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+
+	iter := c.client.Scan(ctx, 0, c.key("*"), 1000).Iterator()
+	for iter.Next(ctx) {
+		stats.Entries++
+	}
+	if err := iter.Err(); err != nil {
+		return stats, fmt.Errorf("redis scan: %w", err)
+	}
+	return stats, nil
+}
+
+// Ping checks that the server is reachable and accepts the credentials
+func (c *RedisCache) Ping(ctx context.Context) error {
+	if err := c.client.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("redis %s: %w", c.config.Addr, err)
+	}
+	return nil
+}
+
+// Close closes the connections to the server
+func (c *RedisCache) Close() error {
+	return c.client.Close()
+}
+
+func (c *RedisCache) key(key string) string {
+	return c.config.Namespace + ":" + key
+}
+
+func (c *RedisCache) encodeValue(value string) ([]byte, error) {
+	if !c.config.Compress || len(value) < c.config.CompressMinBytes {
+		return append([]byte{encodingRaw}, value...), nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(encodingGzip)
+	zw := gzip.NewWriter(&buf)
+	if _, err := io.WriteString(zw, value); err != nil {
+		return nil, fmt.Errorf("compressing value: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("compressing value: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeValue(data []byte) (string, error) {
+	if len(data) == 0 {
+		return "", fmt.Errorf("empty value")
+	}
+	switch data[0] {
+	case encodingRaw:
+		return string(data[1:]), nil
+	case encodingGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(data[1:]))
+		if err != nil {
+			return "", fmt.Errorf("decompressing value: %w", err)
+		}
+		value, err := io.ReadAll(zr)
+		if err != nil {
+			return "", fmt.Errorf("decompressing value: %w", err)
+		}
+		return string(value), nil
+	default:
+		return "", fmt.Errorf("unknown value encoding %q", data[0])
+	}
+}
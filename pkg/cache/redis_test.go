@@ -0,0 +1,294 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is an in-process stand-in for a Redis server speaking enough of
+// RESP2 for RedisCache: PING, GET, SET with PX/EX, DEL and SCAN
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+
+	mu      sync.Mutex
+	data    map[string]string
+	expires map[string]time.Time
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &fakeRedis{
+		ln:       ln,
+		password: password,
+		data:     make(map[string]string),
+		expires:  make(map[string]time.Time),
+	}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *fakeRedis) addr() string {
+	return s.ln.Addr().String()
+}
+
+// raw returns the stored bytes of a key
+func (s *fakeRedis) raw(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.data[key]
+	return value, ok
+}
+
+func (s *fakeRedis) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	authed := s.password == ""
+
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		name := strings.ToUpper(args[0])
+		switch {
+		case name == "AUTH":
+			if args[len(args)-1] != s.password {
+				fmt.Fprint(w, "-WRONGPASS invalid password\r\n")
+			} else {
+				authed = true
+				fmt.Fprint(w, "+OK\r\n")
+			}
+		case !authed:
+			fmt.Fprint(w, "-NOAUTH Authentication required.\r\n")
+		default:
+			s.exec(w, name, args[1:])
+		}
+		w.Flush()
+	}
+}
+
+func (s *fakeRedis) exec(w io.Writer, name string, args []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, at := range s.expires {
+		if !now.Before(at) {
+			delete(s.data, key)
+			delete(s.expires, key)
+		}
+	}
+
+	switch name {
+	case "PING":
+		fmt.Fprint(w, "+PONG\r\n")
+	case "GET":
+		value, ok := s.data[args[0]]
+		if !ok {
+			fmt.Fprint(w, "$-1\r\n")
+			return
+		}
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(value), value)
+	case "SET":
+		s.data[args[0]] = args[1]
+		delete(s.expires, args[0])
+		if len(args) == 4 {
+			n, _ := strconv.Atoi(args[3])
+			unit := time.Millisecond
+			if strings.ToUpper(args[2]) == "EX" {
+				unit = time.Second
+			}
+			s.expires[args[0]] = now.Add(time.Duration(n) * unit)
+		}
+		fmt.Fprint(w, "+OK\r\n")
+	case "DEL":
+		deleted := 0
+		for _, key := range args {
+			if _, ok := s.data[key]; ok {
+				delete(s.data, key)
+				delete(s.expires, key)
+				deleted++
+			}
+		}
+		fmt.Fprintf(w, ":%d\r\n", deleted)
+	case "SCAN":
+		pattern := "*"
+		for i := 1; i+1 < len(args); i += 2 {
+			if strings.ToUpper(args[i]) == "MATCH" {
+				pattern = args[i+1]
+			}
+		}
+		var keys []string
+		for key := range s.data {
+			if ok, _ := path.Match(pattern, key); ok {
+				keys = append(keys, key)
+			}
+		}
+		fmt.Fprintf(w, "*2\r\n$1\r\n0\r\n*%d\r\n", len(keys))
+		for _, key := range keys {
+			fmt.Fprintf(w, "$%d\r\n%s\r\n", len(key), key)
+		}
+	default:
+		fmt.Fprintf(w, "-ERR unknown command '%s'\r\n", name)
+	}
+}
+
+// readCommand reads a command sent as a RESP array of bulk strings
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected %q", line)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func TestRedisCache(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeRedis(t, "")
+	c := NewRedisCache(RedisConfig{Addr: server.addr(), Namespace: "test"})
+	defer c.Close()
+
+	if err := c.Ping(ctx); err != nil {
+		t.Fatalf("Ping() error = %v", err)
+	}
+
+	if err := c.Set(ctx, "greeting", "hello", 0); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if _, ok := server.raw("test:greeting"); !ok {
+		t.Errorf("key wasn't stored under the namespace")
+	}
+	if got, err := c.Get(ctx, "greeting"); err != nil || got != "hello" {
+		t.Errorf("Get() = %q, %v, want %q", got, err, "hello")
+	}
+	if _, err := c.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(missing) error = %v, want ErrNotFound", err)
+	}
+
+	stats, err := c.Stats(ctx)
+	if err != nil {
+		t.Fatalf("Stats() error = %v", err)
+	}
+	if stats.Entries != 1 || stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Stats() = %+v, want 1 entry, 1 hit and 1 miss", stats)
+	}
+
+	if err := c.Delete(ctx, "greeting"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := c.Get(ctx, "greeting"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() error = %v after Delete, want ErrNotFound", err)
+	}
+}
+
+func TestRedisCacheTTL(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeRedis(t, "")
+	c := NewRedisCache(RedisConfig{Addr: server.addr()})
+	defer c.Close()
+
+	c.Set(ctx, "short", "a", 20*time.Millisecond)
+	if _, err := c.Get(ctx, "short"); err != nil {
+		t.Fatalf("Get() error = %v before expiry", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, err := c.Get(ctx, "short"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() error = %v after expiry, want ErrNotFound", err)
+	}
+}
+
+func TestRedisCacheCompression(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeRedis(t, "")
+	c := NewRedisCache(RedisConfig{Addr: server.addr(), Namespace: "z", Compress: true, CompressMinBytes: 16})
+	defer c.Close()
+
+	long := strings.Repeat("all work and no play ", 100)
+	c.Set(ctx, "long", long, time.Hour)
+	c.Set(ctx, "short", "tiny", time.Hour)
+
+	if raw, _ := server.raw("z:long"); len(raw) >= len(long) || raw[0] != encodingGzip {
+		t.Errorf("long value stored as %d bytes, want it compressed", len(raw))
+	}
+	if raw, _ := server.raw("z:short"); raw != "rtiny" {
+		t.Errorf("short value stored as %q, want it uncompressed", raw)
+	}
+	if got, err := c.Get(ctx, "long"); err != nil || got != long {
+		t.Errorf("Get(long) didn't round-trip: %v", err)
+	}
+}
+
+func TestRedisCachePingFailures(t *testing.T) {
+	ctx := context.Background()
+	server := newFakeRedis(t, "secret")
+
+	bad := NewRedisCache(RedisConfig{Addr: server.addr(), Password: "wrong"})
+	defer bad.Close()
+	if err := bad.Ping(ctx); err == nil {
+		t.Error("Ping() with a wrong password succeeded")
+	}
+
+	good := NewRedisCache(RedisConfig{Addr: server.addr(), Password: "secret"})
+	defer good.Close()
+	if err := good.Ping(ctx); err != nil {
+		t.Errorf("Ping() error = %v", err)
+	}
+
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := ln.Addr().String()
+	ln.Close()
+	down := NewRedisCache(RedisConfig{Addr: addr, DialTimeout: 100 * time.Millisecond})
+	defer down.Close()
+	if err := down.Ping(ctx); err == nil {
+		t.Error("Ping() of a stopped server succeeded")
+	}
+}
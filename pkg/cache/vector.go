@@ -0,0 +1,187 @@
+package cache
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// VectorIndex finds the stored value whose vector is closest to a query by
+// cosine similarity. Vectors are only compared within the scope they were
+// added to, since vectors of different scopes, such as different models,
+// aren't comparable. Search is exhaustive, which suits the few thousand
+// entries kept by a response cache.
+type VectorIndex struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	scopes  map[string][]*vectorEntry
+	entries int
+	// seq orders entries by insertion for eviction
+	seq uint64
+}
+
+type vectorEntry struct {
+	vector    []float32 // normalised to unit length
+	value     string
+	expiresAt time.Time
+	seq       uint64
+}
+
+// Match is the closest stored entry to a query
+type Match struct {
+	Value      string
+	Similarity float64
+}
+
+// NewVectorIndex creates an index holding up to maxEntries entries across
+// all scopes, evicting the oldest ones beyond that
+func NewVectorIndex(maxEntries int) *VectorIndex {
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	return &VectorIndex{maxEntries: maxEntries, scopes: make(map[string][]*vectorEntry)}
+}
+
+// Add stores value under vector in scope. A ttl of zero or less keeps the
+// entry until it is evicted. Zero vectors can't be compared and are ignored.
+func (x *VectorIndex) Add(scope string, vector []float32, value string, ttl time.Duration) {
+	unit := normalize(vector)
+	if unit == nil {
+		return
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.seq++
+	entry := &vectorEntry{vector: unit, value: value, seq: x.seq}
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+	x.scopes[scope] = append(x.scopes[scope], entry)
+	x.entries++
+
+	for x.entries > x.maxEntries {
+		x.evictOldestLocked()
+	}
+}
+
+// Search returns the entry of scope most similar to vector, ignoring expired
+// entries. ok is false when the scope holds no comparable entry.
+func (x *VectorIndex) Search(scope string, vector []float32) (match Match, ok bool) {
+	unit := normalize(vector)
+	if unit == nil {
+		return Match{}, false
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	now := time.Now()
+	live := x.scopes[scope][:0]
+	best := math.Inf(-1)
+	for _, entry := range x.scopes[scope] {
+		if !entry.expiresAt.IsZero() && !now.Before(entry.expiresAt) {
+			x.entries--
+			continue
+		}
+		live = append(live, entry)
+
+		if len(entry.vector) != len(unit) {
+			continue
+		}
+		// Newer entries win ties
+		if similarity := dot(entry.vector, unit); similarity >= best {
+			best = similarity
+			match = Match{Value: entry.value, Similarity: similarity}
+			ok = true
+		}
+	}
+	x.setScopeLocked(scope, live)
+	return match, ok
+}
+
+// Remove deletes the entries of scope whose similarity to vector is at
+// least threshold, returning how many it removed
+func (x *VectorIndex) Remove(scope string, vector []float32, threshold float64) int {
+	unit := normalize(vector)
+	if unit == nil {
+		return 0
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	kept := x.scopes[scope][:0]
+	removed := 0
+	for _, entry := range x.scopes[scope] {
+		if len(entry.vector) == len(unit) && dot(entry.vector, unit) >= threshold {
+			removed++
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	x.entries -= removed
+	x.setScopeLocked(scope, kept)
+	return removed
+}
+
+// Len returns the number of entries, including expired ones not yet removed
+func (x *VectorIndex) Len() int {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.entries
+}
+
+func (x *VectorIndex) evictOldestLocked() {
+	var oldestScope string
+	oldest := -1
+	var oldestSeq uint64
+	for scope, entries := range x.scopes {
+		// Entries of a scope are kept in insertion order
+		if len(entries) > 0 && (oldest < 0 || entries[0].seq < oldestSeq) {
+			oldestScope, oldest, oldestSeq = scope, 0, entries[0].seq
+		}
+	}
+	if oldest < 0 {
+		return
+	}
+	x.setScopeLocked(oldestScope, x.scopes[oldestScope][1:])
+	x.entries--
+}
+
+func (x *VectorIndex) setScopeLocked(scope string, entries []*vectorEntry) {
+	if len(entries) == 0 {
+		delete(x.scopes, scope)
+		return
+	}
+	x.scopes[scope] = entries
+}
+
+// dot returns the dot product of two vectors of the same length
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i, v := range a {
+		sum += float64(v) * float64(b[i])
+	}
+	return sum
+}
+
+// normalize returns vector scaled to unit length, or nil for a zero vector
+func normalize(vector []float32) []float32 {
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return nil
+	}
+	norm = math.Sqrt(norm)
+
+	unit := make([]float32, len(vector))
+	for i, v := range vector {
+		unit[i] = float32(float64(v) / norm)
+	}
+	return unit
+}
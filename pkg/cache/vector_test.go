@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVectorIndexSearch(t *testing.T) {
+	x := NewVectorIndex(10)
+	x.Add("llama3", []float32{1, 0}, "east", 0)
+	x.Add("llama3", []float32{0, 2}, "north", 0)
+	x.Add("other", []float32{1, 1}, "elsewhere", 0)
+
+	match, ok := x.Search("llama3", []float32{3, 1})
+	if !ok || match.Value != "east" {
+		t.Fatalf("Search() = %+v, %v, want east", match, ok)
+	}
+	if match.Similarity < 0.94 || match.Similarity > 0.95 {
+		t.Errorf("Similarity = %f, want cos(18.4°) ≈ 0.949", match.Similarity)
+	}
+
+	if _, ok := x.Search("missing", []float32{1, 0}); ok {
+		t.Error("Search() of an empty scope found a match")
+	}
+	if _, ok := x.Search("llama3", []float32{0, 0}); ok {
+		t.Error("Search() with a zero vector found a match")
+	}
+}
+
+func TestVectorIndexExpiryAndEviction(t *testing.T) {
+	x := NewVectorIndex(2)
+	x.Add("a", []float32{1, 0}, "first", 0)
+	x.Add("b", []float32{1, 0}, "second", 0)
+	x.Add("a", []float32{0, 1}, "third", 0) // evicts first
+
+	if match, _ := x.Search("a", []float32{1, 0}); match.Value != "third" {
+		t.Errorf("Search() = %q, want the oldest entry evicted", match.Value)
+	}
+	if x.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", x.Len())
+	}
+
+	x.Add("c", []float32{1, 0}, "brief", 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := x.Search("c", []float32{1, 0}); ok {
+		t.Error("Search() returned an expired entry")
+	}
+}
+
+func TestVectorIndexRemove(t *testing.T) {
+	x := NewVectorIndex(10)
+	x.Add("a", []float32{1, 0}, "old", 0)
+	x.Add("a", []float32{0, 1}, "north", 0)
+	x.Add("a", []float32{1, 0}, "new", 0)
+
+	// The newest of equally similar entries wins
+	if match, _ := x.Search("a", []float32{1, 0}); match.Value != "new" {
+		t.Errorf("Search() = %q, want the newest entry", match.Value)
+	}
+
+	if n := x.Remove("a", []float32{2, 0}, 0.9); n != 2 {
+		t.Errorf("Remove() = %d, want 2", n)
+	}
+	if x.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", x.Len())
+	}
+	if match, _ := x.Search("a", []float32{1, 0}); match.Value != "north" {
+		t.Errorf("Search() = %q, want only north left", match.Value)
+	}
+}
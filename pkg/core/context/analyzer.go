@@ -8,6 +8,8 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"threshAI/pkg/llm/ollama"
 )
 
 // Message represents a chat message with metadata
@@ -33,6 +35,10 @@ var (
 	storeMu sync.RWMutex
 )
 
+// generateEndpoint is the Ollama API used for language detection.
+// OLLAMA_API_URL overrides the server.
+var generateEndpoint = ollama.URL() + "/api/generate"
+
 // OllamaResponse represents the response from Ollama API
 type OllamaResponse struct {
 	Response string `json:"response"`
@@ -65,7 +71,7 @@ Respond with ONLY the programming language name in lowercase.`,
 
 	// Call Ollama API
 	resp, err := http.Post(
-		"http://localhost:11434/api/generate",
+		generateEndpoint,
 		"application/json",
 		bytes.NewBuffer(jsonEncode(request)),
 	)
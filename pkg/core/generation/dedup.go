@@ -0,0 +1,277 @@
+package generation
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"threshAI/pkg/llm"
+)
+
+// Deduplicator coalesces identical concurrent requests to a generator so that
+// they share one upstream call. Requests are identical when they have the
+// same kind, options and prompt or conversation; a Deduplicator serves a
+// single provider.
+//
+// The upstream call outlives the caller that started it as long as another
+// caller is waiting for it, and is cancelled once every caller has gone.
+// Streams are shared from their first chunk, so callers joining a stream late
+// still receive all of it.
+type Deduplicator struct {
+	generator Generator
+	provider  string
+	model     string
+	shared    func(provider, model string)
+
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// flight is an upstream call in progress
+type flight struct {
+	cancel context.CancelFunc
+	// waiters counts the callers still interested, guarded by Deduplicator.mu
+	waiters int
+
+	// done is closed once a regular call has returned value and err
+	done  chan struct{}
+	value interface{}
+	err   error
+
+	// started is closed once a stream has started or failed with err. The
+	// chunks received so far are kept for callers joining late.
+	started  chan struct{}
+	mu       sync.Mutex
+	chunks   []llm.Chunk
+	updated  chan struct{}
+	finished bool
+}
+
+// NewDeduplicator wraps generator, which serves provider with model as its
+// default model. shared, if not nil, is called for every request answered by
+// another request's upstream call.
+func NewDeduplicator(generator Generator, provider, model string, shared func(provider, model string)) *Deduplicator {
+	return &Deduplicator{
+		generator: generator,
+		provider:  provider,
+		model:     model,
+		shared:    shared,
+		flights:   make(map[string]*flight),
+	}
+}
+
+func (d *Deduplicator) Generate(ctx context.Context, prompt string, opts llm.GenerateOptions) (string, error) {
+	key, err := dedupKey(ctx, "generate", opts, prompt, nil)
+	if err != nil {
+		return d.generator.Generate(ctx, prompt, opts)
+	}
+	value, err := d.do(ctx, key, opts, func(ctx context.Context) (interface{}, error) {
+		return d.generator.Generate(ctx, prompt, opts)
+	})
+	if err != nil {
+		return "", err
+	}
+	return value.(string), nil
+}
+
+func (d *Deduplicator) GenerateStream(ctx context.Context, prompt string, opts llm.GenerateOptions) (<-chan llm.Chunk, error) {
+	key, err := dedupKey(ctx, "generate_stream", opts, prompt, nil)
+	if err != nil {
+		return Stream(ctx, d.generator, prompt, opts)
+	}
+	return d.stream(ctx, key, opts, func(ctx context.Context) (<-chan llm.Chunk, error) {
+		return Stream(ctx, d.generator, prompt, opts)
+	})
+}
+
+func (d *Deduplicator) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	key, err := dedupKey(ctx, "chat", req.Options, "", &req)
+	if err != nil {
+		return Chat(ctx, d.generator, req)
+	}
+	value, err := d.do(ctx, key, req.Options, func(ctx context.Context) (interface{}, error) {
+		return Chat(ctx, d.generator, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Callers get their own copy of the shared response
+	resp := *value.(*llm.ChatResponse)
+	return &resp, nil
+}
+
+func (d *Deduplicator) ChatStream(ctx context.Context, req llm.ChatRequest) (<-chan llm.Chunk, error) {
+	key, err := dedupKey(ctx, "chat_stream", req.Options, "", &req)
+	if err != nil {
+		return ChatStream(ctx, d.generator, req)
+	}
+	return d.stream(ctx, key, req.Options, func(ctx context.Context) (<-chan llm.Chunk, error) {
+		return ChatStream(ctx, d.generator, req)
+	})
+}
+
+// join returns the flight for key, starting one with start if none is in
+// progress. The caller counts as a waiter of the returned flight.
+func (d *Deduplicator) join(ctx context.Context, key string, opts llm.GenerateOptions, start func(ctx context.Context, f *flight)) *flight {
+	d.mu.Lock()
+	if f, ok := d.flights[key]; ok {
+		f.waiters++
+		d.mu.Unlock()
+		if d.shared != nil {
+			d.shared(d.provider, d.modelOf(opts))
+		}
+		return f
+	}
+
+	// The upstream call keeps the values of the first caller's context but
+	// not its cancellation, which is handled by leave
+	upstream, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	f := &flight{
+		cancel:  cancel,
+		waiters: 1,
+		done:    make(chan struct{}),
+		started: make(chan struct{}),
+		updated: make(chan struct{}),
+	}
+	d.flights[key] = f
+	d.mu.Unlock()
+
+	go func() {
+		start(upstream, f)
+		d.finish(key, f)
+	}()
+	return f
+}
+
+// finish forgets a completed flight so that later requests start afresh
+func (d *Deduplicator) finish(key string, f *flight) {
+	d.mu.Lock()
+	if d.flights[key] == f {
+		delete(d.flights, key)
+	}
+	d.mu.Unlock()
+	f.cancel()
+}
+
+// leave drops a caller that gave up waiting, cancelling the upstream call if
+// it was the last one
+func (d *Deduplicator) leave(key string, f *flight) {
+	d.mu.Lock()
+	f.waiters--
+	last := f.waiters == 0
+	if last && d.flights[key] == f {
+		delete(d.flights, key)
+	}
+	d.mu.Unlock()
+
+	if last {
+		f.cancel()
+	}
+}
+
+func (d *Deduplicator) do(ctx context.Context, key string, opts llm.GenerateOptions, call func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	f := d.join(ctx, key, opts, func(ctx context.Context, f *flight) {
+		f.value, f.err = call(ctx)
+		close(f.done)
+	})
+
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		d.leave(key, f)
+		return nil, ctx.Err()
+	}
+}
+
+func (d *Deduplicator) stream(ctx context.Context, key string, opts llm.GenerateOptions, call func(ctx context.Context) (<-chan llm.Chunk, error)) (<-chan llm.Chunk, error) {
+	f := d.join(ctx, key, opts, func(ctx context.Context, f *flight) {
+		stream, err := call(ctx)
+		f.err = err
+		close(f.started)
+		if err != nil {
+			return
+		}
+
+		for chunk := range stream {
+			f.mu.Lock()
+			f.chunks = append(f.chunks, chunk)
+			close(f.updated)
+			f.updated = make(chan struct{})
+			f.mu.Unlock()
+		}
+
+		f.mu.Lock()
+		f.finished = true
+		close(f.updated)
+		f.mu.Unlock()
+	})
+
+	select {
+	case <-f.started:
+		if f.err != nil {
+			return nil, f.err
+		}
+	case <-ctx.Done():
+		d.leave(key, f)
+		return nil, ctx.Err()
+	}
+
+	ch := make(chan llm.Chunk)
+	go func() {
+		defer close(ch)
+
+		next := 0
+		for {
+			f.mu.Lock()
+			pending := f.chunks[next:]
+			finished, updated := f.finished, f.updated
+			f.mu.Unlock()
+
+			for _, chunk := range pending {
+				select {
+				case ch <- chunk:
+				case <-ctx.Done():
+					d.leave(key, f)
+					return
+				}
+			}
+			next += len(pending)
+			if finished {
+				return
+			}
+
+			select {
+			case <-updated:
+			case <-ctx.Done():
+				d.leave(key, f)
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (d *Deduplicator) modelOf(opts llm.GenerateOptions) string {
+	if opts.Model != "" {
+		return opts.Model
+	}
+	return d.model
+}
+
+// dedupKey identifies a request. Requests that can't be encoded aren't
+// deduplicated. Requests that treat the response cache differently are kept
+// apart, since the shared call runs with the first caller's context.
+func dedupKey(ctx context.Context, kind string, opts llm.GenerateOptions, prompt string, req *llm.ChatRequest) (string, error) {
+	data, err := json.Marshal(struct {
+		Kind    string              `json:"kind"`
+		Cache   string              `json:"cache"`
+		Options llm.GenerateOptions `json:"options"`
+		Prompt  string              `json:"prompt,omitempty"`
+		Chat    *llm.ChatRequest    `json:"chat,omitempty"`
+	}{kind, llm.CacheModeFrom(ctx).String(), opts, prompt, req})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,159 @@
+package generation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"threshAI/pkg/llm"
+)
+
+// gatedGenerator blocks every call until release is closed
+type gatedGenerator struct {
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (g *gatedGenerator) Generate(ctx context.Context, prompt string, opts llm.GenerateOptions) (string, error) {
+	g.calls.Add(1)
+	select {
+	case <-g.release:
+		return "reply to " + prompt, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func (g *gatedGenerator) GenerateStream(ctx context.Context, prompt string, opts llm.GenerateOptions) (<-chan llm.Chunk, error) {
+	g.calls.Add(1)
+	ch := make(chan llm.Chunk)
+	go func() {
+		defer close(ch)
+		for _, chunk := range []llm.Chunk{{Content: "a"}, {Content: "b"}, {Done: true}} {
+			if chunk.Done {
+				select {
+				case <-g.release:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case ch <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func TestDeduplicatorSharesCalls(t *testing.T) {
+	gen := &gatedGenerator{release: make(chan struct{})}
+	var shared atomic.Int32
+	d := NewDeduplicator(gen, "ollama", "llama3", func(provider, model string) {
+		if provider == "ollama" && model == "llama3" {
+			shared.Add(1)
+		}
+	})
+
+	var wg sync.WaitGroup
+	replies := make([]string, 5)
+	for i := range replies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			replies[i], _ = d.Generate(context.Background(), "hi", llm.GenerateOptions{})
+		}(i)
+	}
+	waitFor(t, func() bool { return shared.Load() == 4 })
+	close(gen.release)
+	wg.Wait()
+
+	if n := gen.calls.Load(); n != 1 {
+		t.Errorf("upstream called %d times, want 1", n)
+	}
+	for _, reply := range replies {
+		if reply != "reply to hi" {
+			t.Errorf("reply = %q, want the shared reply", reply)
+		}
+	}
+
+	// Different options make a different request
+	d.Generate(context.Background(), "hi", llm.GenerateOptions{Model: "mistral"})
+	if n := gen.calls.Load(); n != 2 {
+		t.Errorf("upstream called %d times, want 2", n)
+	}
+}
+
+func TestDeduplicatorCancellation(t *testing.T) {
+	gen := &gatedGenerator{release: make(chan struct{})}
+	var shared atomic.Int32
+	d := NewDeduplicator(gen, "ollama", "llama3", func(string, string) { shared.Add(1) })
+
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leader := make(chan error)
+	go func() {
+		_, err := d.Generate(leaderCtx, "hi", llm.GenerateOptions{})
+		leader <- err
+	}()
+	waitFor(t, func() bool { return gen.calls.Load() == 1 })
+
+	follower := make(chan string)
+	go func() {
+		reply, _ := d.Generate(context.Background(), "hi", llm.GenerateOptions{})
+		follower <- reply
+	}()
+	waitFor(t, func() bool { return shared.Load() == 1 })
+
+	// The leader gives up without taking the follower's request with it
+	cancelLeader()
+	if err := <-leader; !errors.Is(err, context.Canceled) {
+		t.Errorf("leader error = %v, want context.Canceled", err)
+	}
+	close(gen.release)
+	if reply := <-follower; reply != "reply to hi" {
+		t.Errorf("follower reply = %q, want the upstream reply", reply)
+	}
+}
+
+func TestDeduplicatorStream(t *testing.T) {
+	gen := &gatedGenerator{release: make(chan struct{})}
+	d := NewDeduplicator(gen, "ollama", "llama3", nil)
+
+	first, err := d.GenerateStream(context.Background(), "hi", llm.GenerateOptions{})
+	if err != nil {
+		t.Fatalf("GenerateStream() error = %v", err)
+	}
+	// Joining after the first chunk still yields the whole stream
+	if chunk := <-first; chunk.Content != "a" {
+		t.Fatalf("first chunk = %+v", chunk)
+	}
+	second, err := d.GenerateStream(context.Background(), "hi", llm.GenerateOptions{})
+	if err != nil {
+		t.Fatalf("GenerateStream() error = %v", err)
+	}
+	close(gen.release)
+
+	rest, _ := Collect(first, nil)
+	all, _ := Collect(second, nil)
+	if rest != "b" || all != "ab" {
+		t.Errorf("streams = %q and %q, want \"b\" and \"ab\"", rest, all)
+	}
+	if n := gen.calls.Load(); n != 1 {
+		t.Errorf("upstream called %d times, want 1", n)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
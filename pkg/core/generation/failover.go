@@ -0,0 +1,244 @@
+package generation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"threshAI/pkg/llm"
+	"threshAI/pkg/logging"
+)
+
+// Backend is one entry of a failover chain
+type Backend struct {
+	// Name identifies the backend in logs and routes, e.g. "deepseek/deepseek-chat"
+	Name      string
+	Generator Generator
+	// Model, when set, replaces the requested model since model names are
+	// specific to a provider
+	Model string
+}
+
+// Attempt records the outcome of trying one backend
+type Attempt struct {
+	Backend string
+	Err     error
+}
+
+// Route records how a failover request was served: the backends tried in
+// order and the one that answered
+type Route struct {
+	Backend  string
+	Attempts []Attempt
+}
+
+type routeKey struct{}
+
+// WithRoute returns a context that records the route taken by failover
+// generators serving requests made with it
+func WithRoute(ctx context.Context) (context.Context, *Route) {
+	route := &Route{}
+	return context.WithValue(ctx, routeKey{}, route), route
+}
+
+func routeFrom(ctx context.Context) *Route {
+	route, _ := ctx.Value(routeKey{}).(*Route)
+	return route
+}
+
+// FailoverError is returned when every backend of a chain failed
+type FailoverError struct {
+	Attempts []Attempt
+}
+
+func (e *FailoverError) Error() string {
+	failures := make([]string, len(e.Attempts))
+	for i, attempt := range e.Attempts {
+		failures[i] = fmt.Sprintf("%s: %v", attempt.Backend, attempt.Err)
+	}
+	return fmt.Sprintf("all backends failed (%s)", strings.Join(failures, "; "))
+}
+
+func (e *FailoverError) Unwrap() []error {
+	errs := make([]error, len(e.Attempts))
+	for i, attempt := range e.Attempts {
+		errs[i] = attempt.Err
+	}
+	return errs
+}
+
+// IsRetryable reports whether a request that failed with err may succeed on
+// another backend. Cancellation by the caller and requests rejected as
+// malformed are fatal; outages, rate limits, open circuit breakers and
+// backend-specific rejections such as bad credentials or an unknown model
+// are retryable.
+func IsRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var apiErr *llm.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+			return false
+		}
+	}
+	return true
+}
+
+// FailoverGenerator tries an ordered list of backends, moving on to the next
+// when one fails with a retryable error. Streams fail over until the first
+// chunk arrives; after that output has been shown and errors are returned
+// as they are.
+type FailoverGenerator struct {
+	backends []Backend
+}
+
+// NewFailover creates a failover generator over the given backends
+func NewFailover(backends ...Backend) (*FailoverGenerator, error) {
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("failover chain has no backends")
+	}
+	for i, b := range backends {
+		if b.Generator == nil {
+			return nil, fmt.Errorf("failover backend %d (%s) has no generator", i, b.Name)
+		}
+	}
+	return &FailoverGenerator{backends: backends}, nil
+}
+
+// Backends returns the names of the backends in the order they are tried
+func (f *FailoverGenerator) Backends() []string {
+	names := make([]string, len(f.backends))
+	for i, b := range f.backends {
+		names[i] = b.Name
+	}
+	return names
+}
+
+func (f *FailoverGenerator) Generate(ctx context.Context, prompt string, opts llm.GenerateOptions) (string, error) {
+	var out string
+	err := f.try(ctx, func(b Backend) error {
+		var err error
+		out, err = Generate(ctx, b.Generator, prompt, b.options(opts))
+		return err
+	})
+	return out, err
+}
+
+func (f *FailoverGenerator) GenerateStream(ctx context.Context, prompt string, opts llm.GenerateOptions) (<-chan llm.Chunk, error) {
+	return f.stream(ctx, func(ctx context.Context, b Backend) (<-chan llm.Chunk, error) {
+		return Stream(ctx, b.Generator, prompt, b.options(opts))
+	})
+}
+
+func (f *FailoverGenerator) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	var resp *llm.ChatResponse
+	err := f.try(ctx, func(b Backend) error {
+		var err error
+		resp, err = Chat(ctx, b.Generator, b.request(req))
+		return err
+	})
+	return resp, err
+}
+
+func (f *FailoverGenerator) ChatStream(ctx context.Context, req llm.ChatRequest) (<-chan llm.Chunk, error) {
+	return f.stream(ctx, func(ctx context.Context, b Backend) (<-chan llm.Chunk, error) {
+		return ChatStream(ctx, b.Generator, b.request(req))
+	})
+}
+
+// try calls fn for each backend in turn until one succeeds or fails fatally
+func (f *FailoverGenerator) try(ctx context.Context, fn func(Backend) error) error {
+	route := routeFrom(ctx)
+	var attempts []Attempt
+	for _, b := range f.backends {
+		err := fn(b)
+		attempts = append(attempts, Attempt{Backend: b.Name, Err: err})
+		if route != nil {
+			route.Attempts = attempts
+		}
+		if err == nil {
+			if route != nil {
+				route.Backend = b.Name
+			}
+			return nil
+		}
+		if !IsRetryable(ctx, err) {
+			return err
+		}
+		logging.Logger.Printf("Backend %s failed, failing over: %v", b.Name, err)
+	}
+	return &FailoverError{Attempts: attempts}
+}
+
+// stream opens a stream on each backend in turn and waits for its first
+// chunk, so a backend that fails before producing output is skipped
+func (f *FailoverGenerator) stream(ctx context.Context, open func(context.Context, Backend) (<-chan llm.Chunk, error)) (<-chan llm.Chunk, error) {
+	var out <-chan llm.Chunk
+	err := f.try(ctx, func(b Backend) error {
+		attemptCtx, cancel := context.WithCancel(ctx)
+		stream, err := open(attemptCtx, b)
+		if err != nil {
+			cancel()
+			return err
+		}
+
+		first, ok := <-stream
+		if !ok {
+			cancel()
+			return fmt.Errorf("stream closed before completion")
+		}
+		if first.Err != nil {
+			cancel()
+			return first.Err
+		}
+
+		out = forward(ctx, first, stream, cancel)
+		return nil
+	})
+	return out, err
+}
+
+// forward replays first and then the rest of stream, cancelling the backend
+// request once the stream ends or the caller goes away
+func forward(ctx context.Context, first llm.Chunk, stream <-chan llm.Chunk, cancel context.CancelFunc) <-chan llm.Chunk {
+	ch := make(chan llm.Chunk)
+	go func() {
+		defer close(ch)
+		defer cancel()
+
+		send := func(chunk llm.Chunk) bool {
+			select {
+			case ch <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		if !send(first) {
+			return
+		}
+		for chunk := range stream {
+			if !send(chunk) {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+func (b Backend) options(opts llm.GenerateOptions) llm.GenerateOptions {
+	if b.Model != "" {
+		opts.Model = b.Model
+	}
+	return opts
+}
+
+func (b Backend) request(req llm.ChatRequest) llm.ChatRequest {
+	req.Options = b.options(req.Options)
+	return req
+}
@@ -0,0 +1,117 @@
+package generation
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"threshAI/pkg/llm"
+)
+
+// stubGenerator answers with a fixed reply or error and records the model
+// it was asked for
+type stubGenerator struct {
+	reply string
+	err   error
+	model string
+}
+
+func (s *stubGenerator) Generate(ctx context.Context, prompt string, opts llm.GenerateOptions) (string, error) {
+	s.model = opts.Model
+	return s.reply, s.err
+}
+
+func (s *stubGenerator) GenerateStream(ctx context.Context, prompt string, opts llm.GenerateOptions) (<-chan llm.Chunk, error) {
+	s.model = opts.Model
+	ch := make(chan llm.Chunk, 2)
+	if s.err != nil {
+		// Fail the way streaming providers do: after the request was accepted
+		ch <- llm.Chunk{Err: s.err}
+	} else {
+		ch <- llm.Chunk{Content: s.reply}
+		ch <- llm.Chunk{Done: true}
+	}
+	close(ch)
+	return ch, nil
+}
+
+func TestFailoverGenerate(t *testing.T) {
+	down := &stubGenerator{err: &llm.APIError{Provider: "deepseek", StatusCode: http.StatusServiceUnavailable}}
+	up := &stubGenerator{reply: "hello"}
+
+	f, err := NewFailover(
+		Backend{Name: "deepseek", Generator: down},
+		Backend{Name: "ollama/llama3", Generator: up, Model: "llama3"},
+	)
+	if err != nil {
+		t.Fatalf("NewFailover() error = %v", err)
+	}
+
+	ctx, route := WithRoute(context.Background())
+	out, err := f.Generate(ctx, "hi", llm.GenerateOptions{Model: "deepseek-chat"})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if out != "hello" {
+		t.Errorf("Generate() = %q, want %q", out, "hello")
+	}
+	if route.Backend != "ollama/llama3" || len(route.Attempts) != 2 {
+		t.Errorf("route = %+v, want answer from ollama/llama3 after 2 attempts", route)
+	}
+	if down.model != "deepseek-chat" || up.model != "llama3" {
+		t.Errorf("models = %q, %q, want request model kept and backend model applied", down.model, up.model)
+	}
+}
+
+func TestFailoverStream(t *testing.T) {
+	f, _ := NewFailover(
+		Backend{Name: "openai", Generator: &stubGenerator{err: errors.New("connection refused")}},
+		Backend{Name: "ollama", Generator: &stubGenerator{reply: "streamed"}},
+	)
+
+	ctx, route := WithRoute(context.Background())
+	stream, err := f.GenerateStream(ctx, "hi", llm.GenerateOptions{})
+	if err != nil {
+		t.Fatalf("GenerateStream() error = %v", err)
+	}
+	// The backend is settled by the time the stream is returned
+	if route.Backend != "ollama" {
+		t.Errorf("route.Backend = %q, want %q", route.Backend, "ollama")
+	}
+
+	out, err := Collect(stream, nil)
+	if err != nil || out != "streamed" {
+		t.Errorf("Collect() = %q, %v, want %q", out, err, "streamed")
+	}
+}
+
+func TestFailoverFatalError(t *testing.T) {
+	next := &stubGenerator{reply: "unused"}
+	f, _ := NewFailover(
+		Backend{Name: "deepseek", Generator: &stubGenerator{err: &llm.APIError{Provider: "deepseek", StatusCode: http.StatusBadRequest}}},
+		Backend{Name: "ollama", Generator: next, Model: "llama3"},
+	)
+
+	_, err := f.Generate(context.Background(), "hi", llm.GenerateOptions{})
+	var apiErr *llm.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Generate() error = %v, want the 400 from deepseek", err)
+	}
+	if next.model != "" {
+		t.Error("failed over after a fatal error")
+	}
+}
+
+func TestFailoverAllFail(t *testing.T) {
+	f, _ := NewFailover(
+		Backend{Name: "a", Generator: &stubGenerator{err: errors.New("a down")}},
+		Backend{Name: "b", Generator: &stubGenerator{err: errors.New("b down")}},
+	)
+
+	_, err := f.Generate(context.Background(), "hi", llm.GenerateOptions{})
+	var failoverErr *FailoverError
+	if !errors.As(err, &failoverErr) || len(failoverErr.Attempts) != 2 {
+		t.Fatalf("Generate() error = %v, want FailoverError with 2 attempts", err)
+	}
+}
@@ -1,47 +1,127 @@
 package generation
 
 import (
-"context"
-"fmt"
+	"context"
+	"fmt"
+	"strings"
 
-"threshAI/pkg/cache"
-"threshAI/pkg/llm/deepseek"
-"threshAI/pkg/llm/ollama"
-"threshAI/pkg/llm/transformer"
+	"threshAI/pkg/llm"
 )
 
+// Generator produces text for a prompt. Options left unset fall back to the
+// defaults the generator was configured with.
 type Generator interface {
-Generate(ctx context.Context, prompt string) (string, error)
+	Generate(ctx context.Context, prompt string, opts llm.GenerateOptions) (string, error)
 }
 
+// StreamGenerator is implemented by generators that can emit output as it is
+// produced. The returned channel is closed once the stream ends.
+type StreamGenerator interface {
+	GenerateStream(ctx context.Context, prompt string, opts llm.GenerateOptions) (<-chan llm.Chunk, error)
+}
+
+// ChatGenerator is implemented by generators that accept a conversation of
+// role/content messages instead of a single prompt
+type ChatGenerator interface {
+	Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error)
+	ChatStream(ctx context.Context, req llm.ChatRequest) (<-chan llm.Chunk, error)
+}
+
+// Embedder turns texts into vectors whose cosine similarity reflects how
+// close the texts are in meaning. Vectors from different embedders or models
+// are not comparable.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// ProviderType names a registered provider
 type ProviderType string
 
 const (
-ProviderOllama      ProviderType = "ollama"
-ProviderDeepSeek    ProviderType = "deepseek"
-ProviderTransformer ProviderType = "transformer"
+	ProviderOllama      ProviderType = "ollama"
+	ProviderDeepSeek    ProviderType = "deepseek"
+	ProviderTransformer ProviderType = "transformer"
+	ProviderOpenAI      ProviderType = "openai"
 )
 
-func NewGenerator(provider ProviderType, config interface{}, cache cache.Cache) (Generator, error) {
-switch provider {
-case ProviderOllama:
-cfg := config.(ollama.Config)
-return ollama.NewAdapter(cfg), nil
-case ProviderDeepSeek:
-cfg := config.(deepseek.Config)
-return deepseek.NewAdapter(cfg, cache), nil
-case ProviderTransformer:
-cfg := config.(transformer.Config)
-adapter, err := transformer.NewAdapter(cfg)
-if err != nil {
-return nil, fmt.Errorf("failed to create transformer adapter: %v", err)
+func Generate(ctx context.Context, generator Generator, prompt string, opts llm.GenerateOptions) (string, error) {
+	return generator.Generate(ctx, prompt, opts)
 }
-return adapter, nil
-default:
-return nil, fmt.Errorf("unknown provider: %s", provider)
+
+// Stream streams the output of generator. Generators without native streaming
+// support produce their full response as a single chunk.
+func Stream(ctx context.Context, generator Generator, prompt string, opts llm.GenerateOptions) (<-chan llm.Chunk, error) {
+	if sg, ok := generator.(StreamGenerator); ok {
+		return sg.GenerateStream(ctx, prompt, opts)
+	}
+
+	output, err := generator.Generate(ctx, prompt, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan llm.Chunk, 2)
+	ch <- llm.Chunk{Content: output}
+	ch <- llm.Chunk{Done: true}
+	close(ch)
+	return ch, nil
 }
+
+// Collect drains a stream, calling onChunk for every fragment of content, and
+// returns the concatenated output
+func Collect(stream <-chan llm.Chunk, onChunk func(string)) (string, error) {
+	var builder strings.Builder
+	for chunk := range stream {
+		if chunk.Err != nil {
+			return builder.String(), chunk.Err
+		}
+		if chunk.Content != "" {
+			builder.WriteString(chunk.Content)
+			if onChunk != nil {
+				onChunk(chunk.Content)
+			}
+		}
+	}
+	return builder.String(), nil
+}
+
+// Chat sends a conversation to generator. Generators without a message API
+// receive the conversation flattened into a single prompt.
+func Chat(ctx context.Context, generator Generator, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	if cg, ok := generator.(ChatGenerator); ok {
+		return cg.Chat(ctx, req)
+	}
+
+	output, err := generator.Generate(ctx, flattenMessages(req.Messages), req.Options)
+	if err != nil {
+		return nil, err
+	}
+	return &llm.ChatResponse{Message: llm.Message{Role: llm.RoleAssistant, Content: output}}, nil
+}
+
+// ChatStream streams the reply to a conversation, falling back to a flattened
+// prompt for generators without a message API
+func ChatStream(ctx context.Context, generator Generator, req llm.ChatRequest) (<-chan llm.Chunk, error) {
+	if cg, ok := generator.(ChatGenerator); ok {
+		return cg.ChatStream(ctx, req)
+	}
+	return Stream(ctx, generator, flattenMessages(req.Messages), req.Options)
+}
+
+// flattenMessages renders a conversation as a plain-text transcript ending
+// with an open assistant turn
+func flattenMessages(messages []llm.Message) string {
+	var builder strings.Builder
+	for _, msg := range messages {
+		builder.WriteString(fmt.Sprintf("%s: %s\n", roleLabel(msg.Role), msg.Content))
+	}
+	builder.WriteString(roleLabel(llm.RoleAssistant) + ":")
+	return builder.String()
 }
 
-func Generate(ctx context.Context, generator Generator, prompt string) (string, error) {
-return generator.Generate(ctx, prompt)
+func roleLabel(role string) string {
+	if role == "" {
+		return ""
+	}
+	return strings.ToUpper(role[:1]) + role[1:]
 }
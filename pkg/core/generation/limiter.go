@@ -0,0 +1,87 @@
+package generation
+
+import (
+	"context"
+
+	"threshAI/pkg/llm"
+	"threshAI/pkg/llm/admission"
+)
+
+// Limiter admits the requests to a generator through an admission
+// controller, so that only a bounded number of them reach the provider at
+// once. A request holds its slot until it returns or, for streams, until the
+// stream ends.
+type Limiter struct {
+	generator  Generator
+	controller *admission.Controller
+}
+
+// NewLimiter wraps generator so that its requests are admitted by controller
+func NewLimiter(generator Generator, controller *admission.Controller) *Limiter {
+	return &Limiter{generator: generator, controller: controller}
+}
+
+func (l *Limiter) Generate(ctx context.Context, prompt string, opts llm.GenerateOptions) (string, error) {
+	release, err := l.controller.Acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	return l.generator.Generate(ctx, prompt, opts)
+}
+
+func (l *Limiter) GenerateStream(ctx context.Context, prompt string, opts llm.GenerateOptions) (<-chan llm.Chunk, error) {
+	return l.stream(ctx, func() (<-chan llm.Chunk, error) {
+		return Stream(ctx, l.generator, prompt, opts)
+	})
+}
+
+func (l *Limiter) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	release, err := l.controller.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return Chat(ctx, l.generator, req)
+}
+
+func (l *Limiter) ChatStream(ctx context.Context, req llm.ChatRequest) (<-chan llm.Chunk, error) {
+	return l.stream(ctx, func() (<-chan llm.Chunk, error) {
+		return ChatStream(ctx, l.generator, req)
+	})
+}
+
+// stream starts a stream once admitted and releases its slot when the
+// stream ends or the caller goes away
+func (l *Limiter) stream(ctx context.Context, start func() (<-chan llm.Chunk, error)) (<-chan llm.Chunk, error) {
+	release, err := l.controller.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := start()
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	ch := make(chan llm.Chunk)
+	go func() {
+		defer close(ch)
+		defer release()
+
+		for chunk := range stream {
+			select {
+			case ch <- chunk:
+			case <-ctx.Done():
+				// The provider stops on cancellation; drain what it has
+				// already produced so it isn't left blocked
+				go func() {
+					for range stream {
+					}
+				}()
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
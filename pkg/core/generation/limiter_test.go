@@ -0,0 +1,36 @@
+package generation
+
+import (
+	"context"
+	"testing"
+
+	"threshAI/pkg/llm"
+	"threshAI/pkg/llm/admission"
+)
+
+func TestLimiterHoldsSlotUntilStreamEnds(t *testing.T) {
+	gen := &gatedGenerator{release: make(chan struct{})}
+	controller := admission.NewController(t.Name(), admission.Config{MaxConcurrent: 1}, nil)
+	l := NewLimiter(gen, controller)
+
+	stream, err := l.GenerateStream(context.Background(), "hi", llm.GenerateOptions{})
+	if err != nil {
+		t.Fatalf("GenerateStream() error = %v", err)
+	}
+	if got := controller.InFlight(); got != 1 {
+		t.Errorf("InFlight() = %d while streaming, want 1", got)
+	}
+
+	close(gen.release)
+	out, err := Collect(stream, nil)
+	if err != nil || out != "ab" {
+		t.Fatalf("Collect() = %q, %v, want %q", out, err, "ab")
+	}
+	// The slot is released after the stream is closed
+	if _, err := l.Generate(context.Background(), "again", llm.GenerateOptions{}); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if got := controller.InFlight(); got != 0 {
+		t.Errorf("InFlight() = %d after requests ended, want 0", got)
+	}
+}
@@ -0,0 +1,204 @@
+package generation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"threshAI/pkg/cache"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Capability flags describe the optional interfaces a provider's generators
+// implement
+type Capability uint
+
+const (
+	CapStream Capability = 1 << iota
+	CapChat
+	CapEmbeddings
+)
+
+// Has reports whether all of the given capability flags are set
+func (c Capability) Has(flags Capability) bool {
+	return c&flags == flags
+}
+
+func (c Capability) String() string {
+	var names []string
+	for _, cap := range []struct {
+		flag Capability
+		name string
+	}{
+		{CapStream, "stream"},
+		{CapChat, "chat"},
+		{CapEmbeddings, "embeddings"},
+	} {
+		if c.Has(cap.flag) {
+			names = append(names, cap.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ",")
+}
+
+// Factory builds a generator from a provider config. Factories must check the
+// config type and return an error rather than panic on a mismatch.
+type Factory func(config interface{}, cache cache.Cache) (Generator, error)
+
+// ConfigDecoder decodes a raw YAML or JSON document into a provider config
+type ConfigDecoder func(raw []byte) (interface{}, error)
+
+// Provider describes a registered generation backend
+type Provider struct {
+	Name         ProviderType
+	Factory      Factory
+	Decode       ConfigDecoder
+	Capabilities Capability
+}
+
+var registry = struct {
+	mu        sync.RWMutex
+	providers map[ProviderType]Provider
+}{
+	providers: make(map[ProviderType]Provider),
+}
+
+// Register adds a provider to the registry. It fails if the provider is
+// incomplete or its name is already taken.
+func Register(p Provider) error {
+	if p.Name == "" {
+		return fmt.Errorf("provider name is required")
+	}
+	if p.Factory == nil {
+		return fmt.Errorf("provider %s has no factory", p.Name)
+	}
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if _, exists := registry.providers[p.Name]; exists {
+		return fmt.Errorf("provider %s already registered", p.Name)
+	}
+	registry.providers[p.Name] = p
+	return nil
+}
+
+// MustRegister is like Register but panics on error. It is intended for
+// providers registering themselves from init.
+func MustRegister(p Provider) {
+	if err := Register(p); err != nil {
+		panic(err)
+	}
+}
+
+// Unregister removes a provider from the registry
+func Unregister(name ProviderType) error {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if _, exists := registry.providers[name]; !exists {
+		return fmt.Errorf("provider %s not registered", name)
+	}
+	delete(registry.providers, name)
+	return nil
+}
+
+// Lookup returns the registered provider with the given name
+func Lookup(name ProviderType) (Provider, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	p, ok := registry.providers[name]
+	return p, ok
+}
+
+// Providers returns all registered providers sorted by name
+func Providers() []Provider {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	providers := make([]Provider, 0, len(registry.providers))
+	for _, p := range registry.providers {
+		providers = append(providers, p)
+	}
+	sort.Slice(providers, func(i, j int) bool {
+		return providers[i].Name < providers[j].Name
+	})
+	return providers
+}
+
+// NewGenerator builds a generator using the named provider's factory
+func NewGenerator(provider ProviderType, config interface{}, cache cache.Cache) (Generator, error) {
+	p, ok := Lookup(provider)
+	if !ok {
+		return nil, fmt.Errorf("unknown provider: %s (registered providers: %s)", provider, providerNames())
+	}
+
+	generator, err := p.Factory(config, cache)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s generator: %w", provider, err)
+	}
+	return generator, nil
+}
+
+// NewGeneratorFromConfig decodes a raw YAML or JSON provider config and builds
+// a generator from it
+func NewGeneratorFromConfig(provider ProviderType, raw []byte, cache cache.Cache) (Generator, error) {
+	p, ok := Lookup(provider)
+	if !ok {
+		return nil, fmt.Errorf("unknown provider: %s (registered providers: %s)", provider, providerNames())
+	}
+	if p.Decode == nil {
+		return nil, fmt.Errorf("provider %s does not support config decoding", provider)
+	}
+
+	config, err := p.Decode(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s config: %w", provider, err)
+	}
+	return NewGenerator(provider, config, cache)
+}
+
+// DecodeYAML returns a ConfigDecoder that unmarshals into a copy of defaults.
+// YAML is a superset of JSON, so JSON documents decode as well.
+func DecodeYAML[T any](defaults T) ConfigDecoder {
+	return func(raw []byte) (interface{}, error) {
+		config := defaults
+		if err := yaml.UnmarshalStrict(raw, &config); err != nil {
+			return nil, err
+		}
+		return config, nil
+	}
+}
+
+// ConfigAs converts a factory config to T, accepting either a value or a
+// pointer and reporting a descriptive error for anything else
+func ConfigAs[T any](config interface{}) (T, error) {
+	var zero T
+	switch c := config.(type) {
+	case T:
+		return c, nil
+	case *T:
+		if c != nil {
+			return *c, nil
+		}
+	}
+	return zero, fmt.Errorf("expected config of type %T, got %T", zero, config)
+}
+
+func providerNames() string {
+	providers := Providers()
+	if len(providers) == 0 {
+		return "none"
+	}
+	names := make([]string, len(providers))
+	for i, p := range providers {
+		names[i] = string(p.Name)
+	}
+	return strings.Join(names, ", ")
+}
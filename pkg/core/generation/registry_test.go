@@ -0,0 +1,130 @@
+package generation
+
+import (
+	"strings"
+	"testing"
+
+	"threshAI/pkg/cache"
+)
+
+type testConfig struct {
+	Model       string  `yaml:"model"`
+	Temperature float64 `yaml:"temperature"`
+}
+
+// registerTest registers a provider for the duration of the test
+func registerTest(t *testing.T, name ProviderType) {
+	t.Helper()
+	err := Register(Provider{
+		Name: name,
+		Factory: func(config interface{}, _ cache.Cache) (Generator, error) {
+			if _, err := ConfigAs[testConfig](config); err != nil {
+				return nil, err
+			}
+			return &countingGenerator{}, nil
+		},
+		Decode:       DecodeYAML(testConfig{Model: "default"}),
+		Capabilities: CapStream | CapChat,
+	})
+	if err != nil {
+		t.Fatalf("Register(%s) error = %v", name, err)
+	}
+	t.Cleanup(func() { Unregister(name) })
+}
+
+func TestRegisterRejectsDuplicates(t *testing.T) {
+	registerTest(t, "test-dup")
+
+	err := Register(Provider{Name: "test-dup", Factory: func(interface{}, cache.Cache) (Generator, error) { return nil, nil }})
+	if err == nil || !strings.Contains(err.Error(), "already registered") {
+		t.Errorf("Register() of a taken name error = %v", err)
+	}
+	if err := Register(Provider{Name: "test-nofactory"}); err == nil {
+		t.Error("Register() without a factory succeeded")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("MustRegister() of a taken name didn't panic")
+		}
+	}()
+	MustRegister(Provider{Name: "test-dup", Factory: func(interface{}, cache.Cache) (Generator, error) { return nil, nil }})
+}
+
+func TestUnregister(t *testing.T) {
+	if err := Register(Provider{Name: "test-gone", Factory: func(interface{}, cache.Cache) (Generator, error) { return nil, nil }}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := Unregister("test-gone"); err != nil {
+		t.Fatalf("Unregister() error = %v", err)
+	}
+	if _, ok := Lookup("test-gone"); ok {
+		t.Error("Lookup() found an unregistered provider")
+	}
+	if err := Unregister("test-gone"); err == nil {
+		t.Error("Unregister() of an unknown provider succeeded")
+	}
+}
+
+func TestNewGeneratorUnknownProvider(t *testing.T) {
+	registerTest(t, "test-known")
+
+	_, err := NewGenerator("test-missing", testConfig{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "unknown provider: test-missing") || !strings.Contains(err.Error(), "test-known") {
+		t.Errorf("NewGenerator() error = %v, want unknown provider listing the registered ones", err)
+	}
+	if _, err := NewGeneratorFromConfig("test-missing", nil, nil); err == nil {
+		t.Error("NewGeneratorFromConfig() of an unknown provider succeeded")
+	}
+
+	// A factory rejecting the config type fails instead of panicking
+	if _, err := NewGenerator("test-known", "not a config", nil); err == nil {
+		t.Error("NewGenerator() with the wrong config type succeeded")
+	}
+}
+
+func TestNewGeneratorFromConfig(t *testing.T) {
+	registerTest(t, "test-yaml")
+
+	if _, err := NewGeneratorFromConfig("test-yaml", []byte("model: small\ntemperature: 0.2\n"), nil); err != nil {
+		t.Errorf("NewGeneratorFromConfig() error = %v", err)
+	}
+	// JSON documents decode as YAML
+	if _, err := NewGeneratorFromConfig("test-yaml", []byte(`{"model": "small"}`), nil); err != nil {
+		t.Errorf("NewGeneratorFromConfig(JSON) error = %v", err)
+	}
+
+	_, err := NewGeneratorFromConfig("test-yaml", []byte("modle: small\n"), nil)
+	if err == nil || !strings.Contains(err.Error(), "invalid test-yaml config") || !strings.Contains(err.Error(), "modle") {
+		t.Errorf("NewGeneratorFromConfig() with a misspelled key error = %v", err)
+	}
+}
+
+func TestDecodeYAMLKeepsDefaults(t *testing.T) {
+	config, err := DecodeYAML(testConfig{Model: "default", Temperature: 0.7})([]byte("temperature: 0.1\n"))
+	if err != nil {
+		t.Fatalf("decode error = %v", err)
+	}
+	if got := config.(testConfig); got.Model != "default" || got.Temperature != 0.1 {
+		t.Errorf("decoded %+v, want the default model and temperature 0.1", got)
+	}
+}
+
+func TestProvidersSortedByName(t *testing.T) {
+	for _, name := range []ProviderType{"test-c", "test-a", "test-b"} {
+		registerTest(t, name)
+	}
+
+	var names []string
+	for _, p := range Providers() {
+		if strings.HasPrefix(string(p.Name), "test-") {
+			names = append(names, string(p.Name))
+		}
+	}
+	if got := strings.Join(names, ","); got != "test-a,test-b,test-c" {
+		t.Errorf("Providers() = %s, want sorted by name", got)
+	}
+	if p, _ := Lookup("test-b"); p.Capabilities.String() != "stream,chat" {
+		t.Errorf("Capabilities = %s, want stream,chat", p.Capabilities)
+	}
+}
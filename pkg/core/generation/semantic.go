@@ -0,0 +1,258 @@
+package generation
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"threshAI/pkg/cache"
+	"threshAI/pkg/llm"
+	"threshAI/pkg/logging"
+)
+
+const (
+	DefaultSemanticThreshold = 0.95
+	DefaultSemanticTTL       = time.Hour
+)
+
+// SemanticConfig tunes a semantic cache. Zero values select the defaults.
+type SemanticConfig struct {
+	// Threshold is the cosine similarity from which a stored prompt counts
+	// as the same as the incoming one
+	Threshold float64
+	// TTL is how long a reply is served from the cache
+	TTL time.Duration
+	// MaxEntries bounds the number of stored prompts
+	MaxEntries int
+}
+
+// SemanticCache answers a request with the reply to an earlier request whose
+// prompt means the same, judged by the cosine similarity of their
+// embeddings. Only requests with the same kind, model, options and, for
+// chats, the same conversation up to the last user message can answer each
+// other. Only deterministic requests, at temperature 0 or seeded, are
+// cached; requests offering tools never are. The cache mode of the request's
+// context is honoured.
+//
+// A SemanticCache serves a single provider. Embedding failures bypass the
+// cache rather than failing the request.
+type SemanticCache struct {
+	generator Generator
+	embedder  Embedder
+	provider  string
+	model     string
+	config    SemanticConfig
+	index     *cache.VectorIndex
+	observe   func(provider, model string, similarity float64, hit bool)
+}
+
+// NewSemanticCache wraps generator, which serves provider with model as its
+// default model, embedding prompts with embedder. observe, if not nil, is
+// called for every lookup with the similarity of the closest stored prompt,
+// or zero when there was none, and whether it was close enough to be served.
+func NewSemanticCache(generator Generator, embedder Embedder, provider, model string, config SemanticConfig, observe func(provider, model string, similarity float64, hit bool)) *SemanticCache {
+	if config.Threshold <= 0 {
+		config.Threshold = DefaultSemanticThreshold
+	}
+	if config.TTL <= 0 {
+		config.TTL = DefaultSemanticTTL
+	}
+	return &SemanticCache{
+		generator: generator,
+		embedder:  embedder,
+		provider:  provider,
+		model:     model,
+		config:    config,
+		index:     cache.NewVectorIndex(config.MaxEntries),
+		observe:   observe,
+	}
+}
+
+// semanticLookup is a request looked up in the cache, kept to store the
+// reply on a miss
+type semanticLookup struct {
+	scope  string
+	vector []float32
+	// refresh is set when the reply replaces the cached ones
+	refresh bool
+}
+
+func (s *SemanticCache) Generate(ctx context.Context, prompt string, opts llm.GenerateOptions) (string, error) {
+	lookup, cached, ok := s.lookup(ctx, "generate", opts, nil, prompt)
+	if ok {
+		return cached, nil
+	}
+	out, err := s.generator.Generate(ctx, prompt, opts)
+	if err == nil {
+		s.store(lookup, out)
+	}
+	return out, err
+}
+
+func (s *SemanticCache) GenerateStream(ctx context.Context, prompt string, opts llm.GenerateOptions) (<-chan llm.Chunk, error) {
+	lookup, cached, ok := s.lookup(ctx, "generate", opts, nil, prompt)
+	if ok {
+		return cachedStream(cached), nil
+	}
+	stream, err := Stream(ctx, s.generator, prompt, opts)
+	if err != nil {
+		return nil, err
+	}
+	return s.storeStream(ctx, lookup, stream), nil
+}
+
+func (s *SemanticCache) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	prefix, text, cacheable := chatLookupParts(req)
+	if !cacheable {
+		return Chat(ctx, s.generator, req)
+	}
+	lookup, cached, ok := s.lookup(ctx, "chat", req.Options, prefix, text)
+	if ok {
+		return &llm.ChatResponse{
+			Message: llm.Message{Role: llm.RoleAssistant, Content: cached},
+			Usage:   &llm.Usage{},
+		}, nil
+	}
+
+	resp, err := Chat(ctx, s.generator, req)
+	if err == nil && len(resp.Message.ToolCalls) == 0 {
+		s.store(lookup, resp.Message.Content)
+	}
+	return resp, err
+}
+
+func (s *SemanticCache) ChatStream(ctx context.Context, req llm.ChatRequest) (<-chan llm.Chunk, error) {
+	prefix, text, cacheable := chatLookupParts(req)
+	if !cacheable {
+		return ChatStream(ctx, s.generator, req)
+	}
+	lookup, cached, ok := s.lookup(ctx, "chat", req.Options, prefix, text)
+	if ok {
+		return cachedStream(cached), nil
+	}
+
+	stream, err := ChatStream(ctx, s.generator, req)
+	if err != nil {
+		return nil, err
+	}
+	return s.storeStream(ctx, lookup, stream), nil
+}
+
+// lookup embeds text and searches the scope of the request for a reply.
+// The returned lookup is nil when the request can't be cached.
+func (s *SemanticCache) lookup(ctx context.Context, kind string, opts llm.GenerateOptions, prefix interface{}, text string) (*semanticLookup, string, bool) {
+	// Replies sampled at a higher temperature are meant to vary
+	mode := llm.CacheModeFrom(ctx)
+	if mode == llm.CacheBypass || !llm.IsDeterministic(opts) {
+		return nil, "", false
+	}
+
+	scope, err := json.Marshal(struct {
+		Kind    string              `json:"kind"`
+		Model   string              `json:"model"`
+		Options llm.GenerateOptions `json:"options"`
+		Prefix  interface{}         `json:"prefix,omitempty"`
+	}{kind, s.modelOf(opts), opts, prefix})
+	if err != nil {
+		return nil, "", false
+	}
+
+	vectors, err := s.embedder.Embed(ctx, []string{text})
+	if err != nil || len(vectors) != 1 {
+		if err != nil {
+			logging.Logger.Printf("Warning: semantic cache bypassed, embedding failed: %v", err)
+		}
+		return nil, "", false
+	}
+
+	lookup := &semanticLookup{scope: string(scope), vector: vectors[0]}
+	if mode == llm.CacheRefresh {
+		lookup.refresh = true
+		return lookup, "", false
+	}
+	match, found := s.index.Search(lookup.scope, lookup.vector)
+	hit := found && match.Similarity >= s.config.Threshold
+	if s.observe != nil {
+		s.observe(s.provider, s.modelOf(opts), match.Similarity, hit)
+	}
+	if hit {
+		return lookup, match.Value, true
+	}
+	return lookup, "", false
+}
+
+func (s *SemanticCache) store(lookup *semanticLookup, reply string) {
+	if lookup == nil {
+		return
+	}
+	if lookup.refresh {
+		// Stale replies would otherwise keep answering matching prompts
+		s.index.Remove(lookup.scope, lookup.vector, s.config.Threshold)
+	}
+	s.index.Add(lookup.scope, lookup.vector, reply, s.config.TTL)
+}
+
+// storeStream forwards a stream, storing its reply once it completes. It
+// stops once the caller goes away; the upstream request, made with the same
+// ctx, ends with it.
+func (s *SemanticCache) storeStream(ctx context.Context, lookup *semanticLookup, stream <-chan llm.Chunk) <-chan llm.Chunk {
+	ch := make(chan llm.Chunk)
+	go func() {
+		defer close(ch)
+
+		send := func(chunk llm.Chunk) bool {
+			select {
+			case ch <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		var reply strings.Builder
+		complete := false
+		for chunk := range stream {
+			reply.WriteString(chunk.Content)
+			if chunk.Done && chunk.Err == nil && len(chunk.ToolCalls) == 0 {
+				complete = true
+			}
+			if !send(chunk) {
+				return
+			}
+		}
+		if complete {
+			s.store(lookup, reply.String())
+		}
+	}()
+	return ch
+}
+
+func (s *SemanticCache) modelOf(opts llm.GenerateOptions) string {
+	if opts.Model != "" {
+		return opts.Model
+	}
+	return s.model
+}
+
+// chatLookupParts splits a conversation into the context that must match
+// exactly and the last user message, which is compared by meaning
+func chatLookupParts(req llm.ChatRequest) (prefix interface{}, text string, cacheable bool) {
+	n := len(req.Messages)
+	if len(req.Tools) > 0 || n == 0 || req.Messages[n-1].Role != llm.RoleUser {
+		return nil, "", false
+	}
+	return struct {
+		Messages []llm.Message       `json:"messages"`
+		Format   *llm.ResponseFormat `json:"format,omitempty"`
+	}{req.Messages[:n-1], req.Format}, req.Messages[n-1].Content, true
+}
+
+// cachedStream emits a cached reply as a single chunk
+func cachedStream(reply string) <-chan llm.Chunk {
+	ch := make(chan llm.Chunk, 2)
+	ch <- llm.Chunk{Content: reply}
+	ch <- llm.Chunk{Done: true, Usage: &llm.Usage{}}
+	close(ch)
+	return ch
+}
@@ -0,0 +1,212 @@
+package generation
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"threshAI/pkg/llm"
+)
+
+// tableEmbedder embeds texts with fixed vectors
+type tableEmbedder map[string][]float32
+
+func (e tableEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = e[text]
+	}
+	return vectors, nil
+}
+
+// greedy are the options of a deterministic request, the only kind cached
+var greedy = llm.GenerateOptions{Temperature: llm.Float64(0)}
+
+// countingGenerator answers every prompt with a numbered reply
+type countingGenerator struct {
+	calls int
+}
+
+func (g *countingGenerator) Generate(ctx context.Context, prompt string, opts llm.GenerateOptions) (string, error) {
+	g.calls++
+	return fmt.Sprintf("reply-%d", g.calls), nil
+}
+
+func TestSemanticCacheServesSimilarPrompts(t *testing.T) {
+	embedder := tableEmbedder{
+		"What is threshAI?":      {1, 0, 0},
+		"what's threshAI":        {0.99, 0.1, 0},
+		"How do I install it?":   {0, 1, 0},
+		"Tell me about threshAI": {0.7, 0.7, 0},
+	}
+	gen := &countingGenerator{}
+	type lookup struct {
+		similarity float64
+		hit        bool
+	}
+	var lookups []lookup
+	s := NewSemanticCache(gen, embedder, "deepseek", "deepseek-chat", SemanticConfig{Threshold: 0.9},
+		func(provider, model string, similarity float64, hit bool) {
+			lookups = append(lookups, lookup{similarity, hit})
+		})
+	ctx := context.Background()
+
+	first, _ := s.Generate(ctx, "What is threshAI?", greedy)
+	second, _ := s.Generate(ctx, "what's threshAI", greedy)
+	if second != first || gen.calls != 1 {
+		t.Errorf("rephrased prompt got %q after %d calls, want the cached %q", second, gen.calls, first)
+	}
+
+	s.Generate(ctx, "How do I install it?", greedy)
+	s.Generate(ctx, "Tell me about threshAI", greedy)
+	if gen.calls != 3 {
+		t.Errorf("calls = %d, want dissimilar prompts sent upstream", gen.calls)
+	}
+
+	// Another model doesn't share the replies
+	s.Generate(ctx, "What is threshAI?", llm.GenerateOptions{Model: "deepseek-reasoner", Temperature: llm.Float64(0)})
+	if gen.calls != 4 {
+		t.Errorf("calls = %d, want a different model sent upstream", gen.calls)
+	}
+
+	if len(lookups) != 5 || lookups[0].hit || !lookups[1].hit || lookups[1].similarity < 0.99 || lookups[3].hit {
+		t.Errorf("lookups = %+v, want only the rephrased prompt served", lookups)
+	}
+}
+
+func TestSemanticCacheChat(t *testing.T) {
+	embedder := tableEmbedder{"hi": {1, 0}, "hello": {1, 0.05}}
+	gen := &countingGenerator{}
+	s := NewSemanticCache(gen, embedder, "ollama", "llama3", SemanticConfig{}, nil)
+	ctx := context.Background()
+
+	chat := func(history string, text string) {
+		req := llm.ChatRequest{
+			Messages: []llm.Message{
+				{Role: llm.RoleSystem, Content: history},
+				{Role: llm.RoleUser, Content: text},
+			},
+			Options: greedy,
+		}
+		stream, err := s.ChatStream(ctx, req)
+		if err != nil {
+			t.Fatalf("ChatStream() error = %v", err)
+		}
+		Collect(stream, nil)
+	}
+
+	chat("be brief", "hi")
+	chat("be brief", "hello")
+	if gen.calls != 1 {
+		t.Errorf("calls = %d, want the similar message served from the cache", gen.calls)
+	}
+	chat("be verbose", "hello")
+	if gen.calls != 2 {
+		t.Errorf("calls = %d, want a different conversation sent upstream", gen.calls)
+	}
+
+	// Requests offering tools always reach the generator
+	req := llm.ChatRequest{
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "hi"}},
+		Tools:    []llm.Tool{{Name: "clock"}},
+		Options:  greedy,
+	}
+	s.Chat(ctx, req)
+	if gen.calls != 3 {
+		t.Errorf("calls = %d, want the tool request sent upstream", gen.calls)
+	}
+}
+
+func TestSemanticCacheHonoursCacheMode(t *testing.T) {
+	embedder := tableEmbedder{"hi": {1, 0}}
+	gen := &countingGenerator{}
+	s := NewSemanticCache(gen, embedder, "ollama", "llama3", SemanticConfig{}, nil)
+	ctx := context.Background()
+
+	// A bypassed reply isn't stored, a refreshed one replaces the cached one
+	s.Generate(llm.WithCacheMode(ctx, llm.CacheBypass), "hi", greedy)
+	if reply, _ := s.Generate(ctx, "hi", greedy); reply != "reply-2" {
+		t.Errorf("Generate() = %q, want the bypassed reply not served", reply)
+	}
+	if reply, _ := s.Generate(llm.WithCacheMode(ctx, llm.CacheRefresh), "hi", greedy); reply != "reply-3" {
+		t.Errorf("refreshed Generate() = %q, want a new reply", reply)
+	}
+	if gen.calls != 3 {
+		t.Errorf("calls = %d, want bypassed and refreshed requests sent upstream", gen.calls)
+	}
+	if reply, _ := s.Generate(ctx, "hi", greedy); reply != "reply-3" {
+		t.Errorf("Generate() = %q, want the refreshed reply served", reply)
+	}
+	if gen.calls != 3 {
+		t.Errorf("calls = %d, want the refreshed reply served", gen.calls)
+	}
+	if n := s.index.Len(); n != 1 {
+		t.Errorf("index holds %d entries, want the stale reply removed", n)
+	}
+}
+
+func TestSemanticCacheSkipsSampledRequests(t *testing.T) {
+	embedder := tableEmbedder{"hi": {1, 0}}
+	gen := &countingGenerator{}
+	s := NewSemanticCache(gen, embedder, "ollama", "llama3", SemanticConfig{}, nil)
+	ctx := context.Background()
+
+	sampled := llm.GenerateOptions{Temperature: llm.Float64(0.8)}
+	s.Generate(ctx, "hi", sampled)
+	if reply, _ := s.Generate(ctx, "hi", sampled); reply != "reply-2" {
+		t.Errorf("Generate() = %q, want sampled requests sent upstream", reply)
+	}
+	if n := s.index.Len(); n != 0 {
+		t.Errorf("index holds %d entries, want sampled replies not stored", n)
+	}
+
+	seeded := llm.GenerateOptions{Temperature: llm.Float64(0.8), Seed: llm.Int(7)}
+	s.Generate(ctx, "hi", seeded)
+	if reply, _ := s.Generate(ctx, "hi", seeded); reply != "reply-3" {
+		t.Errorf("Generate() = %q, want the seeded reply served", reply)
+	}
+}
+
+// endlessStreamer streams chunks until its context is cancelled
+type endlessStreamer struct {
+	countingGenerator
+}
+
+func (g *endlessStreamer) GenerateStream(ctx context.Context, prompt string, opts llm.GenerateOptions) (<-chan llm.Chunk, error) {
+	ch := make(chan llm.Chunk)
+	go func() {
+		defer close(ch)
+		for {
+			select {
+			case ch <- llm.Chunk{Content: "x"}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func TestSemanticCacheStreamStopsWithCaller(t *testing.T) {
+	s := NewSemanticCache(&endlessStreamer{}, tableEmbedder{"hi": {1, 0}}, "ollama", "llama3", SemanticConfig{}, nil)
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	stream, err := s.GenerateStream(ctx, "hi", greedy)
+	if err != nil {
+		t.Fatalf("GenerateStream() error = %v", err)
+	}
+	<-stream
+	// The caller goes away without draining the stream
+	cancel()
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines still running after the caller went away, want %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,143 @@
+package generation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"threshAI/pkg/llm"
+	"threshAI/pkg/llm/schema"
+	"threshAI/pkg/logging"
+)
+
+// DefaultStructuredAttempts bounds the requests made for a structured reply
+// when the caller sets no limit
+const DefaultStructuredAttempts = 3
+
+const structuredInstruction = `Respond only with JSON that conforms to the following JSON Schema. Do not include explanations or markdown formatting.
+%s`
+
+const structuredRetry = `Your reply was rejected: %v
+Reply again with only the corrected JSON.`
+
+// StructuredError is returned when no attempt produced a reply matching the
+// schema. Err is the problem with the last reply.
+type StructuredError struct {
+	Attempts int
+	Reply    string
+	Err      error
+}
+
+func (e *StructuredError) Error() string {
+	return fmt.Sprintf("no valid structured reply after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *StructuredError) Unwrap() error {
+	return e.Err
+}
+
+// GenerateJSON asks generator for a reply conforming to s and returns the
+// validated JSON. The provider's native JSON mode is requested and the schema
+// is spelled out in the conversation for providers without one. Replies
+// wrapped in prose or code fences are repaired; replies that still don't
+// validate are sent back with the validation error, for at most maxAttempts
+// requests in total. Zero or less means DefaultStructuredAttempts.
+func GenerateJSON(ctx context.Context, generator Generator, req llm.ChatRequest, s *schema.Schema, name string, maxAttempts int) (json.RawMessage, error) {
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultStructuredAttempts
+	}
+
+	spec := s.JSON()
+	req.Format = &llm.ResponseFormat{Name: name, Schema: spec}
+	req.Messages = withInstruction(req.Messages, fmt.Sprintf(structuredInstruction, spec))
+
+	var lastErr error
+	var reply string
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		resp, err := Chat(ctx, generator, req)
+		if err != nil {
+			return nil, err
+		}
+		reply = resp.Message.Content
+
+		document := repairJSON(reply)
+		if lastErr = s.ValidateJSON(document); lastErr == nil {
+			return json.RawMessage(document), nil
+		}
+		logging.Logger.Printf("Structured reply rejected (attempt %d/%d): %v", attempt, maxAttempts, lastErr)
+
+		req.Messages = append(req.Messages,
+			llm.Message{Role: llm.RoleAssistant, Content: reply},
+			llm.Message{Role: llm.RoleUser, Content: fmt.Sprintf(structuredRetry, lastErr)},
+		)
+	}
+	return nil, &StructuredError{Attempts: maxAttempts, Reply: reply, Err: lastErr}
+}
+
+// GenerateStruct is like GenerateJSON with the schema derived from the type
+// out points to, into which the validated reply is decoded
+func GenerateStruct(ctx context.Context, generator Generator, req llm.ChatRequest, out interface{}, maxAttempts int) error {
+	t := reflect.TypeOf(out)
+	if t == nil || t.Kind() != reflect.Pointer {
+		return fmt.Errorf("structured output requires a pointer, got %T", out)
+	}
+	s, err := schema.FromType(t.Elem())
+	if err != nil {
+		return err
+	}
+
+	document, err := GenerateJSON(ctx, generator, req, s, strings.ToLower(t.Elem().Name()), maxAttempts)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(document, out); err != nil {
+		return fmt.Errorf("error decoding structured reply: %w", err)
+	}
+	return nil
+}
+
+// withInstruction adds an instruction to the system prompt, creating one if
+// the conversation has none. The messages passed in are not modified.
+func withInstruction(messages []llm.Message, instruction string) []llm.Message {
+	if len(messages) > 0 && messages[0].Role == llm.RoleSystem {
+		out := append([]llm.Message(nil), messages...)
+		out[0].Content = strings.TrimSpace(out[0].Content + "\n\n" + instruction)
+		return out
+	}
+	return append([]llm.Message{{Role: llm.RoleSystem, Content: instruction}}, messages...)
+}
+
+// repairJSON recovers the JSON document from a reply that wraps it in code
+// fences or surrounding prose. Replies without a recognisable document are
+// returned as they are, so that validation reports the problem.
+func repairJSON(reply string) []byte {
+	text := strings.TrimSpace(reply)
+	if json.Valid([]byte(text)) {
+		return []byte(text)
+	}
+
+	if start := strings.Index(text, "```"); start >= 0 {
+		fenced := text[start+3:]
+		// Skip the language tag on the opening fence
+		if newline := strings.IndexByte(fenced, '\n'); newline >= 0 {
+			fenced = fenced[newline+1:]
+		}
+		if end := strings.Index(fenced, "```"); end >= 0 {
+			fenced = strings.TrimSpace(fenced[:end])
+			if json.Valid([]byte(fenced)) {
+				return []byte(fenced)
+			}
+		}
+	}
+
+	for _, delims := range []string{"{}", "[]"} {
+		start := strings.IndexByte(text, delims[0])
+		end := strings.LastIndexByte(text, delims[1])
+		if start >= 0 && end > start && json.Valid([]byte(text[start:end+1])) {
+			return []byte(text[start : end+1])
+		}
+	}
+	return []byte(text)
+}
@@ -0,0 +1,56 @@
+package generation
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"threshAI/pkg/llm"
+	"threshAI/pkg/llm/schema"
+)
+
+type verdict struct {
+	Label      string  `json:"label" jsonschema:"enum=spam,enum=ham"`
+	Confidence float64 `json:"confidence" jsonschema:"minimum=0,maximum=1"`
+}
+
+func TestGenerateStructRepairsAndRetries(t *testing.T) {
+	gen := &scriptedChat{replies: []llm.Message{
+		{Role: llm.RoleAssistant, Content: "Here you go:\n```json\n{\"label\": \"eggs\", \"confidence\": 0.4}\n```"},
+		{Role: llm.RoleAssistant, Content: "```json\n{\"label\": \"spam\", \"confidence\": 0.9}\n```"},
+	}}
+
+	var out verdict
+	req := llm.ChatRequest{Messages: []llm.Message{{Role: llm.RoleUser, Content: "Buy now!!!"}}}
+	if err := GenerateStruct(context.Background(), gen, req, &out, 0); err != nil {
+		t.Fatalf("GenerateStruct() error = %v", err)
+	}
+	if out.Label != "spam" || out.Confidence != 0.9 {
+		t.Errorf("GenerateStruct() = %+v, want the corrected reply", out)
+	}
+
+	// The schema is spelled out up front and the retry explains the rejection
+	first := gen.requests[0]
+	if first[0].Role != llm.RoleSystem || !strings.Contains(first[0].Content, `"confidence"`) {
+		t.Errorf("first request = %+v, want a system message with the schema", first)
+	}
+	retry := gen.requests[1]
+	if last := retry[len(retry)-1]; last.Role != llm.RoleUser || !strings.Contains(last.Content, `$.label: must be one of "spam", "ham"`) {
+		t.Errorf("retry message = %+v, want the validation error", last)
+	}
+}
+
+func TestGenerateJSONGivesUp(t *testing.T) {
+	gen := &scriptedChat{replies: []llm.Message{{Role: llm.RoleAssistant, Content: "I cannot answer that."}}}
+	s, _ := schema.For[verdict]()
+
+	_, err := GenerateJSON(context.Background(), gen, llm.ChatRequest{}, s, "verdict", 2)
+	var serr *StructuredError
+	if !errors.As(err, &serr) {
+		t.Fatalf("GenerateJSON() error = %v, want *StructuredError", err)
+	}
+	if serr.Attempts != 2 || len(gen.requests) != 2 || serr.Reply != "I cannot answer that." {
+		t.Errorf("StructuredError = %+v after %d requests", serr, len(gen.requests))
+	}
+}
@@ -0,0 +1,275 @@
+package generation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"threshAI/pkg/llm"
+	"threshAI/pkg/logging"
+)
+
+// DefaultMaxToolIterations bounds the model turns of a tool loop when the
+// caller sets no limit
+const DefaultMaxToolIterations = 8
+
+// ErrToolIterations is returned when the model keeps calling tools after the
+// iteration limit is reached
+var ErrToolIterations = errors.New("tool call limit reached")
+
+var toolRegistry = struct {
+	mu    sync.RWMutex
+	tools map[string]llm.Tool
+}{
+	tools: make(map[string]llm.Tool),
+}
+
+// RegisterTool makes a tool available to every tool loop that uses the
+// registered tools. It fails if the tool is incomplete or its name is taken.
+func RegisterTool(tool llm.Tool) error {
+	if tool.Name == "" {
+		return fmt.Errorf("tool name is required")
+	}
+	if tool.Handler == nil {
+		return fmt.Errorf("tool %s has no handler", tool.Name)
+	}
+	if len(tool.Parameters) > 0 && !json.Valid(tool.Parameters) {
+		return fmt.Errorf("tool %s has invalid parameter schema", tool.Name)
+	}
+
+	toolRegistry.mu.Lock()
+	defer toolRegistry.mu.Unlock()
+
+	if _, exists := toolRegistry.tools[tool.Name]; exists {
+		return fmt.Errorf("tool %s already registered", tool.Name)
+	}
+	toolRegistry.tools[tool.Name] = tool
+	return nil
+}
+
+// UnregisterTool removes a tool from the registry
+func UnregisterTool(name string) error {
+	toolRegistry.mu.Lock()
+	defer toolRegistry.mu.Unlock()
+
+	if _, exists := toolRegistry.tools[name]; !exists {
+		return fmt.Errorf("tool %s not registered", name)
+	}
+	delete(toolRegistry.tools, name)
+	return nil
+}
+
+// Tools returns all registered tools sorted by name
+func Tools() []llm.Tool {
+	toolRegistry.mu.RLock()
+	defer toolRegistry.mu.RUnlock()
+
+	tools := make([]llm.Tool, 0, len(toolRegistry.tools))
+	for _, tool := range toolRegistry.tools {
+		tools = append(tools, tool)
+	}
+	sort.Slice(tools, func(i, j int) bool {
+		return tools[i].Name < tools[j].Name
+	})
+	return tools
+}
+
+// ToolResult is the outcome of a tool loop
+type ToolResult struct {
+	// Message is the model's final reply
+	Message llm.Message
+	// Messages holds the turns added to the conversation: every assistant
+	// tool call, the tool results and the final reply
+	Messages []llm.Message
+	// Usage sums the usage of all model turns, nil if none was reported
+	Usage *llm.Usage
+	// Iterations is the number of model turns taken
+	Iterations int
+}
+
+// RunTools sends a conversation to generator and executes the tool calls in
+// its replies, feeding the results back until the model answers without
+// calling a tool. Tools are taken from req.Tools. At most maxIterations model
+// turns are taken; zero or less means DefaultMaxToolIterations.
+func RunTools(ctx context.Context, generator Generator, req llm.ChatRequest, maxIterations int) (*ToolResult, error) {
+	if maxIterations <= 0 {
+		maxIterations = DefaultMaxToolIterations
+	}
+
+	result := &ToolResult{}
+	messages := append([]llm.Message(nil), req.Messages...)
+	for result.Iterations < maxIterations {
+		turn := req
+		turn.Messages = messages
+		resp, err := Chat(ctx, generator, turn)
+		if err != nil {
+			return result, err
+		}
+		result.Iterations++
+		result.Usage = addUsage(result.Usage, resp.Usage)
+
+		reply := resp.Message
+		if reply.Role == "" {
+			reply.Role = llm.RoleAssistant
+		}
+		messages = append(messages, reply)
+		result.Messages = append(result.Messages, reply)
+		if len(reply.ToolCalls) == 0 {
+			result.Message = reply
+			return result, nil
+		}
+
+		results := ExecuteToolCalls(ctx, req.Tools, reply.ToolCalls)
+		messages = append(messages, results...)
+		result.Messages = append(result.Messages, results...)
+	}
+	return result, fmt.Errorf("%w after %d iterations", ErrToolIterations, maxIterations)
+}
+
+// StreamTools is the streaming counterpart of RunTools. The content of every
+// model turn is streamed as it arrives and tool calls are executed between
+// turns. The final chunk carries the usage summed over all turns.
+func StreamTools(ctx context.Context, generator Generator, req llm.ChatRequest, maxIterations int) (<-chan llm.Chunk, error) {
+	if maxIterations <= 0 {
+		maxIterations = DefaultMaxToolIterations
+	}
+
+	// The first turn is started synchronously so that errors raised before
+	// any output are returned directly
+	stream, err := ChatStream(ctx, generator, req)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan llm.Chunk)
+	go func() {
+		defer close(ch)
+
+		send := func(chunk llm.Chunk) bool {
+			select {
+			case ch <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		var usage *llm.Usage
+		messages := append([]llm.Message(nil), req.Messages...)
+		for iteration := 1; ; iteration++ {
+			var content strings.Builder
+			var final llm.Chunk
+			for chunk := range stream {
+				if chunk.Err != nil {
+					send(chunk)
+					return
+				}
+				if chunk.Done {
+					// Usage and tool calls are held back until the loop ends
+					final = chunk
+					chunk = llm.Chunk{Content: chunk.Content}
+				}
+				if chunk.Content == "" {
+					continue
+				}
+				content.WriteString(chunk.Content)
+				if !send(chunk) {
+					return
+				}
+			}
+			if !final.Done {
+				// The stream was closed early, e.g. by cancellation
+				send(llm.Chunk{Err: fmt.Errorf("stream ended before completion")})
+				return
+			}
+
+			usage = addUsage(usage, final.Usage)
+			if len(final.ToolCalls) == 0 {
+				send(llm.Chunk{Done: true, Usage: usage})
+				return
+			}
+			if iteration >= maxIterations {
+				send(llm.Chunk{Err: fmt.Errorf("%w after %d iterations", ErrToolIterations, maxIterations)})
+				return
+			}
+
+			messages = append(messages, llm.Message{
+				Role:      llm.RoleAssistant,
+				Content:   content.String(),
+				ToolCalls: final.ToolCalls,
+			})
+			messages = append(messages, ExecuteToolCalls(ctx, req.Tools, final.ToolCalls)...)
+
+			turn := req
+			turn.Messages = messages
+			if stream, err = ChatStream(ctx, generator, turn); err != nil {
+				send(llm.Chunk{Err: err})
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// ExecuteToolCalls runs each call with the matching tool's handler and
+// returns one tool message per call. Unknown tools and handler errors are
+// reported to the model as the call's result so that it can recover.
+func ExecuteToolCalls(ctx context.Context, tools []llm.Tool, calls []llm.ToolCall) []llm.Message {
+	byName := make(map[string]llm.Tool, len(tools))
+	for _, tool := range tools {
+		byName[tool.Name] = tool
+	}
+
+	results := make([]llm.Message, len(calls))
+	for i, call := range calls {
+		results[i] = llm.Message{
+			Role:       llm.RoleTool,
+			Content:    executeToolCall(ctx, byName, call),
+			ToolCallID: call.ID,
+		}
+	}
+	return results
+}
+
+func executeToolCall(ctx context.Context, tools map[string]llm.Tool, call llm.ToolCall) string {
+	tool, ok := tools[call.Name]
+	if !ok || tool.Handler == nil {
+		logging.Logger.Printf("Model called unknown tool %s", call.Name)
+		return fmt.Sprintf("error: unknown tool %q", call.Name)
+	}
+
+	arguments := json.RawMessage(call.Arguments)
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+	if !json.Valid(arguments) {
+		return fmt.Sprintf("error: arguments for %s are not valid JSON", call.Name)
+	}
+
+	logging.Logger.Printf("Calling tool %s", call.Name)
+	output, err := tool.Handler(ctx, arguments)
+	if err != nil {
+		logging.Logger.Printf("Tool %s failed: %v", call.Name, err)
+		return fmt.Sprintf("error: %v", err)
+	}
+	return output
+}
+
+// addUsage returns the sum of two usages, treating nil as unreported
+func addUsage(total, u *llm.Usage) *llm.Usage {
+	if u == nil {
+		return total
+	}
+	if total == nil {
+		total = &llm.Usage{}
+	}
+	return &llm.Usage{
+		PromptTokens:     total.PromptTokens + u.PromptTokens,
+		CompletionTokens: total.CompletionTokens + u.CompletionTokens,
+		TotalTokens:      total.TotalTokens + u.TotalTokens,
+	}
+}
@@ -0,0 +1,158 @@
+package generation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"threshAI/pkg/llm"
+)
+
+// scriptedChat replies with its scripted messages in turn and records the
+// conversations it receives
+type scriptedChat struct {
+	replies  []llm.Message
+	requests [][]llm.Message
+}
+
+func (s *scriptedChat) Generate(ctx context.Context, prompt string, opts llm.GenerateOptions) (string, error) {
+	return "", errors.New("not a chat request")
+}
+
+func (s *scriptedChat) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	reply := s.replies[len(s.requests)%len(s.replies)]
+	s.requests = append(s.requests, req.Messages)
+	return &llm.ChatResponse{Message: reply, Usage: &llm.Usage{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12}}, nil
+}
+
+func (s *scriptedChat) ChatStream(ctx context.Context, req llm.ChatRequest) (<-chan llm.Chunk, error) {
+	resp, _ := s.Chat(ctx, req)
+	ch := make(chan llm.Chunk, 2)
+	ch <- llm.Chunk{Content: resp.Message.Content}
+	ch <- llm.Chunk{Done: true, Usage: resp.Usage, ToolCalls: resp.Message.ToolCalls}
+	close(ch)
+	return ch, nil
+}
+
+func weatherTool() llm.Tool {
+	return llm.Tool{
+		Name:       "get_weather",
+		Parameters: json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}}}`),
+		Handler: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+			var args struct{ City string }
+			if err := json.Unmarshal(arguments, &args); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Sunny in %s", args.City), nil
+		},
+	}
+}
+
+func TestRunTools(t *testing.T) {
+	gen := &scriptedChat{replies: []llm.Message{
+		{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{
+			{ID: "call_1", Name: "get_weather", Arguments: `{"city":"Oslo"}`},
+			{ID: "call_2", Name: "get_time", Arguments: `{}`},
+		}},
+		{Role: llm.RoleAssistant, Content: "It is sunny in Oslo."},
+	}}
+
+	result, err := RunTools(context.Background(), gen, llm.ChatRequest{
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "Weather in Oslo?"}},
+		Tools:    []llm.Tool{weatherTool()},
+	}, 0)
+	if err != nil {
+		t.Fatalf("RunTools() error = %v", err)
+	}
+	if result.Message.Content != "It is sunny in Oslo." || result.Iterations != 2 {
+		t.Errorf("result = %+v, want final reply after 2 iterations", result)
+	}
+	if result.Usage == nil || result.Usage.TotalTokens != 24 {
+		t.Errorf("Usage = %+v, want usage summed over both turns", result.Usage)
+	}
+
+	// The follow-up turn carries the tool call and one result per call
+	followUp := gen.requests[1]
+	if len(followUp) != 4 {
+		t.Fatalf("follow-up messages = %+v, want user, assistant and two tool results", followUp)
+	}
+	if got := followUp[2]; got.Role != llm.RoleTool || got.ToolCallID != "call_1" || got.Content != "Sunny in Oslo" {
+		t.Errorf("first tool result = %+v", got)
+	}
+	if got := followUp[3]; got.ToolCallID != "call_2" || got.Content != `error: unknown tool "get_time"` {
+		t.Errorf("unknown tool result = %+v", got)
+	}
+	if len(result.Messages) != 4 {
+		t.Errorf("Messages = %+v, want the tool call, two results and the reply", result.Messages)
+	}
+}
+
+func TestRunToolsIterationLimit(t *testing.T) {
+	gen := &scriptedChat{replies: []llm.Message{
+		{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{ID: "call_1", Name: "get_weather", Arguments: `{"city":"Oslo"}`}}},
+	}}
+
+	_, err := RunTools(context.Background(), gen, llm.ChatRequest{Tools: []llm.Tool{weatherTool()}}, 3)
+	if !errors.Is(err, ErrToolIterations) {
+		t.Fatalf("RunTools() error = %v, want ErrToolIterations", err)
+	}
+	if len(gen.requests) != 3 {
+		t.Errorf("model turns = %d, want 3", len(gen.requests))
+	}
+}
+
+func TestStreamTools(t *testing.T) {
+	gen := &scriptedChat{replies: []llm.Message{
+		{Role: llm.RoleAssistant, Content: "Checking. ", ToolCalls: []llm.ToolCall{{ID: "call_1", Name: "get_weather", Arguments: `{"city":"Oslo"}`}}},
+		{Role: llm.RoleAssistant, Content: "Sunny."},
+	}}
+
+	stream, err := StreamTools(context.Background(), gen, llm.ChatRequest{Tools: []llm.Tool{weatherTool()}}, 0)
+	if err != nil {
+		t.Fatalf("StreamTools() error = %v", err)
+	}
+	var final llm.Chunk
+	var out string
+	for chunk := range stream {
+		if chunk.Err != nil {
+			t.Fatalf("stream error = %v", chunk.Err)
+		}
+		out += chunk.Content
+		final = chunk
+	}
+	if out != "Checking. Sunny." {
+		t.Errorf("output = %q, want both turns", out)
+	}
+	if !final.Done || final.Usage == nil || final.Usage.TotalTokens != 24 {
+		t.Errorf("final chunk = %+v, want Done with summed usage", final)
+	}
+	if got := gen.requests[1][1]; got.Role != llm.RoleTool || got.Content != "Sunny in Oslo" {
+		t.Errorf("tool result = %+v", got)
+	}
+}
+
+func TestToolRegistry(t *testing.T) {
+	tool := weatherTool()
+	tool.Name = "test_registry_tool"
+	if err := RegisterTool(tool); err != nil {
+		t.Fatalf("RegisterTool() error = %v", err)
+	}
+	defer UnregisterTool(tool.Name)
+
+	if err := RegisterTool(tool); err == nil {
+		t.Error("RegisterTool() accepted a duplicate name")
+	}
+	if err := RegisterTool(llm.Tool{Name: "no_handler"}); err == nil {
+		t.Error("RegisterTool() accepted a tool without handler")
+	}
+
+	found := false
+	for _, registered := range Tools() {
+		found = found || registered.Name == tool.Name
+	}
+	if !found {
+		t.Errorf("Tools() doesn't list %s", tool.Name)
+	}
+}
@@ -1,7 +1,6 @@
 package flags
 
 import (
-	"log"
 	"sync"
 
 	"github.com/spf13/pflag"
@@ -15,7 +14,6 @@ var (
 
 func Init(flags *pflag.FlagSet) {
 	initOnce.Do(func() {
-		log.Println("Initializing flags package")
 		flags.IntVarP(&brutalFlag, "brutal", "b", 0, "Brutalization intensity (0-3)")
 		flags.BoolVarP(&quantumFlag, "quantum", "q", false, "Enable 11D vector smearing")
 	})
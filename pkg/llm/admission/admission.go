@@ -0,0 +1,293 @@
+// Package admission limits the requests in flight to a provider, queueing
+// the rest by priority
+package admission
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMaxQueue     = 64
+	DefaultQueueTimeout = 30 * time.Second
+)
+
+var (
+	// ErrQueueFull is returned when a request arrives while the wait queue
+	// is full
+	ErrQueueFull = errors.New("admission queue full")
+	// ErrQueueTimeout is returned when a request waited longer than the
+	// queue timeout for a free slot
+	ErrQueueTimeout = errors.New("timed out waiting for a free slot")
+)
+
+// Priority orders the requests waiting for a slot. Waiting interactive
+// requests are always admitted before waiting batch requests.
+type Priority int
+
+const (
+	Interactive Priority = iota
+	Batch
+
+	numPriorities
+)
+
+func (p Priority) String() string {
+	switch p {
+	case Batch:
+		return "batch"
+	default:
+		return "interactive"
+	}
+}
+
+type priorityKey struct{}
+
+// WithPriority returns a context whose requests are queued with priority p
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// PriorityFrom returns the priority of requests made with ctx, which is
+// Interactive unless set with WithPriority
+func PriorityFrom(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok && p >= 0 && p < numPriorities {
+		return p
+	}
+	return Interactive
+}
+
+// Config tunes a controller. Zero values of MaxQueue and QueueTimeout select
+// the defaults.
+type Config struct {
+	// MaxConcurrent is the number of requests let through at once
+	MaxConcurrent int
+	// MaxQueue is the number of requests that may wait for a slot, across
+	// all priorities
+	MaxQueue int
+	// QueueTimeout is how long a request waits for a slot before failing
+	QueueTimeout time.Duration
+}
+
+// Observer receives the state of a controller, e.g. to export it as metrics
+type Observer interface {
+	SetQueueDepth(name string, priority string, depth int)
+	SetInFlight(name string, count int)
+	RecordAdmissionRejected(name string, reason string)
+}
+
+// Controller admits at most MaxConcurrent requests at once. Further requests
+// wait in a bounded queue and are admitted by priority, then in arrival
+// order, as slots are released.
+type Controller struct {
+	name     string
+	config   Config
+	observer Observer
+
+	mu       sync.Mutex
+	inFlight int
+	queues   [numPriorities][]*waiter
+}
+
+// waiter is a request queued for a slot
+type waiter struct {
+	ready chan struct{}
+	// admitted is set, under Controller.mu, when the slot is handed over
+	admitted bool
+}
+
+var controllers = struct {
+	mu sync.Mutex
+	m  map[string]*Controller
+}{m: make(map[string]*Controller)}
+
+// For returns the shared controller for a provider, creating it with config
+// and observer on first use. A later call with a non-zero config applies its
+// limits to the controller, keeping the requests in flight and queued; a zero
+// config just looks it up.
+func For(provider string, config Config, observer Observer) *Controller {
+	controllers.mu.Lock()
+	defer controllers.mu.Unlock()
+
+	if c, ok := controllers.m[provider]; ok {
+		if config != (Config{}) {
+			c.reconfigure(config)
+		}
+		return c
+	}
+	c := NewController(provider, config, observer)
+	controllers.m[provider] = c
+	return c
+}
+
+// NewController creates a standalone controller. observer may be nil.
+func NewController(name string, config Config, observer Observer) *Controller {
+	return &Controller{name: name, config: config.withDefaults(), observer: observer}
+}
+
+func (c Config) withDefaults() Config {
+	if c.MaxConcurrent <= 0 {
+		c.MaxConcurrent = 1
+	}
+	if c.MaxQueue <= 0 {
+		c.MaxQueue = DefaultMaxQueue
+	}
+	if c.QueueTimeout <= 0 {
+		c.QueueTimeout = DefaultQueueTimeout
+	}
+	return c
+}
+
+// reconfigure applies new limits. Slots freed by a higher MaxConcurrent go to
+// waiting requests right away; with a lower one, requests in flight finish
+// and their slots are not handed on until the controller is under the limit.
+func (c *Controller) reconfigure(config Config) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.config = config.withDefaults()
+	for c.inFlight < c.config.MaxConcurrent && c.admitNextLocked() {
+		c.inFlight++
+	}
+	c.observeLocked()
+}
+
+// Acquire waits for a slot for a request made with ctx, queueing it with the
+// priority of ctx. Every successful Acquire must be followed by a call to the
+// returned release function once the request is done.
+func (c *Controller) Acquire(ctx context.Context) (release func(), err error) {
+	p := PriorityFrom(ctx)
+
+	c.mu.Lock()
+	config := c.config
+	if c.inFlight < c.config.MaxConcurrent {
+		c.inFlight++
+		c.observeLocked()
+		c.mu.Unlock()
+		return c.releaser(), nil
+	}
+	if c.queuedLocked() >= config.MaxQueue {
+		c.mu.Unlock()
+		c.reject("queue_full")
+		return nil, fmt.Errorf("%s: %w (%d waiting)", c.name, ErrQueueFull, config.MaxQueue)
+	}
+	w := &waiter{ready: make(chan struct{})}
+	c.queues[p] = append(c.queues[p], w)
+	c.observeLocked()
+	c.mu.Unlock()
+
+	timer := time.NewTimer(config.QueueTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-w.ready:
+		return c.releaser(), nil
+	case <-timer.C:
+		err = fmt.Errorf("%s: %w after %s", c.name, ErrQueueTimeout, config.QueueTimeout)
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	c.mu.Lock()
+	if w.admitted {
+		// The slot was handed over as we gave up, so pass it on
+		c.mu.Unlock()
+		c.releaser()()
+	} else {
+		c.removeLocked(p, w)
+		c.observeLocked()
+		c.mu.Unlock()
+	}
+	if errors.Is(err, ErrQueueTimeout) {
+		c.reject("timeout")
+	}
+	return nil, err
+}
+
+// InFlight returns the number of requests holding a slot
+func (c *Controller) InFlight() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.inFlight
+}
+
+// Queued returns the number of requests of priority p waiting for a slot
+func (c *Controller) Queued(p Priority) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.queues[p])
+}
+
+// releaser returns the function releasing one slot, which may be called
+// more than once
+func (c *Controller) releaser() func() {
+	var once sync.Once
+	return func() {
+		once.Do(c.release)
+	}
+}
+
+// release hands the slot to the first waiter of the highest priority, or
+// frees it when nobody is waiting or the controller is over a lowered limit
+func (c *Controller) release() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.inFlight > c.config.MaxConcurrent || !c.admitNextLocked() {
+		c.inFlight--
+	}
+	c.observeLocked()
+}
+
+// admitNextLocked hands a slot to the first waiter of the highest priority,
+// reporting whether anyone was waiting
+func (c *Controller) admitNextLocked() bool {
+	for p := range c.queues {
+		if len(c.queues[p]) == 0 {
+			continue
+		}
+		w := c.queues[p][0]
+		c.queues[p] = c.queues[p][1:]
+		w.admitted = true
+		close(w.ready)
+		return true
+	}
+	return false
+}
+
+func (c *Controller) removeLocked(p Priority, w *waiter) {
+	queue := c.queues[p]
+	for i, queued := range queue {
+		if queued == w {
+			c.queues[p] = append(queue[:i:i], queue[i+1:]...)
+			return
+		}
+	}
+}
+
+func (c *Controller) queuedLocked() int {
+	total := 0
+	for _, queue := range c.queues {
+		total += len(queue)
+	}
+	return total
+}
+
+func (c *Controller) observeLocked() {
+	if c.observer == nil {
+		return
+	}
+	c.observer.SetInFlight(c.name, c.inFlight)
+	for p, queue := range c.queues {
+		c.observer.SetQueueDepth(c.name, Priority(p).String(), len(queue))
+	}
+}
+
+func (c *Controller) reject(reason string) {
+	if c.observer != nil {
+		c.observer.RecordAdmissionRejected(c.name, reason)
+	}
+}
@@ -0,0 +1,200 @@
+package admission
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitQueued waits until n requests of priority p are queued on c
+func waitQueued(t *testing.T, c *Controller, p Priority, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for c.Queued(p) != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Queued(%s) = %d, want %d", p, c.Queued(p), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestControllerLimitsConcurrency(t *testing.T) {
+	c := NewController("test", Config{MaxConcurrent: 2}, nil)
+
+	first, err := c.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	second, err := c.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if got := c.InFlight(); got != 2 {
+		t.Errorf("InFlight() = %d, want 2", got)
+	}
+
+	admitted := make(chan struct{})
+	go func() {
+		release, err := c.Acquire(context.Background())
+		if err != nil {
+			t.Errorf("queued Acquire() error = %v", err)
+			return
+		}
+		close(admitted)
+		release()
+	}()
+	waitQueued(t, c, Interactive, 1)
+
+	first()
+	first() // releasing twice frees a single slot
+	<-admitted
+	second()
+
+	if got := c.InFlight(); got != 0 {
+		t.Errorf("InFlight() = %d after releasing everything, want 0", got)
+	}
+}
+
+func TestControllerAdmitsByPriority(t *testing.T) {
+	c := NewController("test", Config{MaxConcurrent: 1}, nil)
+	hold, err := c.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	enqueue := func(name string, p Priority) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := c.Acquire(WithPriority(context.Background(), p))
+			if err != nil {
+				t.Errorf("Acquire(%s) error = %v", name, err)
+				return
+			}
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			release()
+		}()
+	}
+
+	enqueue("batch1", Batch)
+	waitQueued(t, c, Batch, 1)
+	enqueue("batch2", Batch)
+	waitQueued(t, c, Batch, 2)
+	enqueue("chat", Interactive)
+	waitQueued(t, c, Interactive, 1)
+
+	hold()
+	wg.Wait()
+
+	want := []string{"chat", "batch1", "batch2"}
+	for i := range want {
+		if i >= len(order) || order[i] != want[i] {
+			t.Fatalf("admission order = %v, want %v", order, want)
+		}
+	}
+}
+
+func TestControllerQueueFull(t *testing.T) {
+	c := NewController("test", Config{MaxConcurrent: 1, MaxQueue: 1}, nil)
+	hold, _ := c.Acquire(context.Background())
+	defer hold()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Acquire(ctx)
+	waitQueued(t, c, Interactive, 1)
+
+	if _, err := c.Acquire(context.Background()); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Acquire() error = %v, want ErrQueueFull", err)
+	}
+}
+
+func TestControllerQueueTimeout(t *testing.T) {
+	c := NewController("test", Config{MaxConcurrent: 1, QueueTimeout: 10 * time.Millisecond}, nil)
+	hold, _ := c.Acquire(context.Background())
+
+	if _, err := c.Acquire(context.Background()); !errors.Is(err, ErrQueueTimeout) {
+		t.Errorf("Acquire() error = %v, want ErrQueueTimeout", err)
+	}
+	if got := c.Queued(Interactive); got != 0 {
+		t.Errorf("Queued() = %d after timeout, want 0", got)
+	}
+
+	// The timed out request must not have taken the released slot
+	hold()
+	if got := c.InFlight(); got != 0 {
+		t.Errorf("InFlight() = %d, want 0", got)
+	}
+}
+
+func TestControllerCancelWhileQueued(t *testing.T) {
+	c := NewController("test", Config{MaxConcurrent: 1}, nil)
+	hold, _ := c.Acquire(context.Background())
+	defer hold()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := c.Acquire(ctx)
+		done <- err
+	}()
+	waitQueued(t, c, Interactive, 1)
+	cancel()
+
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Acquire() error = %v, want context.Canceled", err)
+	}
+	if got := c.Queued(Interactive); got != 0 {
+		t.Errorf("Queued() = %d after cancellation, want 0", got)
+	}
+}
+
+func TestForAppliesLaterConfig(t *testing.T) {
+	c := For("for-test", Config{MaxConcurrent: 1}, nil)
+	hold, _ := c.Acquire(context.Background())
+
+	admitted := make(chan func())
+	go func() {
+		release, err := c.Acquire(context.Background())
+		if err != nil {
+			t.Errorf("queued Acquire() error = %v", err)
+		}
+		admitted <- release
+	}()
+	waitQueued(t, c, Interactive, 1)
+
+	// Raising the limit admits the waiting request at once
+	if got := For("for-test", Config{MaxConcurrent: 2}, nil); got != c {
+		t.Fatal("For() returned a new controller")
+	}
+	second := <-admitted
+	if got := c.InFlight(); got != 2 {
+		t.Errorf("InFlight() = %d, want 2", got)
+	}
+
+	// A zero config keeps the limits
+	For("for-test", Config{}, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.Acquire(ctx); err == nil {
+		t.Error("Acquire() over the limit succeeded")
+	}
+
+	// After lowering it, released slots aren't handed on until under the limit
+	For("for-test", Config{MaxConcurrent: 1}, nil)
+	hold()
+	if got := c.InFlight(); got != 1 {
+		t.Errorf("InFlight() = %d after releasing over the limit, want 1", got)
+	}
+	second()
+	if got := c.InFlight(); got != 0 {
+		t.Errorf("InFlight() = %d after releasing everything, want 0", got)
+	}
+}
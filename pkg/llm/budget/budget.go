@@ -0,0 +1,193 @@
+package budget
+
+import (
+	"errors"
+	"fmt"
+
+	"threshAI/pkg/llm"
+)
+
+// DefaultCompletion is the completion budget reserved when the request
+// doesn't set max_tokens. Small windows reserve a quarter of the window
+// instead.
+const DefaultCompletion = 1024
+
+// ErrOverBudget is returned when a prompt doesn't fit its budget even with
+// everything that may be trimmed removed
+var ErrOverBudget = errors.New("prompt exceeds the context window")
+
+// Budget splits a model's context window between the prompt and the
+// completion
+type Budget struct {
+	Counter TokenCounter
+	// Window is the model's context window in tokens
+	Window int
+	// Completion is the part of the window reserved for the reply
+	Completion int
+}
+
+// New returns the budget for model, reserving completion tokens for the
+// reply. A window of zero uses the model's known context window.
+func New(model string, window, completion int) Budget {
+	if window <= 0 {
+		window = ContextWindow(model)
+	}
+	if completion <= 0 {
+		completion = min(DefaultCompletion, window/4)
+	}
+	return Budget{Counter: CounterFor(model), Window: window, Completion: completion}
+}
+
+// Prompt returns the tokens left for the prompt
+func (b Budget) Prompt() int {
+	return b.Window - b.Completion
+}
+
+// Count counts the tokens of text
+func (b Budget) Count(text string) int {
+	return b.Counter.CountTokens(text)
+}
+
+// Truncate returns the longest prefix of text that takes at most tokens
+// tokens, cutting at a rune boundary
+func (b Budget) Truncate(text string, tokens int) string {
+	if b.Count(text) <= tokens {
+		return text
+	}
+	if tokens <= 0 {
+		return ""
+	}
+
+	// Counts grow with the prefix, so the cut can be found by bisection over
+	// the rune boundaries
+	var cuts []int
+	for i := range text {
+		cuts = append(cuts, i)
+	}
+	lo, hi := 0, len(cuts)-1
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if b.Count(text[:cuts[mid]]) <= tokens {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return text[:cuts[lo]]
+}
+
+// Conversation is a chat request split into the parts a budget treats
+// differently. The system prompt is always kept. Context, such as memories
+// recalled for the input, is dropped before the recent History, and both lose
+// their oldest turns first. The Input is truncated only as a last resort.
+type Conversation struct {
+	System  string
+	Context []llm.Message
+	History []llm.Message
+	Input   string
+}
+
+// Fit reports how a conversation was trimmed
+type Fit struct {
+	// Tokens is the size of the trimmed prompt
+	Tokens int
+	// DroppedContext and DroppedHistory count the messages dropped from the
+	// context and the history
+	DroppedContext int
+	DroppedHistory int
+	// InputTruncated is set when the input itself had to be cut
+	InputTruncated bool
+}
+
+// Trimmed reports whether anything was cut
+func (f Fit) Trimmed() bool {
+	return f.DroppedContext > 0 || f.DroppedHistory > 0 || f.InputTruncated
+}
+
+func (f Fit) String() string {
+	s := fmt.Sprintf("%d prompt tokens", f.Tokens)
+	if f.DroppedContext > 0 {
+		s += fmt.Sprintf(", dropped %d context messages", f.DroppedContext)
+	}
+	if f.DroppedHistory > 0 {
+		s += fmt.Sprintf(", dropped %d history messages", f.DroppedHistory)
+	}
+	if f.InputTruncated {
+		s += ", truncated the input"
+	}
+	return s
+}
+
+// FitChat trims c to the prompt budget and returns its messages: the system
+// prompt, the context, the history and the input as a user message. Turns
+// are dropped whole, a user message along with the replies that follow it.
+func (b Budget) FitChat(c Conversation) ([]llm.Message, Fit, error) {
+	var fit Fit
+	system := llm.Message{Role: llm.RoleSystem, Content: c.System}
+	fixed := CountMessages(b.Counter, []llm.Message{system}) + MessageOverhead
+	inputTokens := b.Count(c.Input)
+
+	contextTokens := messageTokens(b.Counter, c.Context)
+	historyTokens := messageTokens(b.Counter, c.History)
+	total := fixed + inputTokens + sum(contextTokens) + sum(historyTokens)
+
+	context, history := c.Context, c.History
+	for total > b.Prompt() && len(context) > 0 {
+		n := turnLength(context)
+		total -= sum(contextTokens[:n])
+		context, contextTokens = context[n:], contextTokens[n:]
+		fit.DroppedContext += n
+	}
+	for total > b.Prompt() && len(history) > 0 {
+		n := turnLength(history)
+		total -= sum(historyTokens[:n])
+		history, historyTokens = history[n:], historyTokens[n:]
+		fit.DroppedHistory += n
+	}
+
+	input := c.Input
+	if total > b.Prompt() {
+		room := b.Prompt() - (total - inputTokens)
+		if room <= 0 {
+			return nil, fit, fmt.Errorf("%w: the system prompt alone takes %d of %d tokens", ErrOverBudget, total-inputTokens, b.Prompt())
+		}
+		input = b.Truncate(input, room)
+		fit.InputTruncated = true
+		total += b.Count(input) - inputTokens
+	}
+	fit.Tokens = total
+
+	messages := make([]llm.Message, 0, 2+len(context)+len(history))
+	messages = append(messages, system)
+	messages = append(messages, context...)
+	messages = append(messages, history...)
+	messages = append(messages, llm.Message{Role: llm.RoleUser, Content: input})
+	return messages, fit, nil
+}
+
+// messageTokens counts the tokens of each message
+func messageTokens(counter TokenCounter, messages []llm.Message) []int {
+	tokens := make([]int, len(messages))
+	for i, msg := range messages {
+		tokens[i] = counter.CountTokens(msg.Content) + MessageOverhead
+	}
+	return tokens
+}
+
+// turnLength returns the number of messages in the first turn of messages:
+// the first message and every non-user message after it
+func turnLength(messages []llm.Message) int {
+	n := 1
+	for n < len(messages) && messages[n].Role != llm.RoleUser {
+		n++
+	}
+	return n
+}
+
+func sum(values []int) int {
+	total := 0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
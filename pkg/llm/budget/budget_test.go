@@ -0,0 +1,216 @@
+package budget
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"threshAI/pkg/llm"
+	"threshAI/pkg/llm/tokenizer"
+)
+
+// wordCounter counts one token per word, so test budgets are easy to follow
+type wordCounter struct{}
+
+func (wordCounter) CountTokens(text string) int {
+	return len(strings.Fields(text))
+}
+
+func words(n int) string {
+	return strings.TrimSpace(strings.Repeat("w ", n))
+}
+
+func turn(user, assistant int) []llm.Message {
+	return []llm.Message{
+		{Role: llm.RoleUser, Content: words(user)},
+		{Role: llm.RoleAssistant, Content: words(assistant)},
+	}
+}
+
+func TestContextWindow(t *testing.T) {
+	tests := []struct {
+		model string
+		want  int
+	}{
+		{"llama3", 8192},
+		{"llama3:8b", 8192},
+		{"llama3.1:70b", 131072},
+		{"Llama3.2", 131072},
+		{"deepseek-chat", 65536},
+		{"gpt-4o-mini", 128000},
+		{"gpt-4", 8192},
+		{"mistralai/mistral-7b-instruct", 32768},
+		{"unknown-model", DefaultContextWindow},
+		{"", DefaultContextWindow},
+	}
+	for _, tt := range tests {
+		if got := ContextWindow(tt.model); got != tt.want {
+			t.Errorf("ContextWindow(%q) = %d, want %d", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestCounterFor(t *testing.T) {
+	text := strings.Repeat("abcdefgh", 100)
+	if got := CounterFor("gpt-4o").CountTokens(text); got != 200 {
+		t.Errorf("gpt-4o estimate = %d, want 200", got)
+	}
+	if got := CounterFor("llama2:13b").CountTokens(text); got != 229 {
+		t.Errorf("llama2 estimate = %d, want 229", got)
+	}
+}
+
+func TestTokenizerCounter(t *testing.T) {
+	tok, err := tokenizer.LoadGPT2Tokenizer("../tokenizer/testdata/gpt2/vocab.json", "../tokenizer/testdata/gpt2/merges.txt")
+	if err != nil {
+		t.Fatalf("LoadGPT2Tokenizer() error = %v", err)
+	}
+	if got := (TokenizerCounter{Tokenizer: tok}).CountTokens("it, of the!"); got != 5 {
+		t.Errorf("CountTokens() = %d, want 5", got)
+	}
+}
+
+func TestNewReservesCompletion(t *testing.T) {
+	if b := New("llama3", 0, 0); b.Window != 8192 || b.Completion != DefaultCompletion {
+		t.Errorf("New(llama3) = window %d, completion %d", b.Window, b.Completion)
+	}
+	// Small windows don't default to a reply larger than the prompt
+	if b := New("local", 512, 0); b.Completion != 128 || b.Prompt() != 384 {
+		t.Errorf("New(512) = completion %d, prompt %d", b.Completion, b.Prompt())
+	}
+	if b := New("llama3", 1000, 200); b.Prompt() != 800 {
+		t.Errorf("Prompt() = %d, want 800", b.Prompt())
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	b := Budget{Counter: wordCounter{}}
+	if got := b.Truncate("one two three four", 2); got != "one two " {
+		t.Errorf("Truncate() = %q, want %q", got, "one two ")
+	}
+	if got := b.Truncate("one two", 5); got != "one two" {
+		t.Errorf("Truncate() = %q, want the text unchanged", got)
+	}
+	if got := b.Truncate("one two", 0); got != "" {
+		t.Errorf("Truncate(0) = %q, want empty", got)
+	}
+
+	// Cuts never split a rune
+	b = Budget{Counter: HeuristicCounter{BytesPerToken: 4}}
+	got := b.Truncate(strings.Repeat("é", 20), 3)
+	if !strings.HasPrefix(strings.Repeat("é", 20), got) || strings.ContainsRune(got, '�') || len(got)%2 != 0 {
+		t.Errorf("Truncate() = %q, cut inside a rune", got)
+	}
+	if b.Count(got) > 3 {
+		t.Errorf("Truncate() left %d tokens, want at most 3", b.Count(got))
+	}
+}
+
+func TestFitChatKeepsEverythingWithinBudget(t *testing.T) {
+	b := Budget{Counter: wordCounter{}, Window: 1000, Completion: 100}
+	c := Conversation{
+		System:  words(10),
+		Context: turn(5, 5),
+		History: turn(5, 5),
+		Input:   words(10),
+	}
+	messages, fit, err := b.FitChat(c)
+	if err != nil {
+		t.Fatalf("FitChat() error = %v", err)
+	}
+	if len(messages) != 6 || fit.Trimmed() {
+		t.Fatalf("FitChat() = %d messages, %+v; want all 6 untrimmed", len(messages), fit)
+	}
+	if want := 40 + 6*MessageOverhead; fit.Tokens != want {
+		t.Errorf("Tokens = %d, want %d", fit.Tokens, want)
+	}
+	if messages[0].Role != llm.RoleSystem || messages[5].Role != llm.RoleUser || messages[5].Content != c.Input {
+		t.Errorf("FitChat() messages = %+v", messages)
+	}
+}
+
+func TestFitChatDropsContextBeforeHistory(t *testing.T) {
+	// Each turn takes 20 + 2*4 tokens; system and input take 10 + 4 each
+	c := Conversation{
+		System:  words(10),
+		Context: append(turn(10, 10), turn(10, 10)...),
+		History: append(turn(10, 10), turn(10, 10)...),
+		Input:   words(10),
+	}
+	b := Budget{Counter: wordCounter{}, Window: 28 + 3*28 + 10, Completion: 10}
+
+	messages, fit, err := b.FitChat(c)
+	if err != nil {
+		t.Fatalf("FitChat() error = %v", err)
+	}
+	if fit.DroppedContext != 2 || fit.DroppedHistory != 0 || fit.InputTruncated {
+		t.Errorf("FitChat() fit = %+v, want the oldest context turn dropped", fit)
+	}
+	if len(messages) != 1+2+4+1 {
+		t.Errorf("FitChat() = %d messages, want 8", len(messages))
+	}
+	if fit.Tokens > b.Prompt() {
+		t.Errorf("Tokens = %d, over the %d budget", fit.Tokens, b.Prompt())
+	}
+
+	// A tighter budget drops all context and then the oldest history
+	b.Window = 28 + 28 + 10
+	messages, fit, err = b.FitChat(c)
+	if err != nil {
+		t.Fatalf("FitChat() error = %v", err)
+	}
+	if fit.DroppedContext != 4 || fit.DroppedHistory != 2 {
+		t.Errorf("FitChat() fit = %+v, want all context and one history turn dropped", fit)
+	}
+	if len(messages) != 4 {
+		t.Errorf("FitChat() = %d messages, want 4", len(messages))
+	}
+}
+
+func TestFitChatDropsWholeTurns(t *testing.T) {
+	// A turn with a tool reply is dropped along with it
+	history := []llm.Message{
+		{Role: llm.RoleUser, Content: words(10)},
+		{Role: llm.RoleAssistant, Content: words(10)},
+		{Role: "tool", Content: words(10)},
+		{Role: llm.RoleUser, Content: words(1)},
+		{Role: llm.RoleAssistant, Content: words(1)},
+	}
+	b := Budget{Counter: wordCounter{}, Window: 40, Completion: 10}
+	messages, fit, err := b.FitChat(Conversation{History: history, Input: words(1)})
+	if err != nil {
+		t.Fatalf("FitChat() error = %v", err)
+	}
+	if fit.DroppedHistory != 3 || len(messages) != 4 {
+		t.Errorf("FitChat() = %d messages, %+v; want the first turn dropped whole", len(messages), fit)
+	}
+}
+
+func TestFitChatTruncatesInputLast(t *testing.T) {
+	b := Budget{Counter: wordCounter{}, Window: 50, Completion: 10}
+	messages, fit, err := b.FitChat(Conversation{
+		System:  words(10),
+		History: turn(10, 10),
+		Input:   words(100),
+	})
+	if err != nil {
+		t.Fatalf("FitChat() error = %v", err)
+	}
+	if fit.DroppedHistory != 2 || !fit.InputTruncated {
+		t.Errorf("FitChat() fit = %+v, want the history dropped and the input truncated", fit)
+	}
+	if got := (wordCounter{}).CountTokens(messages[len(messages)-1].Content); got != 40-18 {
+		t.Errorf("input kept %d words, want %d", got, 40-18)
+	}
+	if fit.Tokens != b.Prompt() {
+		t.Errorf("Tokens = %d, want %d", fit.Tokens, b.Prompt())
+	}
+}
+
+func TestFitChatOverBudget(t *testing.T) {
+	b := Budget{Counter: wordCounter{}, Window: 20, Completion: 10}
+	_, _, err := b.FitChat(Conversation{System: words(20), Input: "hi"})
+	if !errors.Is(err, ErrOverBudget) {
+		t.Errorf("FitChat() error = %v, want ErrOverBudget", err)
+	}
+}
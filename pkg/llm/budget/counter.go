@@ -0,0 +1,89 @@
+// Package budget counts the tokens of prompts and trims conversations and
+// prompt inputs to fit a model's context window
+package budget
+
+import (
+	"strings"
+
+	"threshAI/pkg/llm"
+	"threshAI/pkg/llm/tokenizer"
+)
+
+// MessageOverhead approximates the tokens a chat template adds per message
+const MessageOverhead = 4
+
+// TokenCounter counts the tokens text encodes to for a model
+type TokenCounter interface {
+	CountTokens(text string) int
+}
+
+// TokenizerCounter counts tokens exactly with the model's own tokenizer
+type TokenizerCounter struct {
+	Tokenizer *tokenizer.Tokenizer
+}
+
+func (c TokenizerCounter) CountTokens(text string) int {
+	return len(c.Tokenizer.Tokenize(text))
+}
+
+// HeuristicCounter estimates tokens for models whose vocabulary isn't at
+// hand. BytesPerToken is the average bytes of text per token of the model's
+// vocabulary; estimates err on the high side.
+type HeuristicCounter struct {
+	BytesPerToken float64
+}
+
+func (c HeuristicCounter) CountTokens(text string) int {
+	return tokenizer.EstimateTokensWith(text, c.BytesPerToken)
+}
+
+// Estimate is the heuristic counter for vocabularies of unknown models
+var Estimate = HeuristicCounter{BytesPerToken: 4}
+
+// bytesPerToken lists the model families whose vocabularies pack notably less
+// text into a token than the default, keyed by model name prefix. SentencePiece
+// vocabularies of 32000 tokens split English into shorter pieces than the
+// 100k-token BPE vocabularies of newer models.
+var bytesPerToken = map[string]float64{
+	"llama2":    3.5,
+	"llama-2":   3.5,
+	"codellama": 3.5,
+	"mistral":   3.5,
+	"mixtral":   3.5,
+	"phi3":      3.5,
+	"gemma":     3.5,
+}
+
+// CounterFor returns the heuristic counter for model
+func CounterFor(model string) TokenCounter {
+	if ratio, ok := bytesPerToken[matchPrefix(model, bytesPerToken)]; ok {
+		return HeuristicCounter{BytesPerToken: ratio}
+	}
+	return Estimate
+}
+
+// CountMessages counts the tokens of messages, including the tokens the chat
+// template adds around each
+func CountMessages(counter TokenCounter, messages []llm.Message) int {
+	total := 0
+	for _, msg := range messages {
+		total += counter.CountTokens(msg.Content) + MessageOverhead
+	}
+	return total
+}
+
+// matchPrefix returns the longest key of table that prefixes the model name,
+// ignoring case and any "provider/" or "namespace/" part, or "" when none does
+func matchPrefix[V any](model string, table map[string]V) string {
+	model = strings.ToLower(model)
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	best := ""
+	for prefix := range table {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	return best
+}
@@ -0,0 +1,53 @@
+package budget
+
+// DefaultContextWindow is the window assumed for models missing from the
+// table. It is the smallest window of the models commonly served.
+const DefaultContextWindow = 4096
+
+// contextWindows lists the context windows of known models in tokens, keyed
+// by model name prefix. The longest matching prefix wins, so "llama3.1"
+// overrides "llama3".
+var contextWindows = map[string]int{
+	"deepseek-chat":     65536,
+	"deepseek-reasoner": 65536,
+	"deepseek-coder":    16384,
+	"deepseek-r1":       131072,
+	"gpt-4o":            128000,
+	"gpt-4.1":           1047576,
+	"gpt-4-turbo":       128000,
+	"gpt-4":             8192,
+	"gpt-3.5-turbo":     16385,
+	"o1":                200000,
+	"o3":                200000,
+	"o4-mini":           200000,
+	"llama2":            4096,
+	"llama-2":           4096,
+	"codellama":         16384,
+	"llama3":            8192,
+	"llama-3":           8192,
+	"llama3.1":          131072,
+	"llama3.2":          131072,
+	"llama3.3":          131072,
+	"llama-3.1":         131072,
+	"llama-3.2":         131072,
+	"llama-3.3":         131072,
+	"mistral":           32768,
+	"mixtral":           32768,
+	"qwen2":             32768,
+	"qwen2.5":           32768,
+	"qwen3":             40960,
+	"gemma":             8192,
+	"gemma2":            8192,
+	"gemma3":            131072,
+	"phi3":              4096,
+	"phi4":              16384,
+}
+
+// ContextWindow returns the context window of model in tokens, or
+// DefaultContextWindow for models it doesn't know
+func ContextWindow(model string) int {
+	if window, ok := contextWindows[matchPrefix(model, contextWindows)]; ok {
+		return window
+	}
+	return DefaultContextWindow
+}
@@ -0,0 +1,191 @@
+// Package cassette records generation traffic to a file and replays it, so
+// that tests and CI runs can exercise the full pipeline without a live
+// provider. A Recorder wraps a real generator and appends every completed
+// request to the cassette; a Replayer serves the recorded responses and
+// fails on requests that were never recorded.
+package cassette
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"threshAI/pkg/llm"
+)
+
+// Cassette modes
+const (
+	ModeRecord = "record"
+	ModeReplay = "replay"
+)
+
+// Request kinds
+const (
+	KindGenerate = "generate"
+	KindChat     = "chat"
+	KindEmbed    = "embed"
+)
+
+// formatVersion is written to every cassette to allow format changes later
+const formatVersion = 1
+
+// ErrNoMatch is returned by a Replayer for requests missing from its cassette
+var ErrNoMatch = errors.New("no recorded response matches the request")
+
+// Request is the part of a request that identifies it. Streamed and regular
+// requests of the same kind match each other.
+type Request struct {
+	Prompt   string              `json:"prompt,omitempty"`
+	Messages []llm.Message       `json:"messages,omitempty"`
+	Options  llm.GenerateOptions `json:"options"`
+	Tools    []llm.Tool          `json:"tools,omitempty"`
+	Format   *llm.ResponseFormat `json:"format,omitempty"`
+	Texts    []string            `json:"texts,omitempty"`
+}
+
+// Response is a recorded reply. Chunks keeps the fragments of a streamed
+// reply so that replays stream the same way.
+type Response struct {
+	Content    string         `json:"content,omitempty"`
+	Chunks     []string       `json:"chunks,omitempty"`
+	ToolCalls  []llm.ToolCall `json:"tool_calls,omitempty"`
+	Usage      *llm.Usage     `json:"usage,omitempty"`
+	Embeddings [][]float32    `json:"embeddings,omitempty"`
+}
+
+// Interaction is a recorded request and its response. Provider names the
+// backend that answered; it is informational and not used for matching.
+type Interaction struct {
+	Key      string   `json:"key"`
+	Kind     string   `json:"kind"`
+	Provider string   `json:"provider,omitempty"`
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+type file struct {
+	Version      int           `json:"version"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Cassette holds the interactions of one cassette file. It is safe for
+// concurrent use.
+type Cassette struct {
+	path string
+
+	mu           sync.Mutex
+	interactions []Interaction
+	// served counts the replays of each key, so that a request recorded
+	// several times is answered with its recordings in order
+	served map[string]int
+}
+
+// Load reads the cassette at path. A missing file is an empty cassette that
+// is created on the first recording.
+func Load(path string) (*Cassette, error) {
+	c := &Cassette{path: path, served: make(map[string]int)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %v", err)
+	}
+
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid cassette %s: %v", path, err)
+	}
+	if f.Version != formatVersion {
+		return nil, fmt.Errorf("cassette %s has unsupported version %d", path, f.Version)
+	}
+	c.interactions = f.Interactions
+	return c, nil
+}
+
+// Path returns the file backing the cassette
+func (c *Cassette) Path() string {
+	return c.path
+}
+
+// Interactions returns the recorded interactions in recording order
+func (c *Cassette) Interactions() []Interaction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Interaction(nil), c.interactions...)
+}
+
+// Add appends an interaction and saves the cassette, so that recordings
+// survive processes that exit without cleaning up
+func (c *Cassette) Add(i Interaction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.interactions = append(c.interactions, i)
+	return c.save()
+}
+
+// find returns the next recording of key. Once all recordings have been
+// served the last one is repeated.
+func (c *Cassette) find(key string) (Interaction, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var matches []int
+	for i := range c.interactions {
+		if c.interactions[i].Key == key {
+			matches = append(matches, i)
+		}
+	}
+	if len(matches) == 0 {
+		return Interaction{}, false
+	}
+
+	n := c.served[key]
+	c.served[key] = n + 1
+	if n >= len(matches) {
+		n = len(matches) - 1
+	}
+	return c.interactions[matches[n]], true
+}
+
+func (c *Cassette) save() error {
+	data, err := json.MarshalIndent(file{Version: formatVersion, Interactions: c.interactions}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode cassette: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf("failed to save cassette: %v", err)
+	}
+
+	// Write to a temporary file first so that a crash never leaves a
+	// truncated cassette behind
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to save cassette: %v", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("failed to save cassette: %v", err)
+	}
+	return nil
+}
+
+// Key derives the key a request is matched by
+func Key(kind string, req Request) string {
+	data, err := json.Marshal(struct {
+		Kind    string  `json:"kind"`
+		Request Request `json:"request"`
+	}{kind, req})
+	if err != nil {
+		// Requests only hold JSON-compatible values
+		panic(fmt.Sprintf("cassette: %v", err))
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
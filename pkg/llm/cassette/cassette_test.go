@@ -0,0 +1,149 @@
+package cassette
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"threshAI/pkg/llm"
+)
+
+// echo answers every prompt with its upper-cased text, counting its calls
+type echo struct {
+	calls int
+}
+
+func (e *echo) Generate(ctx context.Context, prompt string, opts llm.GenerateOptions) (string, error) {
+	e.calls++
+	return strings.ToUpper(prompt), nil
+}
+
+func (e *echo) GenerateStream(ctx context.Context, prompt string, opts llm.GenerateOptions) (<-chan llm.Chunk, error) {
+	e.calls++
+	ch := make(chan llm.Chunk, 3)
+	ch <- llm.Chunk{Content: strings.ToUpper(prompt[:2])}
+	ch <- llm.Chunk{Content: strings.ToUpper(prompt[2:])}
+	ch <- llm.Chunk{Done: true, Usage: &llm.Usage{PromptTokens: 1, CompletionTokens: 2}}
+	close(ch)
+	return ch, nil
+}
+
+func collect(t *testing.T, stream <-chan llm.Chunk) ([]string, llm.Chunk) {
+	t.Helper()
+	var chunks []string
+	var done llm.Chunk
+	for chunk := range stream {
+		if chunk.Done {
+			done = chunk
+			continue
+		}
+		chunks = append(chunks, chunk.Content)
+	}
+	return chunks, done
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "session.json")
+	opts := llm.GenerateOptions{Model: "llama3"}
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	inner := &echo{}
+	recorder := NewRecorder(inner, c, "ollama")
+	if _, err := recorder.Generate(ctx, "hello", opts); err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	stream, err := recorder.GenerateStream(ctx, "stream me", opts)
+	if err != nil {
+		t.Fatalf("GenerateStream() error = %v", err)
+	}
+	collect(t, stream)
+
+	// A fresh process sees the recordings on disk
+	c, err = Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if n := len(c.Interactions()); n != 2 {
+		t.Fatalf("cassette has %d interactions, want 2", n)
+	}
+	replayer := NewReplayer(c, "ollama")
+
+	out, err := replayer.Generate(ctx, "hello", opts)
+	if err != nil || out != "HELLO" {
+		t.Errorf("Generate() = %q, %v, want HELLO", out, err)
+	}
+
+	stream, err = replayer.GenerateStream(ctx, "stream me", opts)
+	if err != nil {
+		t.Fatalf("GenerateStream() error = %v", err)
+	}
+	chunks, done := collect(t, stream)
+	if strings.Join(chunks, "|") != "ST|REAM ME" {
+		t.Errorf("replayed chunks = %q, want the recorded chunking", chunks)
+	}
+	if done.Usage == nil || done.Usage.CompletionTokens != 2 {
+		t.Errorf("replayed usage = %+v, want the recorded usage", done.Usage)
+	}
+
+	// Streamed recordings answer regular requests as well
+	if out, err := replayer.Generate(ctx, "stream me", opts); err != nil || out != "STREAM ME" {
+		t.Errorf("Generate() = %q, %v, want STREAM ME", out, err)
+	}
+	if inner.calls != 2 {
+		t.Errorf("inner generator called %d times, want 2", inner.calls)
+	}
+}
+
+func TestReplayUnmatched(t *testing.T) {
+	c, err := Load(filepath.Join(t.TempDir(), "empty.json"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	replayer := NewReplayer(c, "ollama")
+
+	_, err = replayer.Generate(context.Background(), "never recorded", llm.GenerateOptions{Model: "llama3"})
+	if !errors.Is(err, ErrNoMatch) {
+		t.Fatalf("Generate() error = %v, want ErrNoMatch", err)
+	}
+	if !strings.Contains(err.Error(), `"never recorded"`) {
+		t.Errorf("error %q does not describe the request", err)
+	}
+
+	// Options are part of the match
+	_, err = replayer.Chat(context.Background(), llm.ChatRequest{
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "hi"}},
+	})
+	if !errors.Is(err, ErrNoMatch) {
+		t.Errorf("Chat() error = %v, want ErrNoMatch", err)
+	}
+}
+
+func TestReplayRepeatedRequests(t *testing.T) {
+	c, err := Load(filepath.Join(t.TempDir(), "repeat.json"))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	req := Request{Prompt: "roll a die"}
+	for _, content := range []string{"3", "5"} {
+		c.Add(Interaction{Key: Key(KindGenerate, req), Kind: KindGenerate, Request: req, Response: Response{Content: content}})
+	}
+
+	replayer := NewReplayer(c, "ollama")
+	var got []string
+	for i := 0; i < 3; i++ {
+		out, err := replayer.Generate(context.Background(), "roll a die", llm.GenerateOptions{})
+		if err != nil {
+			t.Fatalf("Generate() error = %v", err)
+		}
+		got = append(got, out)
+	}
+	if strings.Join(got, ",") != "3,5,5" {
+		t.Errorf("replies = %v, want recordings in order then the last repeated", got)
+	}
+}
@@ -0,0 +1,130 @@
+package cassette
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"threshAI/pkg/core/generation"
+	"threshAI/pkg/llm"
+	"threshAI/pkg/logging"
+)
+
+// Recorder passes requests to a generator and records every successful
+// response. Failed requests are not recorded.
+type Recorder struct {
+	generator generation.Generator
+	cassette  *Cassette
+	provider  string
+}
+
+// NewRecorder wraps generator, recording its responses to cassette under
+// the given provider name
+func NewRecorder(generator generation.Generator, cassette *Cassette, provider string) *Recorder {
+	return &Recorder{generator: generator, cassette: cassette, provider: provider}
+}
+
+func (r *Recorder) Generate(ctx context.Context, prompt string, opts llm.GenerateOptions) (string, error) {
+	out, err := r.generator.Generate(ctx, prompt, opts)
+	if err != nil {
+		return "", err
+	}
+	r.record(KindGenerate, Request{Prompt: prompt, Options: opts}, Response{Content: out})
+	return out, nil
+}
+
+func (r *Recorder) GenerateStream(ctx context.Context, prompt string, opts llm.GenerateOptions) (<-chan llm.Chunk, error) {
+	stream, err := generation.Stream(ctx, r.generator, prompt, opts)
+	if err != nil {
+		return nil, err
+	}
+	return r.observe(ctx, stream, KindGenerate, Request{Prompt: prompt, Options: opts}), nil
+}
+
+func (r *Recorder) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	resp, err := generation.Chat(ctx, r.generator, req)
+	if err != nil {
+		return nil, err
+	}
+	r.record(KindChat, chatRequest(req), Response{
+		Content:   resp.Message.Content,
+		ToolCalls: resp.Message.ToolCalls,
+		Usage:     resp.Usage,
+	})
+	return resp, nil
+}
+
+func (r *Recorder) ChatStream(ctx context.Context, req llm.ChatRequest) (<-chan llm.Chunk, error) {
+	stream, err := generation.ChatStream(ctx, r.generator, req)
+	if err != nil {
+		return nil, err
+	}
+	return r.observe(ctx, stream, KindChat, chatRequest(req)), nil
+}
+
+// Embed records the embeddings of the wrapped generator, which must
+// implement generation.Embedder
+func (r *Recorder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	embedder, ok := r.generator.(generation.Embedder)
+	if !ok {
+		return nil, fmt.Errorf("provider %s does not support embeddings", r.provider)
+	}
+	embeddings, err := embedder.Embed(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	r.record(KindEmbed, Request{Texts: texts}, Response{Embeddings: embeddings})
+	return embeddings, nil
+}
+
+// observe forwards a stream, recording it once it completes
+func (r *Recorder) observe(ctx context.Context, stream <-chan llm.Chunk, kind string, req Request) <-chan llm.Chunk {
+	ch := make(chan llm.Chunk)
+	go func() {
+		defer close(ch)
+
+		var chunks []string
+		for chunk := range stream {
+			if chunk.Content != "" {
+				chunks = append(chunks, chunk.Content)
+			}
+			if chunk.Done {
+				r.record(kind, req, Response{
+					Content:   strings.Join(chunks, ""),
+					Chunks:    chunks,
+					ToolCalls: chunk.ToolCalls,
+					Usage:     chunk.Usage,
+				})
+			}
+
+			select {
+			case ch <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+func (r *Recorder) record(kind string, req Request, resp Response) {
+	err := r.cassette.Add(Interaction{
+		Key:      Key(kind, req),
+		Kind:     kind,
+		Provider: r.provider,
+		Request:  req,
+		Response: resp,
+	})
+	if err != nil {
+		logging.Logger.Printf("Warning: failed to record %s request: %v", kind, err)
+	}
+}
+
+func chatRequest(req llm.ChatRequest) Request {
+	return Request{
+		Messages: req.Messages,
+		Options:  req.Options,
+		Tools:    req.Tools,
+		Format:   req.Format,
+	}
+}
@@ -0,0 +1,119 @@
+package cassette
+
+import (
+	"context"
+	"fmt"
+
+	"threshAI/pkg/llm"
+)
+
+// Replayer answers requests from a cassette without contacting any
+// provider. Requests that were never recorded fail with ErrNoMatch.
+type Replayer struct {
+	cassette *Cassette
+	provider string
+}
+
+// NewReplayer serves the interactions recorded in cassette. provider is only
+// used in error messages.
+func NewReplayer(cassette *Cassette, provider string) *Replayer {
+	return &Replayer{cassette: cassette, provider: provider}
+}
+
+func (r *Replayer) Generate(ctx context.Context, prompt string, opts llm.GenerateOptions) (string, error) {
+	resp, err := r.lookup(KindGenerate, Request{Prompt: prompt, Options: opts})
+	if err != nil {
+		return "", err
+	}
+	return resp.Content, nil
+}
+
+func (r *Replayer) GenerateStream(ctx context.Context, prompt string, opts llm.GenerateOptions) (<-chan llm.Chunk, error) {
+	resp, err := r.lookup(KindGenerate, Request{Prompt: prompt, Options: opts})
+	if err != nil {
+		return nil, err
+	}
+	return replay(ctx, resp), nil
+}
+
+func (r *Replayer) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	resp, err := r.lookup(KindChat, chatRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	return &llm.ChatResponse{
+		Message: llm.Message{Role: llm.RoleAssistant, Content: resp.Content, ToolCalls: resp.ToolCalls},
+		Usage:   resp.Usage,
+	}, nil
+}
+
+func (r *Replayer) ChatStream(ctx context.Context, req llm.ChatRequest) (<-chan llm.Chunk, error) {
+	resp, err := r.lookup(KindChat, chatRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	return replay(ctx, resp), nil
+}
+
+func (r *Replayer) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	resp, err := r.lookup(KindEmbed, Request{Texts: texts})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Embeddings, nil
+}
+
+func (r *Replayer) lookup(kind string, req Request) (Response, error) {
+	key := Key(kind, req)
+	interaction, ok := r.cassette.find(key)
+	if !ok {
+		return Response{}, fmt.Errorf("%s: %w (%s request %s, provider %s, %s)",
+			r.cassette.Path(), ErrNoMatch, kind, key[:12], r.provider, describe(req))
+	}
+	return interaction.Response, nil
+}
+
+// replay streams a recorded response with its original chunking
+func replay(ctx context.Context, resp Response) <-chan llm.Chunk {
+	chunks := resp.Chunks
+	if len(chunks) == 0 && resp.Content != "" {
+		chunks = []string{resp.Content}
+	}
+
+	ch := make(chan llm.Chunk)
+	go func() {
+		defer close(ch)
+		for _, content := range chunks {
+			select {
+			case ch <- llm.Chunk{Content: content}:
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case ch <- llm.Chunk{Done: true, Usage: resp.Usage, ToolCalls: resp.ToolCalls}:
+		case <-ctx.Done():
+		}
+	}()
+	return ch
+}
+
+// describe summarises a request for error messages
+func describe(req Request) string {
+	var text string
+	switch {
+	case req.Prompt != "":
+		text = req.Prompt
+	case len(req.Messages) > 0:
+		text = req.Messages[len(req.Messages)-1].Content
+	case len(req.Texts) > 0:
+		text = req.Texts[0]
+	}
+	if runes := []rune(text); len(runes) > 60 {
+		text = string(runes[:60]) + "..."
+	}
+	if req.Options.Model != "" {
+		return fmt.Sprintf("model %s, %q", req.Options.Model, text)
+	}
+	return fmt.Sprintf("%q", text)
+}
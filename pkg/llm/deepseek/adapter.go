@@ -2,24 +2,144 @@ package deepseek
 
 import (
 	"context"
+	"time"
+
 	"threshAI/pkg/cache"
+	"threshAI/pkg/llm"
+	"threshAI/pkg/llm/transport"
 )
 
 type Config struct {
-	BaseURL string
-	APIKey  string
+	BaseURL string `yaml:"base_url" json:"base_url"`
+	APIKey  string `yaml:"api_key" json:"api_key"`
+	// Options are the defaults for every request made through the adapter
+	Options llm.GenerateOptions `yaml:"options" json:"options"`
+	// Transport configures retries, timeouts and circuit breaking
+	Transport transport.Config `yaml:"transport" json:"transport"`
+	// CacheTTL is how long replies are cached; zero selects DefaultCacheTTL
+	CacheTTL time.Duration `yaml:"cache_ttl" json:"cache_ttl"`
 }
 
 type Adapter struct {
-	client *Client
+	client  *Client
+	options llm.GenerateOptions
 }
 
 func NewAdapter(config Config, cache cache.Cache) *Adapter {
 	return &Adapter{
-		client: NewClient(config.BaseURL, config.APIKey, cache),
+		client:  NewBuilder(config.BaseURL, config.APIKey, cache).WithTransport(config.Transport).WithCacheTTL(config.CacheTTL).Build(),
+		options: config.Options,
+	}
+}
+
+func (a *Adapter) Generate(ctx context.Context, prompt string, opts llm.GenerateOptions) (string, error) {
+	return a.client.Generate(ctx, prompt, a.options.Merge(opts))
+}
+
+func (a *Adapter) GenerateStream(ctx context.Context, prompt string, opts llm.GenerateOptions) (<-chan llm.Chunk, error) {
+	return a.client.GenerateStream(ctx, prompt, a.options.Merge(opts))
+}
+
+func (a *Adapter) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	msg, usage, err := a.client.Chat(ctx, a.newRequest(req))
+	if err != nil {
+		return nil, err
 	}
+	return &llm.ChatResponse{
+		Message: llm.Message{Role: msg.Role, Content: msg.Content, ToolCalls: toToolCalls(msg.ToolCalls)},
+		Usage:   toUsage(usage),
+	}, nil
 }
 
-func (a *Adapter) Generate(ctx context.Context, prompt string) (string, error) {
-	return a.client.Generate(ctx, prompt)
+func (a *Adapter) ChatStream(ctx context.Context, req llm.ChatRequest) (<-chan llm.Chunk, error) {
+	return a.client.ChatStream(ctx, a.newRequest(req))
+}
+
+// newRequest maps a chat request onto the wire format, applying the adapter's
+// default options. DeepSeek's JSON mode can't enforce a schema, so only the
+// request for JSON is passed on.
+func (a *Adapter) newRequest(req llm.ChatRequest) Request {
+	reqBody := NewRequest(toMessages(req.Messages), a.options.Merge(req.Options))
+	reqBody.Tools = toTools(req.Tools)
+	if req.Format != nil {
+		reqBody.ResponseFormat = &ResponseFormat{Type: "json_object"}
+	}
+	return reqBody
+}
+
+func toMessages(messages []llm.Message) []Message {
+	out := make([]Message, len(messages))
+	for i, msg := range messages {
+		out[i] = Message{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			ToolCalls:  fromToolCalls(msg.ToolCalls),
+			ToolCallID: msg.ToolCallID,
+		}
+	}
+	return out
+}
+
+func fromMessages(messages []Message) []llm.Message {
+	out := make([]llm.Message, len(messages))
+	for i, msg := range messages {
+		out[i] = llm.Message{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			ToolCalls:  toToolCalls(msg.ToolCalls),
+			ToolCallID: msg.ToolCallID,
+		}
+	}
+	return out
+}
+
+func toTools(tools []llm.Tool) []Tool {
+	if len(tools) == 0 {
+		return nil
+	}
+	out := make([]Tool, len(tools))
+	for i, tool := range tools {
+		out[i] = Tool{
+			Type: "function",
+			Function: FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		}
+	}
+	return out
+}
+
+func toToolCalls(calls []ToolCall) []llm.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	out := make([]llm.ToolCall, len(calls))
+	for i, call := range calls {
+		out[i] = llm.ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		}
+	}
+	return out
+}
+
+func fromToolCalls(calls []llm.ToolCall) []ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	out := make([]ToolCall, len(calls))
+	for i, call := range calls {
+		out[i] = ToolCall{
+			ID:   call.ID,
+			Type: "function",
+			Function: FunctionCall{
+				Name:      call.Name,
+				Arguments: call.Arguments,
+			},
+		}
+	}
+	return out
 }
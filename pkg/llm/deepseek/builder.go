@@ -2,6 +2,8 @@ package deepseek
 
 import (
 	"threshAI/pkg/cache"
+	"threshAI/pkg/core/generation"
+	"threshAI/pkg/llm/transport"
 	"time"
 )
 
@@ -20,10 +22,36 @@ func NewBuilder(baseURL, apiKey string, cache cache.Cache) *Builder {
 	}
 }
 
+// WithTimeout sets the timeout of each attempt of a non-streaming request
 func (b *Builder) WithTimeout(timeout time.Duration) *Builder {
+	b.config.Transport.Timeout = timeout
+	return b
+}
+
+// WithMaxRetries sets how often transient failures are retried; a negative
+// value disables retries
+func (b *Builder) WithMaxRetries(retries int) *Builder {
+	b.config.Transport.MaxRetries = retries
+	return b
+}
+
+// WithTransport replaces the whole transport configuration
+func (b *Builder) WithTransport(config transport.Config) *Builder {
+	b.config.Transport = config
+	return b
+}
+
+// WithCacheTTL sets how long replies are cached; zero keeps DefaultCacheTTL
+func (b *Builder) WithCacheTTL(ttl time.Duration) *Builder {
+	b.config.CacheTTL = ttl
 	return b
 }
 
 func (b *Builder) Build() *Client {
-	return NewClient(b.config.BaseURL, b.config.APIKey, b.cache)
+	client := NewClient(b.config.BaseURL, b.config.APIKey, b.cache)
+	client.HTTPClient, client.StreamClient = transport.NewClients(transport.BreakerKey(string(generation.ProviderDeepSeek), b.config.BaseURL), b.config.Transport)
+	if b.config.CacheTTL > 0 {
+		client.CacheTTL = b.config.CacheTTL
+	}
+	return client
 }
@@ -4,21 +4,33 @@
 // Example:
 //
 //	client := deepseek.NewClient("https://api.deepseek.com", "api-key", cache)
-//	response, err := client.Generate(context.Background(), "What is threshAI?")
+//	response, err := client.Generate(context.Background(), "What is threshAI?", llm.GenerateOptions{})
 package deepseek
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"threshAI/pkg/cache"
+	"threshAI/pkg/core/generation"
+	"threshAI/pkg/llm"
+	"threshAI/pkg/llm/transport"
 	"threshAI/pkg/logging"
 )
 
+// DefaultModel is used when neither the config nor the request names a model
+const DefaultModel = "deepseek-chat"
+
+// DefaultCacheTTL is how long replies are cached unless configured otherwise
+const DefaultCacheTTL = 24 * time.Hour
+
 // Client manages connections and requests to the DeepSeek API.
 // Handles authentication, request building, and response processing.
 //
@@ -27,16 +39,21 @@ import (
 //	BaseURL: The base URL for DeepSeek API endpoints
 //	APIKey: Authentication key for API access
 //	HTTPClient: Configured HTTP client with timeout settings
+//	StreamClient: HTTP client for streamed responses, bounded by the request context
 //	Cache: Cache implementation for storing API responses
+//	CacheTTL: How long cached responses are served
 type Client struct {
-	BaseURL    string
-	APIKey     string
-	HTTPClient *http.Client
-	Cache      cache.Cache
+	BaseURL      string
+	APIKey       string
+	HTTPClient   *http.Client
+	StreamClient *http.Client
+	Cache        cache.Cache
+	CacheTTL     time.Duration
 }
 
 // NewClient creates a new DeepSeek API client instance.
-// Validates configuration parameters and initializes HTTP client with timeout.
+// Requests go through the shared resilient transport with its default retry,
+// timeout and circuit breaker settings; use Builder to tune them.
 //
 // Parameters:
 //
@@ -48,11 +65,14 @@ type Client struct {
 //
 //	*Client: Initialized DeepSeek client instance
 func NewClient(baseURL, apiKey string, cache cache.Cache) *Client {
+	httpClient, streamClient := transport.NewClients(transport.BreakerKey(string(generation.ProviderDeepSeek), baseURL), transport.Config{})
 	return &Client{
-		BaseURL:    baseURL,
-		APIKey:     apiKey,
-		HTTPClient: &http.Client{Timeout: 30 * time.Second},
-		Cache:      cache,
+		BaseURL:      baseURL,
+		APIKey:       apiKey,
+		HTTPClient:   httpClient,
+		StreamClient: streamClient,
+		Cache:        cache,
+		CacheTTL:     DefaultCacheTTL,
 	}
 }
 
@@ -61,11 +81,65 @@ func NewClient(baseURL, apiKey string, cache cache.Cache) *Client {
 //
 // Fields:
 //
-//	Role: The role of the message sender (e.g., "user", "assistant")
+//	Role: The role of the message sender (e.g., "user", "assistant", "tool")
 //	Content: The text content of the message
+//	ToolCalls: Tool calls requested by an assistant message
+//	ToolCallID: The call answered by a tool message
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+// ToolCall is a function call requested by the model.
+//
+// Fields:
+//
+//	Index: Position of the call, only set on streamed deltas
+//	ID: Identifier echoed back by the tool message answering the call
+//	Type: Always "function"
+//	Function: The function name and its JSON-encoded arguments
+type ToolCall struct {
+	Index    *int         `json:"index,omitempty"`
+	ID       string       `json:"id,omitempty"`
+	Type     string       `json:"type,omitempty"`
+	Function FunctionCall `json:"function"`
+}
+
+// FunctionCall names a function and carries its arguments.
+//
+// Fields:
+//
+//	Name: The function to call
+//	Arguments: JSON-encoded arguments, streamed in fragments
+type FunctionCall struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
+}
+
+// Tool declares a function the model may call.
+//
+// Fields:
+//
+//	Type: Always "function"
+//	Function: Name, description and JSON Schema parameters of the function
+type Tool struct {
+	Type     string             `json:"type"`
+	Function FunctionDefinition `json:"function"`
+}
+
+// FunctionDefinition describes a callable function.
+//
+// Fields:
+//
+//	Name: The function name
+//	Description: What the function does, shown to the model
+//	Parameters: JSON Schema object describing the arguments
+type FunctionDefinition struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
 }
 
 // Request represents the payload sent to the DeepSeek API.
@@ -75,9 +149,56 @@ type Message struct {
 //
 //	Model: The model identifier to use for generation
 //	Messages: Conversation history as a sequence of messages
+//	Stream: Whether the response is sent as server-sent events
+//	StreamOptions: Asks for token usage at the end of a stream
+//	Temperature, MaxTokens, Stop, TopP: Optional sampling parameters
+//	Seed: Not supported by the API; only tells cached replies apart
+//	Tools: Functions the model may call
+//	ResponseFormat: Asks for a reply that is a JSON object
 type Request struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
+	Model          string          `json:"model"`
+	Messages       []Message       `json:"messages"`
+	Stream         bool            `json:"stream"`
+	StreamOptions  *StreamOptions  `json:"stream_options,omitempty"`
+	Temperature    *float64        `json:"temperature,omitempty"`
+	MaxTokens      int             `json:"max_tokens,omitempty"`
+	Stop           []string        `json:"stop,omitempty"`
+	TopP           float64         `json:"top_p,omitempty"`
+	Seed           *int            `json:"-"`
+	Tools          []Tool          `json:"tools,omitempty"`
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+}
+
+// ResponseFormat selects the output format. DeepSeek supports JSON mode but
+// can't be constrained to a schema.
+//
+// Fields:
+//
+//	Type: "json_object" for JSON mode, "text" otherwise
+type ResponseFormat struct {
+	Type string `json:"type"`
+}
+
+// StreamOptions configures streamed responses.
+//
+// Fields:
+//
+//	IncludeUsage: Whether a final event reports the token usage
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// Usage reports the tokens consumed by a request.
+//
+// Fields:
+//
+//	PromptTokens: Tokens in the conversation sent to the model
+//	CompletionTokens: Tokens in the generated reply
+//	TotalTokens: Sum of prompt and completion tokens
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
 }
 
 // Response represents the structure of API responses from DeepSeek.
@@ -86,85 +207,395 @@ type Request struct {
 // Fields:
 //
 //	Choices: Array of generated message options
+//	Usage: Tokens consumed by the request
 type Response struct {
 	Choices []struct {
 		Message Message `json:"message"`
 	} `json:"choices"`
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+// StreamResponse represents a single server-sent event of a streamed response.
+// Each event carries an incremental delta of the generated message.
+//
+// Fields:
+//
+//	Choices: Array of message deltas, one per choice
+//	Usage: Tokens consumed by the request, only set on the final event
+type StreamResponse struct {
+	Choices []struct {
+		Delta        Message `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage,omitempty"`
 }
 
 // Generate sends a prompt to the DeepSeek API and returns the generated response.
-// Implements caching to reduce API calls for repeated prompts.
+// The prompt is sent as a single user message; see Chat for conversations.
 //
 // Parameters:
 //
 //	ctx: Context for request cancellation and timeout
 //	prompt: Input text to send to the API
+//	opts: Model and sampling options for the request
 //
 // Returns:
 //
 //	string: Generated response from DeepSeek
 //	error: API request or processing errors
+func (c *Client) Generate(ctx context.Context, prompt string, opts llm.GenerateOptions) (string, error) {
+	resp, _, err := c.Chat(ctx, NewRequest([]Message{{Role: "user", Content: prompt}}, opts))
+	if err != nil {
+		return "", err
+	}
+	return resp.Content, nil
+}
+
+// GenerateStream sends a prompt to the DeepSeek API as a single user message
+// and streams the generated response as it arrives.
+//
+// Parameters:
+//
+//	ctx: Context for request cancellation; cancelling it ends the stream
+//	prompt: Input text to send to the API
+//	opts: Model and sampling options for the request
+//
+// Returns:
+//
+//	<-chan llm.Chunk: Stream of response fragments, closed when the stream ends
+//	error: Errors raised before the stream starts
+func (c *Client) GenerateStream(ctx context.Context, prompt string, opts llm.GenerateOptions) (<-chan llm.Chunk, error) {
+	return c.ChatStream(ctx, NewRequest([]Message{{Role: "user", Content: prompt}}, opts))
+}
+
+// Chat sends a conversation to the DeepSeek API and returns the assistant reply.
+// Implements caching to reduce API calls for repeated conversations: replies
+// to deterministic requests, at temperature 0 or seeded, are cached unless
+// the context bypasses the cache; replies that call tools are never cached.
+//
+// Parameters:
+//
+//	ctx: Context for request cancellation and timeout
+//	reqBody: The conversation, options and tools; see NewRequest
+//
+// Returns:
+//
+//	Message: The assistant message generated by DeepSeek, including tool calls
+//	*Usage: Tokens consumed; zero for cached replies, nil if not reported
+//	error: API request or processing errors
 //
 // Error Handling:
 //   - Returns error for network failures
 //   - Returns error for invalid API responses
 //   - Returns error for empty responses
-func (c *Client) Generate(ctx context.Context, prompt string) (string, error) {
-	// Check cache first
-	if cached, err := c.Cache.Get(ctx, prompt); err == nil {
-		logging.Logger.Printf("Cache hit for prompt: %s", prompt)
-		return cached, nil
+func (c *Client) Chat(ctx context.Context, reqBody Request) (Message, *Usage, error) {
+	reqBody.Stream = false
+	reqBody.StreamOptions = nil
+	key, read, write, err := cachePolicy(ctx, reqBody)
+	if err != nil {
+		return Message{}, nil, err
 	}
 
-	reqBody := Request{
-		Model: "deepseek-chat",
-		Messages: []Message{
-			{
-				Role:    "user",
-				Content: prompt,
-			},
-		},
+	// Check cache first; cached replies cost no tokens
+	if cached, ok := c.cached(ctx, key, read); ok {
+		return Message{Role: "assistant", Content: cached}, &Usage{}, nil
 	}
 
-	reqBytes, err := json.Marshal(reqBody)
+	resp, err := c.post(ctx, c.HTTPClient, reqBody)
 	if err != nil {
-		return "", fmt.Errorf("error marshaling request: %w", err)
+		return Message{}, nil, err
 	}
+	defer resp.Body.Close()
 
-	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+"/v1/chat/completions", bytes.NewBuffer(reqBytes))
+	var response Response
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return Message{}, nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	if len(response.Choices) == 0 {
+		return Message{}, nil, fmt.Errorf("no choices in response")
+	}
+
+	output := response.Choices[0].Message
+
+	// Cache the result; tool calls must reach the caller every time
+	if write && len(output.ToolCalls) == 0 {
+		if err := c.Cache.Set(ctx, key, output.Content, c.CacheTTL); err != nil {
+			logging.Logger.Printf("Warning: failed to cache result: %v", err)
+		}
+	}
+
+	return output, response.Usage, nil
+}
+
+// ChatStream sends a conversation to the DeepSeek API and streams the assistant
+// reply as it arrives. Cached replies are emitted as a single chunk, and
+// completed streams are cached like regular responses. The final chunk
+// carries the token usage reported by the API and any tool calls, which are
+// assembled from their streamed deltas.
+//
+// Parameters:
+//
+//	ctx: Context for request cancellation; cancelling it ends the stream
+//	reqBody: The conversation, options and tools; see NewRequest
+//
+// Returns:
+//
+//	<-chan llm.Chunk: Stream of response fragments, closed when the stream ends
+//	error: Errors raised before the stream starts
+func (c *Client) ChatStream(ctx context.Context, reqBody Request) (<-chan llm.Chunk, error) {
+	key, read, write, err := cachePolicy(ctx, reqBody)
 	if err != nil {
-		return "", fmt.Errorf("error creating request: %w", err)
+		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	if cached, ok := c.cached(ctx, key, read); ok {
+		ch := make(chan llm.Chunk, 2)
+		ch <- llm.Chunk{Content: cached}
+		ch <- llm.Chunk{Done: true, Usage: &llm.Usage{}}
+		close(ch)
+		return ch, nil
+	}
 
-	logging.Logger.Printf("Making request to DeepSeek API with prompt: %s", prompt)
-	resp, err := c.HTTPClient.Do(req)
+	reqBody.Stream = true
+	reqBody.StreamOptions = &StreamOptions{IncludeUsage: true}
+	resp, err := c.post(ctx, c.StreamClient, reqBody)
 	if err != nil {
-		return "", fmt.Errorf("error making request: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	ch := make(chan llm.Chunk)
+	go func() {
+		defer close(ch)
+		defer resp.Body.Close()
+
+		send := func(chunk llm.Chunk) bool {
+			select {
+			case ch <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		var output strings.Builder
+		var usage *Usage
+		calls := make(map[int]*ToolCall)
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if !strings.HasPrefix(line, "data:") {
+				// Blank separators, comments and keep-alives
+				continue
+			}
+
+			data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			if data == "[DONE]" {
+				toolCalls := assembleToolCalls(calls)
+				if write && len(toolCalls) == 0 {
+					if err := c.Cache.Set(ctx, key, output.String(), c.CacheTTL); err != nil {
+						logging.Logger.Printf("Warning: failed to cache result: %v", err)
+					}
+				}
+				send(llm.Chunk{Done: true, Usage: toUsage(usage), ToolCalls: toToolCalls(toolCalls)})
+				return
+			}
+
+			var event StreamResponse
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				send(llm.Chunk{Err: fmt.Errorf("error decoding stream event: %w", err)})
+				return
+			}
+			if event.Usage != nil {
+				usage = event.Usage
+			}
+			if len(event.Choices) == 0 {
+				continue
+			}
+
+			delta := event.Choices[0].Delta
+			for i, tc := range delta.ToolCalls {
+				index := i
+				if tc.Index != nil {
+					index = *tc.Index
+				}
+				mergeToolCall(calls, index, tc)
+			}
+			if delta.Content == "" {
+				continue
+			}
+
+			content := delta.Content
+			output.WriteString(content)
+			if !send(llm.Chunk{Content: content}) {
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			send(llm.Chunk{Err: fmt.Errorf("error reading stream: %w", err)})
+			return
+		}
+		send(llm.Chunk{Err: fmt.Errorf("stream ended before completion")})
+	}()
+
+	return ch, nil
+}
+
+// NewRequest maps a conversation and generation options onto a DeepSeek
+// request. Seed and TopK are not supported by DeepSeek and are dropped.
+//
+// Parameters:
+//
+//	messages: Conversation history, optionally starting with a system message
+//	opts: Model and sampling options
+//
+// Returns:
+//
+//	Request: Request for the default model unless opts names one; tools and
+//	the response format can be set on it before sending
+func NewRequest(messages []Message, opts llm.GenerateOptions) Request {
+	model := opts.Model
+	if model == "" {
+		model = DefaultModel
+	}
+	return Request{
+		Model:       model,
+		Messages:    messages,
+		Temperature: opts.Temperature,
+		MaxTokens:   opts.MaxTokens,
+		Stop:        opts.Stop,
+		TopP:        opts.TopP,
+		Seed:        opts.Seed,
 	}
+}
 
-	var response Response
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return "", fmt.Errorf("error decoding response: %w", err)
+// toUsage converts reported usage to the shared representation
+func toUsage(u *Usage) *llm.Usage {
+	if u == nil {
+		return nil
+	}
+	return &llm.Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
 	}
+}
 
-	if len(response.Choices) == 0 {
-		return "", fmt.Errorf("no choices in response")
+// mergeToolCall folds a streamed tool call delta into the call at index. The
+// ID and name arrive once; the arguments arrive in fragments.
+func mergeToolCall(calls map[int]*ToolCall, index int, delta ToolCall) {
+	call, ok := calls[index]
+	if !ok {
+		call = &ToolCall{Type: "function"}
+		calls[index] = call
 	}
+	if delta.ID != "" {
+		call.ID = delta.ID
+	}
+	if delta.Function.Name != "" {
+		call.Function.Name = delta.Function.Name
+	}
+	call.Function.Arguments += delta.Function.Arguments
+}
+
+// assembleToolCalls returns the accumulated tool calls in index order
+func assembleToolCalls(calls map[int]*ToolCall) []ToolCall {
+	indexes := make([]int, 0, len(calls))
+	for index := range calls {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	out := make([]ToolCall, len(indexes))
+	for i, index := range indexes {
+		out[i] = *calls[index]
+	}
+	return out
+}
+
+// cached returns the cached reply for key. The cache isn't consulted at all
+// unless read is set, so that skipped lookups don't count as misses.
+func (c *Client) cached(ctx context.Context, key string, read bool) (string, bool) {
+	if !read {
+		return "", false
+	}
+	reply, err := c.Cache.Get(ctx, key)
+	if err != nil {
+		return "", false
+	}
+	logging.Logger.Printf("Cache hit for conversation: %s", key)
+	return reply, true
+}
+
+// cachePolicy derives the cache key for a request from its fingerprint, and
+// whether the cache may be read and written for it. Streamed and regular
+// requests for the same conversation and options share an entry.
+func cachePolicy(ctx context.Context, req Request) (key string, read, write bool, err error) {
+	opts := llm.GenerateOptions{
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+		Stop:        req.Stop,
+		Seed:        req.Seed,
+		TopP:        req.TopP,
+	}
+	fingerprint := llm.Fingerprint{
+		Provider: string(generation.ProviderDeepSeek),
+		Model:    req.Model,
+		Messages: fromMessages(req.Messages),
+		Options:  opts,
+	}
+	for _, tool := range req.Tools {
+		fingerprint.Tools = append(fingerprint.Tools, llm.Tool{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			Parameters:  tool.Function.Parameters,
+		})
+	}
+	if req.ResponseFormat != nil && req.ResponseFormat.Type == "json_object" {
+		fingerprint.Format = &llm.ResponseFormat{}
+	}
+
+	key, err = fingerprint.Key()
+	if err != nil {
+		return "", false, false, fmt.Errorf("error encoding cache key: %w", err)
+	}
+	if !llm.IsDeterministic(opts) {
+		return key, false, false, nil
+	}
+	mode := llm.CacheModeFrom(ctx)
+	return key, mode == llm.CacheDefault, mode != llm.CacheBypass, nil
+}
 
-	output := response.Choices[0].Message.Content
+// post sends a chat completion request and returns the raw HTTP response.
+// Responses with a non-200 status code are reported as *llm.APIError.
+func (c *Client) post(ctx context.Context, httpClient *http.Client, reqBody Request) (*http.Response, error) {
+	reqBytes, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request: %w", err)
+	}
 
-	// Cache the result
-	if err := c.Cache.Set(ctx, prompt, output, 24*time.Hour); err != nil {
-		logging.Logger.Printf("Warning: failed to cache result: %v", err)
+	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+"/v1/chat/completions", bytes.NewBuffer(reqBytes))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	if reqBody.Stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+
+	logging.Logger.Printf("Making request to DeepSeek API with %d messages", len(reqBody.Messages))
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, llm.NewAPIError(string(generation.ProviderDeepSeek), resp)
 	}
 
-	return output, nil
+	return resp, nil
 }
@@ -0,0 +1,348 @@
+package deepseek
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"threshAI/pkg/cache"
+	"threshAI/pkg/llm"
+	"threshAI/pkg/llm/transport"
+)
+
+// newTestServer returns a stand-in DeepSeek server that records the last
+// decoded request, counts the requests and answers with the given handler
+func newTestServer(t *testing.T, reply func(w http.ResponseWriter, req Request)) (*httptest.Server, *Request, *atomic.Int32) {
+	t.Helper()
+	var last Request
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
+			t.Errorf("Authorization = %q, want %q", got, "Bearer test-key")
+		}
+		if err := json.NewDecoder(r.Body).Decode(&last); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		calls.Add(1)
+		reply(w, last)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &last, &calls
+}
+
+func newTestAdapter(ts *httptest.Server) *Adapter {
+	return NewAdapter(Config{
+		BaseURL:   ts.URL,
+		APIKey:    "test-key",
+		Transport: transport.Config{MaxRetries: -1},
+	}, cache.NewInMemoryCache())
+}
+
+// sse writes events as server-sent events, ending with the [DONE] sentinel
+func sse(w http.ResponseWriter, events ...string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, event := range events {
+		fmt.Fprintf(w, "data: %s\n\n", event)
+	}
+	fmt.Fprint(w, ": keep-alive\n\ndata: [DONE]\n\n")
+}
+
+// collect drains a stream, returning its content and final chunk
+func collect(t *testing.T, stream <-chan llm.Chunk) (string, llm.Chunk, error) {
+	t.Helper()
+	var content strings.Builder
+	var final llm.Chunk
+	for chunk := range stream {
+		if chunk.Err != nil {
+			return content.String(), final, chunk.Err
+		}
+		content.WriteString(chunk.Content)
+		if chunk.Done {
+			final = chunk
+		}
+	}
+	return content.String(), final, nil
+}
+
+func TestChat(t *testing.T) {
+	ts, last, _ := newTestServer(t, func(w http.ResponseWriter, req Request) {
+		fmt.Fprint(w, `{
+			"choices": [{"message": {"role": "assistant", "content": "Hello!"}}],
+			"usage": {"prompt_tokens": 12, "completion_tokens": 3, "total_tokens": 15}
+		}`)
+	})
+
+	resp, err := newTestAdapter(ts).Chat(context.Background(), llm.ChatRequest{
+		Messages: []llm.Message{
+			{Role: llm.RoleSystem, Content: "Be brief."},
+			{Role: llm.RoleUser, Content: "Hi"},
+			{Role: llm.RoleAssistant, Content: "Hello."},
+			{Role: llm.RoleUser, Content: "Hi again"},
+		},
+		Options: llm.GenerateOptions{Temperature: llm.Float64(0.7), MaxTokens: 64, Stop: []string{"\n\n"}},
+		Format:  &llm.ResponseFormat{Name: "reply"},
+	})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	if resp.Message.Content != "Hello!" || resp.Message.Role != llm.RoleAssistant {
+		t.Errorf("Message = %+v, want the assistant's Hello!", resp.Message)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokens != 15 || resp.Usage.PromptTokens != 12 {
+		t.Errorf("Usage = %+v, want 12 prompt / 15 total tokens", resp.Usage)
+	}
+
+	if last.Model != DefaultModel || last.Stream {
+		t.Errorf("Model = %q, Stream = %v, want %s without streaming", last.Model, last.Stream, DefaultModel)
+	}
+	if len(last.Messages) != 4 || last.Messages[0].Role != llm.RoleSystem || last.Messages[0].Content != "Be brief." {
+		t.Errorf("Messages = %+v, want the system prompt followed by the conversation", last.Messages)
+	}
+	if last.Messages[3].Content != "Hi again" {
+		t.Errorf("last message = %+v, want the new user message", last.Messages[3])
+	}
+	if last.Temperature == nil || *last.Temperature != 0.7 || last.MaxTokens != 64 || len(last.Stop) != 1 {
+		t.Errorf("options = %v, %d, %v; want 0.7, 64 and one stop sequence", last.Temperature, last.MaxTokens, last.Stop)
+	}
+	if last.ResponseFormat == nil || last.ResponseFormat.Type != "json_object" {
+		t.Errorf("ResponseFormat = %+v, want json_object", last.ResponseFormat)
+	}
+}
+
+func TestChatCachePolicy(t *testing.T) {
+	ts, _, calls := newTestServer(t, func(w http.ResponseWriter, req Request) {
+		fmt.Fprintf(w, `{"choices": [{"message": {"role": "assistant", "content": "reply %d"}}]}`, len(req.Messages))
+	})
+	adapter := newTestAdapter(ts)
+	chat := func(ctx context.Context, content string, opts llm.GenerateOptions) *llm.ChatResponse {
+		t.Helper()
+		resp, err := adapter.Chat(ctx, llm.ChatRequest{
+			Messages: []llm.Message{{Role: llm.RoleUser, Content: content}},
+			Options:  opts,
+		})
+		if err != nil {
+			t.Fatalf("Chat() error = %v", err)
+		}
+		return resp
+	}
+	greedy := llm.GenerateOptions{Temperature: llm.Float64(0)}
+	sampled := llm.GenerateOptions{Temperature: llm.Float64(0.9)}
+
+	tests := []struct {
+		name      string
+		mode      llm.CacheMode
+		content   string
+		opts      llm.GenerateOptions
+		wantCalls int32
+	}{
+		{"greedy request is sent", llm.CacheDefault, "greedy", greedy, 1},
+		{"repeat is served from the cache", llm.CacheDefault, "greedy", greedy, 1},
+		{"seeded requests are cached too", llm.CacheDefault, "seeded", llm.GenerateOptions{Seed: llm.Int(3)}, 2},
+		{"seeded repeat", llm.CacheDefault, "seeded", llm.GenerateOptions{Seed: llm.Int(3)}, 2},
+		{"sampled request is sent", llm.CacheDefault, "sampled", sampled, 3},
+		{"sampled repeat is sent again", llm.CacheDefault, "sampled", sampled, 4},
+		{"bypass skips a cached reply", llm.CacheBypass, "greedy", greedy, 5},
+		{"bypass doesn't write", llm.CacheBypass, "bypassed", greedy, 6},
+		{"so the next request is sent", llm.CacheDefault, "bypassed", greedy, 7},
+		{"refresh skips a cached reply", llm.CacheRefresh, "refreshed", greedy, 8},
+		{"refresh writes the new reply", llm.CacheDefault, "refreshed", greedy, 8},
+	}
+	for _, tt := range tests {
+		ctx := llm.WithCacheMode(context.Background(), tt.mode)
+		resp := chat(ctx, tt.content, tt.opts)
+		if got := calls.Load(); got != tt.wantCalls {
+			t.Errorf("%s: %d requests sent, want %d", tt.name, got, tt.wantCalls)
+		}
+		if resp.Message.Content != "reply 1" {
+			t.Errorf("%s: Content = %q", tt.name, resp.Message.Content)
+		}
+	}
+}
+
+func TestChatToolCallsAreNotCached(t *testing.T) {
+	ts, last, calls := newTestServer(t, func(w http.ResponseWriter, req Request) {
+		fmt.Fprint(w, `{"choices": [{"message": {
+			"role": "assistant",
+			"content": "",
+			"tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "get_weather", "arguments": "{\"city\":\"Oslo\"}"}}]
+		}}]}`)
+	})
+	adapter := newTestAdapter(ts)
+	req := llm.ChatRequest{
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "Weather in Oslo?"}},
+		Options:  llm.GenerateOptions{Temperature: llm.Float64(0)},
+		Tools:    []llm.Tool{{Name: "get_weather", Parameters: json.RawMessage(`{"type":"object"}`)}},
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err := adapter.Chat(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Chat() error = %v", err)
+		}
+		want := llm.ToolCall{ID: "call_1", Name: "get_weather", Arguments: `{"city":"Oslo"}`}
+		if len(resp.Message.ToolCalls) != 1 || resp.Message.ToolCalls[0] != want {
+			t.Errorf("ToolCalls = %+v, want %+v", resp.Message.ToolCalls, want)
+		}
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("%d requests sent, want both, since tool calls aren't cached", got)
+	}
+	if len(last.Tools) != 1 || last.Tools[0].Type != "function" || last.Tools[0].Function.Name != "get_weather" {
+		t.Errorf("Tools = %+v, want get_weather function", last.Tools)
+	}
+}
+
+func TestChatStream(t *testing.T) {
+	ts, last, calls := newTestServer(t, func(w http.ResponseWriter, req Request) {
+		sse(w,
+			`{"choices":[{"delta":{"role":"assistant","content":"Checking"}}]}`,
+			`{"choices":[{"delta":{"content":" now"}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":1,"id":"call_2","type":"function","function":{"name":"get_time","arguments":"{}"}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Oslo\"}"}}]}}]}`,
+			`{"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":20,"completion_tokens":9,"total_tokens":29}}`,
+		)
+	})
+
+	adapter := newTestAdapter(ts)
+	req := llm.ChatRequest{
+		Messages: []llm.Message{
+			{Role: llm.RoleSystem, Content: "Use tools."},
+			{Role: llm.RoleUser, Content: "Weather in Oslo?"},
+		},
+		Options: llm.GenerateOptions{Temperature: llm.Float64(0)},
+	}
+	stream, err := adapter.ChatStream(context.Background(), req)
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+	content, final, err := collect(t, stream)
+	if err != nil {
+		t.Fatalf("stream error: %v", err)
+	}
+
+	if !last.Stream || last.StreamOptions == nil || !last.StreamOptions.IncludeUsage {
+		t.Errorf("request did not ask for a stream with usage: %+v", last)
+	}
+	if len(last.Messages) != 2 || last.Messages[0].Role != llm.RoleSystem {
+		t.Errorf("Messages = %+v, want system and user message", last.Messages)
+	}
+	if content != "Checking now" {
+		t.Errorf("content = %q, want %q", content, "Checking now")
+	}
+	if !final.Done {
+		t.Fatal("stream ended without a final chunk")
+	}
+	want := []llm.ToolCall{
+		{ID: "call_1", Name: "get_weather", Arguments: `{"city":"Oslo"}`},
+		{ID: "call_2", Name: "get_time", Arguments: `{}`},
+	}
+	if len(final.ToolCalls) != 2 || final.ToolCalls[0] != want[0] || final.ToolCalls[1] != want[1] {
+		t.Errorf("ToolCalls = %+v, want %+v", final.ToolCalls, want)
+	}
+	if final.Usage == nil || final.Usage.TotalTokens != 29 {
+		t.Errorf("Usage = %+v, want 29 total tokens", final.Usage)
+	}
+
+	// Streams that called tools aren't cached
+	stream, _ = adapter.ChatStream(context.Background(), req)
+	collect(t, stream)
+	if got := calls.Load(); got != 2 {
+		t.Errorf("%d requests sent, want 2", got)
+	}
+}
+
+func TestChatStreamSharesCacheWithChat(t *testing.T) {
+	ts, _, calls := newTestServer(t, func(w http.ResponseWriter, req Request) {
+		if req.Stream {
+			sse(w, `{"choices":[{"delta":{"content":"Hel"}}]}`, `{"choices":[{"delta":{"content":"lo"}}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"choices": [{"message": {"role": "assistant", "content": "not cached"}}]}`)
+	})
+	adapter := newTestAdapter(ts)
+	req := llm.ChatRequest{
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "Hi"}},
+		Options:  llm.GenerateOptions{Temperature: llm.Float64(0)},
+	}
+
+	stream, err := adapter.ChatStream(context.Background(), req)
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+	if content, _, err := collect(t, stream); err != nil || content != "Hello" {
+		t.Fatalf("stream = %q, %v, want Hello", content, err)
+	}
+
+	resp, err := adapter.Chat(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if resp.Message.Content != "Hello" || calls.Load() != 1 {
+		t.Errorf("Chat() = %q after %d requests, want the streamed reply from the cache", resp.Message.Content, calls.Load())
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokens != 0 {
+		t.Errorf("Usage = %+v, want zero for a cached reply", resp.Usage)
+	}
+
+	// A cached reply streams as a single chunk
+	stream, _ = adapter.ChatStream(context.Background(), req)
+	if content, final, err := collect(t, stream); err != nil || content != "Hello" || !final.Done {
+		t.Errorf("cached stream = %q, %+v, %v", content, final, err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("%d requests sent, want 1", got)
+	}
+}
+
+func TestChatStreamErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"malformed event", "data: {\"choices\": [\n\n", "error decoding stream event"},
+		{"missing sentinel", "data: {\"choices\":[{\"delta\":{\"content\":\"Hi\"}}]}\n\n", "stream ended before completion"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, _, _ := newTestServer(t, func(w http.ResponseWriter, req Request) {
+				fmt.Fprint(w, tt.body)
+			})
+			stream, err := newTestAdapter(ts).ChatStream(context.Background(), llm.ChatRequest{
+				Messages: []llm.Message{{Role: llm.RoleUser, Content: "Hi"}},
+			})
+			if err != nil {
+				t.Fatalf("ChatStream() error = %v", err)
+			}
+			if _, _, err := collect(t, stream); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("stream error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestChatErrorStatus(t *testing.T) {
+	ts, _, _ := newTestServer(t, func(w http.ResponseWriter, req Request) {
+		http.Error(w, `{"error":{"message":"invalid model"}}`, http.StatusBadRequest)
+	})
+
+	_, err := newTestAdapter(ts).Generate(context.Background(), "Hi", llm.GenerateOptions{})
+	var apiErr *llm.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Generate() error = %v, want an API error with status 400", err)
+	}
+}
@@ -0,0 +1,35 @@
+package deepseek
+
+import (
+	"fmt"
+
+	"threshAI/pkg/cache"
+	"threshAI/pkg/core/generation"
+)
+
+func init() {
+	generation.MustRegister(generation.Provider{
+		Name:         generation.ProviderDeepSeek,
+		Factory:      newGenerator,
+		Decode:       generation.DecodeYAML(Config{}),
+		Capabilities: generation.CapStream | generation.CapChat,
+	})
+}
+
+func newGenerator(config interface{}, c cache.Cache) (generation.Generator, error) {
+	cfg, err := generation.ConfigAs[Config](config)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.BaseURL == "" {
+		return nil, fmt.Errorf("base_url is required")
+	}
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("api_key is required")
+	}
+	if c == nil {
+		// The client always consults its cache
+		c = cache.NewInMemoryCache()
+	}
+	return NewAdapter(cfg, c), nil
+}
@@ -0,0 +1,366 @@
+// Package fakellm is a fake LLM server speaking the Ollama and OpenAI chat
+// completions protocols, for local development and end-to-end tests without
+// a real model. Replies are canned, echo the prompt or are rendered from a
+// template, and the server can be slowed down or made to fail on purpose.
+package fakellm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Reply modes
+const (
+	// ModeCanned replies with the configured response
+	ModeCanned = "canned"
+	// ModeEcho replies with the prompt
+	ModeEcho = "echo"
+	// ModeTemplate renders the response as a text/template of the Request
+	ModeTemplate = "template"
+)
+
+// DefaultResponse is the canned reply when none is configured
+const DefaultResponse = "This is a fake response."
+
+// DefaultModels are the models listed when none are configured
+var DefaultModels = []string{"llama2", "nomic-embed-text"}
+
+// DefaultEmbeddingDim is the size of embeddings when none is configured
+const DefaultEmbeddingDim = 256
+
+// Config scripts the behaviour of a Server
+type Config struct {
+	// Mode is one of ModeCanned (default), ModeEcho or ModeTemplate
+	Mode string `yaml:"mode"`
+	// Response is the canned reply or the template, depending on Mode
+	Response string `yaml:"response"`
+	// Rules override the reply for matching prompts; the first match wins
+	Rules []Rule `yaml:"rules"`
+	// Reply, when set, computes replies not matched by a rule instead of
+	// Mode. An error fails the request with a 500.
+	Reply func(Request) (string, error) `yaml:"-"`
+
+	// Models are listed by /api/tags and /v1/models
+	Models []string `yaml:"models"`
+	// StrictModels rejects requests for unlisted models with a 404
+	StrictModels bool `yaml:"strict_models"`
+	// EmbeddingDim is the size of the returned embeddings
+	EmbeddingDim int `yaml:"embedding_dim"`
+
+	// Latency delays the start of every response
+	Latency time.Duration `yaml:"latency"`
+	// ChunkDelay is the pause between the chunks of a streamed reply
+	ChunkDelay time.Duration `yaml:"chunk_delay"`
+
+	// FailFirst fails the first requests received
+	FailFirst int `yaml:"fail_first"`
+	// ErrorRate is the fraction of the remaining requests that fail
+	ErrorRate float64 `yaml:"error_rate"`
+	// ErrorStatus is the status of injected failures, 500 by default
+	ErrorStatus int `yaml:"error_status"`
+	// Seed makes injected failures reproducible
+	Seed int64 `yaml:"seed"`
+}
+
+// Rule scripts the reply to prompts containing Match. An empty Match matches
+// every prompt.
+type Rule struct {
+	Match    string `yaml:"match"`
+	Mode     string `yaml:"mode"`
+	Response string `yaml:"response"`
+	// Status fails matching requests with this status instead of replying
+	Status int `yaml:"status"`
+}
+
+// Request is a request received by the server. Prompt is the prompt of a
+// generate request or the last user message of a chat.
+type Request struct {
+	// N numbers the requests from 1
+	N        int
+	Endpoint string
+	Model    string
+	Prompt   string
+	Messages []Message
+	// Input holds the texts of an embeddings request
+	Input []string
+}
+
+// Message is a chat message of a Request
+type Message struct {
+	Role    string
+	Content string
+}
+
+// Server is an http.Handler serving the fake endpoints
+type Server struct {
+	config    Config
+	mux       *http.ServeMux
+	templates map[string]*template.Template
+
+	mu       sync.Mutex
+	rand     *rand.Rand
+	requests []Request
+}
+
+// New validates config and creates a server
+func New(config Config) (*Server, error) {
+	if config.Mode == "" {
+		config.Mode = ModeCanned
+	}
+	if config.Mode == ModeCanned && config.Response == "" {
+		config.Response = DefaultResponse
+	}
+	if len(config.Models) == 0 {
+		config.Models = DefaultModels
+	}
+	if config.EmbeddingDim <= 0 {
+		config.EmbeddingDim = DefaultEmbeddingDim
+	}
+	if config.ErrorStatus == 0 {
+		config.ErrorStatus = http.StatusInternalServerError
+	}
+	if config.ErrorRate < 0 || config.ErrorRate > 1 {
+		return nil, fmt.Errorf("error rate must be between 0 and 1, got %v", config.ErrorRate)
+	}
+
+	s := &Server{
+		config:    config,
+		mux:       http.NewServeMux(),
+		templates: make(map[string]*template.Template),
+		rand:      rand.New(rand.NewSource(config.Seed)),
+	}
+	if err := s.compile(config.Mode, config.Response); err != nil {
+		return nil, err
+	}
+	for _, rule := range config.Rules {
+		if err := s.compile(rule.Mode, rule.Response); err != nil {
+			return nil, fmt.Errorf("rule %q: %v", rule.Match, err)
+		}
+	}
+
+	s.mux.HandleFunc("/api/tags", s.handleTags)
+	s.mux.HandleFunc("/api/generate", s.handleGenerate)
+	s.mux.HandleFunc("/api/chat", s.handleChat)
+	s.mux.HandleFunc("/api/embeddings", s.handleEmbeddings)
+	s.mux.HandleFunc("/v1/models", s.handleModels)
+	s.mux.HandleFunc("/v1/chat/completions", s.handleCompletions)
+	s.mux.HandleFunc("/v1/embeddings", s.handleOpenAIEmbeddings)
+	return s, nil
+}
+
+func (s *Server) compile(mode, response string) error {
+	switch mode {
+	case "", ModeCanned, ModeEcho:
+		return nil
+	case ModeTemplate:
+		if _, ok := s.templates[response]; ok {
+			return nil
+		}
+		tmpl, err := template.New("response").Parse(response)
+		if err != nil {
+			return fmt.Errorf("invalid response template: %v", err)
+		}
+		s.templates[response] = tmpl
+		return nil
+	default:
+		return fmt.Errorf("unknown mode %q: must be %s, %s or %s", mode, ModeCanned, ModeEcho, ModeTemplate)
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Requests returns the requests received so far, in order
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// begin records a request and decides whether it fails. It returns the
+// numbered request and a non-zero status for injected failures.
+func (s *Server) begin(req Request) (Request, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	req.N = len(s.requests) + 1
+	s.requests = append(s.requests, req)
+
+	if req.N <= s.config.FailFirst {
+		return req, s.config.ErrorStatus
+	}
+	if s.config.ErrorRate > 0 && s.rand.Float64() < s.config.ErrorRate {
+		return req, s.config.ErrorStatus
+	}
+	return req, 0
+}
+
+// reply computes the reply to req, or the status it fails with
+func (s *Server) reply(req Request) (string, int, error) {
+	if s.config.StrictModels && req.Model != "" && !s.hasModel(req.Model) {
+		return "", http.StatusNotFound, fmt.Errorf("model %q not found", req.Model)
+	}
+
+	for _, rule := range s.config.Rules {
+		if !strings.Contains(req.Prompt, rule.Match) {
+			continue
+		}
+		if rule.Status != 0 {
+			return "", rule.Status, fmt.Errorf("scripted failure for %q", rule.Match)
+		}
+		out, err := s.render(rule.Mode, rule.Response, req)
+		return out, statusOf(err), err
+	}
+
+	if s.config.Reply != nil {
+		out, err := s.config.Reply(req)
+		return out, statusOf(err), err
+	}
+	out, err := s.render(s.config.Mode, s.config.Response, req)
+	return out, statusOf(err), err
+}
+
+func (s *Server) render(mode, response string, req Request) (string, error) {
+	switch mode {
+	case ModeEcho:
+		return req.Prompt, nil
+	case ModeTemplate:
+		var buf bytes.Buffer
+		if err := s.templates[response].Execute(&buf, req); err != nil {
+			return "", fmt.Errorf("failed to render response: %v", err)
+		}
+		return buf.String(), nil
+	default:
+		return response, nil
+	}
+}
+
+func statusOf(err error) int {
+	if err != nil {
+		return http.StatusInternalServerError
+	}
+	return 0
+}
+
+func (s *Server) hasModel(model string) bool {
+	for _, m := range s.config.Models {
+		if m == model || strings.TrimSuffix(m, ":latest") == model {
+			return true
+		}
+	}
+	return false
+}
+
+// wait sleeps for d unless the client goes away first
+func wait(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// chunks splits a reply into the pieces it is streamed in, one word each
+func chunks(reply string) []string {
+	var out []string
+	for _, piece := range strings.SplitAfter(reply, " ") {
+		if piece != "" {
+			out = append(out, piece)
+		}
+	}
+	return out
+}
+
+// countTokens approximates a token count by counting words
+func countTokens(texts ...string) int {
+	n := 0
+	for _, text := range texts {
+		n += len(strings.Fields(text))
+	}
+	return n
+}
+
+// embed hashes the words of text into a normalised vector, so that texts
+// sharing words have similar embeddings
+func embed(text string, dim int) []float32 {
+	v := make([]float32, dim)
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		h := fnv.New32a()
+		h.Write([]byte(strings.Trim(word, ".,;:!?\"'()")))
+		sum := h.Sum32()
+		if sum&(1<<31) != 0 {
+			v[sum%uint32(dim)] -= 1
+		} else {
+			v[sum%uint32(dim)] += 1
+		}
+	}
+
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	if norm > 0 {
+		scale := float32(1 / math.Sqrt(norm))
+		for i := range v {
+			v[i] *= scale
+		}
+	}
+	return v
+}
+
+// errorWriter writes an error in the format of a protocol
+type errorWriter func(w http.ResponseWriter, status int, message string)
+
+// prepare records req, waits out the configured latency and computes the
+// reply. It writes the error response and returns false if the request fails.
+func (s *Server) prepare(w http.ResponseWriter, r *http.Request, req Request, fail errorWriter) (string, bool) {
+	req, status := s.begin(req)
+	if !wait(r.Context(), s.config.Latency) {
+		return "", false
+	}
+	if status != 0 {
+		fail(w, status, "injected failure")
+		return "", false
+	}
+
+	reply, status, err := s.reply(req)
+	if err != nil {
+		fail(w, status, err.Error())
+		return "", false
+	}
+	return reply, true
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, body interface{}, fail errorWriter) bool {
+	if r.Method != http.MethodPost {
+		fail(w, http.StatusMethodNotAllowed, "method not allowed")
+		return false
+	}
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+		fail(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
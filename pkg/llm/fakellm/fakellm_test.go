@@ -0,0 +1,148 @@
+package fakellm_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"threshAI/pkg/llm"
+	"threshAI/pkg/llm/fakellm"
+	"threshAI/pkg/llm/ollama"
+	"threshAI/pkg/llm/openai"
+	"threshAI/pkg/llm/transport"
+)
+
+func start(t *testing.T, config fakellm.Config) (*fakellm.Server, string) {
+	t.Helper()
+	srv, err := fakellm.New(config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	return srv, ts.URL
+}
+
+func TestOllamaClient(t *testing.T) {
+	ctx := context.Background()
+	_, url := start(t, fakellm.Config{
+		Mode: fakellm.ModeEcho,
+		Rules: []fakellm.Rule{
+			{Match: "weather", Mode: fakellm.ModeTemplate, Response: "Sunny for {{.Model}} (request {{.N}})"},
+		},
+	})
+	adapter := ollama.NewAdapter(ollama.Config{BaseURL: url})
+
+	out, err := adapter.Generate(ctx, "say hello", llm.GenerateOptions{})
+	if err != nil || out != "say hello" {
+		t.Errorf("Generate() = %q, %v, want the prompt echoed", out, err)
+	}
+
+	stream, err := adapter.ChatStream(ctx, llm.ChatRequest{
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "what is the weather"}},
+		Options:  llm.GenerateOptions{Model: "llama3"},
+	})
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+	var chunks []string
+	var usage *llm.Usage
+	for chunk := range stream {
+		if chunk.Err != nil {
+			t.Fatalf("stream error = %v", chunk.Err)
+		}
+		if chunk.Content != "" {
+			chunks = append(chunks, chunk.Content)
+		}
+		if chunk.Done {
+			usage = chunk.Usage
+		}
+	}
+	if got := strings.Join(chunks, ""); got != "Sunny for llama3 (request 2)" || len(chunks) < 2 {
+		t.Errorf("streamed %q, want the templated reply in several chunks", chunks)
+	}
+	if usage == nil || usage.PromptTokens != 4 || usage.CompletionTokens != 5 {
+		t.Errorf("usage = %+v, want word counts", usage)
+	}
+
+	embeddings, err := adapter.Embed(ctx, []string{"red apple", "apple red", "blue sky"})
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	if dot(embeddings[0], embeddings[1]) < 0.99 || dot(embeddings[0], embeddings[2]) > 0.5 {
+		t.Errorf("embeddings don't reflect shared words")
+	}
+}
+
+func TestOpenAIClient(t *testing.T) {
+	ctx := context.Background()
+	srv, url := start(t, fakellm.Config{Response: "All good here", FailFirst: 1, ErrorStatus: 503})
+	adapter := openai.NewAdapter(openai.Config{
+		BaseURL:   url + "/v1",
+		Transport: transport.Config{BaseDelay: time.Millisecond},
+	})
+
+	// The injected failure is retried by the transport
+	resp, err := adapter.Chat(ctx, llm.ChatRequest{Messages: []llm.Message{{Role: llm.RoleUser, Content: "status?"}}})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	if resp.Message.Content != "All good here" || resp.Usage == nil || resp.Usage.CompletionTokens != 3 {
+		t.Errorf("Chat() = %+v, want the canned reply with usage", resp)
+	}
+	if n := len(srv.Requests()); n != 2 {
+		t.Errorf("server saw %d requests, want 2", n)
+	}
+
+	stream, err := adapter.GenerateStream(ctx, "status?", llm.GenerateOptions{})
+	if err != nil {
+		t.Fatalf("GenerateStream() error = %v", err)
+	}
+	var out strings.Builder
+	for chunk := range stream {
+		if chunk.Err != nil {
+			t.Fatalf("stream error = %v", chunk.Err)
+		}
+		out.WriteString(chunk.Content)
+	}
+	if out.String() != "All good here" {
+		t.Errorf("streamed %q, want the canned reply", out.String())
+	}
+}
+
+func TestScriptedFailures(t *testing.T) {
+	_, url := start(t, fakellm.Config{
+		Models:       []string{"llama3"},
+		StrictModels: true,
+		Rules:        []fakellm.Rule{{Match: "boom", Status: 429}},
+	})
+	client := ollama.NewClientWithTransport(url, transport.Config{MaxRetries: -1})
+
+	_, err := client.Generate(context.Background(), "boom", llm.GenerateOptions{Model: "llama3"})
+	if apiErr, ok := err.(*llm.APIError); !ok || apiErr.StatusCode != 429 {
+		t.Errorf("Generate() error = %v, want a 429", err)
+	}
+	_, err = client.Generate(context.Background(), "hello", llm.GenerateOptions{Model: "mistral"})
+	if apiErr, ok := err.(*llm.APIError); !ok || apiErr.StatusCode != 404 {
+		t.Errorf("Generate() error = %v, want a 404 for an unknown model", err)
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	if _, err := fakellm.New(fakellm.Config{Mode: fakellm.ModeTemplate, Response: "{{.Nope"}); err == nil {
+		t.Error("New() accepted an invalid template")
+	}
+	if _, err := fakellm.New(fakellm.Config{Mode: "random"}); err == nil {
+		t.Error("New() accepted an unknown mode")
+	}
+}
+
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
@@ -0,0 +1,161 @@
+package fakellm
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+type ollamaModel struct {
+	Name       string `json:"name"`
+	Model      string `json:"model"`
+	ModifiedAt string `json:"modified_at"`
+	Size       int64  `json:"size"`
+}
+
+type ollamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ollamaResponse struct {
+	Model           string         `json:"model"`
+	CreatedAt       string         `json:"created_at"`
+	Response        *string        `json:"response,omitempty"`
+	Message         *ollamaMessage `json:"message,omitempty"`
+	Done            bool           `json:"done"`
+	DoneReason      string         `json:"done_reason,omitempty"`
+	PromptEvalCount int            `json:"prompt_eval_count,omitempty"`
+	EvalCount       int            `json:"eval_count,omitempty"`
+}
+
+func (s *Server) handleTags(w http.ResponseWriter, r *http.Request) {
+	_, status := s.begin(Request{Endpoint: r.URL.Path})
+	if !wait(r.Context(), s.config.Latency) {
+		return
+	}
+	if status != 0 {
+		ollamaError(w, status, "injected failure")
+		return
+	}
+
+	models := make([]ollamaModel, len(s.config.Models))
+	for i, name := range s.config.Models {
+		models[i] = ollamaModel{Name: name, Model: name, ModifiedAt: time.Now().UTC().Format(time.RFC3339)}
+	}
+	writeJSON(w, map[string]interface{}{"models": models})
+}
+
+func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Model  string `json:"model"`
+		Prompt string `json:"prompt"`
+		Stream *bool  `json:"stream"`
+	}
+	if !decodeBody(w, r, &body, ollamaError) {
+		return
+	}
+
+	req := Request{Endpoint: r.URL.Path, Model: body.Model, Prompt: body.Prompt}
+	reply, ok := s.prepare(w, r, req, ollamaError)
+	if !ok {
+		return
+	}
+
+	response := func(content string, done bool) ollamaResponse {
+		out := ollamaResponse{Model: body.Model, CreatedAt: time.Now().UTC().Format(time.RFC3339Nano), Response: &content, Done: done}
+		if done {
+			out.DoneReason = "stop"
+			out.PromptEvalCount = countTokens(body.Prompt)
+			out.EvalCount = countTokens(reply)
+		}
+		return out
+	}
+	s.writeOllama(w, r, body.Stream, reply, response)
+}
+
+func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Model    string          `json:"model"`
+		Messages []ollamaMessage `json:"messages"`
+		Stream   *bool           `json:"stream"`
+	}
+	if !decodeBody(w, r, &body, ollamaError) {
+		return
+	}
+
+	req := Request{Endpoint: r.URL.Path, Model: body.Model}
+	var prompt []string
+	for _, m := range body.Messages {
+		req.Messages = append(req.Messages, Message{Role: m.Role, Content: m.Content})
+		prompt = append(prompt, m.Content)
+		if m.Role == "user" {
+			req.Prompt = m.Content
+		}
+	}
+	reply, ok := s.prepare(w, r, req, ollamaError)
+	if !ok {
+		return
+	}
+
+	response := func(content string, done bool) ollamaResponse {
+		out := ollamaResponse{
+			Model:     body.Model,
+			CreatedAt: time.Now().UTC().Format(time.RFC3339Nano),
+			Message:   &ollamaMessage{Role: "assistant", Content: content},
+			Done:      done,
+		}
+		if done {
+			out.DoneReason = "stop"
+			out.PromptEvalCount = countTokens(prompt...)
+			out.EvalCount = countTokens(reply)
+		}
+		return out
+	}
+	s.writeOllama(w, r, body.Stream, reply, response)
+}
+
+func (s *Server) handleEmbeddings(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Model  string `json:"model"`
+		Prompt string `json:"prompt"`
+	}
+	if !decodeBody(w, r, &body, ollamaError) {
+		return
+	}
+
+	req := Request{Endpoint: r.URL.Path, Model: body.Model, Prompt: body.Prompt, Input: []string{body.Prompt}}
+	if _, ok := s.prepare(w, r, req, ollamaError); !ok {
+		return
+	}
+	writeJSON(w, map[string]interface{}{"embedding": embed(body.Prompt, s.config.EmbeddingDim)})
+}
+
+// writeOllama writes a reply as a single object, or as one object per line
+// when streaming, which Ollama does unless told otherwise
+func (s *Server) writeOllama(w http.ResponseWriter, r *http.Request, stream *bool, reply string, response func(content string, done bool) ollamaResponse) {
+	if stream != nil && !*stream {
+		writeJSON(w, response(reply, true))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	encoder := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	for i, piece := range chunks(reply) {
+		if i > 0 && !wait(r.Context(), s.config.ChunkDelay) {
+			return
+		}
+		encoder.Encode(response(piece, false))
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	encoder.Encode(response("", true))
+}
+
+func ollamaError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
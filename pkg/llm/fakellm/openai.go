@@ -0,0 +1,186 @@
+package fakellm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type openAIMessage struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type openAIChoice struct {
+	Index        int            `json:"index"`
+	Message      *openAIMessage `json:"message,omitempty"`
+	Delta        *openAIMessage `json:"delta,omitempty"`
+	FinishReason *string        `json:"finish_reason"`
+}
+
+type openAIResponse struct {
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Choices []openAIChoice `json:"choices"`
+	Usage   *openAIUsage   `json:"usage,omitempty"`
+}
+
+func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
+	_, status := s.begin(Request{Endpoint: r.URL.Path})
+	if !wait(r.Context(), s.config.Latency) {
+		return
+	}
+	if status != 0 {
+		openAIError(w, status, "injected failure")
+		return
+	}
+
+	type model struct {
+		ID      string `json:"id"`
+		Object  string `json:"object"`
+		OwnedBy string `json:"owned_by"`
+	}
+	models := make([]model, len(s.config.Models))
+	for i, name := range s.config.Models {
+		models[i] = model{ID: name, Object: "model", OwnedBy: "fakellm"}
+	}
+	writeJSON(w, map[string]interface{}{"object": "list", "data": models})
+}
+
+func (s *Server) handleCompletions(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Model         string          `json:"model"`
+		Messages      []openAIMessage `json:"messages"`
+		Stream        bool            `json:"stream"`
+		StreamOptions *struct {
+			IncludeUsage bool `json:"include_usage"`
+		} `json:"stream_options"`
+	}
+	if !decodeBody(w, r, &body, openAIError) {
+		return
+	}
+
+	req := Request{Endpoint: r.URL.Path, Model: body.Model}
+	var prompt []string
+	for _, m := range body.Messages {
+		req.Messages = append(req.Messages, Message{Role: m.Role, Content: m.Content})
+		prompt = append(prompt, m.Content)
+		if m.Role == "user" {
+			req.Prompt = m.Content
+		}
+	}
+	reply, ok := s.prepare(w, r, req, openAIError)
+	if !ok {
+		return
+	}
+
+	promptTokens, completionTokens := countTokens(prompt...), countTokens(reply)
+	usage := &openAIUsage{PromptTokens: promptTokens, CompletionTokens: completionTokens, TotalTokens: promptTokens + completionTokens}
+	stop := "stop"
+	response := openAIResponse{
+		ID:      fmt.Sprintf("chatcmpl-fake-%d", time.Now().UnixNano()),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   body.Model,
+	}
+
+	if !body.Stream {
+		response.Choices = []openAIChoice{{Message: &openAIMessage{Role: "assistant", Content: reply}, FinishReason: &stop}}
+		response.Usage = usage
+		writeJSON(w, response)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	flusher, _ := w.(http.Flusher)
+	send := func(v interface{}) {
+		data, _ := json.Marshal(v)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	response.Object = "chat.completion.chunk"
+	for i, piece := range chunks(reply) {
+		if i > 0 && !wait(r.Context(), s.config.ChunkDelay) {
+			return
+		}
+		delta := &openAIMessage{Content: piece}
+		if i == 0 {
+			delta.Role = "assistant"
+		}
+		response.Choices = []openAIChoice{{Delta: delta}}
+		send(response)
+	}
+	response.Choices = []openAIChoice{{Delta: &openAIMessage{}, FinishReason: &stop}}
+	send(response)
+
+	if body.StreamOptions != nil && body.StreamOptions.IncludeUsage {
+		response.Choices = []openAIChoice{}
+		response.Usage = usage
+		send(response)
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+func (s *Server) handleOpenAIEmbeddings(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Model string          `json:"model"`
+		Input json.RawMessage `json:"input"`
+	}
+	if !decodeBody(w, r, &body, openAIError) {
+		return
+	}
+
+	// Input is a single string or a list of them
+	var input []string
+	if err := json.Unmarshal(body.Input, &input); err != nil {
+		var single string
+		if err := json.Unmarshal(body.Input, &single); err != nil {
+			openAIError(w, http.StatusBadRequest, "input must be a string or a list of strings")
+			return
+		}
+		input = []string{single}
+	}
+
+	req := Request{Endpoint: r.URL.Path, Model: body.Model, Prompt: strings.Join(input, "\n"), Input: input}
+	if _, ok := s.prepare(w, r, req, openAIError); !ok {
+		return
+	}
+
+	type embedding struct {
+		Object    string    `json:"object"`
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	}
+	data := make([]embedding, len(input))
+	for i, text := range input {
+		data[i] = embedding{Object: "embedding", Index: i, Embedding: embed(text, s.config.EmbeddingDim)}
+	}
+	tokens := countTokens(input...)
+	writeJSON(w, map[string]interface{}{
+		"object": "list",
+		"model":  body.Model,
+		"data":   data,
+		"usage":  openAIUsage{PromptTokens: tokens, TotalTokens: tokens},
+	})
+}
+
+func openAIError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]string{"message": message, "type": "fakellm_error"},
+	})
+}
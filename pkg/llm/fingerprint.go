@@ -0,0 +1,127 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"threshAI/pkg/core/utils"
+)
+
+// CacheMode tells response caches how to treat a request
+type CacheMode int
+
+const (
+	// CacheDefault answers deterministic requests from the cache when it
+	// can and caches their replies
+	CacheDefault CacheMode = iota
+	// CacheBypass neither reads nor writes the cache
+	CacheBypass
+	// CacheRefresh skips the cached reply but caches the new one
+	CacheRefresh
+)
+
+func (m CacheMode) String() string {
+	switch m {
+	case CacheBypass:
+		return "bypass"
+	case CacheRefresh:
+		return "refresh"
+	default:
+		return "default"
+	}
+}
+
+// ParseCacheMode parses the name of a cache mode; an empty name is the
+// default mode
+func ParseCacheMode(name string) (CacheMode, error) {
+	switch strings.ToLower(name) {
+	case "", "default":
+		return CacheDefault, nil
+	case "bypass", "off":
+		return CacheBypass, nil
+	case "refresh":
+		return CacheRefresh, nil
+	default:
+		return CacheDefault, fmt.Errorf("invalid cache mode %q: must be default, bypass or refresh", name)
+	}
+}
+
+type cacheModeKey struct{}
+
+// WithCacheMode returns a context whose requests are cached according to mode
+func WithCacheMode(ctx context.Context, mode CacheMode) context.Context {
+	return context.WithValue(ctx, cacheModeKey{}, mode)
+}
+
+// CacheModeFrom returns the cache mode of requests made with ctx
+func CacheModeFrom(ctx context.Context) CacheMode {
+	mode, _ := ctx.Value(cacheModeKey{}).(CacheMode)
+	return mode
+}
+
+// IsDeterministic reports whether a request with opts should get the same
+// reply every time: greedy decoding at temperature 0, or a seeded request.
+// Only such requests are worth caching.
+func IsDeterministic(opts GenerateOptions) bool {
+	return (opts.Temperature != nil && *opts.Temperature == 0) || opts.Seed != nil
+}
+
+// Fingerprint describes everything that shapes the reply to a request, so
+// that requests with equal fingerprints can share a cached reply
+type Fingerprint struct {
+	Provider string
+	Model    string
+	// System is the system prompt, which is also taken from the leading
+	// system messages
+	System   string
+	Messages []Message
+	Options  GenerateOptions
+	Tools    []Tool
+	Format   *ResponseFormat
+}
+
+// Key returns a canonical cache key for the request. Requests that only
+// differ in how the system prompt is passed, in surrounding whitespace or in
+// the order of stop sequences get the same key.
+func (f Fingerprint) Key() (string, error) {
+	system := []string{}
+	if s := strings.TrimSpace(f.System); s != "" {
+		system = append(system, s)
+	}
+	messages := f.Messages
+	for len(messages) > 0 && messages[0].Role == RoleSystem {
+		if s := strings.TrimSpace(messages[0].Content); s != "" {
+			system = append(system, s)
+		}
+		messages = messages[1:]
+	}
+
+	normalized := make([]Message, len(messages))
+	for i, msg := range messages {
+		msg.Role = strings.ToLower(msg.Role)
+		msg.Content = strings.TrimSpace(msg.Content)
+		normalized[i] = msg
+	}
+
+	opts := f.Options
+	opts.Model = ""
+	if len(opts.Stop) > 0 {
+		opts.Stop = append([]string(nil), opts.Stop...)
+		sort.Strings(opts.Stop)
+	}
+
+	data, err := json.Marshal(struct {
+		System   string          `json:"system,omitempty"`
+		Messages []Message       `json:"messages"`
+		Options  GenerateOptions `json:"options"`
+		Tools    []Tool          `json:"tools,omitempty"`
+		Format   *ResponseFormat `json:"format,omitempty"`
+	}{strings.Join(system, "\n\n"), normalized, opts, f.Tools, f.Format})
+	if err != nil {
+		return "", fmt.Errorf("error encoding request fingerprint: %w", err)
+	}
+	return fmt.Sprintf("%s:%s:%s", f.Provider, f.Model, utils.HashPrompt(string(data))), nil
+}
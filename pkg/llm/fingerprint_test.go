@@ -0,0 +1,84 @@
+package llm
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFingerprintKeyIsCanonical(t *testing.T) {
+	base := Fingerprint{
+		Provider: "deepseek",
+		Model:    "deepseek-chat",
+		System:   "Be brief.",
+		Messages: []Message{{Role: RoleUser, Content: "What is threshAI?"}},
+		Options:  GenerateOptions{Temperature: Float64(0), Stop: []string{"END", "STOP"}},
+	}
+	key, err := base.Key()
+	if err != nil {
+		t.Fatalf("Key() error = %v", err)
+	}
+
+	same := base
+	same.System = ""
+	same.Messages = []Message{
+		{Role: RoleSystem, Content: "Be brief.\n"},
+		{Role: "User", Content: "  What is threshAI?"},
+	}
+	same.Options = GenerateOptions{Model: "deepseek-chat", Temperature: Float64(0), Stop: []string{"STOP", "END"}}
+	if got, _ := same.Key(); got != key {
+		t.Errorf("Key() = %q for an equivalent request, want %q", got, key)
+	}
+
+	for name, change := range map[string]func(f *Fingerprint){
+		"provider": func(f *Fingerprint) { f.Provider = "openai" },
+		"model":    func(f *Fingerprint) { f.Model = "deepseek-reasoner" },
+		"system":   func(f *Fingerprint) { f.System = "Be verbose." },
+		"message":  func(f *Fingerprint) { f.Messages = []Message{{Role: RoleUser, Content: "What is Go?"}} },
+		"options":  func(f *Fingerprint) { f.Options.Seed = Int(7) },
+		"tools":    func(f *Fingerprint) { f.Tools = []Tool{{Name: "search"}} },
+		"format":   func(f *Fingerprint) { f.Format = &ResponseFormat{} },
+	} {
+		other := base
+		change(&other)
+		if got, _ := other.Key(); got == key {
+			t.Errorf("Key() unchanged after changing the %s", name)
+		}
+	}
+}
+
+func TestIsDeterministic(t *testing.T) {
+	tests := []struct {
+		name string
+		opts GenerateOptions
+		want bool
+	}{
+		{"provider default", GenerateOptions{}, false},
+		{"greedy", GenerateOptions{Temperature: Float64(0)}, true},
+		{"sampled", GenerateOptions{Temperature: Float64(0.7)}, false},
+		{"seeded", GenerateOptions{Temperature: Float64(0.7), Seed: Int(42)}, true},
+	}
+	for _, tt := range tests {
+		if got := IsDeterministic(tt.opts); got != tt.want {
+			t.Errorf("IsDeterministic(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCacheMode(t *testing.T) {
+	if got := CacheModeFrom(context.Background()); got != CacheDefault {
+		t.Errorf("CacheModeFrom(background) = %v, want default", got)
+	}
+	ctx := WithCacheMode(context.Background(), CacheRefresh)
+	if got := CacheModeFrom(ctx); got != CacheRefresh {
+		t.Errorf("CacheModeFrom() = %v, want refresh", got)
+	}
+
+	for name, want := range map[string]CacheMode{"": CacheDefault, "bypass": CacheBypass, "off": CacheBypass, "Refresh": CacheRefresh} {
+		if got, err := ParseCacheMode(name); err != nil || got != want {
+			t.Errorf("ParseCacheMode(%q) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+	if _, err := ParseCacheMode("sometimes"); err == nil {
+		t.Error("ParseCacheMode(sometimes) succeeded, want an error")
+	}
+}
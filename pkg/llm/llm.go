@@ -0,0 +1,204 @@
+// Package llm holds the types shared between the generation pipeline and the
+// individual language model providers.
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Chunk is a single fragment of a streamed generation. The final chunk of a
+// stream has Done set; a chunk carrying Err also ends the stream. Providers
+// that report them attach usage and completed tool calls to the final chunk.
+type Chunk struct {
+	Content   string
+	Done      bool
+	Err       error
+	Usage     *Usage
+	ToolCalls []ToolCall
+}
+
+// Message roles understood by every chat-capable provider
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// Message is a single turn of a conversation. Assistant messages may request
+// tool calls, which are answered by tool messages carrying the call ID.
+type Message struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+// Tool describes a function the model may call. Parameters is a JSON Schema
+// object describing the arguments. Handler runs the tool when the model calls
+// it; it is never sent to the provider.
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+	Handler     ToolHandler     `json:"-"`
+}
+
+// ToolHandler executes a tool call. It receives the arguments as produced by
+// the model and returns the result reported back to it.
+type ToolHandler func(ctx context.Context, arguments json.RawMessage) (string, error)
+
+// ToolCall is a model's request to invoke a tool. Arguments holds the raw
+// JSON arguments as produced by the model.
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// Usage reports the tokens consumed by a request
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// ChatRequest is a message-based generation request. Format, when set, asks
+// for a reply in JSON.
+type ChatRequest struct {
+	Messages []Message
+	Options  GenerateOptions
+	Tools    []Tool
+	Format   *ResponseFormat
+}
+
+// ResponseFormat selects the provider's native JSON mode. Providers that can
+// enforce a schema constrain the reply to Schema; the others only guarantee
+// syntactically valid JSON, or nothing at all.
+type ResponseFormat struct {
+	// Name identifies the schema to providers that require one
+	Name string `json:"name,omitempty"`
+	// Schema is a JSON Schema object; nil asks for any JSON object
+	Schema json.RawMessage `json:"schema,omitempty"`
+}
+
+// ChatResponse is the reply to a ChatRequest. Usage is nil when the provider
+// doesn't report it.
+type ChatResponse struct {
+	Message Message
+	Usage   *Usage
+}
+
+// GenerateOptions tunes a single generation. Zero values leave the choice to
+// the provider; Temperature and Seed are pointers because zero is meaningful.
+type GenerateOptions struct {
+	Model       string   `yaml:"model,omitempty" json:"model,omitempty"`
+	Temperature *float64 `yaml:"temperature,omitempty" json:"temperature,omitempty"`
+	MaxTokens   int      `yaml:"max_tokens,omitempty" json:"max_tokens,omitempty"`
+	Stop        []string `yaml:"stop,omitempty" json:"stop,omitempty"`
+	Seed        *int     `yaml:"seed,omitempty" json:"seed,omitempty"`
+	TopP        float64  `yaml:"top_p,omitempty" json:"top_p,omitempty"`
+	TopK        int      `yaml:"top_k,omitempty" json:"top_k,omitempty"`
+}
+
+// Merge returns o with every field that is set in override replaced
+func (o GenerateOptions) Merge(override GenerateOptions) GenerateOptions {
+	if override.Model != "" {
+		o.Model = override.Model
+	}
+	if override.Temperature != nil {
+		o.Temperature = override.Temperature
+	}
+	if override.MaxTokens > 0 {
+		o.MaxTokens = override.MaxTokens
+	}
+	if len(override.Stop) > 0 {
+		o.Stop = override.Stop
+	}
+	if override.Seed != nil {
+		o.Seed = override.Seed
+	}
+	if override.TopP > 0 {
+		o.TopP = override.TopP
+	}
+	if override.TopK > 0 {
+		o.TopK = override.TopK
+	}
+	return o
+}
+
+// Float64 returns a pointer to v, for use with optional option fields
+func Float64(v float64) *float64 {
+	return &v
+}
+
+// Int returns a pointer to v, for use with optional option fields
+func Int(v int) *int {
+	return &v
+}
+
+// maxErrorBody caps how much of an error response is kept on an APIError
+const maxErrorBody = 64 * 1024
+
+// APIError is returned when a provider answers with a non-200 status code
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+	// RetryAfter is the delay requested by the server, if any
+	RetryAfter time.Duration
+}
+
+// NewAPIError builds an APIError from a failed response, consuming and
+// closing its body
+func NewAPIError(provider string, resp *http.Response) *APIError {
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	return &APIError{
+		Provider:   provider,
+		StatusCode: resp.StatusCode,
+		Body:       strings.TrimSpace(string(body)),
+		RetryAfter: ParseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+func (e *APIError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("%s: unexpected status code: %d", e.Provider, e.StatusCode)
+	}
+	return fmt.Sprintf("%s: unexpected status code: %d: %s", e.Provider, e.StatusCode, e.Body)
+}
+
+// Retryable reports whether the request may succeed if sent again
+func (e *APIError) Retryable() bool {
+	return IsRetryableStatus(e.StatusCode)
+}
+
+// IsRetryableStatus reports whether a status code signals a transient failure
+func IsRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// ParseRetryAfter parses a Retry-After header given either in seconds or as an
+// HTTP date. It returns zero if the header is missing or invalid.
+func ParseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if d := time.Until(at); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -2,18 +2,146 @@ package ollama
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+
+	"threshAI/pkg/llm"
 )
 
 type Adapter struct {
-	client *Client
+	client         *Client
+	options        llm.GenerateOptions
+	embeddingModel string
 }
 
 func NewAdapter(config Config) *Adapter {
+	embeddingModel := config.EmbeddingModel
+	if embeddingModel == "" {
+		embeddingModel = DefaultEmbeddingModel
+	}
 	return &Adapter{
-		client: NewClient(config.BaseURL),
+		client:         NewClientWithTransport(config.BaseURL, config.Transport),
+		options:        config.Options,
+		embeddingModel: embeddingModel,
 	}
 }
 
-func (a *Adapter) Generate(ctx context.Context, prompt string) (string, error) {
-	return a.client.Generate(ctx, prompt)
+func (a *Adapter) Generate(ctx context.Context, prompt string, opts llm.GenerateOptions) (string, error) {
+	return a.client.Generate(ctx, prompt, a.options.Merge(opts))
+}
+
+func (a *Adapter) GenerateStream(ctx context.Context, prompt string, opts llm.GenerateOptions) (<-chan llm.Chunk, error) {
+	return a.client.GenerateStream(ctx, prompt, a.options.Merge(opts))
+}
+
+func (a *Adapter) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	msg, usage, err := a.client.Chat(ctx, a.newChatRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	return &llm.ChatResponse{
+		Message: llm.Message{Role: msg.Role, Content: msg.Content, ToolCalls: toToolCalls(msg.ToolCalls)},
+		Usage:   usage,
+	}, nil
+}
+
+func (a *Adapter) ChatStream(ctx context.Context, req llm.ChatRequest) (<-chan llm.Chunk, error) {
+	return a.client.ChatStream(ctx, a.newChatRequest(req))
+}
+
+// newChatRequest maps a chat request onto the wire format, applying the
+// adapter's default options
+func (a *Adapter) newChatRequest(req llm.ChatRequest) ChatRequest {
+	chatReq := NewChatRequest(toMessages(req.Messages), a.options.Merge(req.Options))
+	chatReq.Tools = toTools(req.Tools)
+	if req.Format != nil {
+		chatReq.Format = json.RawMessage(`"json"`)
+		if len(req.Format.Schema) > 0 {
+			chatReq.Format = req.Format.Schema
+		}
+	}
+	return chatReq
+}
+
+// Embed embeds each text with the configured embedding model. Ollama embeds a
+// single text per request.
+func (a *Adapter) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		embedding, err := a.client.Embed(ctx, a.embeddingModel, text)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = embedding
+	}
+	return out, nil
+}
+
+// toMessages maps a conversation onto the wire format. Ollama identifies tool
+// results by tool name, so the name is looked up from the call being answered.
+func toMessages(messages []llm.Message) []Message {
+	toolNames := make(map[string]string)
+	out := make([]Message, len(messages))
+	for i, msg := range messages {
+		out[i] = Message{Role: msg.Role, Content: msg.Content}
+		for _, call := range msg.ToolCalls {
+			toolNames[call.ID] = call.Name
+			out[i].ToolCalls = append(out[i].ToolCalls, ToolCall{
+				ID:       call.ID,
+				Function: FunctionCall{Name: call.Name, Arguments: toArguments(call.Arguments)},
+			})
+		}
+		if msg.ToolCallID != "" {
+			out[i].ToolName = toolNames[msg.ToolCallID]
+		}
+	}
+	return out
+}
+
+func toTools(tools []llm.Tool) []Tool {
+	if len(tools) == 0 {
+		return nil
+	}
+	out := make([]Tool, len(tools))
+	for i, tool := range tools {
+		out[i] = Tool{
+			Type: "function",
+			Function: FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		}
+	}
+	return out
+}
+
+// toToolCalls converts tool calls to the shared representation, encoding the
+// arguments as a string and numbering calls that came without an ID
+func toToolCalls(calls []ToolCall) []llm.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	out := make([]llm.ToolCall, len(calls))
+	for i, call := range calls {
+		id := call.ID
+		if id == "" {
+			id = fmt.Sprintf("call_%d", i)
+		}
+		arguments := string(call.Function.Arguments)
+		if arguments == "" || arguments == "null" {
+			arguments = "{}"
+		}
+		out[i] = llm.ToolCall{ID: id, Name: call.Function.Name, Arguments: arguments}
+	}
+	return out
+}
+
+// toArguments turns encoded arguments back into the JSON object Ollama
+// expects, substituting an empty object for anything that isn't valid JSON
+func toArguments(arguments string) json.RawMessage {
+	if !json.Valid([]byte(arguments)) {
+		return json.RawMessage("{}")
+	}
+	return json.RawMessage(arguments)
 }
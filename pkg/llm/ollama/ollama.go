@@ -1,74 +1,393 @@
 package ollama
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
-	"time"
+	"os"
+	"strings"
 
+	"threshAI/pkg/core/generation"
+	"threshAI/pkg/llm"
+	"threshAI/pkg/llm/transport"
 	"threshAI/pkg/logging"
 )
 
+// DefaultURL is the address of a local Ollama server
+const DefaultURL = "http://localhost:11434"
+
+// URL returns the Ollama server to use when none is configured:
+// OLLAMA_API_URL, or DefaultURL
+func URL() string {
+	if url := os.Getenv("OLLAMA_API_URL"); url != "" {
+		return strings.TrimRight(url, "/")
+	}
+	return DefaultURL
+}
+
+// DefaultModel is used when neither the config nor the request names a model
+const DefaultModel = "llama2"
+
+// DefaultEmbeddingModel is used for embeddings when the config names none
+const DefaultEmbeddingModel = "nomic-embed-text"
+
 type Config struct {
-	BaseURL string
+	BaseURL        string `yaml:"base_url" json:"base_url"`
+	EmbeddingModel string `yaml:"embedding_model" json:"embedding_model"`
+	// Options are the defaults for every request made through the adapter
+	Options llm.GenerateOptions `yaml:"options" json:"options"`
+	// Transport configures retries, timeouts and circuit breaking
+	Transport transport.Config `yaml:"transport" json:"transport"`
 }
 
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
+	// streamClient has no overall timeout since a stream may legitimately
+	// outlive it; streams are bounded by the request context instead
+	streamClient *http.Client
 }
 
 func NewClient(baseURL string) *Client {
+	return NewClientWithTransport(baseURL, transport.Config{})
+}
+
+// NewClientWithTransport creates a client whose requests go through the
+// shared resilient transport configured by config
+func NewClientWithTransport(baseURL string, config transport.Config) *Client {
+	httpClient, streamClient := transport.NewClients(transport.BreakerKey(string(generation.ProviderOllama), baseURL), config)
 	return &Client{
-		baseURL:    baseURL,
-		httpClient: &http.Client{Timeout: 30 * time.Second},
+		baseURL:      baseURL,
+		httpClient:   httpClient,
+		streamClient: streamClient,
 	}
 }
 
 type Request struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
+	Model   string   `json:"model"`
+	Prompt  string   `json:"prompt"`
+	Stream  bool     `json:"stream"`
+	Options *Options `json:"options,omitempty"`
+}
+
+// Options holds Ollama's native model parameters
+type Options struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	NumPredict  int      `json:"num_predict,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+	Seed        *int     `json:"seed,omitempty"`
+	TopP        float64  `json:"top_p,omitempty"`
+	TopK        int      `json:"top_k,omitempty"`
 }
 
 type Response struct {
 	Response string `json:"response"`
+	Done     bool   `json:"done"`
+	Error    string `json:"error,omitempty"`
+	Metrics
+}
+
+// Metrics are the token counts Ollama reports on the final response
+type Metrics struct {
+	PromptEvalCount int `json:"prompt_eval_count,omitempty"`
+	EvalCount       int `json:"eval_count,omitempty"`
 }
 
-func (c *Client) Generate(ctx context.Context, prompt string) (string, error) {
-	reqBody := Request{
-		Model:  "llama2",
-		Prompt: prompt,
+// Usage converts the reported counts, returning nil for responses that carry
+// none
+func (m Metrics) Usage() *llm.Usage {
+	if m.PromptEvalCount == 0 && m.EvalCount == 0 {
+		return nil
+	}
+	return &llm.Usage{
+		PromptTokens:     m.PromptEvalCount,
+		CompletionTokens: m.EvalCount,
+		TotalTokens:      m.PromptEvalCount + m.EvalCount,
 	}
+}
 
-	reqBytes, err := json.Marshal(reqBody)
+// Message is a single turn of an /api/chat conversation. Tool messages name
+// the tool they answer rather than the call.
+type Message struct {
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	ToolName  string     `json:"tool_name,omitempty"`
+}
+
+// ToolCall is a function call requested by the model. Unlike the OpenAI
+// format, the arguments are a JSON object rather than an encoded string, and
+// older Ollama versions don't assign IDs.
+type ToolCall struct {
+	ID       string       `json:"id,omitempty"`
+	Function FunctionCall `json:"function"`
+}
+
+type FunctionCall struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// Tool declares a function the model may call
+type Tool struct {
+	Type     string             `json:"type"`
+	Function FunctionDefinition `json:"function"`
+}
+
+type FunctionDefinition struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+type ChatRequest struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	Stream   bool      `json:"stream"`
+	Options  *Options  `json:"options,omitempty"`
+	Tools    []Tool    `json:"tools,omitempty"`
+	// Format is "json" for any JSON value or a JSON Schema the reply must
+	// follow
+	Format json.RawMessage `json:"format,omitempty"`
+}
+
+type ChatResponse struct {
+	Message Message `json:"message"`
+	Done    bool    `json:"done"`
+	Error   string  `json:"error,omitempty"`
+	Metrics
+}
+
+func (c *Client) Generate(ctx context.Context, prompt string, opts llm.GenerateOptions) (string, error) {
+	logging.Logger.Printf("Making request to Ollama API with prompt: %s", prompt)
+	resp, err := c.post(ctx, c.httpClient, "/api/generate", Request{
+		Model:   modelName(opts),
+		Prompt:  prompt,
+		Options: toOptions(opts),
+		Stream:  false,
+	})
 	if err != nil {
-		return "", fmt.Errorf("error marshaling request: %w", err)
+		return "", err
 	}
+	defer resp.Body.Close()
 
-	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/generate", bytes.NewBuffer(reqBytes))
-	if err != nil {
-		return "", fmt.Errorf("error creating request: %w", err)
+	var response Response
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", fmt.Errorf("error decoding response: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	return response.Response, nil
+}
 
+// GenerateStream sends a prompt to Ollama and emits the response as it is
+// produced. Ollama streams one JSON object per line.
+func (c *Client) GenerateStream(ctx context.Context, prompt string, opts llm.GenerateOptions) (<-chan llm.Chunk, error) {
 	logging.Logger.Printf("Making request to Ollama API with prompt: %s", prompt)
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.post(ctx, c.streamClient, "/api/generate", Request{
+		Model:   modelName(opts),
+		Prompt:  prompt,
+		Options: toOptions(opts),
+		Stream:  true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return streamLines(ctx, resp.Body, func(line []byte) (llm.Chunk, error) {
+		var response Response
+		if err := json.Unmarshal(line, &response); err != nil {
+			return llm.Chunk{}, err
+		}
+		if response.Error != "" {
+			return llm.Chunk{}, fmt.Errorf("ollama: %s", response.Error)
+		}
+		return llm.Chunk{Content: response.Response, Done: response.Done, Usage: response.Usage()}, nil
+	}), nil
+}
+
+// Chat sends a conversation to Ollama's /api/chat endpoint and returns the
+// assistant reply, including any tool calls, along with the reported token
+// usage
+func (c *Client) Chat(ctx context.Context, req ChatRequest) (Message, *llm.Usage, error) {
+	logging.Logger.Printf("Making chat request to Ollama API with %d messages", len(req.Messages))
+	req.Stream = false
+	resp, err := c.post(ctx, c.httpClient, "/api/chat", req)
 	if err != nil {
-		return "", fmt.Errorf("error making request: %w", err)
+		return Message{}, nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	var response ChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return Message{}, nil, fmt.Errorf("error decoding response: %w", err)
+	}
+	if response.Error != "" {
+		return Message{}, nil, fmt.Errorf("ollama: %s", response.Error)
 	}
 
-	var response Response
+	return response.Message, response.Usage(), nil
+}
+
+// ChatStream sends a conversation to Ollama's /api/chat endpoint and emits the
+// assistant reply as it is produced. Tool calls arrive as whole calls on any
+// line of the stream; they are collected and attached to the final chunk.
+func (c *Client) ChatStream(ctx context.Context, req ChatRequest) (<-chan llm.Chunk, error) {
+	logging.Logger.Printf("Making chat request to Ollama API with %d messages", len(req.Messages))
+	req.Stream = true
+	resp, err := c.post(ctx, c.streamClient, "/api/chat", req)
+	if err != nil {
+		return nil, err
+	}
+
+	var calls []ToolCall
+	return streamLines(ctx, resp.Body, func(line []byte) (llm.Chunk, error) {
+		var response ChatResponse
+		if err := json.Unmarshal(line, &response); err != nil {
+			return llm.Chunk{}, err
+		}
+		if response.Error != "" {
+			return llm.Chunk{}, fmt.Errorf("ollama: %s", response.Error)
+		}
+		calls = append(calls, response.Message.ToolCalls...)
+
+		chunk := llm.Chunk{Content: response.Message.Content, Done: response.Done, Usage: response.Usage()}
+		if response.Done {
+			chunk.ToolCalls = toToolCalls(calls)
+		}
+		return chunk, nil
+	}), nil
+}
+
+// NewChatRequest maps a conversation and generation options onto an /api/chat
+// request for the default model unless opts names one
+func NewChatRequest(messages []Message, opts llm.GenerateOptions) ChatRequest {
+	return ChatRequest{
+		Model:    modelName(opts),
+		Messages: messages,
+		Options:  toOptions(opts),
+	}
+}
+
+type EmbeddingRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+}
+
+type EmbeddingResponse struct {
+	Embedding []float32 `json:"embedding"`
+}
+
+// Embed returns the embedding of text from Ollama's /api/embeddings endpoint
+func (c *Client) Embed(ctx context.Context, model, text string) ([]float32, error) {
+	resp, err := c.post(ctx, c.httpClient, "/api/embeddings", EmbeddingRequest{Model: model, Prompt: text})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response EmbeddingResponse
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return "", fmt.Errorf("error decoding response: %w", err)
+		return nil, fmt.Errorf("error decoding response: %w", err)
 	}
+	if len(response.Embedding) == 0 {
+		return nil, fmt.Errorf("ollama: empty embedding for model %s", model)
+	}
+	return response.Embedding, nil
+}
 
-	return response.Response, nil
+func modelName(opts llm.GenerateOptions) string {
+	if opts.Model != "" {
+		return opts.Model
+	}
+	return DefaultModel
+}
+
+// toOptions maps generation options onto Ollama's parameters, returning nil
+// when nothing needs to be sent
+func toOptions(opts llm.GenerateOptions) *Options {
+	o := Options{
+		Temperature: opts.Temperature,
+		NumPredict:  opts.MaxTokens,
+		Stop:        opts.Stop,
+		Seed:        opts.Seed,
+		TopP:        opts.TopP,
+		TopK:        opts.TopK,
+	}
+	if o.Temperature == nil && o.NumPredict == 0 && len(o.Stop) == 0 && o.Seed == nil && o.TopP == 0 && o.TopK == 0 {
+		return nil
+	}
+	return &o
+}
+
+func (c *Client) post(ctx context.Context, httpClient *http.Client, path string, reqBody interface{}) (*http.Response, error) {
+	reqBytes, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+path, bytes.NewBuffer(reqBytes))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, llm.NewAPIError(string(generation.ProviderOllama), resp)
+	}
+
+	return resp, nil
+}
+
+// streamLines decodes a newline-delimited JSON body into chunks until a chunk
+// marked Done is seen. The body is closed once the stream ends.
+func streamLines(ctx context.Context, body io.ReadCloser, decode func(line []byte) (llm.Chunk, error)) <-chan llm.Chunk {
+	ch := make(chan llm.Chunk)
+	go func() {
+		defer close(ch)
+		defer body.Close()
+
+		send := func(chunk llm.Chunk) bool {
+			select {
+			case ch <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+
+			chunk, err := decode(line)
+			if err != nil {
+				send(llm.Chunk{Err: fmt.Errorf("error decoding stream: %w", err)})
+				return
+			}
+			if !send(chunk) || chunk.Done {
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			send(llm.Chunk{Err: fmt.Errorf("error reading stream: %w", err)})
+			return
+		}
+		send(llm.Chunk{Err: fmt.Errorf("stream ended before completion")})
+	}()
+	return ch
 }
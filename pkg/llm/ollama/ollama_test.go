@@ -0,0 +1,256 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"threshAI/pkg/llm"
+	"threshAI/pkg/llm/transport"
+)
+
+// newTestServer returns a stand-in Ollama server for path that records the
+// last decoded request and answers with the given handler
+func newTestServer[T any](t *testing.T, path string, reply func(w http.ResponseWriter, req T)) (*httptest.Server, *T) {
+	t.Helper()
+	var last T
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&last); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		reply(w, last)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &last
+}
+
+func newTestAdapter(ts *httptest.Server) *Adapter {
+	return NewAdapter(Config{
+		BaseURL:   ts.URL,
+		Options:   llm.GenerateOptions{Model: "llama3", Temperature: llm.Float64(0.7)},
+		Transport: transport.Config{MaxRetries: -1},
+	})
+}
+
+// ndjson writes one JSON object per line, as Ollama streams
+func ndjson(w http.ResponseWriter, lines ...string) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+}
+
+// collect drains a stream, returning its content and final chunk
+func collect(t *testing.T, stream <-chan llm.Chunk) (string, llm.Chunk, error) {
+	t.Helper()
+	var content strings.Builder
+	var final llm.Chunk
+	for chunk := range stream {
+		if chunk.Err != nil {
+			return content.String(), final, chunk.Err
+		}
+		content.WriteString(chunk.Content)
+		if chunk.Done {
+			final = chunk
+		}
+	}
+	return content.String(), final, nil
+}
+
+func TestChat(t *testing.T) {
+	ts, last := newTestServer(t, "/api/chat", func(w http.ResponseWriter, req ChatRequest) {
+		fmt.Fprint(w, `{
+			"message": {"role": "assistant", "content": "Hello!"},
+			"done": true,
+			"prompt_eval_count": 12,
+			"eval_count": 3
+		}`)
+	})
+
+	schema := json.RawMessage(`{"type":"object","properties":{"ok":{"type":"boolean"}}}`)
+	resp, err := newTestAdapter(ts).Chat(context.Background(), llm.ChatRequest{
+		Messages: []llm.Message{
+			{Role: llm.RoleSystem, Content: "Be brief."},
+			{Role: llm.RoleUser, Content: "Hi"},
+		},
+		Options: llm.GenerateOptions{Temperature: llm.Float64(0), MaxTokens: 64, Seed: llm.Int(7)},
+		Format:  &llm.ResponseFormat{Schema: schema},
+	})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	if resp.Message.Content != "Hello!" {
+		t.Errorf("Content = %q, want %q", resp.Message.Content, "Hello!")
+	}
+	if resp.Usage == nil || resp.Usage.PromptTokens != 12 || resp.Usage.TotalTokens != 15 {
+		t.Errorf("Usage = %+v, want 12 prompt / 15 total tokens", resp.Usage)
+	}
+
+	if last.Model != "llama3" || last.Stream {
+		t.Errorf("Model = %q, Stream = %v, want llama3 without streaming", last.Model, last.Stream)
+	}
+	if len(last.Messages) != 2 || last.Messages[0].Role != llm.RoleSystem || last.Messages[0].Content != "Be brief." {
+		t.Errorf("Messages = %+v, want the system prompt and the user message", last.Messages)
+	}
+	// Request options override the configured defaults field by field
+	opts := last.Options
+	if opts == nil || opts.Temperature == nil || *opts.Temperature != 0 || opts.NumPredict != 64 || opts.Seed == nil || *opts.Seed != 7 {
+		t.Errorf("Options = %+v, want temperature 0, num_predict 64 and seed 7", opts)
+	}
+	if string(last.Format) != string(schema) {
+		t.Errorf("Format = %s, want the schema", last.Format)
+	}
+}
+
+func TestChatToolMessages(t *testing.T) {
+	ts, last := newTestServer(t, "/api/chat", func(w http.ResponseWriter, req ChatRequest) {
+		fmt.Fprint(w, `{"message": {"role": "assistant", "content": "It is sunny."}, "done": true}`)
+	})
+
+	_, err := newTestAdapter(ts).Chat(context.Background(), llm.ChatRequest{
+		Messages: []llm.Message{
+			{Role: llm.RoleUser, Content: "Weather in Oslo?"},
+			{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{ID: "call_0", Name: "get_weather", Arguments: `{"city":"Oslo"}`}}},
+			{Role: "tool", Content: "sunny", ToolCallID: "call_0"},
+		},
+		Tools: []llm.Tool{{Name: "get_weather", Parameters: json.RawMessage(`{"type":"object"}`)}},
+	})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	if len(last.Tools) != 1 || last.Tools[0].Type != "function" || last.Tools[0].Function.Name != "get_weather" {
+		t.Errorf("Tools = %+v, want get_weather function", last.Tools)
+	}
+	call := last.Messages[1].ToolCalls
+	if len(call) != 1 || string(call[0].Function.Arguments) != `{"city":"Oslo"}` {
+		t.Errorf("assistant tool calls = %+v, want the arguments as an object", call)
+	}
+	// Tool results name the tool rather than the call
+	if last.Messages[2].ToolName != "get_weather" {
+		t.Errorf("ToolName = %q, want get_weather", last.Messages[2].ToolName)
+	}
+}
+
+func TestChatStream(t *testing.T) {
+	ts, last := newTestServer(t, "/api/chat", func(w http.ResponseWriter, req ChatRequest) {
+		ndjson(w,
+			`{"message":{"role":"assistant","content":"Checking"},"done":false}`,
+			``,
+			`{"message":{"role":"assistant","content":" now","tool_calls":[{"function":{"name":"get_weather","arguments":{"city":"Oslo"}}}]},"done":false}`,
+			`{"message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":20,"eval_count":9}`,
+			// Anything after the done line is ignored
+			`not json`,
+		)
+	})
+
+	stream, err := newTestAdapter(ts).ChatStream(context.Background(), llm.ChatRequest{
+		Messages: []llm.Message{
+			{Role: llm.RoleSystem, Content: "Use tools."},
+			{Role: llm.RoleUser, Content: "Weather in Oslo?"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+	content, final, err := collect(t, stream)
+	if err != nil {
+		t.Fatalf("stream error: %v", err)
+	}
+
+	if !last.Stream || len(last.Messages) != 2 || last.Messages[0].Role != llm.RoleSystem {
+		t.Errorf("request = %+v, want a stream of the system prompt and user message", last)
+	}
+	if content != "Checking now" {
+		t.Errorf("content = %q, want %q", content, "Checking now")
+	}
+	if !final.Done {
+		t.Fatal("stream ended without a final chunk")
+	}
+	// Calls without an ID are numbered
+	want := llm.ToolCall{ID: "call_0", Name: "get_weather", Arguments: `{"city":"Oslo"}`}
+	if len(final.ToolCalls) != 1 || final.ToolCalls[0] != want {
+		t.Errorf("ToolCalls = %+v, want %+v", final.ToolCalls, want)
+	}
+	if final.Usage == nil || final.Usage.PromptTokens != 20 || final.Usage.TotalTokens != 29 {
+		t.Errorf("Usage = %+v, want 20 prompt / 29 total tokens", final.Usage)
+	}
+}
+
+func TestGenerateStream(t *testing.T) {
+	ts, last := newTestServer(t, "/api/generate", func(w http.ResponseWriter, req Request) {
+		ndjson(w,
+			`{"response":"Hel","done":false}`,
+			`{"response":"lo","done":false}`,
+			`{"response":"","done":true,"prompt_eval_count":4,"eval_count":2}`,
+		)
+	})
+
+	stream, err := newTestAdapter(ts).GenerateStream(context.Background(), "Say hello", llm.GenerateOptions{Stop: []string{"\n"}})
+	if err != nil {
+		t.Fatalf("GenerateStream() error = %v", err)
+	}
+	content, final, err := collect(t, stream)
+	if err != nil {
+		t.Fatalf("stream error: %v", err)
+	}
+
+	if last.Prompt != "Say hello" || !last.Stream || last.Options == nil || len(last.Options.Stop) != 1 {
+		t.Errorf("request = %+v, want a stream of the prompt with a stop sequence", last)
+	}
+	if content != "Hello" {
+		t.Errorf("content = %q, want Hello", content)
+	}
+	if final.Usage == nil || final.Usage.TotalTokens != 6 {
+		t.Errorf("Usage = %+v, want 6 total tokens", final.Usage)
+	}
+}
+
+func TestChatStreamErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  string
+	}{
+		{"malformed line", []string{`{"message":{"content":"Hi"},"done":false}`, `{"message":`}, "error decoding stream"},
+		{"error line", []string{`{"error":"model 'llama3' not found"}`}, "model 'llama3' not found"},
+		{"missing done", []string{`{"message":{"content":"Hi"},"done":false}`}, "stream ended before completion"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, _ := newTestServer(t, "/api/chat", func(w http.ResponseWriter, req ChatRequest) {
+				ndjson(w, tt.lines...)
+			})
+			stream, err := newTestAdapter(ts).ChatStream(context.Background(), llm.ChatRequest{
+				Messages: []llm.Message{{Role: llm.RoleUser, Content: "Hi"}},
+			})
+			if err != nil {
+				t.Fatalf("ChatStream() error = %v", err)
+			}
+			if _, _, err := collect(t, stream); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("stream error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestChatErrorStatus(t *testing.T) {
+	ts, _ := newTestServer(t, "/api/chat", func(w http.ResponseWriter, req ChatRequest) {
+		http.Error(w, `{"error":"model not found"}`, http.StatusNotFound)
+	})
+
+	if _, err := newTestAdapter(ts).Chat(context.Background(), llm.ChatRequest{
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "Hi"}},
+	}); err == nil {
+		t.Fatal("Chat() error = nil, want error for 404 response")
+	}
+}
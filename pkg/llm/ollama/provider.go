@@ -0,0 +1,28 @@
+package ollama
+
+import (
+	"fmt"
+
+	"threshAI/pkg/cache"
+	"threshAI/pkg/core/generation"
+)
+
+func init() {
+	generation.MustRegister(generation.Provider{
+		Name:         generation.ProviderOllama,
+		Factory:      newGenerator,
+		Decode:       generation.DecodeYAML(Config{}),
+		Capabilities: generation.CapStream | generation.CapChat | generation.CapEmbeddings,
+	})
+}
+
+func newGenerator(config interface{}, _ cache.Cache) (generation.Generator, error) {
+	cfg, err := generation.ConfigAs[Config](config)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.BaseURL == "" {
+		return nil, fmt.Errorf("base_url is required")
+	}
+	return NewAdapter(cfg), nil
+}
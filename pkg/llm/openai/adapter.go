@@ -0,0 +1,170 @@
+package openai
+
+import (
+	"context"
+
+	"threshAI/pkg/llm"
+)
+
+type Adapter struct {
+	client         *Client
+	options        llm.GenerateOptions
+	embeddingModel string
+}
+
+func NewAdapter(config Config) *Adapter {
+	embeddingModel := config.EmbeddingModel
+	if embeddingModel == "" {
+		embeddingModel = DefaultEmbeddingModel
+	}
+	return &Adapter{
+		client:         NewClient(config),
+		options:        config.Options,
+		embeddingModel: embeddingModel,
+	}
+}
+
+func (a *Adapter) Generate(ctx context.Context, prompt string, opts llm.GenerateOptions) (string, error) {
+	resp, err := a.Chat(ctx, llm.ChatRequest{
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: prompt}},
+		Options:  opts,
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.Message.Content, nil
+}
+
+func (a *Adapter) GenerateStream(ctx context.Context, prompt string, opts llm.GenerateOptions) (<-chan llm.Chunk, error) {
+	return a.ChatStream(ctx, llm.ChatRequest{
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: prompt}},
+		Options:  opts,
+	})
+}
+
+func (a *Adapter) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	msg, usage, err := a.client.Chat(ctx, a.newRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	return &llm.ChatResponse{
+		Message: llm.Message{
+			Role:      llm.RoleAssistant,
+			Content:   msg.Content,
+			ToolCalls: toToolCalls(msg.ToolCalls),
+		},
+		Usage: toUsage(usage),
+	}, nil
+}
+
+func (a *Adapter) ChatStream(ctx context.Context, req llm.ChatRequest) (<-chan llm.Chunk, error) {
+	return a.client.ChatStream(ctx, a.newRequest(req))
+}
+
+// Embed embeds texts with the configured embedding model in a single request
+func (a *Adapter) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return a.client.Embed(ctx, a.embeddingModel, texts)
+}
+
+// newRequest maps a chat request onto the wire format, applying the adapter's
+// default options
+func (a *Adapter) newRequest(req llm.ChatRequest) Request {
+	opts := a.options.Merge(req.Options)
+
+	messages := make([]Message, len(req.Messages))
+	for i, msg := range req.Messages {
+		messages[i] = Message{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			ToolCalls:  fromToolCalls(msg.ToolCalls),
+			ToolCallID: msg.ToolCallID,
+		}
+	}
+
+	var tools []Tool
+	for _, tool := range req.Tools {
+		tools = append(tools, Tool{
+			Type: "function",
+			Function: FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+
+	return Request{
+		Model:          opts.Model,
+		Messages:       messages,
+		Temperature:    opts.Temperature,
+		MaxTokens:      opts.MaxTokens,
+		Stop:           opts.Stop,
+		Seed:           opts.Seed,
+		TopP:           opts.TopP,
+		TopK:           opts.TopK,
+		Tools:          tools,
+		ResponseFormat: toResponseFormat(req.Format),
+	}
+}
+
+// toResponseFormat maps a requested format onto OpenAI's response_format
+func toResponseFormat(format *llm.ResponseFormat) *ResponseFormat {
+	if format == nil {
+		return nil
+	}
+	if len(format.Schema) == 0 {
+		return &ResponseFormat{Type: "json_object"}
+	}
+	name := format.Name
+	if name == "" {
+		name = "response"
+	}
+	return &ResponseFormat{
+		Type:       "json_schema",
+		JSONSchema: &JSONSchema{Name: name, Schema: format.Schema},
+	}
+}
+
+func toToolCalls(calls []ToolCall) []llm.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	out := make([]llm.ToolCall, len(calls))
+	for i, call := range calls {
+		out[i] = llm.ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		}
+	}
+	return out
+}
+
+func fromToolCalls(calls []llm.ToolCall) []ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	out := make([]ToolCall, len(calls))
+	for i, call := range calls {
+		out[i] = ToolCall{
+			ID:   call.ID,
+			Type: "function",
+			Function: FunctionCall{
+				Name:      call.Name,
+				Arguments: call.Arguments,
+			},
+		}
+	}
+	return out
+}
+
+func toUsage(usage *Usage) *llm.Usage {
+	if usage == nil {
+		return nil
+	}
+	return &llm.Usage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+	}
+}
@@ -0,0 +1,389 @@
+// Package openai provides a client for servers implementing the OpenAI chat
+// completions protocol, such as llama.cpp server, vLLM, LM Studio or the
+// OpenAI API itself.
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"threshAI/pkg/core/generation"
+	"threshAI/pkg/llm"
+	"threshAI/pkg/llm/transport"
+	"threshAI/pkg/logging"
+)
+
+// DefaultBaseURL is the OpenAI API endpoint
+const DefaultBaseURL = "https://api.openai.com/v1"
+
+// DefaultEmbeddingModel is used for embeddings when the config names none
+const DefaultEmbeddingModel = "text-embedding-3-small"
+
+// Config configures an OpenAI-compatible endpoint. BaseURL includes the API
+// version prefix, e.g. http://localhost:8080/v1 for llama.cpp server.
+type Config struct {
+	BaseURL string `yaml:"base_url" json:"base_url"`
+	APIKey  string `yaml:"api_key" json:"api_key"`
+	// EmbeddingModel is the model used for embeddings
+	EmbeddingModel string `yaml:"embedding_model" json:"embedding_model"`
+	// Headers are added to every request, e.g. for gateways that need
+	// organisation or routing headers
+	Headers map[string]string `yaml:"headers" json:"headers"`
+	// Options are the defaults for every request made through the adapter
+	Options llm.GenerateOptions `yaml:"options" json:"options"`
+	// Transport configures retries, timeouts and circuit breaking
+	Transport transport.Config `yaml:"transport" json:"transport"`
+}
+
+type Client struct {
+	name       string
+	baseURL    string
+	apiKey     string
+	headers    map[string]string
+	httpClient *http.Client
+	// streamClient has no overall timeout since a stream may legitimately
+	// outlive it; streams are bounded by the request context instead
+	streamClient *http.Client
+}
+
+func NewClient(config Config) *Client {
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	baseURL = strings.TrimRight(baseURL, "/")
+
+	// Each endpoint gets its own breaker; a failing local server shouldn't
+	// trip the breaker for a hosted one
+	name := transport.BreakerKey(string(generation.ProviderOpenAI), baseURL)
+	httpClient, streamClient := transport.NewClients(name, config.Transport)
+
+	return &Client{
+		name:         name,
+		baseURL:      baseURL,
+		apiKey:       config.APIKey,
+		headers:      config.Headers,
+		httpClient:   httpClient,
+		streamClient: streamClient,
+	}
+}
+
+// Message is a chat message in the wire format
+type Message struct {
+	Role       string     `json:"role,omitempty"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+// ToolCall is a function call requested by the model. Index is only set on
+// streamed deltas, where a call's fields arrive spread over several events.
+type ToolCall struct {
+	Index    *int         `json:"index,omitempty"`
+	ID       string       `json:"id,omitempty"`
+	Type     string       `json:"type,omitempty"`
+	Function FunctionCall `json:"function"`
+}
+
+type FunctionCall struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
+}
+
+type Tool struct {
+	Type     string             `json:"type"`
+	Function FunctionDefinition `json:"function"`
+}
+
+type FunctionDefinition struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// Request is a chat completions request. TopK is not part of the OpenAI API
+// but is understood by llama.cpp and vLLM; it is only sent when set.
+type Request struct {
+	Model          string          `json:"model,omitempty"`
+	Messages       []Message       `json:"messages"`
+	Stream         bool            `json:"stream,omitempty"`
+	StreamOptions  *StreamOptions  `json:"stream_options,omitempty"`
+	Temperature    *float64        `json:"temperature,omitempty"`
+	MaxTokens      int             `json:"max_tokens,omitempty"`
+	Stop           []string        `json:"stop,omitempty"`
+	Seed           *int            `json:"seed,omitempty"`
+	TopP           float64         `json:"top_p,omitempty"`
+	TopK           int             `json:"top_k,omitempty"`
+	Tools          []Tool          `json:"tools,omitempty"`
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+}
+
+// ResponseFormat selects JSON mode. Type is "json_object" for any JSON
+// object or "json_schema" for replies constrained to JSONSchema.
+type ResponseFormat struct {
+	Type       string      `json:"type"`
+	JSONSchema *JSONSchema `json:"json_schema,omitempty"`
+}
+
+type JSONSchema struct {
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
+}
+
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type Response struct {
+	Choices []struct {
+		Message      Message `json:"message"`
+		FinishReason string  `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+// StreamResponse is a single server-sent event of a streamed response
+type StreamResponse struct {
+	Choices []struct {
+		Delta        Message `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+type EmbeddingRequest struct {
+	Model string   `json:"model,omitempty"`
+	Input []string `json:"input"`
+}
+
+type EmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+// Embed returns the embeddings of texts, in order, from the /embeddings
+// endpoint
+func (c *Client) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	logging.Logger.Printf("Making embeddings request to %s with %d inputs", c.baseURL, len(texts))
+	resp, err := c.do(ctx, c.httpClient, "/embeddings", EmbeddingRequest{Model: model, Input: texts}, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response EmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+
+	out := make([][]float32, len(texts))
+	for _, d := range response.Data {
+		if d.Index < 0 || d.Index >= len(out) {
+			return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+		}
+		out[d.Index] = d.Embedding
+	}
+	for i, embedding := range out {
+		if len(embedding) == 0 {
+			return nil, fmt.Errorf("no embedding returned for input %d", i)
+		}
+	}
+	return out, nil
+}
+
+// Chat sends a chat completions request and returns the first choice
+func (c *Client) Chat(ctx context.Context, reqBody Request) (Message, *Usage, error) {
+	reqBody.Stream = false
+	reqBody.StreamOptions = nil
+
+	resp, err := c.post(ctx, c.httpClient, reqBody)
+	if err != nil {
+		return Message{}, nil, err
+	}
+	defer resp.Body.Close()
+
+	var response Response
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return Message{}, nil, fmt.Errorf("error decoding response: %w", err)
+	}
+	if len(response.Choices) == 0 {
+		return Message{}, nil, fmt.Errorf("no choices in response")
+	}
+
+	return response.Choices[0].Message, response.Usage, nil
+}
+
+// ChatStream sends a streaming chat completions request. Content deltas are
+// emitted as they arrive; tool calls are assembled from their deltas and
+// attached to the final chunk together with the reported usage.
+func (c *Client) ChatStream(ctx context.Context, reqBody Request) (<-chan llm.Chunk, error) {
+	reqBody.Stream = true
+	reqBody.StreamOptions = &StreamOptions{IncludeUsage: true}
+
+	resp, err := c.post(ctx, c.streamClient, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan llm.Chunk)
+	go func() {
+		defer close(ch)
+		defer resp.Body.Close()
+
+		send := func(chunk llm.Chunk) bool {
+			select {
+			case ch <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		var usage *Usage
+		calls := make(map[int]*ToolCall)
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if !strings.HasPrefix(line, "data:") {
+				// Blank separators, comments and keep-alives
+				continue
+			}
+
+			data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			if data == "[DONE]" {
+				send(llm.Chunk{
+					Done:      true,
+					Usage:     toUsage(usage),
+					ToolCalls: toToolCalls(assembleToolCalls(calls)),
+				})
+				return
+			}
+
+			var event StreamResponse
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				send(llm.Chunk{Err: fmt.Errorf("error decoding stream event: %w", err)})
+				return
+			}
+			if event.Usage != nil {
+				usage = event.Usage
+			}
+			if len(event.Choices) == 0 {
+				continue
+			}
+
+			delta := event.Choices[0].Delta
+			for i, tc := range delta.ToolCalls {
+				index := i
+				if tc.Index != nil {
+					index = *tc.Index
+				}
+				mergeToolCall(calls, index, tc)
+			}
+
+			if delta.Content != "" && !send(llm.Chunk{Content: delta.Content}) {
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			send(llm.Chunk{Err: fmt.Errorf("error reading stream: %w", err)})
+			return
+		}
+		send(llm.Chunk{Err: fmt.Errorf("stream ended before completion")})
+	}()
+
+	return ch, nil
+}
+
+func (c *Client) post(ctx context.Context, httpClient *http.Client, reqBody Request) (*http.Response, error) {
+	logging.Logger.Printf("Making request to %s with %d messages", c.baseURL, len(reqBody.Messages))
+	return c.do(ctx, httpClient, "/chat/completions", reqBody, reqBody.Stream)
+}
+
+// do posts a JSON body to path below the base URL. Responses with a non-200
+// status code are reported as *llm.APIError.
+func (c *Client) do(ctx context.Context, httpClient *http.Client, path string, body interface{}, stream bool) (*http.Response, error) {
+	reqBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+path, bytes.NewBuffer(reqBytes))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, llm.NewAPIError(c.name, resp)
+	}
+
+	return resp, nil
+}
+
+// mergeToolCall folds a streamed tool call delta into the call at index. The
+// ID and name arrive once; the arguments arrive in fragments.
+func mergeToolCall(calls map[int]*ToolCall, index int, delta ToolCall) {
+	call, ok := calls[index]
+	if !ok {
+		call = &ToolCall{Type: "function"}
+		calls[index] = call
+	}
+	if delta.ID != "" {
+		call.ID = delta.ID
+	}
+	if delta.Type != "" {
+		call.Type = delta.Type
+	}
+	if delta.Function.Name != "" {
+		call.Function.Name = delta.Function.Name
+	}
+	call.Function.Arguments += delta.Function.Arguments
+}
+
+// assembleToolCalls returns the accumulated tool calls in index order
+func assembleToolCalls(calls map[int]*ToolCall) []ToolCall {
+	indexes := make([]int, 0, len(calls))
+	for index := range calls {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	out := make([]ToolCall, len(indexes))
+	for i, index := range indexes {
+		out[i] = *calls[index]
+	}
+	return out
+}
@@ -0,0 +1,247 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"threshAI/pkg/llm"
+)
+
+// newTestServer returns a stand-in chat completions server that records the
+// last decoded request and answers with the given handler
+func newTestServer(t *testing.T, reply func(w http.ResponseWriter, req Request)) (*httptest.Server, *Request) {
+	t.Helper()
+	var last Request
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
+			t.Errorf("Authorization = %q, want %q", got, "Bearer test-key")
+		}
+		if got := r.Header.Get("X-Team"); got != "thresh" {
+			t.Errorf("X-Team = %q, want %q", got, "thresh")
+		}
+		if err := json.NewDecoder(r.Body).Decode(&last); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		reply(w, last)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &last
+}
+
+func newTestAdapter(ts *httptest.Server) *Adapter {
+	return NewAdapter(Config{
+		BaseURL: ts.URL + "/v1",
+		APIKey:  "test-key",
+		Headers: map[string]string{"X-Team": "thresh"},
+		Options: llm.GenerateOptions{Model: "local-model", Temperature: llm.Float64(0.7)},
+	})
+}
+
+func TestChat(t *testing.T) {
+	ts, last := newTestServer(t, func(w http.ResponseWriter, req Request) {
+		fmt.Fprint(w, `{
+			"choices": [{"message": {"role": "assistant", "content": "Hello!"}, "finish_reason": "stop"}],
+			"usage": {"prompt_tokens": 12, "completion_tokens": 3, "total_tokens": 15}
+		}`)
+	})
+
+	resp, err := newTestAdapter(ts).Chat(context.Background(), llm.ChatRequest{
+		Messages: []llm.Message{
+			{Role: llm.RoleSystem, Content: "Be brief."},
+			{Role: llm.RoleUser, Content: "Hi"},
+		},
+		Options: llm.GenerateOptions{Temperature: llm.Float64(0), MaxTokens: 64, Seed: llm.Int(7)},
+	})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	if resp.Message.Content != "Hello!" {
+		t.Errorf("Content = %q, want %q", resp.Message.Content, "Hello!")
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokens != 15 || resp.Usage.PromptTokens != 12 {
+		t.Errorf("Usage = %+v, want 12 prompt / 15 total tokens", resp.Usage)
+	}
+
+	// Request options override the configured defaults field by field
+	if last.Model != "local-model" {
+		t.Errorf("Model = %q, want %q", last.Model, "local-model")
+	}
+	if last.Temperature == nil || *last.Temperature != 0 {
+		t.Errorf("Temperature = %v, want 0", last.Temperature)
+	}
+	if last.MaxTokens != 64 || last.Seed == nil || *last.Seed != 7 {
+		t.Errorf("MaxTokens = %d, Seed = %v, want 64 and 7", last.MaxTokens, last.Seed)
+	}
+	if len(last.Messages) != 2 || last.Messages[0].Role != llm.RoleSystem {
+		t.Errorf("Messages = %+v, want system and user message", last.Messages)
+	}
+	if last.Stream {
+		t.Error("Stream = true for a non-streaming request")
+	}
+}
+
+func TestChatToolCalls(t *testing.T) {
+	ts, last := newTestServer(t, func(w http.ResponseWriter, req Request) {
+		fmt.Fprint(w, `{
+			"choices": [{
+				"message": {
+					"role": "assistant",
+					"content": null,
+					"tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "get_weather", "arguments": "{\"city\":\"Oslo\"}"}}]
+				},
+				"finish_reason": "tool_calls"
+			}]
+		}`)
+	})
+
+	resp, err := newTestAdapter(ts).Chat(context.Background(), llm.ChatRequest{
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "Weather in Oslo?"}},
+		Tools: []llm.Tool{{
+			Name:        "get_weather",
+			Description: "Current weather for a city",
+			Parameters:  json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}}}`),
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	if len(last.Tools) != 1 || last.Tools[0].Type != "function" || last.Tools[0].Function.Name != "get_weather" {
+		t.Errorf("Tools = %+v, want get_weather function", last.Tools)
+	}
+
+	want := llm.ToolCall{ID: "call_1", Name: "get_weather", Arguments: `{"city":"Oslo"}`}
+	if len(resp.Message.ToolCalls) != 1 || resp.Message.ToolCalls[0] != want {
+		t.Errorf("ToolCalls = %+v, want %+v", resp.Message.ToolCalls, want)
+	}
+}
+
+func TestChatResponseFormat(t *testing.T) {
+	ts, last := newTestServer(t, func(w http.ResponseWriter, req Request) {
+		fmt.Fprint(w, `{"choices": [{"message": {"role": "assistant", "content": "{\"ok\": true}"}}]}`)
+	})
+
+	schema := json.RawMessage(`{"type":"object","properties":{"ok":{"type":"boolean"}}}`)
+	_, err := newTestAdapter(ts).Chat(context.Background(), llm.ChatRequest{
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "Ok?"}},
+		Format:   &llm.ResponseFormat{Name: "status", Schema: schema},
+	})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	format := last.ResponseFormat
+	if format == nil || format.Type != "json_schema" || format.JSONSchema == nil || format.JSONSchema.Name != "status" {
+		t.Fatalf("ResponseFormat = %+v, want json_schema named status", format)
+	}
+	if string(format.JSONSchema.Schema) != string(schema) {
+		t.Errorf("Schema = %s, want %s", format.JSONSchema.Schema, schema)
+	}
+}
+
+func TestChatStream(t *testing.T) {
+	ts, last := newTestServer(t, func(w http.ResponseWriter, req Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		events := []string{
+			`{"choices":[{"delta":{"role":"assistant","content":"Checking"}}]}`,
+			`{"choices":[{"delta":{"content":" now"}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}}]}`,
+			`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Oslo\"}"}}]}}]}`,
+			`{"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`,
+			`{"choices":[],"usage":{"prompt_tokens":20,"completion_tokens":9,"total_tokens":29}}`,
+		}
+		for _, event := range events {
+			fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+		fmt.Fprint(w, ": keep-alive\n\ndata: [DONE]\n\n")
+	})
+
+	stream, err := newTestAdapter(ts).ChatStream(context.Background(), llm.ChatRequest{
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: "Weather in Oslo?"}},
+	})
+	if err != nil {
+		t.Fatalf("ChatStream() error = %v", err)
+	}
+
+	var content string
+	var final llm.Chunk
+	for chunk := range stream {
+		if chunk.Err != nil {
+			t.Fatalf("stream error: %v", chunk.Err)
+		}
+		content += chunk.Content
+		if chunk.Done {
+			final = chunk
+		}
+	}
+
+	if !last.Stream || last.StreamOptions == nil || !last.StreamOptions.IncludeUsage {
+		t.Errorf("request did not ask for a stream with usage: %+v", last)
+	}
+	if content != "Checking now" {
+		t.Errorf("content = %q, want %q", content, "Checking now")
+	}
+	if !final.Done {
+		t.Fatal("stream ended without a final chunk")
+	}
+
+	want := llm.ToolCall{ID: "call_1", Name: "get_weather", Arguments: `{"city":"Oslo"}`}
+	if len(final.ToolCalls) != 1 || final.ToolCalls[0] != want {
+		t.Errorf("ToolCalls = %+v, want %+v", final.ToolCalls, want)
+	}
+	if final.Usage == nil || final.Usage.TotalTokens != 29 {
+		t.Errorf("Usage = %+v, want 29 total tokens", final.Usage)
+	}
+}
+
+func TestChatErrorStatus(t *testing.T) {
+	ts, _ := newTestServer(t, func(w http.ResponseWriter, req Request) {
+		http.Error(w, `{"error":{"message":"model not found"}}`, http.StatusNotFound)
+	})
+
+	if _, err := newTestAdapter(ts).Generate(context.Background(), "Hi", llm.GenerateOptions{}); err == nil {
+		t.Fatal("Generate() error = nil, want error for 404 response")
+	}
+}
+
+func TestEmbed(t *testing.T) {
+	var last EmbeddingRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&last); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		// Entries may come back in any order; index ties them to the input
+		fmt.Fprint(w, `{"data": [
+			{"index": 1, "embedding": [0, 1]},
+			{"index": 0, "embedding": [1, 0]}
+		]}`)
+	}))
+	defer ts.Close()
+
+	adapter := NewAdapter(Config{BaseURL: ts.URL + "/v1", EmbeddingModel: "embed-small"})
+	got, err := adapter.Embed(context.Background(), []string{"first", "second"})
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+
+	if last.Model != "embed-small" || len(last.Input) != 2 {
+		t.Errorf("request = %+v, want embed-small with 2 inputs", last)
+	}
+	if len(got) != 2 || got[0][0] != 1 || got[1][1] != 1 {
+		t.Errorf("Embed() = %v, want [[1 0] [0 1]]", got)
+	}
+}
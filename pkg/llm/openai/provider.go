@@ -0,0 +1,23 @@
+package openai
+
+import (
+	"threshAI/pkg/cache"
+	"threshAI/pkg/core/generation"
+)
+
+func init() {
+	generation.MustRegister(generation.Provider{
+		Name:         generation.ProviderOpenAI,
+		Factory:      newGenerator,
+		Decode:       generation.DecodeYAML(Config{}),
+		Capabilities: generation.CapStream | generation.CapChat | generation.CapEmbeddings,
+	})
+}
+
+func newGenerator(config interface{}, _ cache.Cache) (generation.Generator, error) {
+	cfg, err := generation.ConfigAs[Config](config)
+	if err != nil {
+		return nil, err
+	}
+	return NewAdapter(cfg), nil
+}
@@ -0,0 +1,226 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	rawType       = reflect.TypeOf(json.RawMessage{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// For returns the schema of the JSON encoding of T
+func For[T any]() (*Schema, error) {
+	return FromType(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+// FromValue returns the schema of the JSON encoding of v's type
+func FromValue(v interface{}) (*Schema, error) {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return nil, fmt.Errorf("schema: cannot derive a schema from nil")
+	}
+	return FromType(t)
+}
+
+// FromType derives a schema from a Go type following encoding/json rules.
+// Struct fields are required unless they are pointers, which may also be
+// null, or tagged omitempty. Objects reject members that have no field.
+// Constraints are given in a jsonschema tag as comma-separated key=value
+// pairs:
+//
+//	Score float64 `json:"score" jsonschema:"minimum=0,maximum=1"`
+//	Level string  `json:"level" jsonschema:"enum=low,enum=high,description=Severity"`
+func FromType(t reflect.Type) (*Schema, error) {
+	return fromType(t, make(map[reflect.Type]bool))
+}
+
+func fromType(t reflect.Type, visiting map[reflect.Type]bool) (*Schema, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: Types{"string"}, Format: "date-time"}, nil
+	case t == rawType:
+		return &Schema{}, nil
+	case t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType):
+		// Custom encodings can't be described from the type alone
+		return &Schema{}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: Types{"boolean"}}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: Types{"integer"}}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: Types{"number"}}, nil
+	case reflect.String:
+		return &Schema{Type: Types{"string"}}, nil
+	case reflect.Interface:
+		return &Schema{}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// Byte slices are encoded as base64 strings
+			return &Schema{Type: Types{"string"}}, nil
+		}
+		items, err := fromType(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: Types{"array"}, Items: items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("schema: unsupported map key type %s", t.Key())
+		}
+		return &Schema{Type: Types{"object"}}, nil
+	case reflect.Struct:
+		if visiting[t] {
+			return nil, fmt.Errorf("schema: recursive type %s is not supported", t)
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		s := &Schema{
+			Type:                 Types{"object"},
+			Properties:           make(map[string]*Schema),
+			AdditionalProperties: Bool(false),
+		}
+		if err := addFields(s, t, visiting); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("schema: unsupported type %s", t)
+}
+
+// addFields adds the encoded fields of struct type t to s, flattening
+// embedded structs the way encoding/json does
+func addFields(s *Schema, t reflect.Type, visiting map[reflect.Type]bool) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if err := addFields(s, embedded, visiting); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		prop, err := fromType(field.Type, visiting)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		if err := applyTag(prop, field.Tag.Get("jsonschema")); err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		// Nil pointers are encoded as null
+		optional := field.Type.Kind() == reflect.Pointer
+		if optional && len(prop.Type) > 0 {
+			prop.Type = append(prop.Type, "null")
+		}
+		s.Properties[name] = prop
+
+		for _, opt := range strings.Split(opts, ",") {
+			optional = optional || opt == "omitempty"
+		}
+		if !optional {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return nil
+}
+
+// applyTag applies the constraints of a jsonschema struct tag
+func applyTag(s *Schema, tag string) error {
+	if tag == "" {
+		return nil
+	}
+	for _, pair := range strings.Split(tag, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("invalid jsonschema tag %q", pair)
+		}
+
+		switch key {
+		case "description":
+			s.Description = value
+		case "format":
+			s.Format = value
+		case "pattern":
+			s.Pattern = value
+		case "enum":
+			s.Enum = append(s.Enum, enumValue(s, value))
+		case "minimum", "maximum":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q", key, value)
+			}
+			if key == "minimum" {
+				s.Minimum = &n
+			} else {
+				s.Maximum = &n
+			}
+		case "minLength", "maxLength", "minItems", "maxItems":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q", key, value)
+			}
+			switch key {
+			case "minLength":
+				s.MinLength = &n
+			case "maxLength":
+				s.MaxLength = &n
+			case "minItems":
+				s.MinItems = &n
+			case "maxItems":
+				s.MaxItems = &n
+			}
+		default:
+			return fmt.Errorf("unsupported jsonschema keyword %q", key)
+		}
+	}
+	return nil
+}
+
+// enumValue converts an enum tag value to the schema's type, so that
+// numeric enums compare equal to decoded numbers
+func enumValue(s *Schema, value string) interface{} {
+	if s.Type.allows("number") || s.Type.allows("integer") {
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	}
+	if s.Type.allows("boolean") {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
@@ -0,0 +1,80 @@
+// Package schema implements the subset of JSON Schema used to describe and
+// validate structured model output: types, object properties, required
+// fields, array items, enums, numeric ranges, string lengths and patterns.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Schema is a JSON Schema document. Keywords outside the supported subset
+// are dropped when a schema is parsed.
+type Schema struct {
+	Type        Types              `json:"type,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	// AdditionalProperties, when set to false, rejects object members not
+	// listed in Properties
+	AdditionalProperties *bool         `json:"additionalProperties,omitempty"`
+	Items                *Schema       `json:"items,omitempty"`
+	Enum                 []interface{} `json:"enum,omitempty"`
+	Minimum              *float64      `json:"minimum,omitempty"`
+	Maximum              *float64      `json:"maximum,omitempty"`
+	MinLength            *int          `json:"minLength,omitempty"`
+	MaxLength            *int          `json:"maxLength,omitempty"`
+	MinItems             *int          `json:"minItems,omitempty"`
+	MaxItems             *int          `json:"maxItems,omitempty"`
+	Pattern              string        `json:"pattern,omitempty"`
+}
+
+// Types is the "type" keyword, which may name a single type or a list of
+// allowed types
+type Types []string
+
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+func (t *Types) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = Types{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("type must be a string or a list of strings")
+	}
+	*t = list
+	return nil
+}
+
+// Parse decodes a JSON Schema document
+func Parse(raw []byte) (*Schema, error) {
+	var s Schema
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return &s, nil
+}
+
+// JSON returns the encoded schema
+func (s *Schema) JSON() json.RawMessage {
+	data, err := json.Marshal(s)
+	if err != nil {
+		// A Schema only holds JSON-compatible values
+		panic(fmt.Sprintf("schema: %v", err))
+	}
+	return data
+}
+
+// Bool returns a pointer to v, for use with AdditionalProperties
+func Bool(v bool) *bool {
+	return &v
+}
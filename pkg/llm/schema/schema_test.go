@@ -0,0 +1,98 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type finding struct {
+	Title    string   `json:"title" jsonschema:"minLength=1"`
+	Severity string   `json:"severity" jsonschema:"enum=low,enum=high"`
+	Score    float64  `json:"score" jsonschema:"minimum=0,maximum=1"`
+	Count    int      `json:"count,omitempty"`
+	Tags     []string `json:"tags"`
+	Note     *string  `json:"note"`
+	internal int
+}
+
+func TestFromType(t *testing.T) {
+	s, err := For[finding]()
+	if err != nil {
+		t.Fatalf("For() error = %v", err)
+	}
+
+	if want := []string{"title", "severity", "score", "tags"}; !reflect.DeepEqual(s.Required, want) {
+		t.Errorf("Required = %v, want %v", s.Required, want)
+	}
+	if len(s.Properties) != 6 {
+		t.Errorf("Properties = %v, want 6 exported fields", s.Properties)
+	}
+	if got := s.Properties["count"].Type; !reflect.DeepEqual(got, Types{"integer"}) {
+		t.Errorf("count type = %v, want integer", got)
+	}
+	if got := s.Properties["tags"]; got.Items == nil || got.Items.Type[0] != "string" {
+		t.Errorf("tags = %+v, want array of strings", got)
+	}
+
+	// The schema survives an encoding round trip
+	parsed, err := Parse(s.JSON())
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if string(parsed.JSON()) != string(s.JSON()) {
+		t.Errorf("round trip = %s, want %s", parsed.JSON(), s.JSON())
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s, _ := For[finding]()
+
+	valid := `{"title": "SQLi", "severity": "high", "score": 0.9, "count": 2, "tags": ["db"], "note": null}`
+	if err := s.ValidateJSON([]byte(valid)); err != nil {
+		t.Errorf("ValidateJSON() error = %v", err)
+	}
+
+	invalid := `{"title": "", "severity": "medium", "score": 1.5, "count": 2.5, "tags": [1], "extra": true}`
+	err := s.ValidateJSON([]byte(invalid))
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("ValidateJSON() error = %v, want *ValidationError", err)
+	}
+	for _, want := range []string{
+		"$.title: must be at least 1 characters",
+		`$.severity: must be one of "low", "high"`,
+		"$.score: must be <= 1",
+		"$.count: expected integer, got number",
+		"$.tags[0]: expected string, got number",
+		`$: unexpected property "extra"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't report %q", err, want)
+		}
+	}
+
+	if err := s.ValidateJSON([]byte(`{"title": "x"`)); err == nil || errors.As(err, &verr) {
+		t.Errorf("ValidateJSON() error = %v, want a syntax error", err)
+	}
+}
+
+func TestTypesList(t *testing.T) {
+	s, err := Parse([]byte(`{"type": ["string", "null"], "maxLength": 3}`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	for _, doc := range []string{`"abc"`, `null`} {
+		if err := s.ValidateJSON([]byte(doc)); err != nil {
+			t.Errorf("ValidateJSON(%s) error = %v", doc, err)
+		}
+	}
+	if err := s.ValidateJSON([]byte(`"abcd"`)); err == nil {
+		t.Error("ValidateJSON() accepted a string over maxLength")
+	}
+	if data, _ := json.Marshal(s.Type); string(data) != `["string","null"]` {
+		t.Errorf("type encodes as %s", data)
+	}
+}
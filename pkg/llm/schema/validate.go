@@ -0,0 +1,221 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidationError lists every way a document violates a schema
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return strings.Join(e.Problems, "; ")
+}
+
+// ValidateJSON decodes data and validates it against s. Syntax errors are
+// returned as they are; schema violations as *ValidationError.
+func (s *Schema) ValidateJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	if decoder.More() {
+		return fmt.Errorf("invalid JSON: unexpected data after the top-level value")
+	}
+	return s.Validate(value)
+}
+
+// Validate checks a decoded JSON value against s. Numbers may be float64 or
+// json.Number.
+func (s *Schema) Validate(value interface{}) error {
+	v := &validator{}
+	v.validate(s, value, "$")
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) fail(path, format string, args ...interface{}) {
+	v.problems = append(v.problems, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *validator) validate(s *Schema, value interface{}, path string) {
+	if s == nil {
+		return
+	}
+
+	kind := typeOf(value)
+	if len(s.Type) > 0 && !s.Type.matches(kind, value) {
+		v.fail(path, "expected %s, got %s", strings.Join(s.Type, " or "), kind)
+		return
+	}
+
+	// A nullable field's enum constrains its non-null values
+	if len(s.Enum) > 0 && !(value == nil && s.Type.allows("null")) && !inEnum(s.Enum, value) {
+		v.fail(path, "must be one of %s", enumList(s.Enum))
+	}
+
+	switch kind {
+	case "object":
+		v.validateObject(s, value.(map[string]interface{}), path)
+	case "array":
+		items := value.([]interface{})
+		if s.MinItems != nil && len(items) < *s.MinItems {
+			v.fail(path, "must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(items) > *s.MaxItems {
+			v.fail(path, "must have at most %d items", *s.MaxItems)
+		}
+		for i, item := range items {
+			v.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	case "string":
+		str := value.(string)
+		length := utf8.RuneCountInString(str)
+		if s.MinLength != nil && length < *s.MinLength {
+			v.fail(path, "must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			v.fail(path, "must be at most %d characters", *s.MaxLength)
+		}
+		if s.Pattern != "" {
+			re, err := regexp.Compile(s.Pattern)
+			if err != nil {
+				v.fail(path, "schema pattern %q is invalid: %v", s.Pattern, err)
+			} else if !re.MatchString(str) {
+				v.fail(path, "must match %s", s.Pattern)
+			}
+		}
+	case "number":
+		n, _ := toFloat(value)
+		if s.Minimum != nil && n < *s.Minimum {
+			v.fail(path, "must be >= %v", *s.Minimum)
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			v.fail(path, "must be <= %v", *s.Maximum)
+		}
+	}
+}
+
+func (v *validator) validateObject(s *Schema, object map[string]interface{}, path string) {
+	for _, name := range s.Required {
+		if _, ok := object[name]; !ok {
+			v.fail(path, "missing required property %q", name)
+		}
+	}
+
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		prop, ok := s.Properties[name]
+		if !ok {
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				v.fail(path, "unexpected property %q", name)
+			}
+			continue
+		}
+		v.validate(prop, object[name], path+"."+name)
+	}
+}
+
+// typeOf names the JSON type of a decoded value. Integers are reported as
+// numbers; Types.matches tells them apart.
+func typeOf(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64, json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func (t Types) allows(name string) bool {
+	for _, allowed := range t {
+		if allowed == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (t Types) matches(kind string, value interface{}) bool {
+	if t.allows(kind) {
+		return true
+	}
+	if kind == "number" && t.allows("integer") {
+		n, ok := toFloat(value)
+		return ok && n == math.Trunc(n)
+	}
+	return false
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// inEnum reports whether value is one of the allowed values. Numbers compare
+// by value regardless of their Go representation.
+func inEnum(enum []interface{}, value interface{}) bool {
+	n, isNumber := toFloat(value)
+	for _, allowed := range enum {
+		if isNumber {
+			if m, ok := toFloat(allowed); ok && m == n {
+				return true
+			}
+			if i, ok := allowed.(int); ok && float64(i) == n {
+				return true
+			}
+			continue
+		}
+		if reflect.DeepEqual(allowed, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func enumList(enum []interface{}) string {
+	values := make([]string, len(enum))
+	for i, value := range enum {
+		data, _ := json.Marshal(value)
+		values[i] = string(data)
+	}
+	return strings.Join(values, ", ")
+}
@@ -0,0 +1,48 @@
+package tokenizer
+
+import "sort"
+
+// byteToUnicode maps every byte to the character GPT-2 vocabularies spell it
+// with. Printable bytes stand for themselves; the others, such as spaces and
+// control bytes, are shifted to characters from 256 up, so a space reads Ġ
+// and a newline Ċ.
+var byteToUnicode, unicodeToByte = byteTables()
+
+func byteTables() ([256]rune, map[rune]byte) {
+	var encode [256]rune
+	decode := make(map[rune]byte, 256)
+
+	printable := func(b int) bool {
+		return ('!' <= b && b <= '~') || ('¡' <= b && b <= '¬') || ('®' <= b && b <= 'ÿ')
+	}
+	n := 0
+	for b := 0; b < 256; b++ {
+		r := rune(b)
+		if !printable(b) {
+			r = rune(256 + n)
+			n++
+		}
+		encode[b] = r
+		decode[r] = byte(b)
+	}
+	return encode, decode
+}
+
+// byteSymbols spells every byte of piece as its own symbol, the starting
+// point of BPE
+func byteSymbols(piece string) []string {
+	symbols := make([]string, len(piece))
+	for i := 0; i < len(piece); i++ {
+		symbols[i] = string(byteToUnicode[piece[i]])
+	}
+	return symbols
+}
+
+// byteOrder returns the byte symbols in the order GPT-2 vocabularies number
+// them: printable bytes first, then the shifted ones
+func byteOrder() []rune {
+	order := make([]rune, 256)
+	copy(order, byteToUnicode[:])
+	sort.Slice(order, func(i, j int) bool { return order[i] < order[j] })
+	return order
+}
@@ -0,0 +1,224 @@
+package tokenizer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChatTemplate names a format that renders a conversation as a single
+// sequence for a model trained on that format
+type ChatTemplate string
+
+const (
+	// ChatPlain renders a plain transcript, "User: ...\nAssistant:", for
+	// models not trained on any chat format
+	ChatPlain ChatTemplate = "plain"
+	// ChatML is the format of OpenAI's and Qwen's models:
+	// <|im_start|>user\n...<|im_end|>\n
+	ChatML ChatTemplate = "chatml"
+	// ChatLlama is the format of Llama 3: <|start_header_id|>user
+	// <|end_header_id|>\n\n...<|eot_id|>
+	ChatLlama ChatTemplate = "llama"
+	// ChatMistral is the format of Mistral's instruct models:
+	// <s>[INST] ... [/INST]...</s>. The system prompt is prepended to the
+	// next user message, since the format has no system turns.
+	ChatMistral ChatTemplate = "mistral"
+)
+
+// Message is a turn of a conversation
+type Message struct {
+	Role    string
+	Content string
+}
+
+// ParseChatTemplate parses the name of a chat template
+func ParseChatTemplate(name string) (ChatTemplate, error) {
+	switch template := ChatTemplate(strings.ToLower(name)); template {
+	case ChatPlain, ChatML, ChatLlama, ChatMistral:
+		return template, nil
+	default:
+		return "", fmt.Errorf("unknown chat template %q: must be plain, chatml, llama or mistral", name)
+	}
+}
+
+// chatPart is a piece of a rendered conversation. Markers are encoded as
+// special tokens when the tokenizer has them; text, which includes message
+// content, is always encoded as plain text so that messages can't forge
+// markers.
+type chatPart struct {
+	text   string
+	marker bool
+}
+
+// EncodeChat renders messages with template and encodes them, ending with an
+// open assistant turn for the model to complete
+func (t *Tokenizer) EncodeChat(template ChatTemplate, messages []Message) ([]int, error) {
+	parts, err := t.renderChat(template, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens []int
+	for _, part := range parts {
+		if id, ok := t.special[part.text]; ok && part.marker {
+			tokens = append(tokens, id)
+		} else {
+			tokens = t.appendOrdinary(tokens, part.text)
+		}
+	}
+	return tokens, nil
+}
+
+// RenderChat renders messages with template as text, as EncodeChat encodes
+// them
+func (t *Tokenizer) RenderChat(template ChatTemplate, messages []Message) (string, error) {
+	parts, err := t.renderChat(template, messages)
+	if err != nil {
+		return "", err
+	}
+	var builder strings.Builder
+	for _, part := range parts {
+		builder.WriteString(part.text)
+	}
+	return builder.String(), nil
+}
+
+// ChatStopTokens returns the tokens that end an assistant turn in template:
+// its end-of-turn marker and the EOS token, when the tokenizer has them
+func (t *Tokenizer) ChatStopTokens(template ChatTemplate) []int {
+	var stops []int
+	for _, token := range []string{t.endOfTurn(template), t.specials.EOS} {
+		if id, ok := t.special[token]; ok && token != "" {
+			stops = append(stops, id)
+		}
+	}
+	if len(stops) == 2 && stops[0] == stops[1] {
+		stops = stops[:1]
+	}
+	return stops
+}
+
+func (t *Tokenizer) endOfTurn(template ChatTemplate) string {
+	switch template {
+	case ChatML:
+		return "<|im_end|>"
+	case ChatLlama:
+		return "<|eot_id|>"
+	case ChatMistral:
+		return t.mistralEOS()
+	default:
+		return ""
+	}
+}
+
+func (t *Tokenizer) renderChat(template ChatTemplate, messages []Message) ([]chatPart, error) {
+	switch template {
+	case ChatPlain, "":
+		return renderPlain(messages), nil
+	case ChatML:
+		return renderChatML(messages), nil
+	case ChatLlama:
+		return renderLlama(messages), nil
+	case ChatMistral:
+		return t.renderMistral(messages), nil
+	default:
+		return nil, fmt.Errorf("unknown chat template %q", template)
+	}
+}
+
+func renderPlain(messages []Message) []chatPart {
+	var builder strings.Builder
+	for _, msg := range messages {
+		fmt.Fprintf(&builder, "%s: %s\n", roleLabel(msg.Role), msg.Content)
+	}
+	builder.WriteString(roleLabel("assistant") + ":")
+	return []chatPart{{text: builder.String()}}
+}
+
+func renderChatML(messages []Message) []chatPart {
+	var parts []chatPart
+	for _, msg := range messages {
+		parts = append(parts,
+			chatPart{text: "<|im_start|>", marker: true},
+			chatPart{text: msg.Role + "\n" + msg.Content},
+			chatPart{text: "<|im_end|>", marker: true},
+			chatPart{text: "\n"},
+		)
+	}
+	return append(parts,
+		chatPart{text: "<|im_start|>", marker: true},
+		chatPart{text: "assistant\n"},
+	)
+}
+
+func renderLlama(messages []Message) []chatPart {
+	header := func(role string) []chatPart {
+		return []chatPart{
+			{text: "<|start_header_id|>", marker: true},
+			{text: role},
+			{text: "<|end_header_id|>", marker: true},
+			{text: "\n\n"},
+		}
+	}
+
+	parts := []chatPart{{text: "<|begin_of_text|>", marker: true}}
+	for _, msg := range messages {
+		parts = append(parts, header(msg.Role)...)
+		parts = append(parts,
+			chatPart{text: strings.TrimSpace(msg.Content)},
+			chatPart{text: "<|eot_id|>", marker: true},
+		)
+	}
+	return append(parts, header("assistant")...)
+}
+
+func (t *Tokenizer) renderMistral(messages []Message) []chatPart {
+	parts := []chatPart{{text: t.mistralBOS(), marker: true}}
+	var system []string
+	for _, msg := range messages {
+		switch msg.Role {
+		case "system":
+			system = append(system, msg.Content)
+		case "assistant":
+			parts = append(parts,
+				chatPart{text: msg.Content},
+				chatPart{text: t.mistralEOS(), marker: true},
+			)
+		default:
+			content := msg.Content
+			if len(system) > 0 {
+				content = strings.Join(append(system, content), "\n\n")
+				system = nil
+			}
+			parts = append(parts,
+				chatPart{text: "[INST]", marker: true},
+				chatPart{text: " " + content + " "},
+				chatPart{text: "[/INST]", marker: true},
+			)
+		}
+	}
+	return parts
+}
+
+// mistralBOS and mistralEOS return the tokenizer's BOS and EOS tokens, or
+// those of Mistral's vocabulary when unset
+func (t *Tokenizer) mistralBOS() string {
+	if t.specials.BOS != "" {
+		return t.specials.BOS
+	}
+	return "<s>"
+}
+
+func (t *Tokenizer) mistralEOS() string {
+	if t.specials.EOS != "" {
+		return t.specials.EOS
+	}
+	return "</s>"
+}
+
+func roleLabel(role string) string {
+	if role == "" {
+		return ""
+	}
+	return strings.ToUpper(role[:1]) + role[1:]
+}
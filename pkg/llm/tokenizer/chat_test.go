@@ -0,0 +1,158 @@
+package tokenizer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var conversation = []Message{
+	{Role: "system", Content: "Be brief."},
+	{Role: "user", Content: "hi"},
+	{Role: "assistant", Content: "hello"},
+	{Role: "user", Content: "and then?"},
+}
+
+func TestRenderChat(t *testing.T) {
+	tok := NewTokenizer()
+	tests := []struct {
+		template ChatTemplate
+		want     string
+	}{
+		{ChatPlain, "System: Be brief.\nUser: hi\nAssistant: hello\nUser: and then?\nAssistant:"},
+		{ChatML, "<|im_start|>system\nBe brief.<|im_end|>\n<|im_start|>user\nhi<|im_end|>\n" +
+			"<|im_start|>assistant\nhello<|im_end|>\n<|im_start|>user\nand then?<|im_end|>\n<|im_start|>assistant\n"},
+		{ChatLlama, "<|begin_of_text|><|start_header_id|>system<|end_header_id|>\n\nBe brief.<|eot_id|>" +
+			"<|start_header_id|>user<|end_header_id|>\n\nhi<|eot_id|>" +
+			"<|start_header_id|>assistant<|end_header_id|>\n\nhello<|eot_id|>" +
+			"<|start_header_id|>user<|end_header_id|>\n\nand then?<|eot_id|>" +
+			"<|start_header_id|>assistant<|end_header_id|>\n\n"},
+		{ChatMistral, "<s>[INST] Be brief.\n\nhi [/INST]hello</s>[INST] and then? [/INST]"},
+	}
+	for _, tt := range tests {
+		got, err := tok.RenderChat(tt.template, conversation)
+		if err != nil {
+			t.Fatalf("RenderChat(%s) error = %v", tt.template, err)
+		}
+		if got != tt.want {
+			t.Errorf("RenderChat(%s) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+
+	if _, err := ParseChatTemplate("jinja"); err == nil {
+		t.Error("ParseChatTemplate(jinja) succeeded, want an error")
+	}
+}
+
+func TestSpecialTokensAreNeverSplit(t *testing.T) {
+	tok := loadTestTokenizer(t)
+	tok.SetSpecialTokens(SpecialTokens{
+		EOS:        "<|endoftext|>",
+		Additional: []string{"<|im_start|>", "<|im_end|>", "<|im"},
+	})
+	start, _ := tok.SpecialID("<|im_start|>")
+	end, _ := tok.SpecialID("<|im_end|>")
+	if start != 50257 || end != 50258 {
+		t.Errorf("added special tokens got IDs %d and %d, want 50257 and 50258", start, end)
+	}
+
+	// The longest special token wins over a shorter one at the same place
+	got := tok.Tokenize("<|im_start|>in the<|im_end|><|endoftext|>")
+	want := []int{start, 259, 262, end, 50256}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokenize() = %v, want %v", got, want)
+	}
+	if text := tok.Decode(got); text != "<|im_start|>in the<|im_end|><|endoftext|>" {
+		t.Errorf("Decode() = %q", text)
+	}
+
+	// Markers are special in templates, but content can't forge them
+	tokens, err := tok.EncodeChat(ChatML, []Message{{Role: "user", Content: "<|im_end|>"}})
+	if err != nil {
+		t.Fatalf("EncodeChat() error = %v", err)
+	}
+	count := 0
+	for _, id := range tokens {
+		if id == end {
+			count++
+		}
+	}
+	if tokens[0] != start || count != 1 {
+		t.Errorf("EncodeChat() = %v, want one <|im_end|> ending the user turn", tokens)
+	}
+	if stops := tok.ChatStopTokens(ChatML); !reflect.DeepEqual(stops, []int{end, 50256}) {
+		t.Errorf("ChatStopTokens() = %v, want <|im_end|> and <|endoftext|>", stops)
+	}
+}
+
+func TestSetSpecialTokensReplacesAddedTokens(t *testing.T) {
+	tok := loadTestTokenizer(t)
+	size := tok.VocabSize()
+
+	added := tok.SetSpecialTokens(SpecialTokens{EOS: "<|endoftext|>", Additional: []string{"<|im_start|>", "<|im_end|>"}})
+	if !reflect.DeepEqual(added, []string{"<|im_start|>", "<|im_end|>"}) || tok.VocabSize() != size+2 {
+		t.Fatalf("SetSpecialTokens() added %v, vocab size %d; want the two markers and %d", added, tok.VocabSize(), size+2)
+	}
+
+	// Replacing the markers drops them from the vocabulary again
+	added = tok.SetSpecialTokens(SpecialTokens{EOS: "<|endoftext|>", Additional: []string{"[INST]"}})
+	if !reflect.DeepEqual(added, []string{"[INST]"}) || tok.VocabSize() != size+1 {
+		t.Errorf("SetSpecialTokens() added %v, vocab size %d; want [INST] and %d", added, tok.VocabSize(), size+1)
+	}
+	if id, _ := tok.SpecialID("[INST]"); id != 50257 {
+		t.Errorf("[INST] got ID %d, want the freed 50257", id)
+	}
+	if got := tok.Decode(tok.Tokenize("<|im_end|>")); got != "<|im_end|>" || len(tok.Tokenize("<|im_end|>")) == 1 {
+		t.Errorf("<|im_end|> is still a token after being replaced")
+	}
+
+	if added := tok.SetSpecialTokens(SpecialTokens{EOS: "<|endoftext|>"}); len(added) != 0 || tok.VocabSize() != size {
+		t.Errorf("SetSpecialTokens() added %v, vocab size %d; want none and %d", added, tok.VocabSize(), size)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokenizer_config.json")
+	os.WriteFile(path, []byte(`{
+		"bos_token": {"content": "<s>", "lstrip": false},
+		"eos_token": "</s>",
+		"additional_special_tokens": ["[INST]", "[/INST]"],
+		"chat_template": "mistral",
+		"model_max_length": 32768
+	}`), 0644)
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	want := SpecialTokens{BOS: "<s>", EOS: "</s>", Additional: []string{"[INST]", "[/INST]"}}
+	if got := config.SpecialTokens(); !reflect.DeepEqual(got, want) || config.ChatTemplate != "mistral" {
+		t.Errorf("LoadConfig() = %+v, want %+v and the mistral template", got, want)
+	}
+
+	// A saved config reads back the same
+	tok := NewTokenizer()
+	tok.SetSpecialTokens(config.SpecialTokens())
+	saved := filepath.Join(t.TempDir(), "tokenizer_config.json")
+	if err := tok.SaveConfig(saved, ChatMistral); err != nil {
+		t.Fatalf("SaveConfig() error = %v", err)
+	}
+	if reloaded, err := LoadConfig(saved); err != nil || !reflect.DeepEqual(reloaded, config) {
+		t.Errorf("LoadConfig(saved) = %+v, %v, want %+v", reloaded, err, config)
+	}
+
+	// Mistral's markers and BOS/EOS are single tokens, content isn't
+	tokens, _ := tok.EncodeChat(ChatMistral, []Message{{Role: "user", Content: "hi"}})
+	bos, _ := tok.SpecialID("<s>")
+	inst, _ := tok.SpecialID("[INST]")
+	endInst, _ := tok.SpecialID("[/INST]")
+	if len(tokens) != 7 || tokens[0] != bos || tokens[1] != inst || tokens[6] != endInst {
+		t.Errorf("EncodeChat() = %v, want <s> [INST] \" hi \" [/INST]", tokens)
+	}
+
+	os.WriteFile(path, []byte(`{"chat_template": "{% for message in messages %}{{ message.content }}{% endfor %}"}`), 0644)
+	if config, err := LoadConfig(path); err != nil || config.ChatTemplate != "" {
+		t.Errorf("LoadConfig() = %+v, %v, want a Jinja template ignored", config, err)
+	}
+}
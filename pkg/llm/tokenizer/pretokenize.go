@@ -0,0 +1,84 @@
+package tokenizer
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// contractions are the suffixes GPT-2 splits off words, in the order its
+// pattern tries them
+var contractions = []string{"'s", "'t", "'re", "'ve", "'m", "'ll", "'d"}
+
+// pretokenize splits text into the pieces BPE is applied to, the way GPT-2's
+// pattern does:
+//
+//	's|'t|'re|'ve|'m|'ll|'d| ?\p{L}+| ?\p{N}+| ?[^\s\p{L}\p{N}]+|\s+(?!\S)|\s+
+//
+// Go's regexp package has no lookahead, so the pattern is matched by hand.
+// Pieces keep their leading space, and the pieces join up to text again.
+func pretokenize(text string) []string {
+	var pieces []string
+	for i := 0; i < len(text); {
+		n := matchPiece(text[i:])
+		pieces = append(pieces, text[i:i+n])
+		i += n
+	}
+	return pieces
+}
+
+// matchPiece returns the length of the piece at the start of s, trying the
+// alternatives of the pattern in order
+func matchPiece(s string) int {
+	for _, c := range contractions {
+		if len(s) >= len(c) && s[:len(c)] == c {
+			return len(c)
+		}
+	}
+
+	// An optional space followed by a run of letters, numbers or other
+	// symbols
+	start := 0
+	if s[0] == ' ' {
+		start = 1
+	}
+	if start < len(s) {
+		r, _ := utf8.DecodeRuneInString(s[start:])
+		for _, class := range []func(rune) bool{isLetter, isNumber, isOther} {
+			if class(r) {
+				return start + runLength(s[start:], class)
+			}
+		}
+	}
+
+	// Whitespace, leaving the last space of a run for the word after it
+	n := runLength(s, isSpace)
+	if n == len(s) {
+		return n
+	}
+	if _, size := utf8.DecodeLastRuneInString(s[:n]); n > size {
+		return n - size
+	}
+	return n
+}
+
+// runLength returns the length in bytes of the run of runes in class at the
+// start of s
+func runLength(s string, class func(rune) bool) int {
+	n := 0
+	for n < len(s) {
+		r, size := utf8.DecodeRuneInString(s[n:])
+		if !class(r) {
+			break
+		}
+		n += size
+	}
+	return n
+}
+
+func isLetter(r rune) bool { return unicode.IsLetter(r) }
+
+func isNumber(r rune) bool { return unicode.IsNumber(r) }
+
+func isSpace(r rune) bool { return unicode.IsSpace(r) }
+
+func isOther(r rune) bool { return !isSpace(r) && !isLetter(r) && !isNumber(r) }
@@ -0,0 +1,221 @@
+package tokenizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// SpecialTokens names the tokens with a role of their own. Special tokens are
+// encoded as a whole wherever they appear in text and never split by BPE.
+// Empty names leave the role unset.
+type SpecialTokens struct {
+	BOS string
+	EOS string
+	UNK string
+	PAD string
+	// Additional are further special tokens, such as role markers of chat
+	// templates
+	Additional []string
+}
+
+// all returns every special token, without duplicates
+func (s SpecialTokens) all() []string {
+	seen := make(map[string]bool)
+	var tokens []string
+	for _, token := range append([]string{s.BOS, s.EOS, s.UNK, s.PAD}, s.Additional...) {
+		if token != "" && !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// Config is a tokenizer config file. It uses the keys of Hugging Face's
+// tokenizer_config.json, so those files can be used as they are:
+//
+//	{
+//	  "bos_token": "<s>",
+//	  "eos_token": "</s>",
+//	  "unk_token": "<unk>",
+//	  "additional_special_tokens": ["[INST]", "[/INST]"],
+//	  "chat_template": "mistral"
+//	}
+//
+// Tokens may also be given as objects with a "content" key. chat_template
+// names one of the built-in templates; Jinja templates aren't supported and
+// are ignored.
+type Config struct {
+	BOSToken   tokenText   `json:"bos_token,omitempty"`
+	EOSToken   tokenText   `json:"eos_token,omitempty"`
+	UNKToken   tokenText   `json:"unk_token,omitempty"`
+	PADToken   tokenText   `json:"pad_token,omitempty"`
+	Additional []tokenText `json:"additional_special_tokens,omitempty"`
+	// ChatTemplate is the name of the template conversations are rendered
+	// with
+	ChatTemplate string `json:"chat_template,omitempty"`
+}
+
+// tokenText is a token in a config file, given as a string or as an object
+// with the token in "content"
+type tokenText string
+
+func (t *tokenText) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*t = tokenText(text)
+		return nil
+	}
+	var object struct {
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return fmt.Errorf("token must be a string or an object with content: %v", err)
+	}
+	*t = tokenText(object.Content)
+	return nil
+}
+
+// SpecialTokens returns the special tokens the config names
+func (c Config) SpecialTokens() SpecialTokens {
+	s := SpecialTokens{
+		BOS: string(c.BOSToken),
+		EOS: string(c.EOSToken),
+		UNK: string(c.UNKToken),
+		PAD: string(c.PADToken),
+	}
+	for _, token := range c.Additional {
+		s.Additional = append(s.Additional, string(token))
+	}
+	return s
+}
+
+// LoadConfig reads a tokenizer config file
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read tokenizer config: %v", err)
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return Config{}, fmt.Errorf("failed to parse tokenizer config: %v", err)
+	}
+	if strings.Contains(config.ChatTemplate, "{") {
+		// A Jinja template from a Hugging Face model
+		config.ChatTemplate = ""
+	}
+	if config.ChatTemplate != "" {
+		if _, err := ParseChatTemplate(config.ChatTemplate); err != nil {
+			return Config{}, err
+		}
+	}
+	return config, nil
+}
+
+// SaveConfig writes the special tokens of the tokenizer and chatTemplate, if
+// not empty, to a config file LoadConfig can read
+func (t *Tokenizer) SaveConfig(path string, chatTemplate ChatTemplate) error {
+	config := Config{
+		BOSToken:     tokenText(t.specials.BOS),
+		EOSToken:     tokenText(t.specials.EOS),
+		UNKToken:     tokenText(t.specials.UNK),
+		PADToken:     tokenText(t.specials.PAD),
+		ChatTemplate: string(chatTemplate),
+	}
+	for _, token := range t.specials.Additional {
+		config.Additional = append(config.Additional, tokenText(token))
+	}
+
+	data, err := marshalJSON(config, "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal tokenizer config: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write tokenizer config: %v", err)
+	}
+	return nil
+}
+
+// SetSpecialTokens makes the given tokens special, replacing the special
+// tokens set before. Tokens missing from the vocabulary are added to it with
+// the next free IDs and returned, so callers can check the grown VocabSize
+// against the model's; tokens added by an earlier call are removed again. It
+// must not be called while the tokenizer is in use.
+func (t *Tokenizer) SetSpecialTokens(specials SpecialTokens) []string {
+	for _, token := range t.added {
+		delete(t.decoder, t.vocab[token])
+		delete(t.vocab, token)
+	}
+	t.added = nil
+
+	next := 0
+	for _, id := range t.vocab {
+		if id >= next {
+			next = id + 1
+		}
+	}
+
+	t.specials = specials
+	t.special = make(map[string]int)
+	t.specialOrder = nil
+	for _, token := range specials.all() {
+		id, ok := t.vocab[token]
+		if !ok {
+			id = next
+			next++
+			t.vocab[token] = id
+			t.decoder[id] = token
+			t.added = append(t.added, token)
+		}
+		t.special[token] = id
+		t.specialOrder = append(t.specialOrder, token)
+	}
+	// Longer tokens win when several match at the same position
+	sort.SliceStable(t.specialOrder, func(i, j int) bool {
+		return len(t.specialOrder[i]) > len(t.specialOrder[j])
+	})
+
+	t.bosToken = t.specialID(specials.BOS)
+	t.eosToken = t.specialID(specials.EOS)
+	t.unkToken = t.specialID(specials.UNK)
+	t.padToken = t.specialID(specials.PAD)
+
+	t.mu.Lock()
+	t.cache = make(map[string][]int)
+	t.mu.Unlock()
+	return append([]string(nil), t.added...)
+}
+
+// SpecialTokens returns the special tokens of the tokenizer
+func (t *Tokenizer) SpecialTokens() SpecialTokens {
+	return t.specials
+}
+
+// SpecialID returns the ID of a special token
+func (t *Tokenizer) SpecialID(token string) (int, bool) {
+	id, ok := t.special[token]
+	return id, ok
+}
+
+// specialID returns the ID of a special token, or -1 when it isn't set
+func (t *Tokenizer) specialID(token string) int {
+	if id, ok := t.special[token]; ok {
+		return id
+	}
+	return -1
+}
+
+// nextSpecial finds the first special token in text, returning its position
+// or -1 when text holds none
+func (t *Tokenizer) nextSpecial(text string) (int, string) {
+	at, found := -1, ""
+	for _, token := range t.specialOrder {
+		if i := strings.Index(text, token); i >= 0 && (at < 0 || i < at) {
+			at, found = i, token
+		}
+	}
+	return at, found
+}
@@ -1,12 +1,27 @@
 package tokenizer
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"math"
 	"os"
 	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
 )
 
+// endOfText is the token GPT-2 vocabularies end documents with
+const endOfText = "<|endoftext|>"
+
+// mergesHeader is the first line of GPT-2 merges files
+const mergesHeader = "#version: 0.2"
+
+// maxCacheEntries bounds the number of pieces whose encoding is cached
+const maxCacheEntries = 1 << 14
+
 // Token represents a subword token and its ID
 type Token struct {
 	Text  string
@@ -14,31 +29,51 @@ type Token struct {
 	Count int
 }
 
-// Tokenizer implements Byte-Pair Encoding (BPE) tokenization
+// Tokenizer implements byte-level Byte-Pair Encoding (BPE) tokenization as
+// done by GPT-2. Text is split into pieces by GPT-2's pre-tokenization
+// pattern, the bytes of every piece are mapped to printable characters, and
+// adjacent symbols are merged by the merge of lowest rank until none applies.
+// Every byte has a symbol, so any text encodes and decodes back unchanged.
 type Tokenizer struct {
 	vocab     map[string]int // token text -> id
-	merges    map[string]int // merge rule -> priority
+	merges    map[string]int // "left right" merge rule -> rank
 	decoder   map[int]string // id -> token text
-	unkToken  int
-	padToken  int
-	eosToken  int
 	maxLength int
+
+	specials     SpecialTokens
+	special      map[string]int // special token text -> id
+	specialOrder []string       // special tokens, longest first
+	added        []string       // special tokens added to the vocabulary
+	// IDs of the special tokens, -1 when unset
+	bosToken int
+	eosToken int
+	unkToken int
+	padToken int
+
+	mu    sync.Mutex
+	cache map[string][]int // piece -> token IDs
 }
 
-// NewTokenizer creates a new BPE tokenizer
+// NewTokenizer creates a tokenizer with just the 256 byte tokens, numbered in
+// GPT-2 order, and no merges or special tokens, so text is encoded byte by
+// byte
 func NewTokenizer() *Tokenizer {
-	return &Tokenizer{
-		vocab:     make(map[string]int),
+	t := &Tokenizer{
 		merges:    make(map[string]int),
-		decoder:   make(map[int]string),
-		unkToken:  0,
-		padToken:  1,
-		eosToken:  2,
 		maxLength: 512,
+		cache:     make(map[string][]int),
+	}
+	vocab := make(map[string]int, 256)
+	for i, r := range byteOrder() {
+		vocab[string(r)] = i
 	}
+	t.setVocab(vocab)
+	return t
 }
 
-// LoadGPT2Tokenizer loads the pre-trained GPT-2 tokenizer
+// LoadGPT2Tokenizer loads a GPT-2 style tokenizer from its vocab.json, which
+// maps token text to IDs, and merges.txt, which lists one merge rule per line
+// as two space-separated symbols with the highest priority first
 func LoadGPT2Tokenizer(vocabPath, mergePath string) (*Tokenizer, error) {
 	t := NewTokenizer()
 
@@ -47,132 +82,228 @@ func LoadGPT2Tokenizer(vocabPath, mergePath string) (*Tokenizer, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read vocab file: %v", err)
 	}
-	if err := json.Unmarshal(vocabBytes, &t.vocab); err != nil {
+	var vocab map[string]int
+	if err := json.Unmarshal(vocabBytes, &vocab); err != nil {
 		return nil, fmt.Errorf("failed to parse vocab: %v", err)
 	}
+	t.setVocab(vocab)
 
-	// Build decoder
-	for token, id := range t.vocab {
-		t.decoder[id] = token
-	}
-
-	// Load merges
+	// Load merges, ranked by their order in the file
 	mergeBytes, err := os.ReadFile(mergePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read merges file: %v", err)
 	}
-	merges := strings.Split(string(mergeBytes), "\n")
-	for i, merge := range merges {
-		if merge == "" {
+	scanner := bufio.NewScanner(bytes.NewReader(mergeBytes))
+	for line := 1; scanner.Scan(); line++ {
+		merge := strings.TrimRight(scanner.Text(), "\r")
+		if merge == "" || (line == 1 && strings.HasPrefix(merge, "#version")) {
 			continue
 		}
-		t.merges[merge] = i
+		if len(strings.Split(merge, " ")) != 2 {
+			return nil, fmt.Errorf("invalid merge on line %d: %q", line, merge)
+		}
+		if _, ok := t.merges[merge]; !ok {
+			t.merges[merge] = len(t.merges)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read merges file: %v", err)
 	}
 
 	return t, nil
 }
 
-// Encode converts text into token IDs using BPE
+// setVocab replaces the vocabulary and builds the decoder from it. A GPT-2
+// vocabulary's <|endoftext|> becomes the EOS token; other special tokens are
+// set with SetSpecialTokens.
+func (t *Tokenizer) setVocab(vocab map[string]int) {
+	t.vocab = vocab
+	t.added = nil
+	t.decoder = make(map[int]string, len(vocab))
+	for token, id := range vocab {
+		t.decoder[id] = token
+	}
+
+	var specials SpecialTokens
+	if _, ok := vocab[endOfText]; ok {
+		specials.EOS = endOfText
+	}
+	t.SetSpecialTokens(specials)
+}
+
+// Encode converts text into token IDs using BPE, ending them with the EOS
+// token, if the tokenizer has one, when fewer than maxLength tokens were
+// produced
 func (t *Tokenizer) Encode(text string, maxLength int) ([]int, error) {
 	if maxLength == 0 {
 		maxLength = t.maxLength
 	}
 
-	// Pre-tokenize into words
-	words := strings.Fields(text)
-	tokens := make([]int, 0)
+	tokens := t.Tokenize(text)
+	if len(tokens) >= maxLength {
+		return tokens[:maxLength], nil
+	}
 
-	// Apply BPE to each word
-	for _, word := range words {
-		wordTokens := t.tokenizeWord(word)
-		tokens = append(tokens, wordTokens...)
+	// Add EOS token if there's room
+	if t.eosToken >= 0 {
+		tokens = append(tokens, t.eosToken)
+	}
+	return tokens, nil
+}
 
-		if len(tokens) >= maxLength {
-			tokens = tokens[:maxLength]
+// Tokenize converts text into token IDs using BPE without adding any tokens.
+// Special tokens in text are encoded as a whole.
+func (t *Tokenizer) Tokenize(text string) []int {
+	tokens := make([]int, 0, len(text)/4+1)
+	for text != "" {
+		at, special := t.nextSpecial(text)
+		if at < 0 {
 			break
 		}
+		tokens = t.appendOrdinary(tokens, text[:at])
+		tokens = append(tokens, t.special[special])
+		text = text[at+len(special):]
 	}
+	return t.appendOrdinary(tokens, text)
+}
 
-	// Add EOS token if there's room
-	if len(tokens) < maxLength {
-		tokens = append(tokens, t.eosToken)
+// appendOrdinary appends the tokens of text to tokens, reading any special
+// tokens in text as plain text
+func (t *Tokenizer) appendOrdinary(tokens []int, text string) []int {
+	for _, piece := range pretokenize(text) {
+		tokens = append(tokens, t.encodePiece(piece)...)
 	}
+	return tokens
+}
 
-	return tokens, nil
+// VocabSize returns the number of tokens in the vocabulary
+func (t *Tokenizer) VocabSize() int {
+	return len(t.vocab)
 }
 
 // Decode converts token IDs back into text
 func (t *Tokenizer) Decode(tokens []int) string {
-	parts := make([]string, len(tokens))
-	for i, id := range tokens {
-		if text, ok := t.decoder[id]; ok {
-			parts[i] = text
-		} else {
-			parts[i] = "[UNK]"
+	var out []byte
+	for _, id := range tokens {
+		text, ok := t.decoder[id]
+		if !ok {
+			out = append(out, "[UNK]"...)
+			continue
+		}
+		for _, r := range text {
+			if b, ok := unicodeToByte[r]; ok {
+				out = append(out, b)
+			} else {
+				out = utf8.AppendRune(out, r)
+			}
+		}
+	}
+	return string(out)
+}
+
+// EstimateTokens approximates how many tokens text encodes to when no
+// vocabulary is at hand, e.g. for backends that don't report usage. BPE
+// vocabularies average about four bytes of English per token, but every
+// word and punctuation mark takes at least one.
+func EstimateTokens(text string) int {
+	return EstimateTokensWith(text, 4)
+}
+
+// EstimateTokensWith estimates tokens like EstimateTokens for a vocabulary
+// averaging bytesPerToken bytes of text per token
+func EstimateTokensWith(text string, bytesPerToken float64) int {
+	pieces := 0
+	inWord := false
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if !inWord {
+				pieces++
+			}
+			inWord = true
+		default:
+			pieces++
+			inWord = false
 		}
 	}
-	return strings.Join(parts, "")
+
+	byBytes := int(math.Ceil(float64(len(text)) / bytesPerToken))
+	if pieces > byBytes {
+		return pieces
+	}
+	return byBytes
 }
 
-func (t *Tokenizer) tokenizeWord(word string) []int {
-	// Start with character-level tokens
-	parts := strings.Split(word, "")
+// encodePiece applies BPE to a piece of pre-tokenized text, caching the
+// result since the same words come up again and again. Symbols missing from
+// the vocabulary become the UNK token, or are dropped without one.
+func (t *Tokenizer) encodePiece(piece string) []int {
+	t.mu.Lock()
+	cached, ok := t.cache[piece]
+	t.mu.Unlock()
+	if ok {
+		return cached
+	}
 
-	for {
-		bestPair := ""
-		bestScore := -1
+	symbols := t.bpe(byteSymbols(piece))
+	tokens := make([]int, 0, len(symbols))
+	for _, symbol := range symbols {
+		if id, ok := t.vocab[symbol]; ok {
+			tokens = append(tokens, id)
+		} else if t.unkToken >= 0 {
+			tokens = append(tokens, t.unkToken)
+		}
+	}
 
-		// Find the best merge
-		for i := 0; i < len(parts)-1; i++ {
-			pair := parts[i] + parts[i+1]
-			if score, ok := t.merges[pair]; ok {
-				if score > bestScore {
-					bestScore = score
-					bestPair = pair
-				}
+	t.mu.Lock()
+	if len(t.cache) >= maxCacheEntries {
+		t.cache = make(map[string][]int)
+	}
+	t.cache[piece] = tokens
+	t.mu.Unlock()
+	return tokens
+}
+
+// bpe repeatedly merges the adjacent pair of symbols with the lowest rank,
+// merging every occurrence of that pair from left to right, until no pair has
+// a merge rule
+func (t *Tokenizer) bpe(symbols []string) []string {
+	for len(symbols) > 1 {
+		best, bestRank := "", -1
+		for i := 0; i < len(symbols)-1; i++ {
+			pair := symbols[i] + " " + symbols[i+1]
+			if rank, ok := t.merges[pair]; ok && (bestRank < 0 || rank < bestRank) {
+				best, bestRank = pair, rank
 			}
 		}
 
 		// No more merges possible
-		if bestPair == "" {
+		if bestRank < 0 {
 			break
 		}
 
 		// Apply the merge
-		newParts := make([]string, 0)
-		i := 0
-		for i < len(parts)-1 {
-			if parts[i]+parts[i+1] == bestPair {
-				newParts = append(newParts, bestPair)
-				i += 2
-			} else {
-				newParts = append(newParts, parts[i])
+		left, right, _ := strings.Cut(best, " ")
+		merged := make([]string, 0, len(symbols))
+		for i := 0; i < len(symbols); i++ {
+			if i < len(symbols)-1 && symbols[i] == left && symbols[i+1] == right {
+				merged = append(merged, left+right)
 				i++
+			} else {
+				merged = append(merged, symbols[i])
 			}
 		}
-		if i < len(parts) {
-			newParts = append(newParts, parts[i])
-		}
-		parts = newParts
+		symbols = merged
 	}
-
-	// Convert subwords to token IDs
-	tokens := make([]int, len(parts))
-	for i, part := range parts {
-		if id, ok := t.vocab[part]; ok {
-			tokens[i] = id
-		} else {
-			tokens[i] = t.unkToken
-		}
-	}
-
-	return tokens
+	return symbols
 }
 
-// Save saves the tokenizer state to files
+// Save saves the tokenizer state to files LoadGPT2Tokenizer can read
 func (t *Tokenizer) Save(vocabPath, mergePath string) error {
 	// Save vocabulary
-	vocabBytes, err := json.Marshal(t.vocab)
+	vocabBytes, err := marshalJSON(t.vocab, "")
 	if err != nil {
 		return fmt.Errorf("failed to marshal vocab: %v", err)
 	}
@@ -180,15 +311,28 @@ func (t *Tokenizer) Save(vocabPath, mergePath string) error {
 		return fmt.Errorf("failed to write vocab file: %v", err)
 	}
 
-	// Save merges
+	// Save merges in rank order
 	merges := make([]string, len(t.merges))
-	for merge, priority := range t.merges {
-		merges[priority] = merge
+	for merge, rank := range t.merges {
+		merges[rank] = merge
 	}
-	mergeStr := strings.Join(merges, "\n")
+	mergeStr := mergesHeader + "\n" + strings.Join(merges, "\n") + "\n"
 	if err := os.WriteFile(mergePath, []byte(mergeStr), 0644); err != nil {
 		return fmt.Errorf("failed to write merges file: %v", err)
 	}
 
 	return nil
 }
+
+// marshalJSON encodes v without escaping the angle brackets special tokens
+// are written with
+func marshalJSON(v interface{}, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", indent)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
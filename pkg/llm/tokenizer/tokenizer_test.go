@@ -0,0 +1,115 @@
+package tokenizer
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// loadTestTokenizer loads testdata/gpt2, which holds GPT-2's 256 byte tokens,
+// its first 35 merges and <|endoftext|>, all with their GPT-2 IDs
+func loadTestTokenizer(t *testing.T) *Tokenizer {
+	t.Helper()
+	tok, err := LoadGPT2Tokenizer(filepath.Join("testdata", "gpt2", "vocab.json"), filepath.Join("testdata", "gpt2", "merges.txt"))
+	if err != nil {
+		t.Fatalf("LoadGPT2Tokenizer() error = %v", err)
+	}
+	return tok
+}
+
+func TestPretokenize(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Hello world", []string{"Hello", " world"}},
+		{"It's 2024, isn't it?", []string{"It", "'s", " 2024", ",", " isn", "'t", " it", "?"}},
+		{"a  b\n\n c", []string{"a", " ", " b", "\n\n", " c"}},
+		{"end \t", []string{"end", " \t"}},
+		{"x\ty", []string{"x", "\t", "y"}},
+		{"héllo wörld!!", []string{"héllo", " wörld", "!!"}},
+		{" 42abc ...", []string{" 42", "abc", " ..."}},
+	}
+	for _, tt := range tests {
+		if got := pretokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("pretokenize(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTokenizeMatchesGPT2(t *testing.T) {
+	tok := loadTestTokenizer(t)
+
+	// Texts the full GPT-2 tokenizer encodes with the merges in testdata
+	// alone, with the IDs it produces
+	tests := []struct {
+		text string
+		want []int
+	}{
+		{"in the", []int{259, 262}},
+		{"it, of the!", []int{270, 11, 286, 262, 0}},
+		{" and to a", []int{290, 284, 257}},
+		{"is\n\tin", []int{271, 198, 197, 259}},
+		{"  the", []int{220, 262}},
+		{"ing.", []int{278, 13}},
+	}
+	for _, tt := range tests {
+		if got := tok.Tokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Tokenize(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestBPEMergesByRank(t *testing.T) {
+	tok := loadTestTokenizer(t)
+
+	// "h e" outranks "e n", so " then" becomes " the" and "n"
+	if got, want := tok.Tokenize(" then"), []int{262, 77}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokenize(\" then\") = %v, want %v", got, want)
+	}
+	// A cached piece encodes the same
+	if got, want := tok.Tokenize(" then then"), []int{262, 77, 262, 77}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokenize(\" then then\") = %v, want %v", got, want)
+	}
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	tok := loadTestTokenizer(t)
+
+	for _, text := range []string{"Hello, world!", "héllo  wörld 👋\n\n\tend ", "it's\r\nfine"} {
+		tokens, err := tok.Encode(text, 1024)
+		if err != nil {
+			t.Fatalf("Encode(%q) error = %v", text, err)
+		}
+		if last := tokens[len(tokens)-1]; last != 50256 {
+			t.Errorf("Encode(%q) ends with %d, want <|endoftext|>", text, last)
+		}
+		if got := tok.Decode(tokens[:len(tokens)-1]); got != text {
+			t.Errorf("Decode(Encode(%q)) = %q", text, got)
+		}
+	}
+
+	if tokens, _ := tok.Encode("in the end", 2); !reflect.DeepEqual(tokens, []int{259, 262}) {
+		t.Errorf("Encode() = %v, want truncation to 2 tokens", tokens)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	tok := loadTestTokenizer(t)
+	dir := t.TempDir()
+	vocab, merges := filepath.Join(dir, "vocab.json"), filepath.Join(dir, "merges.txt")
+	if err := tok.Save(vocab, merges); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := LoadGPT2Tokenizer(vocab, merges)
+	if err != nil {
+		t.Fatalf("LoadGPT2Tokenizer() error = %v", err)
+	}
+	if !reflect.DeepEqual(loaded.merges, tok.merges) {
+		t.Error("merges changed after Save and load")
+	}
+	if got := loaded.Tokenize(" and the"); !reflect.DeepEqual(got, []int{290, 262}) {
+		t.Errorf("Tokenize() = %v after Save and load, want [290 262]", got)
+	}
+}
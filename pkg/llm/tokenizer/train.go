@@ -0,0 +1,381 @@
+package tokenizer
+
+import (
+	"container/heap"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	DefaultTrainVocabSize = 8192
+	DefaultMinFrequency   = 2
+)
+
+// TrainConfig tunes the training of a tokenizer. Zero values select the
+// defaults.
+type TrainConfig struct {
+	// VocabSize is the number of tokens to learn, counting the special and
+	// byte tokens. Training stops early when no pair is frequent enough.
+	VocabSize int
+	// MinFrequency is the number of times a pair must occur to be merged
+	MinFrequency int
+	// SpecialTokens are given the first IDs and are never learned from or
+	// split; their occurrences in the corpus are skipped. <|endoftext|>
+	// becomes the EOS token.
+	SpecialTokens []string
+	// Workers is the number of goroutines reading files and counting pairs,
+	// by default one per CPU
+	Workers int
+}
+
+// Trainer learns a byte-level BPE vocabulary from a corpus. Feed it text,
+// then call Train. Feeding is safe from several goroutines.
+type Trainer struct {
+	config TrainConfig
+
+	mu sync.Mutex
+	// words counts the pre-tokenized pieces of the corpus
+	words map[string]int
+}
+
+// NewTrainer creates a trainer with config
+func NewTrainer(config TrainConfig) *Trainer {
+	if config.VocabSize <= 0 {
+		config.VocabSize = DefaultTrainVocabSize
+	}
+	if config.MinFrequency <= 0 {
+		config.MinFrequency = DefaultMinFrequency
+	}
+	if config.Workers <= 0 {
+		config.Workers = runtime.NumCPU()
+	}
+	return &Trainer{config: config, words: make(map[string]int)}
+}
+
+// Feed adds text to the corpus
+func (tr *Trainer) Feed(text string) {
+	counts := make(map[string]int)
+	for _, segment := range splitSpecial(text, tr.config.SpecialTokens) {
+		for _, piece := range pretokenize(segment) {
+			counts[piece]++
+		}
+	}
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	for piece, n := range counts {
+		tr.words[piece] += n
+	}
+}
+
+// FeedDir adds every text file under dir to the corpus, skipping hidden
+// files and directories and files that aren't valid UTF-8. It returns the
+// number of files read.
+func (tr *Trainer) FeedDir(dir string) (int, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list corpus: %v", err)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		read     int
+		firstErr error
+	)
+	jobs := make(chan string)
+	for w := 0; w < tr.config.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				data, err := os.ReadFile(path)
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = fmt.Errorf("failed to read %s: %v", path, err)
+				}
+				mu.Unlock()
+				if err != nil || !utf8.Valid(data) {
+					continue
+				}
+
+				tr.Feed(string(data))
+				mu.Lock()
+				read++
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, path := range paths {
+		jobs <- path
+	}
+	close(jobs)
+	wg.Wait()
+
+	return read, firstErr
+}
+
+// Train learns merges from the corpus until the vocabulary reaches the
+// configured size. The most frequent pair of adjacent symbols is merged
+// first; ties go to the pair that sorts first, so training is deterministic.
+// The vocabulary holds the special tokens, then the 256 byte tokens in GPT-2
+// order, then one token per merge.
+func (tr *Trainer) Train() (*Tokenizer, error) {
+	vocab := make(map[string]int)
+	for _, special := range tr.config.SpecialTokens {
+		if !hasToken(vocab, special) {
+			vocab[special] = len(vocab)
+		}
+	}
+	for _, r := range byteOrder() {
+		vocab[string(r)] = len(vocab)
+	}
+	if tr.config.VocabSize < len(vocab) {
+		return nil, fmt.Errorf("vocab size %d is smaller than the %d special and byte tokens", tr.config.VocabSize, len(vocab))
+	}
+
+	words := tr.corpus()
+	counts, where := countPairs(words, tr.config.Workers)
+	queue := &pairQueue{}
+	for p, n := range counts {
+		queue.items = append(queue.items, pairCount{p, n})
+	}
+	heap.Init(queue)
+
+	merges := make(map[string]int)
+	for len(vocab) < tr.config.VocabSize && queue.Len() > 0 {
+		top := heap.Pop(queue).(pairCount)
+		if counts[top.pair] != top.count {
+			// Stale entry, the pair was counted again after it was queued
+			continue
+		}
+		if top.count < tr.config.MinFrequency {
+			break
+		}
+
+		merges[top.pair.left+" "+top.pair.right] = len(merges)
+		if merged := top.pair.left + top.pair.right; !hasToken(vocab, merged) {
+			vocab[merged] = len(vocab)
+		}
+
+		changed := make(map[pair]bool)
+		for i := range where[top.pair] {
+			w := &words[i]
+			forEachPair(w.symbols, func(p pair) {
+				counts[p] -= w.count
+				changed[p] = true
+			})
+			w.symbols = mergePair(w.symbols, top.pair)
+			forEachPair(w.symbols, func(p pair) {
+				counts[p] += w.count
+				changed[p] = true
+				if where[p] == nil {
+					where[p] = make(map[int]bool)
+				}
+				where[p][i] = true
+			})
+		}
+		delete(where, top.pair)
+		delete(counts, top.pair)
+		delete(changed, top.pair)
+		for p := range changed {
+			if counts[p] <= 0 {
+				delete(counts, p)
+				delete(where, p)
+				continue
+			}
+			heap.Push(queue, pairCount{p, counts[p]})
+		}
+	}
+
+	t := NewTokenizer()
+	t.merges = merges
+	t.setVocab(vocab)
+	specials := t.SpecialTokens()
+	specials.Additional = tr.config.SpecialTokens
+	t.SetSpecialTokens(specials)
+	return t, nil
+}
+
+func hasToken(vocab map[string]int, token string) bool {
+	_, ok := vocab[token]
+	return ok
+}
+
+// trainWord is a distinct piece of the corpus, spelled in symbols
+type trainWord struct {
+	symbols []string
+	count   int
+}
+
+// corpus returns the distinct pieces fed so far, in a stable order
+func (tr *Trainer) corpus() []trainWord {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	pieces := make([]string, 0, len(tr.words))
+	for piece := range tr.words {
+		pieces = append(pieces, piece)
+	}
+	sort.Strings(pieces)
+
+	words := make([]trainWord, len(pieces))
+	for i, piece := range pieces {
+		words[i] = trainWord{symbols: byteSymbols(piece), count: tr.words[piece]}
+	}
+	return words
+}
+
+// pair is two adjacent symbols
+type pair struct {
+	left, right string
+}
+
+// countPairs counts the pairs of adjacent symbols in words, weighted by how
+// often the words occur, and records which words contain every pair. The
+// words are split between workers, whose counts are then added up.
+func countPairs(words []trainWord, workers int) (map[pair]int, map[pair]map[int]bool) {
+	type partial struct {
+		counts map[pair]int
+		where  map[pair][]int
+	}
+	chunk := (len(words) + workers - 1) / workers
+	if chunk == 0 {
+		chunk = 1
+	}
+
+	partials := make([]partial, (len(words)+chunk-1)/chunk)
+	var wg sync.WaitGroup
+	for n, start := 0, 0; start < len(words); n, start = n+1, start+chunk {
+		end := start + chunk
+		if end > len(words) {
+			end = len(words)
+		}
+		wg.Add(1)
+		go func(part *partial, start, end int) {
+			defer wg.Done()
+			part.counts = make(map[pair]int)
+			part.where = make(map[pair][]int)
+			for i := start; i < end; i++ {
+				forEachPair(words[i].symbols, func(p pair) {
+					part.counts[p] += words[i].count
+					if n := len(part.where[p]); n == 0 || part.where[p][n-1] != i {
+						part.where[p] = append(part.where[p], i)
+					}
+				})
+			}
+		}(&partials[n], start, end)
+	}
+	wg.Wait()
+
+	counts := make(map[pair]int)
+	where := make(map[pair]map[int]bool)
+	for _, part := range partials {
+		for p, n := range part.counts {
+			counts[p] += n
+		}
+		for p, indexes := range part.where {
+			if where[p] == nil {
+				where[p] = make(map[int]bool)
+			}
+			for _, i := range indexes {
+				where[p][i] = true
+			}
+		}
+	}
+	return counts, where
+}
+
+func forEachPair(symbols []string, fn func(pair)) {
+	for i := 0; i < len(symbols)-1; i++ {
+		fn(pair{symbols[i], symbols[i+1]})
+	}
+}
+
+// mergePair merges every occurrence of p in symbols from left to right, the
+// same way encoding does
+func mergePair(symbols []string, p pair) []string {
+	merged := make([]string, 0, len(symbols))
+	for i := 0; i < len(symbols); i++ {
+		if i < len(symbols)-1 && symbols[i] == p.left && symbols[i+1] == p.right {
+			merged = append(merged, p.left+p.right)
+			i++
+		} else {
+			merged = append(merged, symbols[i])
+		}
+	}
+	return merged
+}
+
+// splitSpecial returns the parts of text between occurrences of the special
+// tokens
+func splitSpecial(text string, specials []string) []string {
+	parts := []string{text}
+	for _, special := range specials {
+		if special == "" {
+			continue
+		}
+		var split []string
+		for _, part := range parts {
+			split = append(split, strings.Split(part, special)...)
+		}
+		parts = split
+	}
+	return parts
+}
+
+// pairCount is a queued candidate merge
+type pairCount struct {
+	pair  pair
+	count int
+}
+
+// pairQueue orders candidate merges by count, then by their symbols
+type pairQueue struct {
+	items []pairCount
+}
+
+func (q *pairQueue) Len() int { return len(q.items) }
+
+func (q *pairQueue) Less(i, j int) bool {
+	a, b := q.items[i], q.items[j]
+	if a.count != b.count {
+		return a.count > b.count
+	}
+	if a.pair.left != b.pair.left {
+		return a.pair.left < b.pair.left
+	}
+	return a.pair.right < b.pair.right
+}
+
+func (q *pairQueue) Swap(i, j int) { q.items[i], q.items[j] = q.items[j], q.items[i] }
+
+func (q *pairQueue) Push(x interface{}) { q.items = append(q.items, x.(pairCount)) }
+
+func (q *pairQueue) Pop() interface{} {
+	last := q.items[len(q.items)-1]
+	q.items = q.items[:len(q.items)-1]
+	return last
+}
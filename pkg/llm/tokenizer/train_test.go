@@ -0,0 +1,119 @@
+package tokenizer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTrainLearnsFrequentPairs(t *testing.T) {
+	tr := NewTrainer(TrainConfig{VocabSize: 2 + 256 + 4, SpecialTokens: []string{"<|endoftext|>", "<pad>"}, Workers: 2})
+	tr.Feed(strings.Repeat("low lower lowest<|endoftext|>", 10))
+	tok, err := tr.Train()
+	if err != nil {
+		t.Fatalf("Train() error = %v", err)
+	}
+
+	if tok.vocab["<|endoftext|>"] != 0 || tok.vocab["<pad>"] != 1 || tok.vocab["!"] != 2 {
+		t.Errorf("special and byte tokens numbered %d, %d, %d, want 0, 1, 2",
+			tok.vocab["<|endoftext|>"], tok.vocab["<pad>"], tok.vocab["!"])
+	}
+	if len(tok.vocab) != 262 {
+		t.Errorf("vocab size = %d, want 262", len(tok.vocab))
+	}
+
+	// "l o" and "o w" occur 30 times each, then "Ġ low" and "low e" 20 times;
+	// ties go to the pair that sorts first
+	want := []string{"l o", "lo w", "low e", "Ġ lowe"}
+	merges := make([]string, len(tok.merges))
+	for merge, rank := range tok.merges {
+		merges[rank] = merge
+	}
+	if !reflect.DeepEqual(merges, want) {
+		t.Errorf("merges = %q, want %q", merges, want)
+	}
+	if got := tok.Tokenize(" lower"); !reflect.DeepEqual(got, []int{tok.vocab["Ġlowe"], tok.vocab["r"]}) {
+		t.Errorf("Tokenize(\" lower\") = %v, want Ġlowe r", got)
+	}
+	if tok.eosToken != 0 {
+		t.Errorf("eos token = %d, want <|endoftext|>", tok.eosToken)
+	}
+}
+
+func TestTrainMinFrequency(t *testing.T) {
+	tr := NewTrainer(TrainConfig{VocabSize: 1000, MinFrequency: 3})
+	tr.Feed("ab ab ab ab cd cd")
+	tok, err := tr.Train()
+	if err != nil {
+		t.Fatalf("Train() error = %v", err)
+	}
+	// Only pairs occurring at least 3 times are merged: "a b" and "Ġ ab"
+	if len(tok.merges) != 2 {
+		t.Errorf("learned %d merges, want 2", len(tok.merges))
+	}
+
+	if _, err := NewTrainer(TrainConfig{VocabSize: 100}).Train(); err == nil {
+		t.Error("Train() with a vocab size below 256 succeeded, want an error")
+	}
+}
+
+func TestTrainIsDeterministic(t *testing.T) {
+	text := "the quick brown fox jumps over the lazy dog, then the dog sleeps. "
+	train := func(workers int) map[string]int {
+		tr := NewTrainer(TrainConfig{VocabSize: 300, Workers: workers})
+		tr.Feed(strings.Repeat(text, 5))
+		tok, err := tr.Train()
+		if err != nil {
+			t.Fatalf("Train() error = %v", err)
+		}
+		return tok.merges
+	}
+	if one, many := train(1), train(8); !reflect.DeepEqual(one, many) {
+		t.Error("merges differ between 1 and 8 workers")
+	}
+}
+
+func TestTrainFromDirRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "docs", ".git"), 0755)
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte(strings.Repeat("hello world\n", 20)), 0644)
+	os.WriteFile(filepath.Join(dir, "docs", "b.md"), []byte(strings.Repeat("hello there\n", 20)), 0644)
+	os.WriteFile(filepath.Join(dir, "docs", ".git", "HEAD"), []byte("ref: refs/heads/main\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "blob.bin"), []byte{0xff, 0xfe, 0x00}, 0644)
+
+	tr := NewTrainer(TrainConfig{VocabSize: 300, SpecialTokens: []string{"<|endoftext|>"}})
+	files, err := tr.FeedDir(dir)
+	if err != nil || files != 2 {
+		t.Fatalf("FeedDir() = %d, %v, want 2 text files read", files, err)
+	}
+	tok, err := tr.Train()
+	if err != nil {
+		t.Fatalf("Train() error = %v", err)
+	}
+
+	out := t.TempDir()
+	vocab, merges := filepath.Join(out, "vocab.json"), filepath.Join(out, "merges.txt")
+	if err := tok.Save(vocab, merges); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	loaded, err := LoadGPT2Tokenizer(vocab, merges)
+	if err != nil {
+		t.Fatalf("LoadGPT2Tokenizer() error = %v", err)
+	}
+
+	text := "hello world, hello there\n"
+	tokens := loaded.Tokenize(text)
+	if !reflect.DeepEqual(tokens, tok.Tokenize(text)) {
+		t.Errorf("loaded tokenizer encodes %q differently", text)
+	}
+	// " hello" never occurs in the corpus, unlike "hello" and " there"
+	want := []int{tok.vocab["hello"], tok.vocab["Ġworld"], tok.vocab[","], tok.vocab["Ġ"], tok.vocab["hello"], tok.vocab["Ġthere"], tok.vocab["Ċ"]}
+	if !reflect.DeepEqual(tokens, want) {
+		t.Errorf("Tokenize(%q) = %v, want %v", text, tokens, want)
+	}
+	if got := loaded.Decode(tokens); got != text {
+		t.Errorf("Decode() = %q, want %q", got, text)
+	}
+}
@@ -2,12 +2,27 @@ package transformer
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"threshAI/pkg/llm"
+	"threshAI/pkg/llm/tokenizer"
 )
 
 type Adapter struct {
 	model  *TransformerModel
 	config Config
+	// source generates the tokens in place of the model when set, so that
+	// the token loop can be tested without weights
+	source tokenSource
+}
+
+// tokenSource generates tokens following input until maxLen tokens are
+// reached, calling onToken with each; see TransformerModel.GenerateFunc
+type tokenSource interface {
+	GenerateFunc(input []float64, maxLen int, sampling SamplingStrategy, onToken func(token int) error) ([]float64, error)
 }
 
 func NewAdapter(config Config) (*Adapter, error) {
@@ -22,14 +37,249 @@ func NewAdapter(config Config) (*Adapter, error) {
 	}, nil
 }
 
-func (a *Adapter) Generate(ctx context.Context, prompt string) (string, error) {
-	// Tokenize the input
+// Generate runs the token loop to completion. opts.Model is ignored since the
+// adapter serves a single local model.
+func (a *Adapter) Generate(ctx context.Context, prompt string, opts llm.GenerateOptions) (string, error) {
 	tokens, err := a.model.tokenizer.Encode(prompt, a.config.MaxContext)
 	if err != nil {
 		return "", fmt.Errorf("tokenization failed: %v", err)
 	}
+	out, _, err := a.run(ctx, tokens, a.eosTokens(), opts, nil)
+	return out, err
+}
+
+// GenerateStream runs the token loop in the background and emits each token
+// as soon as it is decoded. Cancelling ctx stops generation. The final chunk
+// carries the token counts.
+func (a *Adapter) GenerateStream(ctx context.Context, prompt string, opts llm.GenerateOptions) (<-chan llm.Chunk, error) {
+	tokens, err := a.model.tokenizer.Encode(prompt, a.config.MaxContext)
+	if err != nil {
+		return nil, fmt.Errorf("tokenization failed: %v", err)
+	}
+	return a.stream(ctx, tokens, a.eosTokens(), opts), nil
+}
+
+// Chat renders the conversation with the model's chat template and generates
+// the assistant's reply, which ends with the template's end-of-turn token.
+// Tools and response formats aren't supported and are ignored.
+func (a *Adapter) Chat(ctx context.Context, req llm.ChatRequest) (*llm.ChatResponse, error) {
+	tokens, err := a.encodeChat(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	out, usage, err := a.run(ctx, tokens, a.model.tokenizer.ChatStopTokens(a.model.chat), req.Options, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &llm.ChatResponse{
+		Message: llm.Message{Role: llm.RoleAssistant, Content: out},
+		Usage:   usage,
+	}, nil
+}
+
+// ChatStream streams the reply to a conversation like Chat
+func (a *Adapter) ChatStream(ctx context.Context, req llm.ChatRequest) (<-chan llm.Chunk, error) {
+	tokens, err := a.encodeChat(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	return a.stream(ctx, tokens, a.model.tokenizer.ChatStopTokens(a.model.chat), req.Options), nil
+}
+
+func (a *Adapter) encodeChat(messages []llm.Message) ([]int, error) {
+	converted := make([]tokenizer.Message, len(messages))
+	for i, msg := range messages {
+		converted[i] = tokenizer.Message{Role: msg.Role, Content: msg.Content}
+	}
+	tokens, err := a.model.tokenizer.EncodeChat(a.model.chat, converted)
+	if err != nil {
+		return nil, fmt.Errorf("tokenization failed: %v", err)
+	}
+	return tokens, nil
+}
+
+// eosTokens returns the EOS token, which ends the completion of a prompt
+func (a *Adapter) eosTokens() []int {
+	tok := a.model.tokenizer
+	if id, ok := tok.SpecialID(tok.SpecialTokens().EOS); ok {
+		return []int{id}
+	}
+	return nil
+}
+
+// stream runs the token loop for input in the background, emitting each
+// token as soon as it is decoded
+func (a *Adapter) stream(ctx context.Context, input, stopTokens []int, opts llm.GenerateOptions) <-chan llm.Chunk {
+	ch := make(chan llm.Chunk)
+	go func() {
+		defer close(ch)
+
+		_, usage, err := a.run(ctx, input, stopTokens, opts, func(text string) error {
+			select {
+			case ch <- llm.Chunk{Content: text}:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		chunk := llm.Chunk{Done: true, Usage: usage}
+		if err != nil {
+			chunk = llm.Chunk{Err: err}
+		}
+		select {
+		case ch <- chunk:
+		case <-ctx.Done():
+		}
+	}()
+
+	return ch
+}
+
+// errStopSequence ends the token loop once a stop sequence is generated
+var errStopSequence = errors.New("stop sequence reached")
+
+// run generates a completion for the input tokens, passing decoded text to
+// emit as it becomes final. Text that could still turn into a stop sequence
+// is held back until it can no longer match, and so are the bytes of a UTF-8
+// character split across tokens until the rest of it is generated. Generating
+// one of stopTokens ends the completion without adding to it.
+func (a *Adapter) run(ctx context.Context, tokens, stopTokens []int, opts llm.GenerateOptions, emit func(string) error) (string, *llm.Usage, error) {
+	input, maxLen := a.prepareInput(tokens)
+	if opts.MaxTokens > 0 {
+		maxLen = len(input) + opts.MaxTokens
+	}
+
+	holdBack := 0
+	for _, stop := range opts.Stop {
+		if len(stop)-1 > holdBack {
+			holdBack = len(stop) - 1
+		}
+	}
+
+	var output strings.Builder
+	emitted, generated := 0, 0
+	flush := func(end int) error {
+		text := output.String()
+		for end > emitted && end < len(text) && !utf8.RuneStart(text[end]) {
+			end--
+		}
+		if emit == nil || end <= emitted {
+			return nil
+		}
+		text = text[emitted:end]
+		emitted = end
+		return emit(text)
+	}
+
+	// Tokens decoding to an incomplete character are kept in pending, of
+	// which the first decoded bytes are already in output
+	var pending []int
+	decoded := 0
+	var source tokenSource = a.model
+	if a.source != nil {
+		source = a.source
+	}
+	_, err := source.GenerateFunc(input, maxLen, a.samplingStrategy(opts), func(token int) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		generated++
+		for _, stop := range stopTokens {
+			if token == stop {
+				return errStopSequence
+			}
+		}
+		pending = append(pending, token)
+		pendingText := a.model.tokenizer.Decode(pending)
+		complete := completeUTF8(pendingText)
+		output.WriteString(pendingText[decoded:complete])
+		decoded = complete
+		if complete == len(pendingText) {
+			pending, decoded = pending[:0], 0
+		}
+
+		text := output.String()
+		for _, stop := range opts.Stop {
+			if i := strings.Index(text, stop); i >= 0 && stop != "" {
+				pending = nil
+				output.Reset()
+				output.WriteString(text[:i])
+				if err := flush(i); err != nil {
+					return err
+				}
+				return errStopSequence
+			}
+		}
+		return flush(len(text) - holdBack)
+	})
+	if err != nil && !errors.Is(err, errStopSequence) {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", nil, ctxErr
+		}
+		return "", nil, fmt.Errorf("generation failed: %v", err)
+	}
+
+	if len(pending) > 0 {
+		output.WriteString(a.model.tokenizer.Decode(pending)[decoded:])
+	}
+	if err := flush(output.Len()); err != nil {
+		return "", nil, err
+	}
+	usage := &llm.Usage{
+		PromptTokens:     len(input),
+		CompletionTokens: generated,
+		TotalTokens:      len(input) + generated,
+	}
+	return output.String(), usage, nil
+}
+
+// completeUTF8 returns the length of text without the bytes of an incomplete
+// UTF-8 character at its end
+func completeUTF8(text string) int {
+	for i := len(text) - 1; i >= 0 && i >= len(text)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(text[i]) {
+			if utf8.FullRuneInString(text[i:]) {
+				return len(text)
+			}
+			return i
+		}
+	}
+	return len(text)
+}
+
+// samplingStrategy maps generation options onto a sampling strategy. Without
+// any sampling options the model's configured strategy is used.
+func (a *Adapter) samplingStrategy(opts llm.GenerateOptions) SamplingStrategy {
+	strategy := a.model.sampling
+	switch {
+	case opts.TopK > 0:
+		strategy = TopKStrategy(opts.TopK)
+	case opts.TopP > 0:
+		strategy = NucleusStrategy(opts.TopP)
+	case opts.Temperature != nil && *opts.Temperature == 0:
+		strategy = DefaultGreedyStrategy()
+	case opts.Temperature != nil:
+		// Plain temperature sampling over the full distribution
+		strategy = NucleusStrategy(1.0)
+	}
+
+	if opts.Temperature != nil {
+		strategy.Temperature = *opts.Temperature
+	}
+	if opts.Seed != nil {
+		strategy.Seed = opts.Seed
+	}
+	return strategy
+}
 
-	// Convert tokens to float64
+// prepareInput converts the input tokens for the model and works out the
+// target sequence length
+func (a *Adapter) prepareInput(tokens []int) ([]float64, int) {
 	input := make([]float64, len(tokens))
 	for i, t := range tokens {
 		input[i] = float64(t)
@@ -41,22 +291,7 @@ func (a *Adapter) Generate(ctx context.Context, prompt string) (string, error) {
 		maxLen = len(input) + 100 // Generate 100 more tokens
 	}
 
-	// Generate tokens
-	generated, err := a.model.Generate(input, maxLen)
-	if err != nil {
-		return "", fmt.Errorf("generation failed: %v", err)
-	}
-
-	// Convert generated tokens back to integers
-	outputTokens := make([]int, len(generated)-len(input))
-	for i, token := range generated[len(input):] {
-		outputTokens[i] = int(token)
-	}
-
-	// Decode tokens to text
-	result := a.model.tokenizer.Decode(outputTokens)
-
-	return result, nil
+	return input, maxLen
 }
 
 // Save saves the model weights to a file
@@ -73,9 +308,3 @@ func (a *Adapter) Load(path string) error {
 	a.model = model
 	return nil
 }
-
-// RegisterProvider registers the transformer provider type
-func RegisterProvider() {
-	// TODO: Register the transformer provider in the generation package
-	// This would require updating the generation package to support registration
-}
@@ -0,0 +1,229 @@
+package transformer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"threshAI/pkg/llm"
+	"threshAI/pkg/llm/tokenizer"
+)
+
+// scriptedTokens generates its tokens in order, like a model that always
+// samples them. An endless script starts over until maxLen is reached.
+type scriptedTokens struct {
+	tokens  []int
+	endless bool
+}
+
+func (s scriptedTokens) GenerateFunc(input []float64, maxLen int, _ SamplingStrategy, onToken func(token int) error) ([]float64, error) {
+	generated := input
+	for i := 0; len(generated) < maxLen && (s.endless || i < len(s.tokens)); i++ {
+		token := s.tokens[i%len(s.tokens)]
+		generated = append(generated, float64(token))
+		if err := onToken(token); err != nil {
+			return generated, err
+		}
+	}
+	return generated, nil
+}
+
+// newScriptedAdapter returns an adapter whose byte-level tokenizer has an
+// <eos> token, generating the given script
+func newScriptedAdapter(t *testing.T, script scriptedTokens, maxContext int) (*Adapter, *tokenizer.Tokenizer) {
+	t.Helper()
+	tok := tokenizer.NewTokenizer()
+	tok.SetSpecialTokens(tokenizer.SpecialTokens{EOS: "<eos>"})
+	return &Adapter{
+		model:  &TransformerModel{tokenizer: tok, sampling: DefaultGreedyStrategy()},
+		config: Config{MaxContext: maxContext},
+		source: script,
+	}, tok
+}
+
+func TestRun(t *testing.T) {
+	tok := tokenizer.NewTokenizer()
+	tok.SetSpecialTokens(tokenizer.SpecialTokens{EOS: "<eos>"})
+	eos, _ := tok.SpecialID("<eos>")
+	concat := func(parts ...[]int) []int {
+		var out []int
+		for _, part := range parts {
+			out = append(out, part...)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name      string
+		script    []int
+		opts      llm.GenerateOptions
+		want      string
+		generated int
+	}{
+		{
+			name:      "plain text",
+			script:    tok.Tokenize("Hello"),
+			want:      "Hello",
+			generated: 5,
+		},
+		{
+			name:      "stop string split across tokens",
+			script:    tok.Tokenize("Hi END there"),
+			opts:      llm.GenerateOptions{Stop: []string{"END"}},
+			want:      "Hi ",
+			generated: 6,
+		},
+		{
+			name:      "partial stop string that doesn't match",
+			script:    tok.Tokenize("Hi ENd"),
+			opts:      llm.GenerateOptions{Stop: []string{"END"}},
+			want:      "Hi ENd",
+			generated: 6,
+		},
+		{
+			name:      "multi-byte runes split across tokens",
+			script:    tok.Tokenize("héllo, 世界"),
+			want:      "héllo, 世界",
+			generated: len("héllo, 世界"),
+		},
+		{
+			name:      "multi-byte runes with a stop string held back",
+			script:    tok.Tokenize("日本語!stop"),
+			opts:      llm.GenerateOptions{Stop: []string{"!stop"}},
+			want:      "日本語",
+			generated: len("日本語!stop"),
+		},
+		{
+			name:      "stop token",
+			script:    concat(tok.Tokenize("ab"), []int{eos}, tok.Tokenize("cd")),
+			want:      "ab",
+			generated: 3,
+		},
+		{
+			name:      "incomplete rune at the end is flushed",
+			script:    concat(tok.Tokenize("a"), tok.Tokenize("é")[:1]),
+			want:      "a\xc3",
+			generated: 2,
+		},
+		{
+			name:      "max tokens",
+			script:    tok.Tokenize("abcdef"),
+			opts:      llm.GenerateOptions{MaxTokens: 4},
+			want:      "abcd",
+			generated: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, tok := newScriptedAdapter(t, scriptedTokens{tokens: tt.script}, 64)
+			input := tok.Tokenize("prompt")
+
+			var chunks []string
+			out, usage, err := a.run(context.Background(), input, a.eosTokens(), tt.opts, func(text string) error {
+				chunks = append(chunks, text)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("run() error = %v", err)
+			}
+
+			if out != tt.want {
+				t.Errorf("run() = %q, want %q", out, tt.want)
+			}
+			if streamed := strings.Join(chunks, ""); streamed != out {
+				t.Errorf("streamed %q, want the output %q", streamed, out)
+			}
+			// Only the flushed remainder of the last chunk may be invalid
+			for i, chunk := range chunks {
+				if i < len(chunks)-1 && !utf8.ValidString(chunk) {
+					t.Errorf("chunk %d = %q splits a rune", i, chunk)
+				}
+			}
+			for _, stop := range tt.opts.Stop {
+				for _, chunk := range chunks {
+					if strings.Contains(chunk, stop[:1]) && !strings.Contains(tt.want, stop[:1]) {
+						t.Errorf("chunk %q emitted part of stop sequence %q", chunk, stop)
+					}
+				}
+			}
+
+			if usage.PromptTokens != len(input) || usage.CompletionTokens != tt.generated || usage.TotalTokens != len(input)+tt.generated {
+				t.Errorf("usage = %+v, want %d prompt and %d completion tokens", usage, len(input), tt.generated)
+			}
+		})
+	}
+}
+
+func TestStream(t *testing.T) {
+	a, tok := newScriptedAdapter(t, scriptedTokens{tokens: tokenizer.NewTokenizer().Tokenize("héllo")}, 64)
+
+	var content strings.Builder
+	var final llm.Chunk
+	for chunk := range a.stream(context.Background(), tok.Tokenize("hi"), a.eosTokens(), llm.GenerateOptions{MaxTokens: 6}) {
+		if chunk.Err != nil {
+			t.Fatalf("stream error: %v", chunk.Err)
+		}
+		if !utf8.ValidString(chunk.Content) {
+			t.Errorf("chunk %q splits a rune", chunk.Content)
+		}
+		content.WriteString(chunk.Content)
+		if chunk.Done {
+			final = chunk
+		}
+	}
+
+	if content.String() != "héllo" {
+		t.Errorf("content = %q, want %q", content.String(), "héllo")
+	}
+	if !final.Done || final.Usage == nil || final.Usage.CompletionTokens != 6 {
+		t.Errorf("final chunk = %+v, want usage of 6 completion tokens", final)
+	}
+}
+
+func TestStreamCancel(t *testing.T) {
+	a, tok := newScriptedAdapter(t, scriptedTokens{tokens: []int{'a'}, endless: true}, 1<<30)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := a.stream(ctx, tok.Tokenize("hi"), nil, llm.GenerateOptions{})
+	if chunk := <-stream; chunk.Err != nil || chunk.Content == "" {
+		t.Fatalf("first chunk = %+v, want content", chunk)
+	}
+	cancel()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case chunk, ok := <-stream:
+			if !ok {
+				return
+			}
+			if chunk.Done || chunk.Err != nil {
+				t.Errorf("chunk %+v after cancel, want the stream closed", chunk)
+			}
+		case <-timeout:
+			t.Fatal("stream kept generating after cancel")
+		}
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	a, tok := newScriptedAdapter(t, scriptedTokens{tokens: []int{'a'}, endless: true}, 1<<30)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	emitted := 0
+	_, _, err := a.run(ctx, tok.Tokenize("hi"), nil, llm.GenerateOptions{}, func(string) error {
+		if emitted++; emitted == 3 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("run() error = %v, want context.Canceled", err)
+	}
+	if emitted != 3 {
+		t.Errorf("emitted %d chunks, want generation to stop at the cancel", emitted)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/gob"
 	"fmt"
 	"os"
-	"threshAI/pkg/llm/tokenizer"
 
 	"gorgonia.org/gorgonia"
 	"gorgonia.org/tensor"
@@ -180,15 +179,10 @@ func LoadCheckpoint(path string) (*TransformerModel, error) {
 
 	vm := gorgonia.NewTapeMachine(g)
 
-	// Initialize tokenizer based on config
-	var tok *tokenizer.Tokenizer
-	if state.Config.TokenizerType == "bpe" {
-		tok, err = tokenizer.LoadGPT2Tokenizer(state.Config.VocabPath, state.Config.MergePath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to load tokenizer: %v", err)
-		}
-	} else {
-		tok = tokenizer.NewTokenizer()
+	// Initialize tokenizer
+	tok, chatTemplate, err := loadTokenizer(state.Config)
+	if err != nil {
+		return nil, err
 	}
 
 	return &TransformerModel{
@@ -200,5 +194,6 @@ func LoadCheckpoint(path string) (*TransformerModel, error) {
 		head:      head,
 		vm:        vm,
 		tokenizer: tok,
+		chat:      chatTemplate,
 	}, nil
 }
@@ -1,35 +1,41 @@
 package transformer
 
 type Config struct {
-    VocabSize      int
-    MaxContext     int
-    EmbedSize      int
-    NumLayers      int
-    NumHeads       int
-    BatchSize      int
-    Device         string // "cuda" or "cpu"
-    
-    // Tokenizer settings
-    TokenizerType  string // "char" or "bpe"
-    VocabPath     string // Path to vocabulary file for BPE
-    MergePath     string // Path to merges file for BPE
-    CheckpointDir string // Directory for saving/loading model checkpoints
+	VocabSize  int    `yaml:"vocab_size" json:"vocab_size"`
+	MaxContext int    `yaml:"max_context" json:"max_context"`
+	EmbedSize  int    `yaml:"embed_size" json:"embed_size"`
+	NumLayers  int    `yaml:"num_layers" json:"num_layers"`
+	NumHeads   int    `yaml:"num_heads" json:"num_heads"`
+	BatchSize  int    `yaml:"batch_size" json:"batch_size"`
+	Device     string `yaml:"device" json:"device"` // "cuda" or "cpu"
+
+	// Tokenizer settings
+	TokenizerType string `yaml:"tokenizer_type" json:"tokenizer_type"` // "char" or "bpe"
+	VocabPath     string `yaml:"vocab_path" json:"vocab_path"`         // Path to vocabulary file for BPE
+	MergePath     string `yaml:"merge_path" json:"merge_path"`         // Path to merges file for BPE
+	// TokenizerConfig is a tokenizer config file naming the special tokens
+	// and chat template
+	TokenizerConfig string `yaml:"tokenizer_config" json:"tokenizer_config"`
+	// ChatTemplate renders conversations: plain, chatml, llama or mistral.
+	// It defaults to the tokenizer config's template, then plain.
+	ChatTemplate  string `yaml:"chat_template" json:"chat_template"`
+	CheckpointDir string `yaml:"checkpoint_dir" json:"checkpoint_dir"` // Directory for saving/loading model checkpoints
 }
 
 func DefaultConfig() Config {
-    return Config{
-        VocabSize:     50257, // Standard GPT-2 vocabulary size
-        MaxContext:    512,   // Context window size
-        EmbedSize:    768,   // Embedding dimension
-        NumLayers:    6,     // Number of transformer layers
-        NumHeads:     12,    // Number of attention heads
-        BatchSize:    32,    // Default batch size
-        Device:       "cuda",
-        
-        // Default to GPT-2 tokenizer
-        TokenizerType: "bpe",
-        VocabPath:    "models/gpt2-vocab.json",
-        MergePath:    "models/gpt2-merges.txt",
-        CheckpointDir: "checkpoints",
-    }
+	return Config{
+		VocabSize:  50257, // Standard GPT-2 vocabulary size
+		MaxContext: 512,   // Context window size
+		EmbedSize:  768,   // Embedding dimension
+		NumLayers:  6,     // Number of transformer layers
+		NumHeads:   12,    // Number of attention heads
+		BatchSize:  32,    // Default batch size
+		Device:     "cuda",
+
+		// Default to GPT-2 tokenizer
+		TokenizerType: "bpe",
+		VocabPath:     "models/gpt2-vocab.json",
+		MergePath:     "models/gpt2-merges.txt",
+		CheckpointDir: "checkpoints",
+	}
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 	"runtime"
+	"strings"
 	"threshAI/pkg/llm/tokenizer"
 	"time"
 
@@ -17,9 +18,11 @@ const (
 
 // SamplingStrategy defines how to sample the next token
 type SamplingStrategy struct {
-	Type string  // "greedy", "topk", or "nucleus"
-	K    int     // for top-k sampling
-	P    float64 // for nucleus sampling (top-p)
+	Type        string  // "greedy", "topk", or "nucleus"
+	K           int     // for top-k sampling
+	P           float64 // for nucleus sampling (top-p)
+	Temperature float64 // logit temperature for top-k and nucleus; 0 leaves logits unscaled
+	Seed        *int    // seed for reproducible sampling; nil draws from the global source
 }
 
 // DefaultGreedyStrategy returns a greedy sampling strategy
@@ -160,22 +163,69 @@ type TransformerModel struct {
 	head      *gorgonia.Node
 	vm        gorgonia.VM
 	tokenizer *tokenizer.Tokenizer
+	chat      tokenizer.ChatTemplate
 	sampling  SamplingStrategy
 }
 
-func NewTransformerModel(config Config) (*TransformerModel, error) {
-	g := gorgonia.NewGraph()
+// LoadTokenizer loads the tokenizer of a model configured with config, with
+// the special tokens of its tokenizer config
+func LoadTokenizer(config Config) (*tokenizer.Tokenizer, error) {
+	tok, _, err := loadTokenizer(config)
+	return tok, err
+}
 
-	// Initialize tokenizer
-	var tok *tokenizer.Tokenizer
-	var err error
+// loadTokenizer loads the tokenizer of config and works out the template its
+// conversations are rendered with
+func loadTokenizer(config Config) (*tokenizer.Tokenizer, tokenizer.ChatTemplate, error) {
+	tok := tokenizer.NewTokenizer()
 	if config.TokenizerType == "bpe" {
+		var err error
 		tok, err = tokenizer.LoadGPT2Tokenizer(config.VocabPath, config.MergePath)
 		if err != nil {
-			return nil, fmt.Errorf("failed to load tokenizer: %v", err)
+			return nil, "", fmt.Errorf("failed to load tokenizer: %v", err)
 		}
-	} else {
-		tok = tokenizer.NewTokenizer()
+	}
+
+	name := config.ChatTemplate
+	if config.TokenizerConfig != "" {
+		tokConfig, err := tokenizer.LoadConfig(config.TokenizerConfig)
+		if err != nil {
+			return nil, "", err
+		}
+		// Without an EOS token of its own the config keeps the vocabulary's,
+		// such as GPT-2's <|endoftext|>
+		specials := tokConfig.SpecialTokens()
+		if specials.EOS == "" {
+			specials.EOS = tok.SpecialTokens().EOS
+		}
+		// Tokens missing from vocab.json get IDs past its end, which the
+		// embedding table only has rows for when vocab_size allows them
+		if added := tok.SetSpecialTokens(specials); len(added) > 0 && config.VocabSize > 0 && tok.VocabSize() > config.VocabSize {
+			return nil, "", fmt.Errorf("special tokens %s are missing from the vocabulary and grow it to %d tokens, past the model's vocab_size of %d",
+				strings.Join(added, ", "), tok.VocabSize(), config.VocabSize)
+		}
+		if name == "" {
+			name = tokConfig.ChatTemplate
+		}
+	}
+
+	chat := tokenizer.ChatPlain
+	if name != "" {
+		var err error
+		if chat, err = tokenizer.ParseChatTemplate(name); err != nil {
+			return nil, "", err
+		}
+	}
+	return tok, chat, nil
+}
+
+func NewTransformerModel(config Config) (*TransformerModel, error) {
+	g := gorgonia.NewGraph()
+
+	// Initialize tokenizer
+	tok, chatTemplate, err := loadTokenizer(config)
+	if err != nil {
+		return nil, err
 	}
 
 	// Initialize model components
@@ -201,6 +251,7 @@ func NewTransformerModel(config Config) (*TransformerModel, error) {
 		head:      head,
 		vm:        vm,
 		tokenizer: tok,
+		chat:      chatTemplate,
 		sampling:  DefaultGreedyStrategy(),
 	}, nil
 }
@@ -312,7 +363,17 @@ func (m *TransformerModel) Forward(input *tensor.Dense) (*tensor.Dense, error) {
 }
 
 func (m *TransformerModel) Generate(input []float64, maxLen int) ([]float64, error) {
+	return m.GenerateFunc(input, maxLen, m.sampling, nil)
+}
+
+// GenerateFunc works like Generate with an explicit sampling strategy and calls
+// onToken with every token as soon as it is sampled. Generation stops early if
+// onToken returns an error.
+func (m *TransformerModel) GenerateFunc(input []float64, maxLen int, sampling SamplingStrategy, onToken func(token int) error) ([]float64, error) {
 	ops := NewTensorOps(m.g)
+	if sampling.Seed != nil {
+		ops.SetSeed(int64(*sampling.Seed))
+	}
 
 	// Convert input to tensor
 	inputTensor := ops.CreateInputTensor(input)
@@ -331,17 +392,21 @@ func (m *TransformerModel) Generate(input []float64, maxLen int) ([]float64, err
 			return nil, fmt.Errorf("failed to extract logits: %v", err)
 		}
 
+		if sampling.Temperature > 0 && sampling.Type != "greedy" {
+			lastLogits = ops.ScaleLogits(lastLogits, sampling.Temperature)
+		}
+
 		// Sample next token based on strategy
 		var nextToken int
-		switch m.sampling.Type {
+		switch sampling.Type {
 		case "greedy":
 			nextToken, err = ops.Argmax(lastLogits)
 		case "topk":
-			nextToken, err = ops.SampleTopK(lastLogits, m.sampling.K)
+			nextToken, err = ops.SampleTopK(lastLogits, sampling.K)
 		case "nucleus":
-			nextToken, err = ops.SampleNucleus(lastLogits, m.sampling.P)
+			nextToken, err = ops.SampleNucleus(lastLogits, sampling.P)
 		default:
-			return nil, fmt.Errorf("unknown sampling strategy: %s", m.sampling.Type)
+			return nil, fmt.Errorf("unknown sampling strategy: %s", sampling.Type)
 		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to sample next token: %v", err)
@@ -349,6 +414,11 @@ func (m *TransformerModel) Generate(input []float64, maxLen int) ([]float64, err
 
 		// Append to generated sequence
 		generated = append(generated, float64(nextToken))
+		if onToken != nil {
+			if err := onToken(nextToken); err != nil {
+				return generated, err
+			}
+		}
 
 		// Update input tensor for next iteration
 		contextStart := len(generated) - m.config.MaxContext
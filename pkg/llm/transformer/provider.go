@@ -0,0 +1,23 @@
+package transformer
+
+import (
+	"threshAI/pkg/cache"
+	"threshAI/pkg/core/generation"
+)
+
+func init() {
+	generation.MustRegister(generation.Provider{
+		Name:         generation.ProviderTransformer,
+		Factory:      newGenerator,
+		Decode:       generation.DecodeYAML(DefaultConfig()),
+		Capabilities: generation.CapStream | generation.CapChat,
+	})
+}
+
+func newGenerator(config interface{}, _ cache.Cache) (generation.Generator, error) {
+	cfg, err := generation.ConfigAs[Config](config)
+	if err != nil {
+		return nil, err
+	}
+	return NewAdapter(cfg)
+}
@@ -12,7 +12,8 @@ import (
 
 // TensorOps provides tensor operation utilities for the transformer model
 type TensorOps struct {
-	g *gorgonia.ExprGraph
+	g   *gorgonia.ExprGraph
+	rng *rand.Rand
 }
 
 // NewTensorOps creates a new TensorOps instance
@@ -20,6 +21,28 @@ func NewTensorOps(g *gorgonia.ExprGraph) *TensorOps {
 	return &TensorOps{g: g}
 }
 
+// SetSeed makes sampling deterministic for the given seed
+func (ops *TensorOps) SetSeed(seed int64) {
+	ops.rng = rand.New(rand.NewSource(seed))
+}
+
+// randFloat64 draws from the seeded source if set, otherwise the global one
+func (ops *TensorOps) randFloat64() float64 {
+	if ops.rng != nil {
+		return ops.rng.Float64()
+	}
+	return rand.Float64()
+}
+
+// ScaleLogits divides logits by the sampling temperature
+func (ops *TensorOps) ScaleLogits(logits []float64, temperature float64) []float64 {
+	scaled := make([]float64, len(logits))
+	for i, l := range logits {
+		scaled[i] = l / temperature
+	}
+	return scaled
+}
+
 // LayerNorm applies layer normalization to the input
 func (ops *TensorOps) LayerNorm(input, scale *gorgonia.Node) (*gorgonia.Node, error) {
 	mean, err := gorgonia.Mean(input, 1)
@@ -162,7 +185,7 @@ func (ops *TensorOps) SampleTopK(logits []float64, k int) (int, error) {
 	probs := computeSoftmax(topKLogits)
 
 	// Sample from the distribution
-	r := rand.Float64()
+	r := ops.randFloat64()
 	cumulativeProb := 0.0
 	for i, prob := range probs {
 		cumulativeProb += prob
@@ -222,7 +245,7 @@ func (ops *TensorOps) SampleNucleus(logits []float64, p float64) (int, error) {
 	selectedProbs := computeSoftmax(selectedLogits)
 
 	// Sample from the distribution
-	r := rand.Float64()
+	r := ops.randFloat64()
 	cumulativeProb = 0.0
 	for i, prob := range selectedProbs {
 		cumulativeProb += prob
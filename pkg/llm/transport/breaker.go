@@ -0,0 +1,176 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultFailureThreshold = 5
+	DefaultCooldown         = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned while a provider's circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// BreakerConfig tunes a circuit breaker. Zero values select the defaults.
+type BreakerConfig struct {
+	// FailureThreshold is the number of consecutive failed requests that
+	// opens the breaker
+	FailureThreshold int `yaml:"failure_threshold" json:"failure_threshold"`
+	// Cooldown is how long the breaker stays open before a trial request
+	// is let through
+	Cooldown time.Duration `yaml:"cooldown" json:"cooldown"`
+}
+
+// BreakerState is the state of a circuit breaker
+type BreakerState int
+
+const (
+	StateClosed BreakerState = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// Breaker stops sending requests to a provider after repeated failures. Once
+// the cooldown has passed a single trial request decides whether it closes
+// again.
+type Breaker struct {
+	name   string
+	config BreakerConfig
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+var breakers = struct {
+	mu sync.Mutex
+	m  map[string]*Breaker
+}{m: make(map[string]*Breaker)}
+
+// BreakerKey names the breaker of a provider served at baseURL, so that
+// backends of the same provider type at different endpoints, such as two
+// OpenAI-compatible servers, don't trip each other's breaker
+func BreakerKey(provider, baseURL string) string {
+	if u, err := url.Parse(baseURL); err == nil && u.Host != "" {
+		return provider + "@" + u.Host
+	}
+	return provider
+}
+
+// BreakerFor returns the shared breaker named key, usually a BreakerKey,
+// creating it with config on first use. A later call with a non-zero config
+// reconfigures the breaker, keeping its state; a zero config just looks it
+// up.
+func BreakerFor(key string, config BreakerConfig) *Breaker {
+	breakers.mu.Lock()
+	defer breakers.mu.Unlock()
+
+	if b, ok := breakers.m[key]; ok {
+		if config != (BreakerConfig{}) {
+			b.mu.Lock()
+			b.config = config.withDefaults()
+			b.mu.Unlock()
+		}
+		return b
+	}
+	b := NewBreaker(key, config)
+	breakers.m[key] = b
+	return b
+}
+
+// NewBreaker creates a standalone circuit breaker
+func NewBreaker(name string, config BreakerConfig) *Breaker {
+	return &Breaker{name: name, config: config.withDefaults()}
+}
+
+func (c BreakerConfig) withDefaults() BreakerConfig {
+	if c.FailureThreshold <= 0 {
+		c.FailureThreshold = DefaultFailureThreshold
+	}
+	if c.Cooldown <= 0 {
+		c.Cooldown = DefaultCooldown
+	}
+	return c
+}
+
+// State returns the current breaker state
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == StateOpen && time.Since(b.openedAt) >= b.config.Cooldown {
+		return StateHalfOpen
+	}
+	return b.state
+}
+
+// Allow reports whether a request may be sent. Every allowed request must be
+// followed by a call to Success, Failure or Release.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateOpen && time.Since(b.openedAt) >= b.config.Cooldown {
+		b.state = StateHalfOpen
+	}
+
+	switch b.state {
+	case StateOpen:
+		retryIn := b.config.Cooldown - time.Since(b.openedAt)
+		return fmt.Errorf("%s: %w (retry in %s)", b.name, ErrCircuitOpen, retryIn.Round(time.Second))
+	case StateHalfOpen:
+		if b.probing {
+			return fmt.Errorf("%s: %w (trial request in flight)", b.name, ErrCircuitOpen)
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Success records a successful request, closing the breaker
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = StateClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// Failure records a failed request, opening the breaker once the threshold
+// is reached or when a trial request fails
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == StateHalfOpen || b.failures >= b.config.FailureThreshold {
+		b.state = StateOpen
+		b.openedAt = time.Now()
+	}
+	b.probing = false
+}
+
+// Release ends a request without judging the provider, e.g. when the caller
+// cancelled it
+func (b *Breaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
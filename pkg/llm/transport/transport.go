@@ -0,0 +1,213 @@
+// Package transport provides the resilient HTTP transport shared by the LLM
+// provider clients. It retries transient failures with exponential backoff
+// and jitter, honours Retry-After, and guards each provider with a circuit
+// breaker.
+package transport
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"threshAI/pkg/llm"
+	"threshAI/pkg/logging"
+)
+
+const (
+	DefaultMaxRetries = 2
+	DefaultTimeout    = 30 * time.Second
+	DefaultBaseDelay  = 500 * time.Millisecond
+	DefaultMaxDelay   = 30 * time.Second
+)
+
+// Config tunes the transport. Zero values select the defaults; set
+// MaxRetries to a negative value to disable retries.
+type Config struct {
+	MaxRetries int           `yaml:"max_retries" json:"max_retries"`
+	Timeout    time.Duration `yaml:"timeout" json:"timeout"` // Timeout of each attempt of a non-streaming request, including reading the response
+	BaseDelay  time.Duration `yaml:"base_delay" json:"base_delay"`
+	MaxDelay   time.Duration `yaml:"max_delay" json:"max_delay"` // Longest wait between attempts, including Retry-After
+	Breaker    BreakerConfig `yaml:"breaker" json:"breaker"`
+}
+
+func (c Config) withDefaults() Config {
+	if c.MaxRetries == 0 {
+		c.MaxRetries = DefaultMaxRetries
+	} else if c.MaxRetries < 0 {
+		c.MaxRetries = 0
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultTimeout
+	}
+	if c.BaseDelay <= 0 {
+		c.BaseDelay = DefaultBaseDelay
+	}
+	if c.MaxDelay <= 0 {
+		c.MaxDelay = DefaultMaxDelay
+	}
+	return c
+}
+
+// Transport is an http.RoundTripper that retries 429 and 5xx responses and
+// network errors. Once retries are exhausted the last response is returned
+// unchanged so callers can turn it into an llm.APIError.
+type Transport struct {
+	base    http.RoundTripper
+	config  Config
+	breaker *Breaker
+	// timeout bounds each attempt, including reading its response body, so
+	// that waiting between attempts doesn't eat into it. Zero leaves
+	// attempts bounded by the request context only.
+	timeout time.Duration
+}
+
+// New wraps base, or http.DefaultTransport if nil, for the named provider.
+// Transports created for the same provider name share a circuit breaker; name
+// providers with BreakerKey to give each endpoint a breaker of its own.
+func New(provider string, config Config, base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	config = config.withDefaults()
+	return &Transport{
+		base:    base,
+		config:  config,
+		breaker: BreakerFor(provider, config.Breaker),
+		timeout: config.Timeout,
+	}
+}
+
+// NewClients returns the HTTP clients used by provider clients: one whose
+// attempts are bounded by the configured timeout for regular requests, and
+// one without a timeout for streams, which are bounded by their request
+// context instead. Both share the provider's circuit breaker.
+func NewClients(provider string, config Config) (*http.Client, *http.Client) {
+	t := New(provider, config, nil)
+	stream := *t
+	stream.timeout = 0
+	return &http.Client{Transport: t}, &http.Client{Transport: &stream}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.breaker.Allow(); err != nil {
+		return nil, err
+	}
+
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		attemptReq := req
+		if attempt > 0 {
+			var err error
+			if attemptReq, err = rewind(req); err != nil {
+				t.breaker.Failure()
+				return nil, err
+			}
+		}
+
+		cancel := context.CancelFunc(func() {})
+		if t.timeout > 0 {
+			var attemptCtx context.Context
+			attemptCtx, cancel = context.WithTimeout(ctx, t.timeout)
+			attemptReq = attemptReq.WithContext(attemptCtx)
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+		if err == nil && !llm.IsRetryableStatus(resp.StatusCode) {
+			t.breaker.Success()
+			resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			// The caller gave up; that says nothing about the provider
+			t.breaker.Release()
+			if resp != nil {
+				resp.Body.Close()
+			}
+			cancel()
+			return nil, ctx.Err()
+		}
+
+		delay := t.backoff(attempt)
+		if resp != nil {
+			if retryAfter := llm.ParseRetryAfter(resp.Header.Get("Retry-After")); retryAfter > 0 {
+				delay = retryAfter
+			}
+		}
+
+		// Give up once retries are spent, the body can't be replayed or the
+		// server asks for a longer pause than we're willing to wait
+		if attempt >= t.config.MaxRetries || (req.Body != nil && req.GetBody == nil) || delay > t.config.MaxDelay {
+			t.breaker.Failure()
+			if resp == nil {
+				cancel()
+				return nil, err
+			}
+			resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+			return resp, nil
+		}
+
+		if resp != nil {
+			logging.Logger.Printf("Retrying %s after status %d (attempt %d/%d, waiting %s)",
+				req.URL.Host, resp.StatusCode, attempt+1, t.config.MaxRetries, delay)
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+			resp.Body.Close()
+		} else {
+			logging.Logger.Printf("Retrying %s after error: %v (attempt %d/%d, waiting %s)",
+				req.URL.Host, err, attempt+1, t.config.MaxRetries, delay)
+		}
+		cancel()
+
+		if err := sleep(ctx, delay); err != nil {
+			t.breaker.Release()
+			return nil, err
+		}
+	}
+}
+
+// cancelBody ends the attempt's timeout once the response body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// backoff returns the wait before the next attempt: exponential growth from
+// BaseDelay with full jitter, capped at MaxDelay
+func (t *Transport) backoff(attempt int) time.Duration {
+	ceiling := t.config.BaseDelay << uint(attempt)
+	if ceiling <= 0 || ceiling > t.config.MaxDelay {
+		ceiling = t.config.MaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)) + 1)
+}
+
+// rewind returns a copy of req with a fresh body for another attempt
+func rewind(req *http.Request) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if req.Body != nil && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		clone.Body = body
+	}
+	return clone, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
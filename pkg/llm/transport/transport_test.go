@@ -0,0 +1,258 @@
+package transport
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client with short delays so retries don't slow the
+// tests down. Each test uses its own provider name to get a fresh breaker.
+func newTestClient(provider string, config Config) *http.Client {
+	if config.BaseDelay == 0 {
+		config.BaseDelay = time.Millisecond
+	}
+	if config.MaxDelay == 0 {
+		config.MaxDelay = 50 * time.Millisecond
+	}
+	client, _ := NewClients(provider, config)
+	return client
+}
+
+func TestRetryThenSuccess(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := make([]byte, 5)
+		if n, _ := r.Body.Read(body); string(body[:n]) != "hello" {
+			t.Errorf("attempt %d body = %q, want %q", atomic.LoadInt32(&calls)+1, body[:n], "hello")
+		}
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	client := newTestClient(t.Name(), Config{MaxRetries: 2})
+	resp, err := client.Post(ts.URL, "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %d, want 200", resp.StatusCode)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3", calls)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	var calls int32
+	var first time.Time
+	var waited time.Duration
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			first = time.Now()
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		waited = time.Since(first)
+	}))
+	defer ts.Close()
+
+	client := newTestClient(t.Name(), Config{MaxRetries: 1, MaxDelay: 2 * time.Second})
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+
+	if calls != 2 {
+		t.Fatalf("calls = %d, want 2", calls)
+	}
+	if waited < 900*time.Millisecond {
+		t.Errorf("retried after %s, want Retry-After of 1s honoured", waited)
+	}
+}
+
+func TestRetryAfterBeyondMaxDelay(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	client := newTestClient(t.Name(), Config{MaxRetries: 3})
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusTooManyRequests || calls != 1 {
+		t.Errorf("StatusCode = %d after %d calls, want 429 after 1", resp.StatusCode, calls)
+	}
+}
+
+func TestNoRetryOnClientError(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer ts.Close()
+
+	client := newTestClient(t.Name(), Config{MaxRetries: 3})
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest || calls != 1 {
+		t.Errorf("StatusCode = %d after %d calls, want 400 after 1", resp.StatusCode, calls)
+	}
+}
+
+func TestBreakerOpens(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	client := newTestClient(t.Name(), Config{
+		MaxRetries: -1,
+		Breaker:    BreakerConfig{FailureThreshold: 2, Cooldown: 50 * time.Millisecond},
+	})
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	if _, err := client.Get(ts.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Get() error = %v, want ErrCircuitOpen", err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2 while the breaker is open", calls)
+	}
+
+	// After the cooldown a single trial request goes through; it fails again
+	// so the breaker reopens
+	time.Sleep(60 * time.Millisecond)
+	if state := BreakerFor(t.Name(), BreakerConfig{}).State(); state != StateHalfOpen {
+		t.Errorf("State() = %s, want half-open", state)
+	}
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("trial Get() error = %v", err)
+	}
+	resp.Body.Close()
+	if state := BreakerFor(t.Name(), BreakerConfig{}).State(); state != StateOpen {
+		t.Errorf("State() = %s, want open after failed trial", state)
+	}
+}
+
+func TestTimeoutPerAttempt(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// The first attempt hangs past the timeout
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	client := newTestClient(t.Name(), Config{MaxRetries: 1, Timeout: 100 * time.Millisecond})
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v, want the hung attempt retried", err)
+	}
+	defer resp.Body.Close()
+	if calls != 2 || resp.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %d after %d calls, want 200 after 2", resp.StatusCode, calls)
+	}
+}
+
+func TestTimeoutExcludesRetryWait(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	// Honouring Retry-After takes longer than the timeout of an attempt
+	client := newTestClient(t.Name(), Config{MaxRetries: 1, Timeout: 300 * time.Millisecond, MaxDelay: 2 * time.Second})
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v, want the wait not counted against the timeout", err)
+	}
+	resp.Body.Close()
+	if calls != 2 || resp.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %d after %d calls, want 200 after 2", resp.StatusCode, calls)
+	}
+}
+
+func TestBreakerPerEndpoint(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer healthy.Close()
+
+	config := Config{MaxRetries: -1, Breaker: BreakerConfig{FailureThreshold: 1, Cooldown: time.Minute}}
+	failingClient := newTestClient(BreakerKey(t.Name(), failing.URL), config)
+	healthyClient := newTestClient(BreakerKey(t.Name(), healthy.URL), config)
+
+	resp, err := failingClient.Get(failing.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+	if _, err := failingClient.Get(failing.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Get() error = %v, want ErrCircuitOpen", err)
+	}
+
+	// Another endpoint of the same provider keeps its own breaker
+	resp, err = healthyClient.Get(healthy.URL)
+	if err != nil {
+		t.Fatalf("Get() of the other endpoint error = %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestBreakerForAppliesConfig(t *testing.T) {
+	b := BreakerFor(t.Name(), BreakerConfig{FailureThreshold: 3})
+	if again := BreakerFor(t.Name(), BreakerConfig{FailureThreshold: 1}); again != b {
+		t.Fatal("BreakerFor() returned a new breaker for the same key")
+	}
+	b.Failure()
+	if state := b.State(); state != StateOpen {
+		t.Errorf("State() = %s, want open after one failure with the new threshold", state)
+	}
+}
@@ -7,13 +7,15 @@ import (
 	"os"
 	"path/filepath"
 	"time"
+
+	"threshAI/pkg/llm/ollama"
 )
 
 var (
 	fallbackSequence   = []string{"nous-hermes2:10.7b", "mistral-r3:7b"}
 	cachePath          = filepath.Join(os.Getenv("HOME"), ".thresh/cache/last_working_model")
 	cacheTTL           = time.Hour
-	validationEndpoint = "http://localhost:11434/api/tags"
+	validationEndpoint = ollama.URL() + "/api/tags"
 )
 
 type OllamaModel struct {
@@ -57,7 +59,7 @@ func WriteCacheModel(model string) error {
 }
 
 func FetchOllamaModels() []string {
-	resp, err := http.Get("http://localhost:11434/api/tags")
+	resp, err := http.Get(validationEndpoint)
 	if err != nil {
 		return []string{}
 	}
@@ -7,6 +7,8 @@ import (
 	"path/filepath"
 	"testing"
 	"time"
+
+	"threshAI/pkg/llm/fakellm"
 )
 
 func TestValidateModel(t *testing.T) {
@@ -43,6 +45,49 @@ func TestValidateModel(t *testing.T) {
 	}
 }
 
+func TestValidateModelWithFakeServer(t *testing.T) {
+	// Setup fake Ollama server
+	srv, err := fakellm.New(fakellm.Config{Models: []string{"llama3", "mistral-r3:7b"}})
+	if err != nil {
+		t.Fatalf("fakellm.New() error = %v", err)
+	}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	// Override API endpoint and cache
+	originalEndpoint, originalCache := validationEndpoint, cachePath
+	validationEndpoint = ts.URL + "/api/tags"
+	cachePath = filepath.Join(t.TempDir(), "last_working_model")
+	defer func() { validationEndpoint, cachePath = originalEndpoint, originalCache }()
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "Available Model",
+			input:    "llama3",
+			expected: "llama3",
+		},
+		{
+			name:     "Fallback Model Activation",
+			input:    "invalid-model",
+			expected: "mistral-r3:7b",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(cachePath)
+			result := ValidateModel(tt.input)
+			if result != tt.expected {
+				t.Errorf("ValidateModel() = %v, want %v", result, tt.expected)
+			}
+		})
+	}
+}
+
 func TestCacheOperations(t *testing.T) {
 	// Setup test cache
 	testCachePath := "test_cache"
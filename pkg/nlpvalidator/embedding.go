@@ -0,0 +1,152 @@
+package nlpvalidator
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"sync"
+)
+
+// DefaultEmbeddingDim is the vector size used by the offline embedder
+const DefaultEmbeddingDim = 1024
+
+// stopWords carry little meaning and would otherwise dominate the overlap
+// between short texts
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "can": true, "do": true, "doe": true, "for": true,
+	"from": true, "how": true, "i": true, "in": true, "is": true, "it": true,
+	"me": true, "my": true, "of": true, "on": true, "or": true, "that": true,
+	"the": true, "this": true, "to": true, "was": true, "what": true,
+	"which": true, "with": true, "you": true,
+}
+
+// bigramWeight scales word bigrams relative to single terms, so that sharing
+// a term counts for more than sharing a phrase
+const bigramWeight = 0.5
+
+// HashingEmbedder embeds texts offline by hashing stemmed terms and word
+// bigrams into a fixed number of buckets, weighted by TF-IDF. Document
+// frequencies are learned from the texts passed to Fit; until then every
+// term is weighted equally.
+type HashingEmbedder struct {
+	dim int
+
+	mu   sync.RWMutex
+	docs int
+	df   []int
+}
+
+// NewHashingEmbedder creates an embedder producing vectors of size dim
+func NewHashingEmbedder(dim int) *HashingEmbedder {
+	if dim <= 0 {
+		dim = DefaultEmbeddingDim
+	}
+	return &HashingEmbedder{dim: dim, df: make([]int, dim)}
+}
+
+// Fit adds documents to the collection used for inverse document frequencies
+func (h *HashingEmbedder) Fit(documents []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, doc := range documents {
+		seen := make(map[int]bool)
+		for _, f := range features(doc) {
+			bucket, _ := h.bucket(f.text)
+			if !seen[bucket] {
+				seen[bucket] = true
+				h.df[bucket]++
+			}
+		}
+		h.docs++
+	}
+}
+
+// Embed implements generation.Embedder. It never fails.
+func (h *HashingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		out[i] = h.EmbedText(text)
+	}
+	return out, nil
+}
+
+// EmbedText returns the L2-normalised embedding of text. Texts without any
+// meaningful terms embed to the zero vector.
+func (h *HashingEmbedder) EmbedText(text string) []float32 {
+	counts := make(map[int]float64)
+	for _, f := range features(text) {
+		bucket, sign := h.bucket(f.text)
+		counts[bucket] += sign * f.weight
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	vector := make([]float32, h.dim)
+	var norm float64
+	for bucket, count := range counts {
+		if count == 0 {
+			continue
+		}
+		// Sublinear term frequency keeps repeated words from dominating
+		weight := math.Abs(count)
+		if weight > 1 {
+			weight = 1 + math.Log(weight)
+		}
+		if h.docs > 0 {
+			weight *= math.Log(float64(1+h.docs)/float64(1+h.df[bucket])) + 1
+		}
+		weight = math.Copysign(weight, count)
+		vector[bucket] = float32(weight)
+		norm += weight * weight
+	}
+
+	if norm > 0 {
+		norm = math.Sqrt(norm)
+		for i := range vector {
+			vector[i] = float32(float64(vector[i]) / norm)
+		}
+	}
+	return vector
+}
+
+// bucket hashes a feature to its bucket and a sign, which keeps collisions
+// from systematically inflating similarity
+func (h *HashingEmbedder) bucket(feature string) (int, float64) {
+	hasher := fnv.New32a()
+	hasher.Write([]byte(feature))
+	sum := hasher.Sum32()
+
+	sign := 1.0
+	if sum&(1<<31) != 0 {
+		sign = -1.0
+	}
+	return int(sum % uint32(h.dim)), sign
+}
+
+type feature struct {
+	text   string
+	weight float64
+}
+
+// features returns the stemmed terms of text without stop words and single
+// characters, followed by its word bigrams
+func features(text string) []feature {
+	var terms []string
+	for _, term := range tokenize(text) {
+		if len(term) > 1 && !stopWords[term] {
+			terms = append(terms, term)
+		}
+	}
+
+	out := make([]feature, 0, 2*len(terms))
+	for _, term := range terms {
+		out = append(out, feature{term, 1})
+	}
+	for i := 0; i+1 < len(terms); i++ {
+		out = append(out, feature{terms[i] + " " + terms[i+1], bigramWeight})
+	}
+	return out
+}
@@ -146,7 +146,7 @@ func DetectRepetition(text string) float32 {
 // tokenize splits text into normalized terms
 func tokenize(text string) []string {
 	// Remove punctuation and convert to lowercase
-	re := regexp.MustCompile(`[^\\p{L}\\p{N}]+`)
+	re := regexp.MustCompile(`[^\p{L}\p{N}]+`)
 	text = re.ReplaceAllString(text, " ")
 	text = strings.ToLower(text)
 
@@ -1,13 +1,45 @@
 // File: internal/quantum/entanglement.go
 package quantum
 
+import (
+	"context"
+	"math"
+	"sync"
+
+	"threshAI/pkg/core/generation"
+	"threshAI/pkg/logging"
+	"threshAI/pkg/nlpvalidator"
+)
+
+// offline is used when no embedder is configured or the configured one fails
+var offline = nlpvalidator.NewHashingEmbedder(nlpvalidator.DefaultEmbeddingDim)
+
+var (
+	embedderMu sync.RWMutex
+	embedder   generation.Embedder = offline
+)
+
+// SetEmbedder selects the embedder used for sentence embeddings. nil restores
+// the offline hashing embedder.
+func SetEmbedder(e generation.Embedder) {
+	embedderMu.Lock()
+	defer embedderMu.Unlock()
+	if e == nil {
+		e = offline
+	}
+	embedder = e
+}
+
+// CalculateEntanglement returns the mean pairwise cosine similarity of the
+// shard outputs, or 0 when there are fewer than two shards to compare
 func CalculateEntanglement(shardOutputs []string) float32 {
-	// Step 1: Generate embeddings for each shard's output
-	embeddings := make([][]float32, len(shardOutputs))
-	for i, output := range shardOutputs {
-		embeddings[i] = GetSentenceEmbedding(output)
+	if len(shardOutputs) < 2 {
+		return 0
 	}
 
+	// Step 1: Generate embeddings for each shard's output
+	embeddings := embed(shardOutputs)
+
 	// Step 2: Compute cosine similarity between all pairs
 	var totalSimilarity float32
 	pairs := 0
@@ -21,14 +53,46 @@ func CalculateEntanglement(shardOutputs []string) float32 {
 	return totalSimilarity / float32(pairs)
 }
 
-// Placeholder function for getting sentence embeddings
+// GetSentenceEmbedding embeds a sentence with the configured embedder
 func GetSentenceEmbedding(sentence string) []float32 {
-	// TODO: Implement actual embedding generation
-	return []float32{}
+	return embed([]string{sentence})[0]
 }
 
-// Placeholder function for calculating cosine similarity
+// CosineSimilarity returns the cosine of the angle between two embeddings.
+// Mismatched or zero vectors have no meaningful angle and score 0.
 func CosineSimilarity(embedding1, embedding2 []float32) float32 {
-	// TODO: Implement actual cosine similarity calculation
-	return 0.0
+	if len(embedding1) != len(embedding2) || len(embedding1) == 0 {
+		return 0.0
+	}
+
+	var dot, norm1, norm2 float64
+	for i := range embedding1 {
+		a, b := float64(embedding1[i]), float64(embedding2[i])
+		dot += a * b
+		norm1 += a * a
+		norm2 += b * b
+	}
+	if norm1 == 0 || norm2 == 0 {
+		return 0.0
+	}
+	return float32(dot / (math.Sqrt(norm1) * math.Sqrt(norm2)))
+}
+
+// embed embeds texts in one batch, falling back to the offline embedder if
+// the configured one fails
+func embed(texts []string) [][]float32 {
+	embedderMu.RLock()
+	e := embedder
+	embedderMu.RUnlock()
+
+	embeddings, err := e.Embed(context.Background(), texts)
+	if err == nil && len(embeddings) == len(texts) {
+		return embeddings
+	}
+	if err != nil {
+		logging.Logger.Printf("Warning: embedding failed, using offline embeddings: %v", err)
+	}
+
+	embeddings, _ = offline.Embed(context.Background(), texts)
+	return embeddings
 }
@@ -0,0 +1,69 @@
+package quantum
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float32
+		want float32
+	}{
+		{"identical", []float32{1, 2, 3}, []float32{1, 2, 3}, 1},
+		{"orthogonal", []float32{1, 0}, []float32{0, 1}, 0},
+		{"opposite", []float32{1, 1}, []float32{-1, -1}, -1},
+		{"zero vector", []float32{0, 0}, []float32{1, 1}, 0},
+		{"mismatched", []float32{1}, []float32{1, 1}, 0},
+		{"empty", nil, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CosineSimilarity(tt.a, tt.b); math.Abs(float64(got-tt.want)) > 1e-6 {
+				t.Errorf("CosineSimilarity() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculateEntanglement(t *testing.T) {
+	similar := CalculateEntanglement([]string{
+		"The reactor core temperature is rising quickly",
+		"Reactor core temperatures are rising fast",
+	})
+	unrelated := CalculateEntanglement([]string{
+		"The reactor core temperature is rising quickly",
+		"Bake the bread for twenty minutes",
+	})
+
+	for _, v := range []float32{similar, unrelated} {
+		if math.IsNaN(float64(v)) {
+			t.Fatal("CalculateEntanglement() = NaN")
+		}
+	}
+	if similar <= unrelated {
+		t.Errorf("similar shards scored %v, unrelated %v; want similar higher", similar, unrelated)
+	}
+
+	if got := CalculateEntanglement(nil); got != 0 {
+		t.Errorf("CalculateEntanglement(nil) = %v, want 0", got)
+	}
+}
+
+type failingEmbedder struct{}
+
+func (failingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return nil, errors.New("embedding backend unavailable")
+}
+
+func TestEmbedderFallback(t *testing.T) {
+	SetEmbedder(failingEmbedder{})
+	defer SetEmbedder(nil)
+
+	if got := GetSentenceEmbedding("quantum shard output"); len(got) == 0 {
+		t.Error("GetSentenceEmbedding() returned no embedding after the embedder failed")
+	}
+}
@@ -1,6 +1,7 @@
 package security
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/json"
 	"fmt"
@@ -8,10 +9,16 @@ import (
 	"os"
 	"os/exec"
 	"regexp"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
+	"text/template"
 	"time"
+
+	"threshAI/pkg/core/generation"
+	"threshAI/pkg/llm"
+	"threshAI/pkg/llm/schema"
 )
 
 // Quantum security parameters
@@ -108,7 +115,35 @@ Important:
 - The response must be valid JSON
 - The response must match this exact pattern: {"risk_score": 0.0-1.0}`
 
-var ministralValidationRegex = regexp.MustCompile(`^\{\s*"risk_score":\s*0\.\d+\s*\}$`)
+// RiskAssessment is the reply expected for ministralPrompt
+type RiskAssessment struct {
+	RiskScore float32 `json:"risk_score" jsonschema:"minimum=0,maximum=1"`
+}
+
+var riskSchema = func() *schema.Schema {
+	s, err := schema.For[RiskAssessment]()
+	if err != nil {
+		panic(err)
+	}
+	return s
+}()
+
+// AssessInjection asks a model to score the injection risk of payload. The
+// reply is validated against the RiskAssessment schema and the model is
+// re-prompted with the validation error if it doesn't conform.
+func AssessInjection(ctx context.Context, gen generation.Generator, payload string) (float32, error) {
+	var prompt strings.Builder
+	if err := template.Must(template.New("ministral").Parse(ministralPrompt)).Execute(&prompt, struct{ Input string }{payload}); err != nil {
+		return 0.0, fmt.Errorf("failed to render prompt: %v", err)
+	}
+
+	var assessment RiskAssessment
+	req := llm.ChatRequest{Messages: []llm.Message{{Role: llm.RoleUser, Content: prompt.String()}}}
+	if err := generation.GenerateStruct(ctx, gen, req, &assessment, 0); err != nil {
+		return 0.0, err
+	}
+	return assessment.RiskScore, nil
+}
 
 type SecurityConfig struct {
 	DetectionThresholds struct {
@@ -134,13 +169,8 @@ func LoadConfig(configPath string) error {
 
 func DetectInjections(payload string) (float32, error) {
 	// First try ministral validation
-	if ministralValidationRegex.MatchString(payload) {
-		// Extract risk score from JSON
-		matches := ministralValidationRegex.FindStringSubmatch(payload)
-		if len(matches) > 0 {
-			riskScore := matches[1]
-			return parseRiskScore(riskScore)
-		}
+	if riskScore, err := ValidateModelResponse(payload); err == nil {
+		return riskScore, nil
 	}
 
 	// Fallback to traditional pattern matching
@@ -157,27 +187,17 @@ func DetectInjections(payload string) (float32, error) {
 	return 0.0, nil
 }
 
-func parseRiskScore(score string) (float32, error) {
-	// TODO: Implement proper float parsing with error handling
-	return 0.0, nil
-}
-
+// ValidateModelResponse checks a reply to ministralPrompt against the
+// RiskAssessment schema and returns its risk score
 func ValidateModelResponse(response string) (float32, error) {
-	// First try to parse as JSON
-	var result struct {
-		RiskScore float32 `json:"risk_score"`
+	if err := riskSchema.ValidateJSON([]byte(response)); err != nil {
+		return 0.0, fmt.Errorf("invalid model response: %v", err)
 	}
 
-	err := json.Unmarshal([]byte(response), &result)
-	if err != nil {
+	var result RiskAssessment
+	if err := json.Unmarshal([]byte(response), &result); err != nil {
 		return 0.0, fmt.Errorf("invalid JSON format: %v", err)
 	}
-
-	// Validate risk score range
-	if result.RiskScore < 0.0 || result.RiskScore > 1.0 {
-		return 0.0, fmt.Errorf("risk_score must be between 0.0 and 1.0")
-	}
-
 	return result.RiskScore, nil
 }
 